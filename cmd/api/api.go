@@ -13,6 +13,8 @@ import (
 
 	"github.com/devphaseX/buyr-api.git/internal/auth"
 	"github.com/devphaseX/buyr-api.git/internal/fileobject"
+	"github.com/devphaseX/buyr-api.git/internal/oauth"
+	"github.com/devphaseX/buyr-api.git/internal/passkey"
 	"github.com/devphaseX/buyr-api.git/internal/ratelimiter"
 	"github.com/devphaseX/buyr-api.git/internal/store"
 	"github.com/devphaseX/buyr-api.git/internal/store/cache"
@@ -24,17 +26,17 @@ import (
 	"github.com/go-playground/form/v4"
 
 	"go.uber.org/zap"
-	"golang.org/x/oauth2"
 )
 
 type application struct {
 	cfg              config
 	totp             totp.TOTP
+	passkey          passkey.Passkey
 	wg               sync.WaitGroup
 	logger           *zap.SugaredLogger
 	store            *store.Storage
 	authToken        auth.AuthToken
-	googleOauth      *oauth2.Config
+	oauthProviders   *oauth.Registry
 	rateLimitService *ratelimiter.RateLimiterService
 	fileobject       fileobject.FileObject
 	formDecoder      *form.Decoder
@@ -43,18 +45,40 @@ type application struct {
 }
 
 type config struct {
-	addr              string
-	env               string
-	apiURL            string
-	clientURL         string
-	db                dbConfig
-	redisCfg          redisConfig
-	mailConfig        mailConfig
-	authConfig        AuthConfig
-	encryptConfig     encryptConfig
-	supabaseConfig    supabaseConfig
-	stripe            stripeConfig
-	googleOauthConfig googleOauthConfig
+	addr           string
+	env            string
+	apiURL         string
+	clientURL      string
+	db             dbConfig
+	redisCfg       redisConfig
+	mailConfig     mailConfig
+	authConfig     AuthConfig
+	encryptConfig  encryptConfig
+	supabaseConfig supabaseConfig
+	stripe         stripeConfig
+	oauth          oauthConfig
+	oauthServer    oauthServerConfig
+	inventory      inventoryConfig
+	webauthn       webauthnConfig
+}
+
+// oauthServerConfig sets the lifetimes of what is issued to third-party apps
+type oauthServerConfig struct {
+	codeTTL         time.Duration
+	accessTokenTTL  time.Duration
+	refreshTokenTTL time.Duration
+}
+
+type webauthnConfig struct {
+	rpID          string
+	rpDisplayName string
+	rpOrigins     []string
+	timeout       time.Duration
+}
+
+type inventoryConfig struct {
+	// lowStockThreshold applies to products without their own threshold.
+	lowStockThreshold int
 }
 
 type AuthConfig struct {
@@ -66,6 +90,7 @@ type AuthConfig struct {
 	AccesssCookieName string
 	RefreshCookiName  string
 	totpIssuerName    string
+	lockout           cache.LockoutPolicy
 }
 
 type stripeConfig struct {
@@ -84,9 +109,28 @@ type mailConfig struct {
 	mailTrap mailTrapConfig
 }
 
-type googleOauthConfig struct {
-	clientId     string
+type oauthConfig struct {
+	google   oauthClientConfig
+	github   oauthClientConfig
+	facebook oauthClientConfig
+	apple    oauthClientConfig
+	// oidc lists the providers configured through OpenID Connect discovery.
+	oidc     []oidcProviderConfig
+	stateTTL time.Duration
+}
+
+// oauthClientConfig holds the credentials of a registered OAuth client. A
+// provider without a client id is left disabled.
+type oauthClientConfig struct {
+	clientID     string
 	clientSecret string
+	scopes       []string
+}
+
+type oidcProviderConfig struct {
+	name   string
+	issuer string
+	client oauthClientConfig
 }
 
 type mailTrapConfig struct {
@@ -129,7 +173,7 @@ func (app *application) routes() http.Handler {
 		cors.Handler(cors.Options{
 			AllowedOrigins:   []string{"https://*", "http://*"},
 			AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-			AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token"},
+			AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token", "X-API-Key"},
 			ExposedHeaders:   []string{"Link", "Vary"},
 			AllowCredentials: false,
 			MaxAge:           300, // Maximum value not ignored by any of major browsers
@@ -149,6 +193,9 @@ func (app *application) routes() http.Handler {
 
 		r.Route("/categories", func(r chi.Router) {
 			r.Get("/", app.getPublicCategories)
+			r.Get("/tree", app.getCategoryTree)
+			r.Get("/{category}", app.getCategory)
+			r.Get("/{category}/attributes", app.getCategoryAttributes)
 		})
 
 		workDir, _ := os.Getwd()
@@ -160,6 +207,11 @@ func (app *application) routes() http.Handler {
 			r.Post("/sign-in", app.signIn)
 			r.Post("/sign-in/2fa", app.verifyLogin2FA)
 			r.Post("/sign-in/recovery-code", app.verifyLogin2faRecoveryCode)
+			r.Post("/sign-in/email-code", app.sendLogin2faEmailCode)
+			r.Post("/sign-in/webauthn", app.beginLogin2faPasskey)
+			r.Post("/sign-in/webauthn/verify", app.verifyLogin2faPasskey)
+			r.Post("/passkey", app.beginPasskeySignIn)
+			r.Post("/passkey/verify", app.finishPasskeySignIn)
 			r.Post("/refresh", app.refreshToken)
 			r.Post("/forget-password", app.forgetPassword)
 			r.Post("/reset-password/verify-email", app.confirmForgetPasswordToken)
@@ -167,8 +219,14 @@ func (app *application) routes() http.Handler {
 			r.Post("/reset-password/recovery-code", app.verifyForgetPasswordRecoveryCode)
 			r.Post("/reset-password/change", app.resetPassword)
 
-			r.Post("/google", app.signInWithProvider)
-			r.Post("/google/callback", app.googleCallbackHandler)
+			r.With(app.requireAuthenicatedUser, app.requireSession).Post("/sign-out", app.signOut)
+			r.Post("/unlock-account", app.unlockAccount)
+
+			r.Get("/oauth", app.getOAuthProviders)
+			r.Get("/oauth/{provider}", app.signInWithProvider)
+			r.Post("/oauth/{provider}", app.signInWithProvider)
+			r.Get("/oauth/{provider}/callback", app.oauthCallbackHandler)
+			r.Post("/oauth/{provider}/callback", app.oauthCallbackHandler)
 
 		})
 
@@ -179,6 +237,7 @@ func (app *application) routes() http.Handler {
 
 			r.Group(func(r chi.Router) {
 				r.Use(app.requireAuthenicatedUser)
+				r.Use(app.requireSession)
 				r.Get("/", app.getNormalUsers)
 				r.Get("/current", app.getCurrentUser)
 				r.Patch("/change-password", app.changePassword)
@@ -186,22 +245,74 @@ func (app *application) routes() http.Handler {
 
 				r.Post("/email/initiate-change", app.initiateEmailChange)
 				r.Post("/email/verify-2fa", app.verifyEmailChange2fa)
+				r.Post("/2fa/email-code", app.send2faEmailCode)
+
+				r.Get("/notifications", app.getNotifications)
+				r.Patch("/notifications/{notificationID}/read", app.markNotificationRead)
+
+				r.Get("/accounts", app.getLinkedAccounts)
+				r.Post("/accounts/{provider}", app.linkAccount)
+				r.Delete("/accounts/{provider}", app.unlinkAccount)
+
+				r.Get("/sessions", app.getUserSessions)
+				r.Delete("/sessions", app.revokeOtherSessions)
+				r.Delete("/sessions/{sessionID}", app.revokeUserSession)
+
+				r.Get("/api-keys", app.getAPIKeys)
+				r.Post("/api-keys", app.createAPIKey)
+				r.Delete("/api-keys/{keyID}", app.revokeAPIKey)
+
+				r.Get("/authorized-apps", app.getAuthorizedApps)
+				r.Delete("/authorized-apps/{clientID}", app.revokeAuthorizedApp)
 			})
 
 		})
 
+		r.Route("/oauth", func(r chi.Router) {
+			r.Post("/token", app.issueOAuthToken)
+			r.Post("/revoke", app.revokeOAuthToken)
+			r.Post("/introspect", app.introspectOAuthToken)
+
+			r.Group(func(r chi.Router) {
+				r.Use(app.requireAuthenicatedUser)
+				r.Use(app.requireSession)
+
+				r.Get("/authorize", app.getAuthorizationConsent)
+				r.Post("/authorize", app.authorizeClient)
+
+				r.Route("/clients", func(r chi.Router) {
+					r.Get("/", app.getOAuthClients)
+					r.Post("/", app.registerOAuthClient)
+					r.Post("/{clientID}/secret", app.rotateOAuthClientSecret)
+					r.Delete("/{clientID}", app.deleteOAuthClient)
+				})
+			})
+		})
+
 		r.Route("/files", func(r chi.Router) {
 			r.Post("/image", app.uploadImage)
 		})
 
 		r.Route("/mfa", func(r chi.Router) {
 			r.Use(app.requireAuthenicatedUser)
-			r.With(app.CheckPermissions(RequireRoles(store.AdminRole, store.VendorRole))).Group(
+			r.With(app.CheckPermissions(RequireRoles(store.UserRole, store.AdminRole, store.VendorRole))).Group(
 				func(r chi.Router) {
 					r.Get("/setup", app.setup2fa)
 					r.Post("/verify", app.verify2faSetup)
 					r.Post("/recovery-codes", app.viewRecoveryCodes)
 					r.Patch("/recovery-codes/reset", app.resetRecoveryCodes)
+					r.Post("/disable", app.disable2fa)
+					r.Post("/re-enroll", app.reenroll2fa)
+					r.Post("/re-enroll/verify", app.verifyReenroll2fa)
+
+					r.Post("/email/setup", app.setupEmail2fa)
+					r.Post("/email/verify", app.verifyEmail2faSetup)
+					r.Post("/email/disable", app.disableEmail2fa)
+
+					r.Post("/webauthn/register", app.beginPasskeyRegistration)
+					r.Post("/webauthn/register/verify", app.finishPasskeyRegistration)
+					r.Get("/webauthn/credentials", app.getPasskeys)
+					r.Delete("/webauthn/credentials/{credentialID}", app.deletePasskey)
 				},
 			)
 
@@ -221,6 +332,7 @@ func (app *application) routes() http.Handler {
 				r.Get("/items/{cardItemID}", app.getCartItemByID)
 				r.Delete("/items/{itemID}", app.removeCartItem)
 				r.Patch("/items/{itemID}", app.setCartItemQuantity)
+				r.Post("/items/move-to-wishlist", app.moveCartItemsToWishlist)
 
 			})
 		})
@@ -229,12 +341,16 @@ func (app *application) routes() http.Handler {
 			r.Use(app.requireAuthenicatedUser)
 			r.With(app.CheckPermissions(RequireRoles(store.UserRole))).Group(func(r chi.Router) {
 				r.Post("/webhook/stripe", app.handleStripeWebhook)
+			})
+			r.With(app.CheckPermissions(RequireRoles(store.UserRole), RequireScopes(store.OrdersReadScope))).Group(func(r chi.Router) {
 				r.Get("/", app.getUserViewOrderLists)
 				r.Get("/{orderID}", app.getOrderForUserByID)
 			})
 		})
 
 		r.Route("/addresses", func(r chi.Router) {
+			r.Use(app.requireAuthenicatedUser)
+			r.Use(app.requireSession)
 			r.Get("/", app.getUserAddresses)
 			r.Post("/", app.createUserAddress)
 			r.Get("/{addressID}", app.getUserAddressByID)
@@ -249,6 +365,19 @@ func (app *application) routes() http.Handler {
 			})
 		})
 
+		r.Route("/wishlists", func(r chi.Router) {
+			r.Get("/public", app.getPublicWishlists)
+
+			r.Route("/shared/{shareToken}", func(r chi.Router) {
+				r.Get("/", app.getSharedWishlist)
+
+				r.With(app.requireAuthenicatedUser, app.CheckPermissions(RequireRoles(store.UserRole))).Group(func(r chi.Router) {
+					r.Post("/items/{itemID}/claim", app.claimWishlistItem)
+					r.Delete("/items/{itemID}/claim", app.unclaimWishlistItem)
+				})
+			})
+		})
+
 		r.Route("/products", func(r chi.Router) {
 			r.Get("/", app.getProducts)
 
@@ -259,18 +388,64 @@ func (app *application) routes() http.Handler {
 					r.Delete("/{itemID}", app.removeProductFromWhitelist)
 					r.Get("/", app.getGroupVendorWishlisttem)
 					r.Get("/vendor", app.getVendorWishlistItem)
+					r.Post("/move-to-cart", app.moveWishlistItemsToCart)
+
+					r.Route("/collections", func(r chi.Router) {
+						r.Post("/", app.createWishlistCollection)
+						r.Get("/", app.getWishlistCollections)
+
+						r.Route("/{collectionID}", func(r chi.Router) {
+							r.Get("/", app.getWishlistCollection)
+							r.Patch("/", app.updateWishlistCollection)
+							r.Delete("/", app.deleteWishlistCollection)
+							r.Post("/share-token", app.rotateWishlistShareToken)
+						})
+					})
 				})
 			})
 
 			r.Route("/{productID}", func(r chi.Router) {
 
 				r.Get("/", app.getProduct)
+
+				r.With(app.requireAuthenicatedUser).Group(func(r chi.Router) {
+					r.With(app.CheckPermissions(RequireRoles(store.VendorRole), RequireScopes(store.ProductsWriteScope))).Patch("/", app.updateProduct)
+					r.With(app.CheckPermissions(RequireRoles(store.VendorRole))).Post("/resubmit", app.resubmitProduct)
+					r.With(app.CheckPermissions(RequireRoles(store.VendorRole), RequireScopes(store.ProductsWriteScope))).Group(func(r chi.Router) {
+						r.Put("/schedule", app.setProductSchedule)
+						r.Put("/sale", app.setProductSale)
+						r.Delete("/sale", app.removeProductSale)
+						r.Put("/backorder", app.setProductBackorder)
+					})
+
+					r.Route("/inventory", func(r chi.Router) {
+						r.With(app.CheckPermissions(RequireRoles(store.VendorRole), RequireScopes(store.InventoryReadScope))).
+							Get("/movements", app.getProductInventoryMovements)
+						r.With(app.CheckPermissions(RequireRoles(store.VendorRole), RequireScopes(store.InventoryWriteScope))).Group(func(r chi.Router) {
+							r.Post("/movements", app.adjustProductStock)
+							r.Put("/threshold", app.setLowStockThreshold)
+						})
+					})
+					r.With(app.CheckPermissions(RequireAny(RequireRoles(store.VendorRole), MinimumAdminLevel(store.AdminLevelManager)))).
+						Get("/moderation", app.getProductModeration)
+				})
+
 				r.Route("/reviews", func(r chi.Router) {
 					r.Get("/", app.getProductReviews)
 					r.Get("/analytics", app.getReviewRatingAnalytics)
 
 					r.With(app.requireAuthenicatedUser).Group(func(r chi.Router) {
-						r.With(app.CheckPermissions(RequireRoles(store.UserRole))).Post("/", app.createReview)
+						r.With(app.CheckPermissions(RequireRoles(store.UserRole))).Group(func(r chi.Router) {
+							r.Post("/", app.createReview)
+							r.Put("/{reviewID}", app.updateReview)
+							r.Delete("/{reviewID}", app.removeOwnReview)
+							r.Post("/{reviewID}/media", app.uploadReviewMedia)
+							r.Delete("/{reviewID}/media/{mediaID}", app.removeReviewMedia)
+							r.Put("/{reviewID}/vote", app.voteReview)
+							r.Delete("/{reviewID}/vote", app.removeReviewVote)
+							r.Post("/{reviewID}/report", app.reportReview)
+						})
+						r.With(app.CheckPermissions(RequireRoles(store.VendorRole))).Post("/{reviewID}/reply", app.replyToReview)
 					})
 				})
 			})
@@ -278,7 +453,7 @@ func (app *application) routes() http.Handler {
 			r.Group(func(r chi.Router) {
 				r.Use(app.requireAuthenicatedUser)
 
-				r.With(app.CheckPermissions(RequireRoles(store.VendorRole))).Group(func(r chi.Router) {
+				r.With(app.CheckPermissions(RequireRoles(store.VendorRole), RequireScopes(store.ProductsWriteScope))).Group(func(r chi.Router) {
 					r.Post("/", app.createProduct)
 					r.Patch("/{productID}/publish", app.publishProduct)
 					r.Patch("/{productID}/unpublish", app.unPublishProduct)
@@ -292,6 +467,32 @@ func (app *application) routes() http.Handler {
 
 		})
 
+		r.Route("/vendors", func(r chi.Router) {
+			r.Use(app.requireAuthenicatedUser)
+
+			r.Route("/products", func(r chi.Router) {
+				r.With(app.CheckPermissions(RequireRoles(store.VendorRole), RequireScopes(store.ProductsWriteScope))).Group(func(r chi.Router) {
+					r.Post("/import", app.importProducts)
+					r.Get("/imports/{importID}", app.getProductImport)
+				})
+				r.With(app.CheckPermissions(RequireRoles(store.VendorRole), RequireScopes(store.ProductsReadScope))).
+					Get("/export", app.exportProducts)
+			})
+
+			r.Route("/warehouses", func(r chi.Router) {
+				r.With(app.CheckPermissions(RequireRoles(store.VendorRole), RequireScopes(store.InventoryReadScope))).Group(func(r chi.Router) {
+					r.Get("/", app.getWarehouses)
+					r.Get("/{warehouseID}", app.getWarehouse)
+				})
+
+				r.With(app.CheckPermissions(RequireRoles(store.VendorRole), RequireScopes(store.InventoryWriteScope))).Group(func(r chi.Router) {
+					r.Post("/", app.createWarehouse)
+					r.Patch("/{warehouseID}", app.updateWarehouse)
+					r.Delete("/{warehouseID}", app.deleteWarehouse)
+				})
+			})
+		})
+
 		r.Route("/admins", func(r chi.Router) {
 			r.Use(app.requireAuthenicatedUser)
 			r.Use(app.CheckPermissions(RequireRoles(store.AdminRole)))
@@ -313,6 +514,8 @@ func (app *application) routes() http.Handler {
 			})
 
 			r.Route("/products", func(r chi.Router) {
+				r.With(app.CheckPermissions(MinimumAdminLevel(store.AdminLevelManager))).Get("/moderation", app.getModerationQueue)
+
 				r.Route("/{productID}", func(r chi.Router) {
 					r.Route("/reviews", func(r chi.Router) {
 						r.Delete("/{reviewID}", app.removeReview)
@@ -320,8 +523,26 @@ func (app *application) routes() http.Handler {
 				})
 			})
 
+			r.Route("/reviews", func(r chi.Router) {
+				r.Use(app.CheckPermissions(MinimumAdminLevel(store.AdminLevelManager)))
+				r.Get("/moderation", app.getReviewModerationQueue)
+
+				r.Route("/{reviewID}", func(r chi.Router) {
+					r.Patch("/hide", app.hideReview)
+					r.Patch("/restore", app.restoreReview)
+					r.Patch("/dismiss", app.dismissReviewReports)
+				})
+			})
+
 			r.Route("/users", func(r chi.Router) {
 				r.Get("/", app.getNormalUsers)
+				r.With(app.CheckPermissions(MinimumAdminLevel(store.AdminLevelSuper))).Post("/{userID}/2fa/reset", app.reset2fa)
+			})
+
+			r.Route("/lockouts", func(r chi.Router) {
+				r.Get("/", app.getLoginLockouts)
+				r.Get("/{userID}", app.getUserLoginLockout)
+				r.With(app.CheckPermissions(MinimumAdminLevel(store.AdminLevelManager))).Delete("/{userID}", app.clearLoginLockout)
 			})
 
 			r.Route("/vendors", func(r chi.Router) {
@@ -335,6 +556,13 @@ func (app *application) routes() http.Handler {
 				r.With(app.CheckPermissions(MinimumAdminLevel(store.AdminLevelManager))).Delete("/{id}", app.removeCategory)
 
 				r.With(app.CheckPermissions(MinimumAdminLevel(store.AdminLevelManager))).Put("/{id}/visibility", app.setCategoryVisibility)
+				r.With(app.CheckPermissions(MinimumAdminLevel(store.AdminLevelManager))).Put("/{id}/parent", app.moveCategory)
+
+				r.With(app.CheckPermissions(MinimumAdminLevel(store.AdminLevelManager))).Route("/{id}/attributes", func(r chi.Router) {
+					r.Post("/", app.createCategoryAttribute)
+					r.Put("/{attributeID}", app.updateCategoryAttribute)
+					r.Delete("/{attributeID}", app.removeCategoryAttribute)
+				})
 			})
 
 			r.Route("/option-types", func(r chi.Router) {
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/devphaseX/buyr-api.git/internal/store"
+)
+
+// maxAPIKeysPerUser keeps forgotten keys from piling up on an account
+const maxAPIKeysPerUser = 20
+
+type createAPIKeyForm struct {
+	Name          string              `json:"name" validate:"required,min=1,max=100"`
+	Scopes        []store.APIKeyScope `json:"scopes" validate:"required,min=1,dive,required"`
+	ExpiresInDays int                 `json:"expires_in_days" validate:"omitempty,min=1,max=365"`
+}
+
+// createAPIKey issues a key for the user's own systems. The plaintext key is
+// only part of this response, afterwards the key is known by its prefix.
+func (app *application) createAPIKey(w http.ResponseWriter, r *http.Request) {
+	var form createAPIKeyForm
+	user := getUserFromCtx(r)
+
+	if err := app.readJSON(w, r, &form); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := validate.Struct(form); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	for _, scope := range form.Scopes {
+		if !slices.Contains(store.APIKeyScopes, scope) {
+			app.badRequestResponse(w, r, fmt.Errorf("unknown scope '%s'", scope))
+			return
+		}
+	}
+
+	keys, err := app.store.APIKeys.GetByUserID(r.Context(), user.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if len(keys) >= maxAPIKeysPerUser {
+		app.conflictResponse(w, r, fmt.Sprintf("you can have at most %d API keys, revoke one first", maxAPIKeysPerUser))
+		return
+	}
+
+	plaintext, hash, err := store.GenerateAPIKey()
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	apiKey := &store.APIKey{
+		UserID:  user.ID,
+		Name:    form.Name,
+		Prefix:  plaintext[:len(store.APIKeyPrefix)+8],
+		KeyHash: hash,
+		Scopes:  slices.Compact(slices.Sorted(slices.Values(form.Scopes))),
+	}
+
+	if form.ExpiresInDays > 0 {
+		expiresAt := time.Now().AddDate(0, 0, form.ExpiresInDays)
+		apiKey.ExpiresAt = &expiresAt
+	}
+
+	if err := app.store.APIKeys.Create(r.Context(), apiKey); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.successResponse(w, http.StatusCreated, envelope{
+		"api_key": apiKey,
+		"key":     plaintext,
+		"message": "store this key safely, it will not be shown again",
+	})
+}
+
+func (app *application) getAPIKeys(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromCtx(r)
+
+	keys, err := app.store.APIKeys.GetByUserID(r.Context(), user.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.successResponse(w, http.StatusOK, envelope{
+		"api_keys": keys,
+		"scopes":   store.APIKeyScopes,
+	})
+}
+
+func (app *application) revokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromCtx(r)
+	keyID := app.readStringID(r, "keyID")
+
+	err := app.store.APIKeys.Delete(r.Context(), keyID, user.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrRecordNotFound):
+			app.notFoundResponse(w, r, "API key not found")
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.successResponse(w, http.StatusOK, envelope{
+		"message": "API key revoked",
+		"id":      keyID,
+	})
+}
@@ -265,6 +265,13 @@ func (app *application) signIn(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	attempt, ok := app.reserveLoginAttempt(w, r, user)
+	if !ok {
+		return
+	}
+
+	defer attempt.release(r.Context())
+
 	if user.Password.IsSetPasswordEmpty() {
 		account, err := app.store.Users.GetUserAccountByUserID(r.Context(), user.ID)
 
@@ -305,7 +312,7 @@ func (app *application) signIn(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if !match {
-		app.invalidCredentialsResponse(w, r)
+		attempt.failed(w, r, func() { app.invalidCredentialsResponse(w, r) })
 		return
 	}
 
@@ -320,8 +327,20 @@ func (app *application) signIn(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if user.TwoFactorAuthEnabled {
-		payload, _ := json.Marshal(signIn2faPayload{RememberMe: form.RememberMe})
+	app.completeSignIn(w, r, user, form.RememberMe)
+}
+
+// completeSignIn finishes a sign-in whose first factor was accepted: users
+// with 2FA get a token for the second step, everyone else a session.
+func (app *application) completeSignIn(w http.ResponseWriter, r *http.Request, user *store.User, rememberMe bool) {
+	mfaMethods, err := app.twoFactorMethods(r.Context(), user)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if len(mfaMethods) > 0 {
+		payload, _ := json.Marshal(signIn2faPayload{RememberMe: rememberMe})
 		token, err := app.cacheStore.Tokens.New(
 			user.ID,
 			time.Hour*4,
@@ -343,16 +362,19 @@ func (app *application) signIn(w http.ResponseWriter, r *http.Request) {
 		app.successResponse(w, http.StatusOK, envelope{
 			"mfa_enabled":    true,
 			"mfa_auth_token": token.Plaintext,
+			"mfa_methods":    mfaMethods,
 		})
 		return
 	}
 
-	app.createUserSessionAndSetCookies(w, r, user, form.RememberMe)
+	app.loginSucceeded(r.Context(), user.ID)
+	app.createUserSessionAndSetCookies(w, r, user, rememberMe)
 }
 
 type verify2FAForm struct {
-	MfaToken string `json:"mfa_token" validate:"required"`
-	MfaCode  string `json:"mfa_code" validate:"required,min=6,max=6"` // Assuming 6-digit codes
+	MfaToken string              `json:"mfa_token" validate:"required"`
+	MfaCode  string              `json:"mfa_code" validate:"required,min=6,max=6"` // Assuming 6-digit codes
+	Method   store.TwoFactorType `json:"method" validate:"omitempty,oneof=totp email"`
 }
 
 func (app *application) verifyLogin2FA(w http.ResponseWriter, r *http.Request) {
@@ -391,22 +413,28 @@ func (app *application) verifyLogin2FA(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Fetch the user
-	user, err := app.getUser(r.Context(), token.UserID)
+	user, err := app.getUser(r.Context(), token.UserID, true)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 		return
 	}
 
-	secret, err := encrypt.DecryptSecret(user.AuthSecret, app.cfg.encryptConfig.masterSecretKey)
+	attempt, ok := app.reserveLoginAttempt(w, r, user.User)
+	if !ok {
+		return
+	}
+
+	defer attempt.release(r.Context())
 
+	// Verify the 2FA code with the method the user picked
+	valid, err := app.verify2faCode(r.Context(), user.User, form.Method, form.MfaCode, cache.Login2faTokenScope)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 		return
 	}
 
-	// Verify the 2FA code (e.g., using a TOTP library)
-	if !app.totp.VerifyCode(secret, form.MfaCode) {
-		app.unauthorizedResponse(w, r, "invalid 2FA code")
+	if !valid {
+		attempt.failed(w, r, func() { app.unauthorizedResponse(w, r, "invalid 2FA code") })
 		return
 	}
 
@@ -416,6 +444,7 @@ func (app *application) verifyLogin2FA(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	attempt.succeeded(r.Context())
 	app.createUserSessionAndSetCookies(w, r, user.User, signin2faPayload.RememberMe)
 }
 
@@ -462,6 +491,13 @@ func (app *application) verifyLogin2faRecoveryCode(w http.ResponseWriter, r *htt
 		return
 	}
 
+	attempt, ok := app.reserveLoginAttempt(w, r, user.User)
+	if !ok {
+		return
+	}
+
+	defer attempt.release(r.Context())
+
 	recoveryCodes, err := encrypt.DecryptRecoveryCodes(user.RecoveryCodes, app.cfg.encryptConfig.masterSecretKey)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
@@ -477,7 +513,7 @@ func (app *application) verifyLogin2faRecoveryCode(w http.ResponseWriter, r *htt
 	}
 
 	if !validRecoveryCode {
-		app.unauthorizedResponse(w, r, "invalid recovery code")
+		attempt.failed(w, r, func() { app.unauthorizedResponse(w, r, "invalid recovery code") })
 		return
 	}
 
@@ -493,6 +529,7 @@ func (app *application) verifyLogin2faRecoveryCode(w http.ResponseWriter, r *htt
 		return
 	}
 
+	attempt.succeeded(r.Context())
 	app.createUserSessionAndSetCookies(w, r, user.User, signin2faPayload.RememberMe)
 }
 
@@ -522,14 +559,21 @@ func (app *application) refreshToken(w http.ResponseWriter, r *http.Request) {
 
 	claims, err := app.authToken.ValidateRefreshToken(form.RefreshToken)
 
-	if err != nil {
+	// Refresh tokens of third-party apps are only accepted at /oauth/token
+	if err != nil || claims.IsClientToken() {
 		app.unauthorizedResponse(w, r, "invalid refresh token")
 		return
 	}
 
 	session, user, canExtend, err := app.store.Sessions.ValidateSession(r.Context(), claims.SessionID, claims.Version)
 
-	if err != nil || session == nil || user.Version != session.Version {
+	if errors.Is(err, store.ErrRefreshTokenReused) {
+		app.revokeReusedSession(r, session, user)
+		app.unauthorizedResponse(w, r, "invalid session")
+		return
+	}
+
+	if err != nil || session == nil {
 		app.unauthorizedResponse(w, r, "invalid session")
 		return
 	}
@@ -549,12 +593,29 @@ func (app *application) refreshToken(w http.ResponseWriter, r *http.Request) {
 		rememberPeriod = app.cfg.authConfig.RememberMeTTL
 	}
 
+	// Refresh tokens are single use: every refresh issues a new one, extending
+	// the session when it is due.
 	if canExtend {
 		newRefreshToken, err = app.store.Sessions.ExtendSessionAndGenerateRefreshToken(r.Context(), session, app.authToken, rememberPeriod)
-		if err != nil {
-			app.serverErrorResponse(w, r, fmt.Errorf("failed to extend session: %v", err))
-			return
+	} else {
+		rememberPeriod = time.Until(session.ExpiresAt)
+		newRefreshToken, err = app.store.Sessions.RotateRefreshToken(r.Context(), session, app.authToken)
+	}
+
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrRefreshTokenReused):
+			app.revokeReusedSession(r, session, user)
+			app.unauthorizedResponse(w, r, "invalid session")
+		default:
+			app.serverErrorResponse(w, r, fmt.Errorf("failed to rotate refresh token: %v", err))
 		}
+		return
+	}
+
+	if err := app.forgetSessions(r.Context(), session.ID); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
 	}
 
 	app.setAuthCookiesAndRespond(
@@ -194,7 +194,7 @@ func (app *application) addCardItem(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if product.StockQuantity < form.Quantity {
+	if product.StockQuantity < form.Quantity && !product.AcceptsBackorder() {
 		app.forbiddenResponse(w, r, "product not in stock")
 		return
 	}
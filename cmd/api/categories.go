@@ -8,8 +8,10 @@ import (
 )
 
 type createCategoryForm struct {
-	Name        string `json:"name" validate:"min=1,max=255"`
-	Description string `json:"description" validate:"min=1,max=500"`
+	Name        string  `json:"name" validate:"min=1,max=255"`
+	Slug        string  `json:"slug" validate:"omitempty,max=255"`
+	Description string  `json:"description" validate:"min=1,max=500"`
+	ParentID    *string `json:"parent_id" validate:"omitempty,min=1"`
 }
 
 func (app *application) createCategory(w http.ResponseWriter, r *http.Request) {
@@ -26,15 +28,34 @@ func (app *application) createCategory(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	slug := slugify(form.Slug)
+	if slug == "" {
+		slug = slugify(form.Name)
+	}
+
+	if slug == "" {
+		app.badRequestResponse(w, r, errors.New("slug must contain at least one letter or digit"))
+		return
+	}
+
 	category := &store.Category{
+		ParentID:         form.ParentID,
 		Name:             form.Name,
+		Slug:             slug,
 		Description:      form.Description,
 		Visible:          false,
 		CreatedByAdminID: user.AdminUser.ID,
 	}
 
 	if err := app.store.Category.Create(r.Context(), category); err != nil {
-		app.serverErrorResponse(w, r, err)
+		switch {
+		case errors.Is(err, store.ErrDuplicateCategorySlug):
+			app.conflictResponse(w, r, err.Error())
+		case errors.Is(err, store.ErrCategoryParentNotFound):
+			app.notFoundResponse(w, r, err.Error())
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
 		return
 	}
 
@@ -104,6 +125,8 @@ func (app *application) removeCategory(w http.ResponseWriter, r *http.Request) {
 		switch {
 		case errors.Is(err, store.ErrRecordNotFound):
 			app.notFoundResponse(w, r)
+		case errors.Is(err, store.ErrCategoryHasChildren):
+			app.conflictResponse(w, r, "category has subcategories, move or remove them first")
 
 		default:
 			app.serverErrorResponse(w, r, err)
@@ -154,3 +177,117 @@ func (app *application) setCategoryVisibility(w http.ResponseWriter, r *http.Req
 	app.successResponse(w, http.StatusOK, response)
 
 }
+
+func (app *application) getCategoryTree(w http.ResponseWriter, r *http.Request) {
+	categories, err := app.store.Category.GetVisibleTree(r.Context(), nil)
+
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.successResponse(w, http.StatusOK, envelope{
+		"categories": categories,
+	})
+}
+
+// getCategory looks a visible category up by id or slug and returns it with
+// its visible subtree and breadcrumb trail.
+func (app *application) getCategory(w http.ResponseWriter, r *http.Request) {
+	idOrSlug := app.readStringID(r, "category")
+
+	category, err := app.store.Category.GetByID(r.Context(), idOrSlug)
+
+	if errors.Is(err, store.ErrRecordNotFound) {
+		category, err = app.store.Category.GetBySlug(r.Context(), idOrSlug)
+	}
+
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrRecordNotFound):
+			app.notFoundResponse(w, r, "category not found")
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if !category.Visible {
+		app.notFoundResponse(w, r, "category not found")
+		return
+	}
+
+	breadcrumbs, err := app.store.Category.GetBreadcrumbs(r.Context(), category)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	// a hidden ancestor hides the whole branch.
+	if len(breadcrumbs) != category.Depth+1 {
+		app.notFoundResponse(w, r, "category not found")
+		return
+	}
+
+	tree, err := app.store.Category.GetVisibleTree(r.Context(), &category.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if len(tree) != 0 {
+		category = tree[0]
+	}
+
+	app.successResponse(w, http.StatusOK, envelope{
+		"category":    category,
+		"breadcrumbs": breadcrumbs,
+	})
+}
+
+type moveCategoryForm struct {
+	ParentID *string `json:"parent_id" validate:"omitempty,min=1"`
+}
+
+func (app *application) moveCategory(w http.ResponseWriter, r *http.Request) {
+	categoryID := app.readStringID(r, "id")
+
+	var form moveCategoryForm
+
+	if err := app.readJSON(w, r, &form); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := validate.Struct(form); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	category, err := app.store.Category.Move(r.Context(), categoryID, form.ParentID)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrRecordNotFound):
+			app.notFoundResponse(w, r, "category not found")
+		case errors.Is(err, store.ErrCategoryParentNotFound):
+			app.notFoundResponse(w, r, err.Error())
+		case errors.Is(err, store.ErrCategoryCycle):
+			app.conflictResponse(w, r, err.Error())
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	breadcrumbs, err := app.store.Category.GetBreadcrumbs(r.Context(), category)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.successResponse(w, http.StatusOK, envelope{
+		"category":    category,
+		"breadcrumbs": breadcrumbs,
+	})
+}
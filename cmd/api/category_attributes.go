@@ -0,0 +1,164 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/devphaseX/buyr-api.git/internal/store"
+)
+
+type categoryAttributeForm struct {
+	Name          string                      `json:"name" validate:"required,max=100"`
+	Type          store.CategoryAttributeType `json:"type" validate:"required,oneof=text number boolean enum"`
+	Unit          string                      `json:"unit" validate:"max=50"`
+	AllowedValues []string                    `json:"allowed_values" validate:"required_if=Type enum,dive,min=1,max=255"`
+	Required      bool                        `json:"required"`
+}
+
+func (form *categoryAttributeForm) attribute(categoryID string) *store.CategoryAttribute {
+	allowedValues := form.AllowedValues
+	if form.Type != store.EnumAttributeType || allowedValues == nil {
+		allowedValues = []string{}
+	}
+
+	return &store.CategoryAttribute{
+		CategoryID:    categoryID,
+		Name:          form.Name,
+		Type:          form.Type,
+		Unit:          form.Unit,
+		AllowedValues: allowedValues,
+		Required:      form.Required,
+	}
+}
+
+func (app *application) createCategoryAttribute(w http.ResponseWriter, r *http.Request) {
+	categoryID := app.readStringID(r, "id")
+
+	var form categoryAttributeForm
+
+	if err := app.readJSON(w, r, &form); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := validate.Struct(form); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	attribute := form.attribute(categoryID)
+
+	if err := app.store.CategoryAttributes.Create(r.Context(), attribute); err != nil {
+		switch {
+		case errors.Is(err, store.ErrRecordNotFound):
+			app.notFoundResponse(w, r, "category not found")
+		case errors.Is(err, store.ErrDuplicateCategoryAttribute):
+			app.conflictResponse(w, r, err.Error())
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.successResponse(w, http.StatusCreated, envelope{
+		"attribute": attribute,
+	})
+}
+
+func (app *application) updateCategoryAttribute(w http.ResponseWriter, r *http.Request) {
+	var (
+		categoryID  = app.readStringID(r, "id")
+		attributeID = app.readStringID(r, "attributeID")
+		form        categoryAttributeForm
+	)
+
+	if err := app.readJSON(w, r, &form); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := validate.Struct(form); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	attribute := form.attribute(categoryID)
+	attribute.ID = attributeID
+
+	if err := app.store.CategoryAttributes.Update(r.Context(), attribute); err != nil {
+		switch {
+		case errors.Is(err, store.ErrRecordNotFound):
+			app.notFoundResponse(w, r, "attribute not found")
+		case errors.Is(err, store.ErrDuplicateCategoryAttribute):
+			app.conflictResponse(w, r, err.Error())
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.successResponse(w, http.StatusOK, envelope{
+		"attribute": attribute,
+	})
+}
+
+func (app *application) removeCategoryAttribute(w http.ResponseWriter, r *http.Request) {
+	var (
+		categoryID  = app.readStringID(r, "id")
+		attributeID = app.readStringID(r, "attributeID")
+	)
+
+	if err := app.store.CategoryAttributes.Delete(r.Context(), categoryID, attributeID); err != nil {
+		switch {
+		case errors.Is(err, store.ErrRecordNotFound):
+			app.notFoundResponse(w, r, "attribute not found")
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.successResponse(w, http.StatusOK, envelope{
+		"message": "attribute successfully deleted",
+		"id":      attributeID,
+	})
+}
+
+// getCategoryAttributes returns the effective schema of a category, including
+// attributes inherited from its ancestors, so clients can build filters.
+func (app *application) getCategoryAttributes(w http.ResponseWriter, r *http.Request) {
+	idOrSlug := app.readStringID(r, "category")
+
+	category, err := app.store.Category.GetByID(r.Context(), idOrSlug)
+
+	if errors.Is(err, store.ErrRecordNotFound) {
+		category, err = app.store.Category.GetBySlug(r.Context(), idOrSlug)
+	}
+
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrRecordNotFound):
+			app.notFoundResponse(w, r, "category not found")
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	user := getUserFromCtx(r)
+
+	if !category.Visible && !user.IsAdmin() {
+		app.notFoundResponse(w, r, "category not found")
+		return
+	}
+
+	attributes, err := app.store.CategoryAttributes.GetEffectiveSchema(r.Context(), category.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.successResponse(w, http.StatusOK, envelope{
+		"attributes": attributes,
+	})
+}
@@ -1,6 +1,9 @@
 package main
 
-import "net/http"
+import (
+	"net/http"
+	"time"
+)
 
 func (app *application) getAccessCookie(r *http.Request) string {
 	cookie, err := r.Cookie(app.cfg.authConfig.AccesssCookieName)
@@ -11,3 +14,20 @@ func (app *application) getAccessCookie(r *http.Request) string {
 
 	return cookie.Value
 }
+
+// clearAuthCookies expires the access and refresh cookies of a signed out
+// session.
+func (app *application) clearAuthCookies(w http.ResponseWriter) {
+	for _, name := range []string{app.cfg.authConfig.AccesssCookieName, app.cfg.authConfig.RefreshCookiName} {
+		http.SetCookie(w, &http.Cookie{
+			Name:     name,
+			Value:    "",
+			Expires:  time.Unix(0, 0),
+			MaxAge:   -1,
+			Path:     "/",
+			HttpOnly: true,
+			Secure:   true,
+			SameSite: http.SameSiteStrictMode,
+		})
+	}
+}
@@ -0,0 +1,309 @@
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/devphaseX/buyr-api.git/internal/encrypt"
+	"github.com/devphaseX/buyr-api.git/internal/store"
+	"github.com/devphaseX/buyr-api.git/internal/store/cache"
+	"github.com/devphaseX/buyr-api.git/worker"
+	"github.com/hibiken/asynq"
+)
+
+const email2faCodeTTL = time.Minute * 10
+
+// codeTwoFactorMethods lists the second factors of a user that are completed
+// by typing a code.
+func codeTwoFactorMethods(user *store.User) []store.TwoFactorType {
+	var methods []store.TwoFactorType
+
+	if user.TwoFactorAuthEnabled {
+		methods = append(methods, store.TotpFactorType)
+	}
+
+	if user.Email2faEnabled {
+		methods = append(methods, store.EmailFactorType)
+	}
+
+	return methods
+}
+
+// sendEmail2faCode emails a one-time code that completes the step guarded by
+// a token of the purpose scope. Requesting a new code voids the previous one.
+func (app *application) sendEmail2faCode(ctx context.Context, user *store.User, purpose cache.TokenScope) error {
+	if err := app.cacheStore.Tokens.DeleteAllForUser(ctx, cache.Email2faCodeTokenScope, user.ID); err != nil {
+		return err
+	}
+
+	token, err := app.cacheStore.Tokens.NewCode(user.ID, email2faCodeTTL, cache.Email2faCodeTokenScope, []byte(purpose))
+	if err != nil {
+		return err
+	}
+
+	if err := app.cacheStore.Tokens.Insert(ctx, token); err != nil {
+		return err
+	}
+
+	return app.taskDistributor.DistributeTaskSendTwoFactorCodeEmail(ctx, &worker.PayloadSendTwoFactorCodeEmail{
+		Username:  app.displayName(ctx, user),
+		Email:     user.Email,
+		CodeID:    hex.EncodeToString(token.Hash),
+		Code:      token.Plaintext,
+		ExpiresIn: email2faCodeTTL,
+	}, asynq.MaxRetry(3), asynq.Queue(worker.QueueCritical))
+}
+
+// checkEmail2faCode consumes a code sent by sendEmail2faCode. A code only
+// completes the step it was requested for.
+func (app *application) checkEmail2faCode(ctx context.Context, userID, code string, purpose cache.TokenScope) (bool, error) {
+	token, err := app.cacheStore.Tokens.GetCode(ctx, cache.Email2faCodeTokenScope, userID, code)
+	if err != nil {
+		if errors.Is(err, store.ErrRecordNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	if cache.TokenScope(token.Data) != purpose {
+		return false, nil
+	}
+
+	if err := app.cacheStore.Tokens.DeleteAllForUser(ctx, cache.Email2faCodeTokenScope, userID); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// verify2faCode checks a code against one of the user's code based second
+// factors. TOTP is assumed when no method is given.
+func (app *application) verify2faCode(ctx context.Context, user *store.User, method store.TwoFactorType, code string, purpose cache.TokenScope) (bool, error) {
+	switch method {
+	case store.TotpFactorType, "":
+		if !user.TwoFactorAuthEnabled {
+			return false, nil
+		}
+
+		secret, err := encrypt.DecryptSecret(user.AuthSecret, app.cfg.encryptConfig.masterSecretKey)
+		if err != nil {
+			return false, err
+		}
+
+		return app.totp.VerifyCode(secret, code), nil
+
+	case store.EmailFactorType:
+		if !user.Email2faEnabled {
+			return false, nil
+		}
+
+		return app.checkEmail2faCode(ctx, user.ID, code, purpose)
+
+	default:
+		return false, nil
+	}
+}
+
+type sendLogin2faEmailCodeForm struct {
+	MfaToken string `json:"mfa_token" validate:"required"`
+}
+
+func (app *application) sendLogin2faEmailCode(w http.ResponseWriter, r *http.Request) {
+	var form sendLogin2faEmailCodeForm
+
+	if err := app.readJSON(w, r, &form); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := validate.Struct(form); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	token, err := app.cacheStore.Tokens.Get(r.Context(), cache.Login2faTokenScope, form.MfaToken)
+	if err != nil {
+		app.unauthorizedResponse(w, r, "invalid or expired 2FA token")
+		return
+	}
+
+	user, err := app.getUser(r.Context(), token.UserID, true)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if app.loginBlocked(w, r, user.ID) {
+		return
+	}
+
+	if !user.Email2faEnabled {
+		app.forbiddenResponse(w, r, "email 2fa not enabled")
+		return
+	}
+
+	if err := app.sendEmail2faCode(r.Context(), user.User, cache.Login2faTokenScope); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.successResponse(w, http.StatusOK, envelope{
+		"message": "a verification code has been sent to your email",
+	})
+}
+
+type send2faEmailCodeForm struct {
+	MfaToken string `json:"mfa_token" validate:"required"`
+	Action   string `json:"action" validate:"required,oneof=change_password change_email"`
+}
+
+// send2faEmailCode emails a code for a signed in user confirming a sensitive
+// change with the token returned in the required 2FA response.
+func (app *application) send2faEmailCode(w http.ResponseWriter, r *http.Request) {
+	var (
+		form    send2faEmailCodeForm
+		user    = getUserFromCtx(r)
+		purpose = cache.ChangePassword2faTokenScope
+	)
+
+	if err := app.readJSON(w, r, &form); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := validate.Struct(form); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if form.Action == "change_email" {
+		purpose = cache.ChangeEmail2faTokenScope
+	}
+
+	token, err := app.cacheStore.Tokens.Get(r.Context(), purpose, form.MfaToken)
+	if err != nil || token.UserID != user.ID {
+		app.notFoundResponse(w, r, "token invalid or expired")
+		return
+	}
+
+	account, err := app.getUser(r.Context(), user.ID, true)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if !account.Email2faEnabled {
+		app.forbiddenResponse(w, r, "email 2fa not enabled")
+		return
+	}
+
+	if err := app.sendEmail2faCode(r.Context(), account.User, purpose); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.successResponse(w, http.StatusOK, envelope{
+		"message": "a verification code has been sent to your email",
+	})
+}
+
+func (app *application) setupEmail2fa(w http.ResponseWriter, r *http.Request) {
+	user, err := app.getUser(r.Context(), getUserFromCtx(r).ID, true)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if user.Email2faEnabled {
+		app.forbiddenResponse(w, r, "email 2fa already enabled")
+		return
+	}
+
+	if err := app.sendEmail2faCode(r.Context(), user.User, cache.Email2faSetupTokenScope); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.successResponse(w, http.StatusOK, envelope{
+		"message": "a verification code has been sent to your email",
+	})
+}
+
+type verifyEmail2faSetupForm struct {
+	Code string `json:"code" validate:"min=6,max=6"`
+}
+
+func (app *application) verifyEmail2faSetup(w http.ResponseWriter, r *http.Request) {
+	var (
+		form verifyEmail2faSetupForm
+		user = getUserFromCtx(r)
+	)
+
+	if err := app.readJSON(w, r, &form); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := validate.Struct(form); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	valid, err := app.checkEmail2faCode(r.Context(), user.ID, form.Code, cache.Email2faSetupTokenScope)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if !valid {
+		app.unauthorizedResponse(w, r, "invalid code")
+		return
+	}
+
+	if err := app.store.Users.SetEmail2fa(r.Context(), user.ID, true); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.successResponse(w, http.StatusOK, envelope{
+		"message": "email 2fa enabled",
+	})
+}
+
+type disableEmail2faForm struct {
+	Password string `json:"password" validate:"required"`
+}
+
+func (app *application) disableEmail2fa(w http.ResponseWriter, r *http.Request) {
+	var (
+		form disableEmail2faForm
+		user = getUserFromCtx(r)
+	)
+
+	if err := app.readJSON(w, r, &form); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := validate.Struct(form); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if match := app.withPasswordAccess(r, form.Password); !match {
+		app.forbiddenResponse(w, r, "password not a match")
+		return
+	}
+
+	if err := app.store.Users.SetEmail2fa(r.Context(), user.ID, false); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.successResponse(w, http.StatusOK, envelope{
+		"message": "email 2fa disabled",
+	})
+}
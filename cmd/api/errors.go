@@ -2,9 +2,12 @@ package main
 
 import (
 	"errors"
+	"math"
 	"net/http"
+	"strconv"
 
 	"github.com/devphaseX/buyr-api.git/internal/store"
+	"github.com/devphaseX/buyr-api.git/internal/store/cache"
 	"github.com/devphaseX/buyr-api.git/internal/validator"
 )
 
@@ -24,6 +27,7 @@ const (
 	ErrorCodeInternalServerError ResponseErrorCode = "internal_server_error"
 	ErrorCodeOAuthAccount        ResponseErrorCode = "oauth_account"
 	ErrorPasswordResetRequired   ResponseErrorCode = "password_reset_required"
+	ErrorCodeAccountLocked       ResponseErrorCode = "account_locked"
 )
 
 func (app *application) badRequestResponse(w http.ResponseWriter, r *http.Request, err error) {
@@ -70,6 +74,21 @@ func (app *application) rateLimitExceededResponse(w http.ResponseWriter) {
 	app.errorResponse(w, http.StatusTooManyRequests, message, envelope{"code": ErrorTooManyRequest})
 }
 
+func (app *application) accountLockedResponse(w http.ResponseWriter, lockout *cache.LoginLockout) {
+	retryAfter := lockout.RetryAfter()
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+
+	message := "too many failed sign-in attempts, try again later"
+	if lockout.Locked {
+		message = "account temporarily locked after too many failed sign-in attempts"
+	}
+
+	app.errorResponse(w, http.StatusTooManyRequests, message, envelope{
+		"code":         ErrorCodeAccountLocked,
+		"locked_until": lockout.LockedUntil,
+	})
+}
+
 func (app *application) conflictResponse(w http.ResponseWriter, r *http.Request, message string) {
 	app.logger.Warnf(
 		"conflict error",
@@ -6,15 +6,23 @@ import (
 	"mime/multipart"
 	"net/http"
 	"path/filepath"
+	"regexp"
 	"strings"
 	"time"
 
-	"github.com/devphaseX/buyr-api.git/internal/encrypt"
 	"github.com/devphaseX/buyr-api.git/internal/store"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-playground/form/v4"
 )
 
+var nonSlugChars = regexp.MustCompile(`[^a-z0-9]+`)
+
+// slugify lowercases s and collapses everything that is not a letter or digit
+// into single dashes.
+func slugify(s string) string {
+	return strings.Trim(nonSlugChars.ReplaceAllString(strings.ToLower(s), "-"), "-")
+}
+
 func (app *application) readStringID(r *http.Request, param string) string {
 	return chi.URLParam(r, param)
 }
@@ -170,13 +178,3 @@ func isImage(fileHeader *multipart.FileHeader) bool {
 	// Check if the MIME type starts with "image/"
 	return strings.HasPrefix(mimeType, "image/")
 }
-
-func (app *application) verifyTOTP(user *store.User, code string) (bool, error) {
-	secret, err := encrypt.DecryptSecret(user.AuthSecret, app.cfg.encryptConfig.masterSecretKey)
-
-	if err != nil {
-		return false, err
-	}
-
-	return !app.totp.VerifyCode(secret, code), nil
-}
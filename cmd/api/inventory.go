@@ -0,0 +1,174 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/devphaseX/buyr-api.git/internal/store"
+	"github.com/devphaseX/buyr-api.git/internal/store/modelfilter"
+	"github.com/devphaseX/buyr-api.git/internal/validator"
+)
+
+type adjustStockRequest struct {
+	Kind        store.InventoryMovementKind `json:"kind" validate:"required,oneof=restock return adjustment"`
+	Quantity    int                         `json:"quantity" validate:"required"`
+	Reason      string                      `json:"reason" validate:"required,max=500"`
+	WarehouseID string                      `json:"warehouse_id"`
+}
+
+type setLowStockThresholdRequest struct {
+	Threshold *int `json:"threshold" validate:"omitempty,gte=0"`
+}
+
+func (app *application) adjustProductStock(w http.ResponseWriter, r *http.Request) {
+	var (
+		user      = getUserFromCtx(r)
+		productID = app.readStringID(r, "productID")
+		form      adjustStockRequest
+	)
+
+	if err := app.readJSON(w, r, &form); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := validate.Struct(form); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	// Restocks and returns only ever add stock; adjustments go either way.
+	if form.Kind != store.AdjustmentInventoryMovement && form.Quantity < 0 {
+		var validationErrors validator.ValidationErrors
+		validationErrors.AddFieldError("quantity", "must be positive for a restock or return")
+		app.badRequestResponse(w, r, &validationErrors)
+		return
+	}
+
+	vendorUser, err := app.store.Users.GetVendorUserByID(r.Context(), user.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	before, err := app.store.Products.GetProductByID(r.Context(), productID)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrRecordNotFound):
+			app.notFoundResponse(w, r, "product not found")
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	movement := &store.InventoryMovement{
+		ProductID:   productID,
+		WarehouseID: form.WarehouseID,
+		Kind:        form.Kind,
+		Quantity:    form.Quantity,
+		ActorID:     user.ID,
+		Reason:      form.Reason,
+	}
+
+	if err := app.store.Inventory.Adjust(r.Context(), vendorUser.ID, movement); err != nil {
+		switch {
+		case errors.Is(err, store.ErrRecordNotFound):
+			app.notFoundResponse(w, r, "product not found")
+		case errors.Is(err, store.ErrWarehouseNotFound):
+			app.notFoundResponse(w, r, err.Error())
+		case errors.Is(err, store.ErrInsufficientStock):
+			app.conflictResponse(w, r, "adjustment would take stock below zero")
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.queueWishlistAlertsAfterChange(r.Context(), before)
+
+	app.successResponse(w, http.StatusCreated, envelope{"movement": movement})
+}
+
+func (app *application) getProductInventoryMovements(w http.ResponseWriter, r *http.Request) {
+	var (
+		user      = getUserFromCtx(r)
+		productID = app.readStringID(r, "productID")
+	)
+
+	vendorUser, err := app.store.Users.GetVendorUserByID(r.Context(), user.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	fq := store.PaginateQueryFilter{
+		Page:         1,
+		PageSize:     20,
+		Sort:         "-created_at",
+		SortSafelist: []string{"created_at", "-created_at"},
+		Filters:      &modelfilter.GetInventoryMovementsFilter{},
+	}
+
+	if err := fq.Parse(r); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	movements, metadata, err := app.store.Inventory.GetMovements(r.Context(), productID, vendorUser.ID, fq)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.successResponse(w, http.StatusOK, envelope{
+		"movements": movements,
+		"metadata":  metadata,
+	})
+}
+
+func (app *application) setLowStockThreshold(w http.ResponseWriter, r *http.Request) {
+	var (
+		user      = getUserFromCtx(r)
+		productID = app.readStringID(r, "productID")
+		form      setLowStockThresholdRequest
+	)
+
+	if err := app.readJSON(w, r, &form); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := validate.Struct(form); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	vendorUser, err := app.store.Users.GetVendorUserByID(r.Context(), user.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if err := app.store.Inventory.SetLowStockThreshold(r.Context(), productID, vendorUser.ID, form.Threshold); err != nil {
+		switch {
+		case errors.Is(err, store.ErrRecordNotFound):
+			app.notFoundResponse(w, r, "product not found")
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	threshold := app.cfg.inventory.lowStockThreshold
+	if form.Threshold != nil {
+		threshold = *form.Threshold
+	}
+
+	app.successResponse(w, http.StatusOK, envelope{
+		"message":   "low stock threshold updated",
+		"id":        productID,
+		"threshold": threshold,
+		"default":   form.Threshold == nil,
+	})
+}
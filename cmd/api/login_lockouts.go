@@ -0,0 +1,245 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/devphaseX/buyr-api.git/internal/store"
+	"github.com/devphaseX/buyr-api.git/internal/store/cache"
+	"github.com/devphaseX/buyr-api.git/worker"
+	"github.com/hibiken/asynq"
+)
+
+// unlockTokenTTL is how long the link in a lockout email stays valid.
+const unlockTokenTTL = time.Hour * 24
+
+// loginBlocked rejects a sign-in attempt while the account is waiting out a
+// backoff delay or a lockout. It reports whether a response was written.
+func (app *application) loginBlocked(w http.ResponseWriter, r *http.Request, userID string) bool {
+	lockout, err := app.cacheStore.LoginAttempts.Get(r.Context(), userID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return true
+	}
+
+	if lockout.RetryAfter() > 0 {
+		app.accountLockedResponse(w, lockout)
+		return true
+	}
+
+	return false
+}
+
+// loginAttempt is a sign-in attempt counted as failed before its credentials
+// are checked, so that parallel guesses cannot all get past the lockout. An
+// attempt that ends without failing is given back by release.
+type loginAttempt struct {
+	app  *application
+	user *store.User
+	done bool
+}
+
+// reserveLoginAttempt counts an attempt against the account, or rejects it
+// while the account is waiting out a backoff delay or a lockout. It reports
+// whether the attempt may go ahead; otherwise a response was written.
+func (app *application) reserveLoginAttempt(w http.ResponseWriter, r *http.Request, user *store.User) (*loginAttempt, bool) {
+	lockout, reserved, err := app.cacheStore.LoginAttempts.Reserve(r.Context(), user.ID, user.Email, app.cfg.authConfig.lockout)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return nil, false
+	}
+
+	if !reserved {
+		app.accountLockedResponse(w, lockout)
+		return nil, false
+	}
+
+	return &loginAttempt{app: app, user: user}, true
+}
+
+// failed keeps a failed password, 2FA code or recovery code counted and
+// responds. The user is emailed a link to unlock the account when this
+// failure locked it.
+func (a *loginAttempt) failed(w http.ResponseWriter, r *http.Request, respond func()) {
+	a.done = true
+
+	lockout, err := a.app.cacheStore.LoginAttempts.Get(r.Context(), a.user.ID)
+	if err != nil {
+		a.app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if lockout.Locked && lockout.RetryAfter() > 0 {
+		notify, err := a.app.cacheStore.LoginAttempts.MarkLockNotified(r.Context(), a.user.ID)
+		if err != nil {
+			a.app.logger.Errorw("failed to mark account lockout notified", "user_id", a.user.ID, "error", err)
+		}
+
+		if notify {
+			if err := a.app.sendAccountLockedEmail(r.Context(), a.user, lockout); err != nil {
+				a.app.logger.Errorw("failed to send account locked email", "user_id", a.user.ID, "error", err)
+			}
+		}
+	}
+
+	if lockout.RetryAfter() > 0 {
+		a.app.accountLockedResponse(w, lockout)
+		return
+	}
+
+	respond()
+}
+
+// succeeded forgets the failed attempts of the account once it has fully
+// signed in.
+func (a *loginAttempt) succeeded(ctx context.Context) {
+	a.done = true
+	a.app.loginSucceeded(ctx, a.user.ID)
+}
+
+// release gives the attempt back unless it failed or completed the sign-in,
+// such as a correct password still waiting on a second factor. It is meant
+// to be deferred right after the attempt is reserved.
+func (a *loginAttempt) release(ctx context.Context) {
+	if a.done {
+		return
+	}
+
+	if err := a.app.cacheStore.LoginAttempts.Refund(ctx, a.user.ID); err != nil {
+		a.app.logger.Errorw("failed to refund login attempt", "user_id", a.user.ID, "error", err)
+	}
+}
+
+func (app *application) sendAccountLockedEmail(ctx context.Context, user *store.User, lockout *cache.LoginLockout) error {
+	token, err := app.cacheStore.Tokens.New(user.ID, unlockTokenTTL, cache.UnlockAccountTokenScope, nil)
+	if err != nil {
+		return err
+	}
+
+	if err := app.cacheStore.Tokens.Insert(ctx, token); err != nil {
+		return err
+	}
+
+	return app.taskDistributor.DistributeTaskSendAccountLockedEmail(ctx, &worker.PayloadSendAccountLockedEmail{
+		Username:    app.displayName(ctx, user),
+		Email:       user.Email,
+		Token:       token.Plaintext,
+		LockedUntil: *lockout.LockedUntil,
+		ClientURL:   app.cfg.clientURL,
+	}, asynq.MaxRetry(3), asynq.Queue(worker.QueueCritical))
+}
+
+// loginSucceeded forgets the failed attempts of an account once it has fully
+// signed in.
+func (app *application) loginSucceeded(ctx context.Context, userID string) {
+	if err := app.cacheStore.LoginAttempts.Clear(ctx, userID); err != nil {
+		app.logger.Errorw("failed to clear login failures", "user_id", userID, "error", err)
+	}
+}
+
+type unlockAccountForm struct {
+	Token string `json:"token" validate:"required"`
+}
+
+// unlockAccount lifts a lockout using the link emailed when it happened.
+func (app *application) unlockAccount(w http.ResponseWriter, r *http.Request) {
+	var form unlockAccountForm
+
+	if err := app.readJSON(w, r, &form); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := validate.Struct(form); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	token, err := app.cacheStore.Tokens.Get(r.Context(), cache.UnlockAccountTokenScope, form.Token)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrRecordNotFound):
+			app.unauthorizedResponse(w, r, "invalid or expired unlock token")
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if err := app.cacheStore.LoginAttempts.Clear(r.Context(), token.UserID); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if err := app.cacheStore.Tokens.DeleteAllForUser(r.Context(), cache.UnlockAccountTokenScope, token.UserID); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.successResponse(w, http.StatusOK, envelope{
+		"message": "account unlocked, you can sign in again",
+	})
+}
+
+func (app *application) getLoginLockouts(w http.ResponseWriter, r *http.Request) {
+	lockouts, err := app.cacheStore.LoginAttempts.GetLocked(r.Context())
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.successResponse(w, http.StatusOK, envelope{
+		"lockouts": lockouts,
+	})
+}
+
+func (app *application) getUserLoginLockout(w http.ResponseWriter, r *http.Request) {
+	lockout, err := app.cacheStore.LoginAttempts.Get(r.Context(), app.readStringID(r, "userID"))
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.successResponse(w, http.StatusOK, envelope{
+		"lockout": lockout,
+	})
+}
+
+func (app *application) clearLoginLockout(w http.ResponseWriter, r *http.Request) {
+	var (
+		admin  = getUserFromCtx(r)
+		userID = app.readStringID(r, "userID")
+	)
+
+	if err := app.cacheStore.LoginAttempts.Clear(r.Context(), userID); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if err := app.cacheStore.Tokens.DeleteAllForUser(r.Context(), cache.UnlockAccountTokenScope, userID); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.background(func() {
+		if err := app.store.AuditLogs.LogEvent(context.Background(), store.AuditEvent{
+			EventType:   store.LoginLockoutClearedAuditEventType,
+			AccountID:   userID,
+			PerformedBy: admin.AdminUser.ID,
+			Reason:      "login lockout cleared",
+			AccessLevel: store.AdminLevelSupport.GetRank(),
+			Timestamp:   time.Now().UTC(),
+			IPAddress:   r.RemoteAddr,
+			UserAgent:   r.UserAgent(),
+		}); err != nil {
+			app.logger.Errorw("failed to log audit event", "error", err)
+		}
+	})
+
+	app.successResponse(w, http.StatusOK, envelope{
+		"message": "login lockout cleared",
+		"user_id": userID,
+	})
+}
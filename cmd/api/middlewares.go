@@ -2,8 +2,11 @@ package main
 
 import (
 	"context"
+	"errors"
 	"net/http"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/devphaseX/buyr-api.git/internal/auth"
 	"github.com/devphaseX/buyr-api.git/internal/store"
@@ -15,11 +18,21 @@ type contextKey string
 
 const authContextKey contextKey = "auth"
 
+// apiKeyHeader carries the API key of requests made by a user's own systems
+const apiKeyHeader = "X-API-Key"
+
 // AuthMiddleware authenticates requests using a Bearer token or cookie
 func (app *application) AuthMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		// Extract the token from the Authorization header or cookie
 		w.Header().Add("Vary", "Authorization")
+		w.Header().Add("Vary", apiKeyHeader)
+
+		if key := r.Header.Get(apiKeyHeader); key != "" {
+			app.authenticateAPIKey(w, r, next, key)
+			return
+		}
+
 		token := extractToken(r, app)
 		if token == "" {
 			// Add the user to the request context
@@ -44,6 +57,19 @@ func (app *application) AuthMiddleware(next http.Handler) http.Handler {
 			return
 		}
 
+		if payload.IsClientToken() {
+			app.authenticateClientToken(w, r, next, payload)
+			return
+		}
+
+		// Reject tokens whose session was signed out or revoked
+		session, err := app.getSession(r.Context(), payload.SessionID)
+
+		if err != nil || session.UserID != payload.UserID || time.Now().After(session.ExpiresAt) {
+			app.unauthorizedResponse(w, r, "session has expired or been revoked")
+			return
+		}
+
 		// Fetch the user associated with the token
 		user, err := app.getUser(r.Context(), payload.UserID)
 
@@ -62,6 +88,7 @@ func (app *application) AuthMiddleware(next http.Handler) http.Handler {
 			user.AdminUser = adminUser
 		}
 
+		user.SessionID = session.ID
 		user.populateAdminFlags()
 		// Add the user to the request context
 		ctx := context.WithValue(r.Context(), authContextKey, user)
@@ -74,6 +101,98 @@ func (app *application) AuthMiddleware(next http.Handler) http.Handler {
 	})
 }
 
+// authenticateAPIKey authenticates a request made with an API key. Scopes are
+// enforced later by CheckPermissions.
+func (app *application) authenticateAPIKey(w http.ResponseWriter, r *http.Request, next http.Handler, key string) {
+	apiKey, err := app.store.APIKeys.GetByHash(r.Context(), store.HashAPIKey(key))
+
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrRecordNotFound):
+			app.unauthorizedResponse(w, r, "invalid API key")
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if apiKey.IsExpired() {
+		app.unauthorizedResponse(w, r, "API key has expired")
+		return
+	}
+
+	user, err := app.getUser(r.Context(), apiKey.UserID)
+
+	if err != nil {
+		app.unauthorizedResponse(w, r, "invalid API key")
+		return
+	}
+
+	if user.Role == store.AdminRole {
+		adminUser, err := app.store.Users.GetAdminUserByID(r.Context(), user.ID)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+		user.AdminUser = adminUser
+	}
+
+	user.APIKey = apiKey
+	user.populateAdminFlags()
+	ctx := context.WithValue(r.Context(), authContextKey, user)
+
+	app.background(func() {
+		app.store.APIKeys.RecordUse(context.Background(), apiKey.ID, r.RemoteAddr)
+	})
+	r.Header.Set("X-User-ID", user.ID)
+	next.ServeHTTP(w, r.WithContext(ctx))
+}
+
+// authenticateClientToken authenticates a request made by a third-party app
+// with an access token the user authorized. The token is only honoured while
+// its grant exists, so revoking the grant signs the app out at once.
+func (app *application) authenticateClientToken(w http.ResponseWriter, r *http.Request, next http.Handler, payload *auth.AccessPayload) {
+	grant, err := app.store.OAuthGrants.GetByID(r.Context(), payload.GrantID)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrRecordNotFound):
+			app.unauthorizedResponse(w, r, "access token has been revoked")
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if grant.UserID != payload.UserID || grant.ClientID != payload.ClientID {
+		app.unauthorizedResponse(w, r, "invalid authentication token")
+		return
+	}
+
+	user, err := app.getUser(r.Context(), payload.UserID)
+
+	if err != nil {
+		app.unauthorizedResponse(w, r, "invalid authentication token")
+		return
+	}
+
+	if user.Role == store.AdminRole {
+		adminUser, err := app.store.Users.GetAdminUserByID(r.Context(), user.ID)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+		user.AdminUser = adminUser
+	}
+
+	user.OAuthGrant = grant
+	user.populateAdminFlags()
+	ctx := context.WithValue(r.Context(), authContextKey, user)
+
+	r.Header.Set("X-User-ID", user.ID)
+	next.ServeHTTP(w, r.WithContext(ctx))
+}
+
 // extractToken extracts the token from the Authorization header or cookie
 func extractToken(r *http.Request, app *application) string {
 	// Try to get the token from the Authorization header
@@ -112,13 +231,28 @@ func (app *application) requireAuthenicatedUser(next http.Handler) http.Handler
 	})
 }
 
+// requireSession rejects requests authenticated with an API key or a
+// third-party app token, for routes that manage the account itself
+func (app *application) requireSession(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user := getUserFromCtx(r)
+
+		if user.IsScoped() {
+			app.forbiddenResponse(w, r, "this resource requires signing in, API keys and third-party apps are not accepted")
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
 func (app *application) loadCSRF(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		// Check if request is from a browser
 		isBrowser := isBrowserRequest(r)
 
 		// If not a browser request, skip CSRF
-		if !isBrowser {
+		if !isBrowser || csrfExempt(r.URL.Path) {
 			next.ServeHTTP(w, r)
 			return
 		}
@@ -143,6 +277,32 @@ func (app *application) loadCSRF(next http.Handler) http.Handler {
 	})
 }
 
+// csrfExemptPaths take form posts that authenticate the calling app rather
+// than the browser's cookies, so there is no session to forge a request with
+var csrfExemptPaths = []string{
+	"/v1/oauth/token",
+	"/v1/oauth/revoke",
+	"/v1/oauth/introspect",
+}
+
+// csrfExempt reports whether a form post is exempt from the CSRF token. Besides
+// csrfExemptPaths, the sign-in callbacks of providers that post them cross
+// site are exempt, as the state token and its cookie already tie the callback
+// to the browser that started the sign-in.
+func csrfExempt(path string) bool {
+	if slices.Contains(csrfExemptPaths, path) {
+		return true
+	}
+
+	provider, ok := strings.CutPrefix(path, "/v1/auth/oauth/")
+	if !ok {
+		return false
+	}
+
+	provider, ok = strings.CutSuffix(provider, "/callback")
+	return ok && provider != "" && !strings.Contains(provider, "/")
+}
+
 // Helper function to determine if request is from a browser
 func isBrowserRequest(r *http.Request) bool {
 	// Check User-Agent
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/devphaseX/buyr-api.git/internal/store"
+)
+
+func (app *application) getNotifications(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromCtx(r)
+
+	fq := store.PaginateQueryFilter{
+		Page:         1,
+		PageSize:     20,
+		Sort:         "-created_at",
+		SortSafelist: []string{"created_at", "-created_at"},
+	}
+
+	if err := fq.Parse(r); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	notifications, metadata, err := app.store.Notifications.GetByUserID(r.Context(), user.ID, fq)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.successResponse(w, http.StatusOK, envelope{
+		"notifications": notifications,
+		"metadata":      metadata,
+	})
+}
+
+func (app *application) markNotificationRead(w http.ResponseWriter, r *http.Request) {
+	var (
+		user           = getUserFromCtx(r)
+		notificationID = app.readStringID(r, "notificationID")
+	)
+
+	if err := app.store.Notifications.MarkAsRead(r.Context(), user.ID, notificationID); err != nil {
+		switch {
+		case errors.Is(err, store.ErrRecordNotFound):
+			app.notFoundResponse(w, r, "notification not found")
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.successResponse(w, http.StatusOK, envelope{
+		"message": "notification marked as read",
+		"id":      notificationID,
+	})
+}
@@ -6,152 +6,557 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
-	"github.com/devphaseX/buyr-api.git/internal/encrypt"
+	"github.com/devphaseX/buyr-api.git/internal/env"
+	"github.com/devphaseX/buyr-api.git/internal/oauth"
 	"github.com/devphaseX/buyr-api.git/internal/store"
+	"github.com/devphaseX/buyr-api.git/internal/store/cache"
 	"golang.org/x/oauth2"
 )
 
-func (app *application) signInWithProvider(w http.ResponseWriter, r *http.Request) {
-	state, err := encrypt.GenerateRandomString(32)
+const oauthStateCookie = "oauth_state"
+
+// oauthClientFromEnv reads the credentials of a provider from
+// <PREFIX>_CLIENT_ID, <PREFIX>_CLIENT_SECRET and the comma separated
+// <PREFIX>_SCOPES.
+func oauthClientFromEnv(prefix string) oauthClientConfig {
+	client := oauthClientConfig{
+		clientID:     env.GetString(prefix+"_CLIENT_ID", ""),
+		clientSecret: env.GetString(prefix+"_CLIENT_SECRET", ""),
+	}
+
+	if scopes := env.GetString(prefix+"_SCOPES", ""); scopes != "" {
+		client.scopes = strings.Split(scopes, ",")
+	}
+
+	return client
+}
+
+// oidcProvidersFromEnv reads the OpenID Connect providers named in the comma
+// separated OIDC_PROVIDERS. Each one is configured with OIDC_<NAME>_ISSUER
+// and the OIDC_<NAME>_ client variables.
+func oidcProvidersFromEnv() []oidcProviderConfig {
+	var providers []oidcProviderConfig
+
+	for _, name := range strings.Split(env.GetString("OIDC_PROVIDERS", ""), ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+
+		prefix := "OIDC_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+
+		providers = append(providers, oidcProviderConfig{
+			name:   name,
+			issuer: env.GetString(prefix+"_ISSUER", ""),
+			client: oauthClientFromEnv(prefix),
+		})
+	}
+
+	return providers
+}
+
+// newOAuthRegistry registers every provider with a configured client. The
+// redirect URL of each one is its callback route.
+func newOAuthRegistry(ctx context.Context, cfg config) (*oauth.Registry, error) {
+	registry := oauth.NewRegistry()
+
+	clientConfig := func(name string, client oauthClientConfig) oauth.Config {
+		return oauth.Config{
+			ClientID:     client.clientID,
+			ClientSecret: client.clientSecret,
+			RedirectURL:  fmt.Sprintf("%s/v1/auth/oauth/%s/callback", cfg.apiURL, name),
+			Scopes:       client.scopes,
+		}
+	}
+
+	if cfg.oauth.github.clientID != "" {
+		registry.Register(oauth.NewGitHub(clientConfig("github", cfg.oauth.github)))
+	}
+
+	if cfg.oauth.facebook.clientID != "" {
+		registry.Register(oauth.NewFacebook(clientConfig("facebook", cfg.oauth.facebook)))
+	}
+
+	discovered := []struct {
+		name   string
+		client oauthClientConfig
+		new    func(context.Context, oauth.Config) (oauth.Provider, error)
+	}{
+		{"google", cfg.oauth.google, oauth.NewGoogle},
+		{"apple", cfg.oauth.apple, oauth.NewApple},
+	}
+
+	for _, p := range discovered {
+		if p.client.clientID == "" {
+			continue
+		}
 
+		provider, err := p.new(ctx, clientConfig(p.name, p.client))
+		if err != nil {
+			return nil, err
+		}
+
+		registry.Register(provider)
+	}
+
+	for _, p := range cfg.oauth.oidc {
+		if p.issuer == "" || p.client.clientID == "" {
+			return nil, fmt.Errorf("oidc provider %q is missing an issuer or client id", p.name)
+		}
+
+		provider, err := oauth.NewOIDC(ctx, p.name, p.issuer, clientConfig(p.name, p.client))
+		if err != nil {
+			return nil, err
+		}
+
+		registry.Register(provider)
+	}
+
+	return registry, nil
+}
+
+// oauthStatePayload is kept in the state token between the redirect to the
+// provider and the callback.
+type oauthStatePayload struct {
+	Provider   string `json:"provider"`
+	Verifier   string `json:"verifier"`
+	Nonce      string `json:"nonce"`
+	RememberMe bool   `json:"remember_me"`
+}
+
+// beginOAuth stores the state and PKCE verifier of a new authorization
+// request and returns the provider URL to send the browser to. A non empty
+// userID marks the request as linking the provider to that user.
+func (app *application) beginOAuth(w http.ResponseWriter, r *http.Request, provider oauth.Provider, userID string, rememberMe bool) (string, error) {
+	var (
+		verifier = oauth.GenerateVerifier()
+		nonce    = oauth.GenerateNonce()
+	)
+
+	payload, err := json.Marshal(oauthStatePayload{
+		Provider:   provider.Name(),
+		Verifier:   verifier,
+		Nonce:      nonce,
+		RememberMe: rememberMe,
+	})
 	if err != nil {
-		app.serverErrorResponse(w, r, err)
-		return
+		return "", err
 	}
 
-	url := app.googleOauth.AuthCodeURL(state)
+	token, err := app.cacheStore.Tokens.New(userID, app.cfg.oauth.stateTTL, cache.OAuthStateTokenScope, payload)
+	if err != nil {
+		return "", err
+	}
+
+	if err := app.cacheStore.Tokens.Insert(r.Context(), token); err != nil {
+		return "", err
+	}
+
+	// A provider posting the callback cross site only gets the cookie back
+	// when it is SameSite=None, which browsers require to be secure
+	sameSite, secure := http.SameSiteLaxMode, app.cfg.env == "production"
+	if provider.FormPost() {
+		sameSite, secure = http.SameSiteNoneMode, true
+	}
 
 	http.SetCookie(w, &http.Cookie{
-		Name:     "oauth_state",
-		Value:    state,
-		Expires:  time.Now().Add(10 * time.Minute),
+		Name:     oauthStateCookie,
+		Value:    token.Plaintext,
+		Path:     "/",
+		Expires:  time.Now().Add(app.cfg.oauth.stateTTL),
 		HttpOnly: true,
-		Secure:   app.cfg.env == "production",
-		SameSite: http.SameSiteLaxMode,
+		Secure:   secure,
+		SameSite: sameSite,
+	})
+
+	return provider.AuthCodeURL(token.Plaintext, verifier, nonce), nil
+}
+
+func (app *application) oauthProviderFromURL(w http.ResponseWriter, r *http.Request) (oauth.Provider, bool) {
+	provider, err := app.oauthProviders.Get(app.readStringID(r, "provider"))
+	if err != nil {
+		app.notFoundResponse(w, r, "oauth provider not found")
+		return nil, false
+	}
+
+	return provider, true
+}
+
+func (app *application) getOAuthProviders(w http.ResponseWriter, r *http.Request) {
+	app.successResponse(w, http.StatusOK, envelope{
+		"providers": app.oauthProviders.Names(),
 	})
+}
+
+func (app *application) signInWithProvider(w http.ResponseWriter, r *http.Request) {
+	provider, ok := app.oauthProviderFromURL(w, r)
+	if !ok {
+		return
+	}
+
+	rememberMe, _ := strconv.ParseBool(r.FormValue("remember_me"))
+
+	url, err := app.beginOAuth(w, r, provider, "", rememberMe)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
 
 	http.Redirect(w, r, url, http.StatusTemporaryRedirect)
 }
 
-func (app *application) googleCallbackHandler(w http.ResponseWriter, r *http.Request) {
+func (app *application) oauthCallbackHandler(w http.ResponseWriter, r *http.Request) {
+	provider, ok := app.oauthProviderFromURL(w, r)
+	if !ok {
+		return
+	}
+
+	if reason := r.FormValue("error"); reason != "" {
+		app.badRequestResponse(w, r, fmt.Errorf("authorization failed: %s", reason))
+		return
+	}
+
 	// Retrieve the state from the cookie
-	secretState, err := r.Cookie("oauth_state")
+	secretState, err := r.Cookie(oauthStateCookie)
 	if err != nil {
 		app.badRequestResponse(w, r, errors.New("missing OAuth state cookie"))
 		return
 	}
 
-	state := r.URL.Query().Get("state")
+	state := r.FormValue("state")
 	if state != secretState.Value {
 		app.badRequestResponse(w, r, errors.New("invalid OAuth state"))
 		return
 	}
 
-	code := r.URL.Query().Get("code")
-	token, err := app.googleOauth.Exchange(r.Context(), code)
+	stateToken, err := app.cacheStore.Tokens.Get(r.Context(), cache.OAuthStateTokenScope, state)
+	if err != nil {
+		app.badRequestResponse(w, r, errors.New("invalid or expired OAuth state"))
+		return
+	}
+
+	if err := app.cacheStore.Tokens.Delete(r.Context(), stateToken); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:   oauthStateCookie,
+		Path:   "/",
+		MaxAge: -1,
+	})
+
+	var payload oauthStatePayload
+	if err := json.Unmarshal(stateToken.Data, &payload); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if payload.Provider != provider.Name() {
+		app.badRequestResponse(w, r, errors.New("invalid OAuth state"))
+		return
+	}
+
+	token, err := provider.Exchange(r.Context(), r.FormValue("code"), payload.Verifier)
 	if err != nil {
 		app.serverErrorResponse(w, r, fmt.Errorf("code exchange failed: %w", err))
 		return
 	}
 
-	userData, err := app.fetchUserData(r.Context(), token)
+	userData, err := provider.UserInfo(r.Context(), token, payload.Nonce)
 	if err != nil {
-		app.serverErrorResponse(w, r, fmt.Errorf("failed to fetch user data: %v", err))
+		switch {
+		case errors.Is(err, oauth.ErrNonceMismatch):
+			app.badRequestResponse(w, r, err)
+		default:
+			app.serverErrorResponse(w, r, fmt.Errorf("failed to fetch user data: %w", err))
+		}
 		return
 	}
 
-	user, err := app.store.Users.GetByEmail(r.Context(), userData.Email)
+	account := newOAuthAccount(provider.Name(), userData, token)
+
+	if stateToken.UserID != "" {
+		app.linkOAuthAccount(w, r, stateToken.UserID, account)
+		return
+	}
+
+	user, err := app.oauthUser(r.Context(), userData, account)
 	if err != nil {
-		if errors.Is(err, store.ErrRecordNotFound) {
+		switch {
+		case errors.Is(err, errOAuthEmailMissing):
+			app.badRequestResponse(w, r, err)
+		case errors.Is(err, errOAuthEmailUnverified):
+			app.conflictResponse(w, r, fmt.Sprintf("an account with this email already exists. Sign in and link your %s account from your settings.", account.Provider))
+		case errors.Is(err, errOAuthSignUpUnverified):
+			app.forbiddenResponse(w, r, fmt.Sprintf("%s has not verified this email address. Register with your email, then link your %s account from your settings.", account.Provider, account.Provider))
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
 
-			var (
-				names           = strings.Fields(userData.Name)
-				emailVerifiedAt = time.Now()
-			)
+	if app.loginBlocked(w, r, user.ID) {
+		return
+	}
 
-			normalUser := &store.NormalUser{
-				FirstName: names[0],
-				LastName:  names[1],
-				User: store.User{
-					Email:     userData.Email, // Use userData.Email
-					Role:      store.UserRole,
-					AvatarURL: userData.Image,
-					IsActive:  true,
-				},
-			}
+	account.UserID = user.ID
 
-			if userData.VerifiedEmail {
-				normalUser.User.EmailVerifiedAt = &emailVerifiedAt
-			}
+	err = app.store.Users.UpsertAccount(r.Context(), account)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrDuplicateAccount):
+			app.conflictResponse(w, r, fmt.Sprintf("a different %s account is already linked to this user", account.Provider))
+		default:
+			app.serverErrorResponse(w, r, fmt.Errorf("failed to upsert account: %v", err))
+		}
+		return
+	}
+
+	// Only an address the provider vouches for can activate the account
+	if user.EmailVerifiedAt == nil && userData.EmailVerified && strings.EqualFold(user.Email, userData.Email) {
+		err = app.store.Users.SetUserAccountAsActivate(r.Context(), user)
 
-			err := app.store.Users.CreateNormalUser(r.Context(), normalUser)
-			if err != nil {
-				app.serverErrorResponse(w, r, fmt.Errorf("failed to create user: %v", err))
-				return
-			}
+		if err != nil {
+			app.serverErrorResponse(w, r, fmt.Errorf("failed to upsert account: %v", err))
+			return
+		}
+	}
 
-			user = &normalUser.User
-		} else {
-			app.serverErrorResponse(w, r, fmt.Errorf("failed to fetch user: %v", err))
+	// Like a password sign-in, an address nobody confirmed gets no session
+	if user.EmailVerifiedAt == nil {
+		if err := app.sendAccountActivationEmail(r.Context(), user); err != nil {
+			app.serverErrorResponse(w, r, err)
 			return
 		}
+
+		app.forbiddenResponse(w, r, "Account not activated. A new activation email has been sent to your email address.")
+		return
+	}
+
+	app.completeSignIn(w, r, user, payload.RememberMe)
+}
+
+var (
+	errOAuthEmailMissing     = errors.New("the provider did not share an email address for this account")
+	errOAuthEmailUnverified  = errors.New("the provider has not verified the email address of this account")
+	errOAuthSignUpUnverified = errors.New("accounts can only be created from an email address the provider verified")
+)
+
+// oauthUser resolves the user signing in with a provider account: the user
+// it is already linked to, an existing user with the same email, or a new
+// customer. Matching an existing user or creating one both need the provider
+// to have verified the address. Otherwise anyone could claim an account by
+// registering its email with a provider, or create one in someone else's
+// name before they sign up, keeping access through the linked provider.
+func (app *application) oauthUser(ctx context.Context, userData *oauth.UserInfo, account *store.Account) (*store.User, error) {
+	linked, err := app.store.Users.GetAccountByProvider(ctx, account.Provider, account.ProviderAccountID)
+	if err == nil {
+		return app.store.Users.GetByID(ctx, linked.UserID)
+	}
+
+	if !errors.Is(err, store.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	if userData.Email == "" {
+		return nil, errOAuthEmailMissing
+	}
+
+	user, err := app.store.Users.GetByEmail(ctx, userData.Email)
+	if err == nil {
+		if !userData.EmailVerified {
+			return nil, errOAuthEmailUnverified
+		}
+
+		return user, nil
+	}
+
+	if !errors.Is(err, store.ErrRecordNotFound) {
+		return nil, fmt.Errorf("failed to fetch user: %w", err)
+	}
+
+	if !userData.EmailVerified {
+		return nil, errOAuthSignUpUnverified
 	}
 
+	emailVerifiedAt := time.Now()
+	firstName, lastName, _ := strings.Cut(strings.TrimSpace(userData.Name), " ")
+
+	normalUser := &store.NormalUser{
+		FirstName: firstName,
+		LastName:  strings.TrimSpace(lastName),
+		User: store.User{
+			Email:           userData.Email,
+			Role:            store.UserRole,
+			AvatarURL:       userData.Image,
+			IsActive:        true,
+			EmailVerifiedAt: &emailVerifiedAt,
+		},
+	}
+
+	if err := app.store.Users.CreateNormalUser(ctx, normalUser); err != nil {
+		return nil, fmt.Errorf("failed to create user: %w", err)
+	}
+
+	return &normalUser.User, nil
+}
+
+func newOAuthAccount(provider string, userData *oauth.UserInfo, token *oauth2.Token) *store.Account {
 	account := &store.Account{
-		UserID:            user.ID,
-		Provider:          "google",
+		Provider:          provider,
 		ProviderAccountID: userData.ID,
 		Type:              "oauth",
 		AccessToken:       token.AccessToken,
 		RefreshToken:      token.RefreshToken,
 		TokenType:         token.TokenType,
-		ExpiresAt:         token.Expiry.Unix(),
-		Scope:             fmt.Sprintf("%s", token.Extra("scope")),
 	}
 
-	err = app.store.Users.UpsertAccount(r.Context(), account)
+	if !token.Expiry.IsZero() {
+		account.ExpiresAt = token.Expiry.Unix()
+	}
+
+	if scope, ok := token.Extra("scope").(string); ok {
+		account.Scope = scope
+	}
+
+	return account
+}
+
+// linkOAuthAccount attaches a provider account to the signed in user who
+// started the authorization request.
+func (app *application) linkOAuthAccount(w http.ResponseWriter, r *http.Request, userID string, account *store.Account) {
+	linked, err := app.store.Users.GetAccountByProvider(r.Context(), account.Provider, account.ProviderAccountID)
+
+	if !(err == nil || errors.Is(err, store.ErrRecordNotFound)) {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if linked != nil && linked.UserID != userID {
+		app.conflictResponse(w, r, fmt.Sprintf("this %s account is linked to another user", account.Provider))
+		return
+	}
+
+	account.UserID = userID
+
+	if err := app.store.Users.UpsertAccount(r.Context(), account); err != nil {
+		switch {
+		case errors.Is(err, store.ErrDuplicateAccount):
+			app.conflictResponse(w, r, fmt.Sprintf("a different %s account is already linked", account.Provider))
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.successResponse(w, http.StatusOK, envelope{
+		"message": fmt.Sprintf("%s account linked", account.Provider),
+		"account": account,
+	})
+}
+
+func (app *application) getLinkedAccounts(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromCtx(r)
+
+	accounts, err := app.store.Users.GetUserAccounts(r.Context(), user.ID)
 	if err != nil {
-		app.serverErrorResponse(w, r, fmt.Errorf("failed to upsert account: %v", err))
+		app.serverErrorResponse(w, r, err)
 		return
 	}
 
-	if user.EmailVerifiedAt == nil {
-		err = app.store.Users.SetUserAccountAsActivate(r.Context(), user)
+	app.successResponse(w, http.StatusOK, envelope{
+		"accounts": accounts,
+	})
+}
 
-		if err != nil {
-			app.serverErrorResponse(w, r, fmt.Errorf("failed to upsert account: %v", err))
+// linkAccount starts an authorization request whose callback links the
+// provider account to the signed in user. The URL is returned rather than
+// followed so it works for clients using bearer tokens.
+func (app *application) linkAccount(w http.ResponseWriter, r *http.Request) {
+	provider, ok := app.oauthProviderFromURL(w, r)
+	if !ok {
+		return
+	}
+
+	user := getUserFromCtx(r)
+
+	accounts, err := app.store.Users.GetUserAccounts(r.Context(), user.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	for _, account := range accounts {
+		if account.Provider == provider.Name() {
+			app.conflictResponse(w, r, fmt.Sprintf("a %s account is already linked", provider.Name()))
 			return
 		}
 	}
 
-	app.createUserSessionAndSetCookies(w, r, user, true)
+	url, err := app.beginOAuth(w, r, provider, user.ID, false)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
 
+	app.successResponse(w, http.StatusOK, envelope{
+		"authorization_url": url,
+	})
 }
 
-type UserData struct {
-	ID            string `json:"id"`
-	Name          string `json:"name"`
-	Email         string `json:"email"`
-	Image         string `json:"picture"`
-	VerifiedEmail bool   `json:"verified_email"`
-}
+// unlinkAccount removes a provider account from the signed in user. The last
+// way to sign in cannot be removed: the user must keep a password, another
+// linked account or a passkey.
+func (app *application) unlinkAccount(w http.ResponseWriter, r *http.Request) {
+	provider := app.readStringID(r, "provider")
 
-func (app *application) fetchUserData(ctx context.Context, token *oauth2.Token) (*UserData, error) {
-	client := app.googleOauth.Client(ctx, token)
+	user, err := app.getUser(r.Context(), getUserFromCtx(r).ID, true)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	accounts, err := app.store.Users.GetUserAccounts(r.Context(), user.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
 
-	resp, err := client.Get("https://www.googleapis.com/oauth2/v2/userinfo")
+	credentials, err := app.store.WebAuthnCredentials.GetByUserID(r.Context(), user.ID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to fetch user data: %v", err)
+		app.serverErrorResponse(w, r, err)
+		return
 	}
-	defer resp.Body.Close()
 
-	var userData UserData
-	if err := json.NewDecoder(resp.Body).Decode(&userData); err != nil {
-		return nil, fmt.Errorf("failed to parse user data: %v", err)
+	otherAccounts := 0
+	for _, account := range accounts {
+		if account.Provider != provider {
+			otherAccounts++
+		}
 	}
 
-	return &userData, nil
+	if user.Password.IsSetPasswordEmpty() && otherAccounts == 0 && len(credentials) == 0 {
+		app.forbiddenResponse(w, r, "set a password before unlinking your only sign-in method")
+		return
+	}
+
+	if err := app.store.Users.DeleteAccount(r.Context(), user.ID, provider); err != nil {
+		switch {
+		case errors.Is(err, store.ErrRecordNotFound):
+			app.notFoundResponse(w, r, "linked account not found")
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.successResponse(w, http.StatusOK, envelope{
+		"message":  fmt.Sprintf("%s account unlinked", provider),
+		"provider": provider,
+	})
 }
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+
+	"github.com/devphaseX/buyr-api.git/internal/store"
+)
+
+// maxOAuthClientsPerUser keeps abandoned app registrations from piling up
+const maxOAuthClientsPerUser = 10
+
+type registerOAuthClientForm struct {
+	Name         string              `json:"name" validate:"required,min=1,max=100"`
+	WebsiteURL   *string             `json:"website_url" validate:"omitempty,url"`
+	RedirectURIs []string            `json:"redirect_uris" validate:"required,min=1,max=10,dive,required"`
+	Scopes       []store.APIKeyScope `json:"scopes" validate:"required,min=1,dive,required"`
+	// Public registers an app that cannot keep a secret, such as a mobile or
+	// single page app. It gets no secret and has to use PKCE.
+	Public bool `json:"public"`
+}
+
+// validateRedirectURI accepts https URIs, http only on the loopback address
+// for local development, and custom schemes for apps installed on a device
+func validateRedirectURI(value string, public bool) error {
+	uri, err := url.Parse(value)
+	if err != nil || uri.Scheme == "" {
+		return fmt.Errorf("redirect uri '%s' is not a valid absolute URI", value)
+	}
+
+	if uri.Fragment != "" {
+		return fmt.Errorf("redirect uri '%s' must not contain a fragment", value)
+	}
+
+	switch uri.Scheme {
+	case "https":
+		if uri.Host == "" {
+			return fmt.Errorf("redirect uri '%s' has no host", value)
+		}
+	case "http":
+		if ip := net.ParseIP(uri.Hostname()); uri.Hostname() != "localhost" && (ip == nil || !ip.IsLoopback()) {
+			return fmt.Errorf("redirect uri '%s' must use https", value)
+		}
+	default:
+		if !public {
+			return fmt.Errorf("redirect uri '%s' must use https", value)
+		}
+	}
+
+	return nil
+}
+
+// registerOAuthClient registers a third-party app. The secret of a
+// confidential app is only part of this response.
+func (app *application) registerOAuthClient(w http.ResponseWriter, r *http.Request) {
+	var form registerOAuthClientForm
+	user := getUserFromCtx(r)
+
+	if err := app.readJSON(w, r, &form); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := validate.Struct(form); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	for _, uri := range form.RedirectURIs {
+		if err := validateRedirectURI(uri, form.Public); err != nil {
+			app.badRequestResponse(w, r, err)
+			return
+		}
+	}
+
+	for _, scope := range form.Scopes {
+		if !slices.Contains(store.APIKeyScopes, scope) {
+			app.badRequestResponse(w, r, fmt.Errorf("unknown scope '%s'", scope))
+			return
+		}
+	}
+
+	clients, err := app.store.OAuthClients.GetByUserID(r.Context(), user.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if len(clients) >= maxOAuthClientsPerUser {
+		app.conflictResponse(w, r, fmt.Sprintf("you can register at most %d apps, delete one first", maxOAuthClientsPerUser))
+		return
+	}
+
+	client := &store.OAuthClient{
+		UserID:       user.ID,
+		Name:         form.Name,
+		WebsiteURL:   form.WebsiteURL,
+		RedirectURIs: slices.Compact(slices.Sorted(slices.Values(form.RedirectURIs))),
+		Scopes:       slices.Compact(slices.Sorted(slices.Values(form.Scopes))),
+	}
+
+	var secret string
+	if !form.Public {
+		secret, client.SecretHash, err = store.GenerateClientSecret()
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+	}
+
+	if err := app.store.OAuthClients.Create(r.Context(), client); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	response := envelope{
+		"client": client,
+	}
+
+	if secret != "" {
+		response["client_secret"] = secret
+		response["message"] = "store the client secret safely, it will not be shown again"
+	}
+
+	app.successResponse(w, http.StatusCreated, response)
+}
+
+func (app *application) getOAuthClients(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromCtx(r)
+
+	clients, err := app.store.OAuthClients.GetByUserID(r.Context(), user.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.successResponse(w, http.StatusOK, envelope{
+		"clients": clients,
+	})
+}
+
+// rotateOAuthClientSecret replaces the secret of a confidential app. Tokens
+// already issued to the app stay valid.
+func (app *application) rotateOAuthClientSecret(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromCtx(r)
+	clientID := app.readStringID(r, "clientID")
+
+	secret, secretHash, err := store.GenerateClientSecret()
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.store.OAuthClients.UpdateSecret(r.Context(), clientID, user.ID, secretHash)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrRecordNotFound):
+			app.notFoundResponse(w, r, "confidential app not found")
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.successResponse(w, http.StatusOK, envelope{
+		"id":            clientID,
+		"client_secret": secret,
+		"message":       "store the client secret safely, it will not be shown again",
+	})
+}
+
+// deleteOAuthClient removes an app along with every grant users gave it
+func (app *application) deleteOAuthClient(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromCtx(r)
+	clientID := app.readStringID(r, "clientID")
+
+	err := app.store.OAuthClients.Delete(r.Context(), clientID, user.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrRecordNotFound):
+			app.notFoundResponse(w, r, "app not found")
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.successResponse(w, http.StatusOK, envelope{
+		"message": "app deleted",
+		"id":      clientID,
+	})
+}
+
+// getAuthorizedApps lists the third-party apps the user has authorized
+func (app *application) getAuthorizedApps(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromCtx(r)
+
+	grants, err := app.store.OAuthGrants.GetByUserID(r.Context(), user.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.successResponse(w, http.StatusOK, envelope{
+		"apps": grants,
+	})
+}
+
+// revokeAuthorizedApp signs a third-party app out of the user's account
+func (app *application) revokeAuthorizedApp(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromCtx(r)
+	clientID := app.readStringID(r, "clientID")
+
+	err := app.store.OAuthGrants.DeleteForClient(r.Context(), user.ID, clientID)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrRecordNotFound):
+			app.notFoundResponse(w, r, "authorized app not found")
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.successResponse(w, http.StatusOK, envelope{
+		"message":   "app access revoked",
+		"client_id": clientID,
+	})
+}
@@ -0,0 +1,619 @@
+package main
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/devphaseX/buyr-api.git/internal/store"
+	"github.com/devphaseX/buyr-api.git/internal/store/cache"
+)
+
+// scopeDescriptions explain each scope on the consent screen
+var scopeDescriptions = map[store.APIKeyScope]string{
+	store.ProductsReadScope:   "View your products",
+	store.ProductsWriteScope:  "Create, update and publish your products",
+	store.InventoryReadScope:  "View your stock levels, movements and warehouses",
+	store.InventoryWriteScope: "Adjust your stock and manage your warehouses",
+	store.OrdersReadScope:     "View your orders",
+}
+
+// authorizationRequest is the authorization request a third-party app sends
+// the user to the consent screen with
+type authorizationRequest struct {
+	ResponseType        string `json:"response_type"`
+	ClientID            string `json:"client_id"`
+	RedirectURI         string `json:"redirect_uri"`
+	Scope               string `json:"scope"`
+	State               string `json:"state"`
+	CodeChallenge       string `json:"code_challenge"`
+	CodeChallengeMethod string `json:"code_challenge_method"`
+}
+
+type authorizationDecision struct {
+	authorizationRequest
+	Approve bool `json:"approve"`
+}
+
+// oauthCodePayload is kept with an authorization code until the app
+// exchanges it for tokens
+type oauthCodePayload struct {
+	ClientID      string              `json:"client_id"`
+	RedirectURI   string              `json:"redirect_uri"`
+	Scopes        []store.APIKeyScope `json:"scopes"`
+	CodeChallenge string              `json:"code_challenge"`
+}
+
+// checkAuthorizationRequest validates an authorization request against the
+// app's registration and returns the scopes it asks for, all of the app's
+// scopes when none are named. Problems are reported to the consent screen
+// rather than redirected, as the redirect uri may be the problem.
+func (app *application) checkAuthorizationRequest(w http.ResponseWriter, r *http.Request, req *authorizationRequest) (*store.OAuthClient, []store.APIKeyScope, bool) {
+	client, err := app.store.OAuthClients.GetByID(r.Context(), req.ClientID)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrRecordNotFound):
+			app.badRequestResponse(w, r, errors.New("unknown client_id"))
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return nil, nil, false
+	}
+
+	if !slices.Contains(client.RedirectURIs, req.RedirectURI) {
+		app.badRequestResponse(w, r, errors.New("redirect_uri is not registered for this app"))
+		return nil, nil, false
+	}
+
+	if req.ResponseType != "code" {
+		app.badRequestResponse(w, r, errors.New("response_type must be code"))
+		return nil, nil, false
+	}
+
+	if req.CodeChallengeMethod != "S256" || len(req.CodeChallenge) != 43 {
+		app.badRequestResponse(w, r, errors.New("a PKCE code_challenge with the S256 method is required"))
+		return nil, nil, false
+	}
+
+	scopes := store.ParseScopes(req.Scope)
+	if len(scopes) == 0 {
+		scopes = client.Scopes
+	}
+
+	for _, scope := range scopes {
+		if !client.HasScopes(scope) {
+			app.badRequestResponse(w, r, fmt.Errorf("scope '%s' is not allowed for this app", scope))
+			return nil, nil, false
+		}
+	}
+
+	return client, slices.Compact(slices.Sorted(slices.Values(scopes))), true
+}
+
+// getAuthorizationConsent returns what the consent screen shows the signed in
+// user: the app asking for access and what it would be allowed to do
+func (app *application) getAuthorizationConsent(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	req := authorizationRequest{
+		ResponseType:        query.Get("response_type"),
+		ClientID:            query.Get("client_id"),
+		RedirectURI:         query.Get("redirect_uri"),
+		Scope:               query.Get("scope"),
+		State:               query.Get("state"),
+		CodeChallenge:       query.Get("code_challenge"),
+		CodeChallengeMethod: query.Get("code_challenge_method"),
+	}
+
+	client, scopes, ok := app.checkAuthorizationRequest(w, r, &req)
+	if !ok {
+		return
+	}
+
+	requested := make([]envelope, 0, len(scopes))
+	for _, scope := range scopes {
+		requested = append(requested, envelope{
+			"scope":       scope,
+			"description": scopeDescriptions[scope],
+		})
+	}
+
+	app.successResponse(w, http.StatusOK, envelope{
+		"client": envelope{
+			"id":          client.ID,
+			"name":        client.Name,
+			"website_url": client.WebsiteURL,
+		},
+		"scopes":       requested,
+		"redirect_uri": req.RedirectURI,
+		"state":        req.State,
+	})
+}
+
+// authorizeClient records the user's answer on the consent screen. The
+// response names where to send the user back to the app, with an
+// authorization code when access was approved.
+func (app *application) authorizeClient(w http.ResponseWriter, r *http.Request) {
+	var form authorizationDecision
+	user := getUserFromCtx(r)
+
+	if err := app.readJSON(w, r, &form); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	client, scopes, ok := app.checkAuthorizationRequest(w, r, &form.authorizationRequest)
+	if !ok {
+		return
+	}
+
+	params := url.Values{}
+	if form.State != "" {
+		params.Set("state", form.State)
+	}
+
+	if !form.Approve {
+		params.Set("error", "access_denied")
+		params.Set("error_description", "the user denied the request")
+
+		app.successResponse(w, http.StatusOK, envelope{
+			"redirect_to": redirectWithParams(form.RedirectURI, params),
+		})
+		return
+	}
+
+	payload, err := json.Marshal(oauthCodePayload{
+		ClientID:      client.ID,
+		RedirectURI:   form.RedirectURI,
+		Scopes:        scopes,
+		CodeChallenge: form.CodeChallenge,
+	})
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	code, err := app.cacheStore.Tokens.New(user.ID, app.cfg.oauthServer.codeTTL, cache.OAuthCodeTokenScope, payload)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if err := app.cacheStore.Tokens.Insert(r.Context(), code); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	params.Set("code", code.Plaintext)
+
+	app.successResponse(w, http.StatusOK, envelope{
+		"redirect_to": redirectWithParams(form.RedirectURI, params),
+	})
+}
+
+func redirectWithParams(redirectURI string, params url.Values) string {
+	uri, _ := url.Parse(redirectURI)
+
+	query := uri.Query()
+	for key, values := range params {
+		query[key] = values
+	}
+
+	uri.RawQuery = query.Encode()
+	return uri.String()
+}
+
+// oauthErrorResponse writes an error in the format OAuth clients expect from
+// the token, revocation and introspection endpoints
+func (app *application) oauthErrorResponse(w http.ResponseWriter, status int, code, description string) {
+	err := app.writeJSON(w, status, envelope{
+		"error":             code,
+		"error_description": description,
+	}, noStoreHeaders())
+
+	if err != nil {
+		app.logger.Info("Failed to write JSON response:", err)
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+func noStoreHeaders() http.Header {
+	return http.Header{
+		"Cache-Control": []string{"no-store"},
+		"Pragma":        []string{"no-cache"},
+	}
+}
+
+// authenticateOAuthClient reads the form of a token, revocation or
+// introspection request and identifies the app making it. Confidential apps
+// authenticate with their secret, through HTTP basic auth or the form, public
+// apps only name their client_id.
+func (app *application) authenticateOAuthClient(w http.ResponseWriter, r *http.Request) (*store.OAuthClient, bool) {
+	r.Body = http.MaxBytesReader(w, r.Body, 1_048_578)
+
+	if err := r.ParseForm(); err != nil {
+		app.oauthErrorResponse(w, http.StatusBadRequest, "invalid_request", "the request body must be form encoded")
+		return nil, false
+	}
+
+	clientID, clientSecret, basic := r.BasicAuth()
+	if basic {
+		clientID, _ = url.QueryUnescape(clientID)
+		clientSecret, _ = url.QueryUnescape(clientSecret)
+	} else {
+		clientID, clientSecret = r.PostForm.Get("client_id"), r.PostForm.Get("client_secret")
+	}
+
+	client, err := app.store.OAuthClients.GetByID(r.Context(), clientID)
+	if err != nil && !errors.Is(err, store.ErrRecordNotFound) {
+		app.serverErrorResponse(w, r, err)
+		return nil, false
+	}
+
+	authenticated := client != nil && (client.IsConfidential() && client.VerifySecret(clientSecret) ||
+		!client.IsConfidential() && clientSecret == "")
+
+	if !authenticated {
+		if basic {
+			w.Header().Set("WWW-Authenticate", `Basic realm="oauth"`)
+		}
+		app.oauthErrorResponse(w, http.StatusUnauthorized, "invalid_client", "client authentication failed")
+		return nil, false
+	}
+
+	return client, true
+}
+
+// issueOAuthToken is the token endpoint. It exchanges authorization codes and
+// refresh tokens for new tokens.
+func (app *application) issueOAuthToken(w http.ResponseWriter, r *http.Request) {
+	client, ok := app.authenticateOAuthClient(w, r)
+	if !ok {
+		return
+	}
+
+	switch r.PostForm.Get("grant_type") {
+	case "authorization_code":
+		app.exchangeAuthorizationCode(w, r, client)
+	case "refresh_token":
+		app.refreshOAuthGrant(w, r, client)
+	default:
+		app.oauthErrorResponse(w, http.StatusBadRequest, "unsupported_grant_type",
+			"grant_type must be authorization_code or refresh_token")
+	}
+}
+
+func (app *application) exchangeAuthorizationCode(w http.ResponseWriter, r *http.Request, client *store.OAuthClient) {
+	// Codes are single use, whatever the outcome of the exchange
+	code, err := app.cacheStore.Tokens.Consume(r.Context(), cache.OAuthCodeTokenScope, r.PostForm.Get("code"))
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrRecordNotFound):
+			app.revokeReplayedAuthorizationCode(r, r.PostForm.Get("code"))
+			app.oauthErrorResponse(w, http.StatusBadRequest, "invalid_grant", "authorization code is invalid or expired")
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	var payload oauthCodePayload
+	if err := json.Unmarshal(code.Data, &payload); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if payload.ClientID != client.ID || payload.RedirectURI != r.PostForm.Get("redirect_uri") {
+		app.oauthErrorResponse(w, http.StatusBadRequest, "invalid_grant", "authorization code was not issued for this request")
+		return
+	}
+
+	if !verifyCodeChallenge(r.PostForm.Get("code_verifier"), payload.CodeChallenge) {
+		app.oauthErrorResponse(w, http.StatusBadRequest, "invalid_grant", "code_verifier does not match the code challenge")
+		return
+	}
+
+	grant := &store.OAuthGrant{
+		ClientID:  client.ID,
+		UserID:    code.UserID,
+		Scopes:    payload.Scopes,
+		ExpiresAt: time.Now().Add(app.cfg.oauthServer.refreshTokenTTL),
+	}
+
+	if err := app.store.OAuthGrants.Create(r.Context(), grant); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	recorded, err := app.cacheStore.Tokens.RecordConsumer(r.Context(), code, grant.ID)
+	if err != nil || !recorded {
+		// the code was presented again while the grant was being created
+		if revokeErr := app.store.OAuthGrants.Delete(r.Context(), grant.ID); revokeErr != nil {
+			app.logger.Errorw("failed to revoke oauth grant of a replayed code", "grant_id", grant.ID, "error", revokeErr)
+		}
+
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
+		app.oauthErrorResponse(w, http.StatusBadRequest, "invalid_grant", "authorization code has already been used")
+		return
+	}
+
+	app.writeOAuthTokens(w, r, grant)
+}
+
+// revokeReplayedAuthorizationCode revokes the grant issued for an
+// authorization code that is presented again, as someone other than the app
+// may hold a copy of it (RFC 6749 section 4.1.2).
+func (app *application) revokeReplayedAuthorizationCode(r *http.Request, code string) {
+	grantID, err := app.cacheStore.Tokens.TakeConsumer(r.Context(), cache.OAuthCodeTokenScope, code)
+	if err != nil {
+		if !errors.Is(err, store.ErrRecordNotFound) {
+			app.logger.Errorw("failed to look up replayed authorization code", "error", err)
+		}
+		return
+	}
+
+	if grantID == "" {
+		return
+	}
+
+	if err := app.store.OAuthGrants.Delete(r.Context(), grantID); err != nil {
+		app.logger.Errorw("failed to revoke oauth grant of a replayed code", "grant_id", grantID, "error", err)
+	}
+}
+
+// verifyCodeChallenge checks a PKCE verifier against the S256 challenge sent
+// with the authorization request
+func verifyCodeChallenge(verifier, challenge string) bool {
+	if len(verifier) < 43 || len(verifier) > 128 {
+		return false
+	}
+
+	sum := sha256.Sum256([]byte(verifier))
+	computed := base64.RawURLEncoding.EncodeToString(sum[:])
+
+	return subtle.ConstantTimeCompare([]byte(computed), []byte(challenge)) == 1
+}
+
+// refreshOAuthGrant rotates the refresh token of a grant. Presenting a
+// refresh token that was already rotated revokes the grant, as someone else
+// holds a copy of it.
+func (app *application) refreshOAuthGrant(w http.ResponseWriter, r *http.Request, client *store.OAuthClient) {
+	claims, err := app.authToken.ValidateRefreshToken(r.PostForm.Get("refresh_token"))
+	if err != nil || !claims.IsClientToken() || claims.Valid() != nil || claims.ClientID != client.ID {
+		app.oauthErrorResponse(w, http.StatusBadRequest, "invalid_grant", "refresh token is invalid or expired")
+		return
+	}
+
+	grant, err := app.store.OAuthGrants.GetByID(r.Context(), claims.SessionID)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrRecordNotFound):
+			app.oauthErrorResponse(w, http.StatusBadRequest, "invalid_grant", "refresh token has been revoked")
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if grant.ClientID != client.ID || grant.IsExpired() || claims.Version > grant.Version {
+		app.oauthErrorResponse(w, http.StatusBadRequest, "invalid_grant", "refresh token is invalid or expired")
+		return
+	}
+
+	if claims.Version == grant.Version {
+		err = app.store.OAuthGrants.Rotate(r.Context(), grant, time.Now().Add(app.cfg.oauthServer.refreshTokenTTL))
+	} else {
+		err = store.ErrRefreshTokenReused
+	}
+
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrRefreshTokenReused):
+			if err := app.store.OAuthGrants.Delete(r.Context(), grant.ID); err != nil {
+				app.logger.Errorw("failed to revoke reused oauth grant", "grant_id", grant.ID, "error", err)
+			}
+			app.oauthErrorResponse(w, http.StatusBadRequest, "invalid_grant", "refresh token has already been used")
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.writeOAuthTokens(w, r, grant)
+}
+
+func (app *application) writeOAuthTokens(w http.ResponseWriter, r *http.Request, grant *store.OAuthGrant) {
+	scope := store.FormatScopes(grant.Scopes)
+
+	accessToken, err := app.authToken.GenerateClientAccessToken(grant.UserID, grant.ID, grant.ClientID,
+		strings.Fields(scope), app.cfg.oauthServer.accessTokenTTL)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	refreshToken, err := app.authToken.GenerateClientRefreshToken(grant.ID, grant.ClientID, grant.Version, time.Until(grant.ExpiresAt))
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{
+		"access_token":  accessToken,
+		"token_type":    "Bearer",
+		"expires_in":    int(app.cfg.oauthServer.accessTokenTTL.Seconds()),
+		"refresh_token": refreshToken,
+		"scope":         scope,
+	}, noStoreHeaders())
+
+	if err != nil {
+		app.logger.Info("Failed to write JSON response:", err)
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+// clientToken is what an access or refresh token issued to a third-party
+// app says about itself
+type clientToken struct {
+	grantID   string
+	clientID  string
+	userID    string
+	refresh   bool
+	version   int
+	issuedAt  time.Time
+	expiresAt time.Time
+}
+
+// readClientToken decodes a token issued to a third-party app. The hint only
+// decides which kind of token is tried first.
+func (app *application) readClientToken(token, hint string) (*clientToken, bool) {
+	readAccess := func() (*clientToken, bool) {
+		payload, err := app.authToken.ValidateAccessToken(token)
+		if err != nil || !payload.IsClientToken() {
+			return nil, false
+		}
+
+		return &clientToken{
+			grantID:   payload.GrantID,
+			clientID:  payload.ClientID,
+			userID:    payload.UserID,
+			issuedAt:  payload.IssuedAt.Time,
+			expiresAt: payload.ExpiresAt.Time,
+		}, true
+	}
+
+	readRefresh := func() (*clientToken, bool) {
+		payload, err := app.authToken.ValidateRefreshToken(token)
+		if err != nil || !payload.IsClientToken() {
+			return nil, false
+		}
+
+		return &clientToken{
+			grantID:   payload.SessionID,
+			clientID:  payload.ClientID,
+			refresh:   true,
+			version:   payload.Version,
+			issuedAt:  payload.IssuedAt.Time,
+			expiresAt: payload.ExpiresAt.Time,
+		}, true
+	}
+
+	if hint == "refresh_token" {
+		if info, ok := readRefresh(); ok {
+			return info, true
+		}
+		return readAccess()
+	}
+
+	if info, ok := readAccess(); ok {
+		return info, true
+	}
+	return readRefresh()
+}
+
+// revokeOAuthToken is the revocation endpoint. Revoking either token of an
+// app revokes the whole grant it belongs to. Unknown tokens are not an error,
+// the app only needs to know the token no longer works.
+func (app *application) revokeOAuthToken(w http.ResponseWriter, r *http.Request) {
+	client, ok := app.authenticateOAuthClient(w, r)
+	if !ok {
+		return
+	}
+
+	token := r.PostForm.Get("token")
+	if token == "" {
+		app.oauthErrorResponse(w, http.StatusBadRequest, "invalid_request", "token is required")
+		return
+	}
+
+	info, ok := app.readClientToken(token, r.PostForm.Get("token_type_hint"))
+	if ok && info.clientID == client.ID {
+		grant, err := app.store.OAuthGrants.GetByID(r.Context(), info.grantID)
+
+		switch {
+		case err == nil && grant.ClientID == client.ID:
+			if err := app.store.OAuthGrants.Delete(r.Context(), grant.ID); err != nil {
+				app.serverErrorResponse(w, r, err)
+				return
+			}
+		case err != nil && !errors.Is(err, store.ErrRecordNotFound):
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+	}
+
+	for key, values := range noStoreHeaders() {
+		w.Header()[key] = values
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// introspectOAuthToken is the introspection endpoint. An app can only
+// introspect the tokens issued to it, any other token is reported inactive.
+func (app *application) introspectOAuthToken(w http.ResponseWriter, r *http.Request) {
+	client, ok := app.authenticateOAuthClient(w, r)
+	if !ok {
+		return
+	}
+
+	inactive := func() {
+		if err := app.writeJSON(w, http.StatusOK, envelope{"active": false}, noStoreHeaders()); err != nil {
+			app.logger.Info("Failed to write JSON response:", err)
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}
+
+	info, ok := app.readClientToken(r.PostForm.Get("token"), r.PostForm.Get("token_type_hint"))
+	if !ok || info.clientID != client.ID || time.Now().After(info.expiresAt) {
+		inactive()
+		return
+	}
+
+	grant, err := app.store.OAuthGrants.GetByID(r.Context(), info.grantID)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrRecordNotFound):
+			inactive()
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if grant.ClientID != client.ID ||
+		info.refresh && (info.version != grant.Version || grant.IsExpired()) ||
+		!info.refresh && info.userID != grant.UserID {
+		inactive()
+		return
+	}
+
+	response := envelope{
+		"active":    true,
+		"scope":     store.FormatScopes(grant.Scopes),
+		"client_id": grant.ClientID,
+		"sub":       grant.UserID,
+		"iat":       info.issuedAt.Unix(),
+		"exp":       info.expiresAt.Unix(),
+	}
+
+	if !info.refresh {
+		response["token_type"] = "Bearer"
+	}
+
+	if err := app.writeJSON(w, http.StatusOK, response, noStoreHeaders()); err != nil {
+		app.logger.Info("Failed to write JSON response:", err)
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
@@ -96,15 +96,17 @@ func (app *application) handleCheckout(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var totalPrice float64
-	// Check if all products are in stock and have sufficient quantity.
+	// Check if all products are in stock and have sufficient quantity, unless
+	// they can be backordered or pre-ordered.
 	for _, item := range products {
-		if item.StockQuantity <= productsCount[item.ID] {
+		if item.StockQuantity < productsCount[item.ID] && !item.AcceptsBackorder() {
 			app.badRequestResponse(w, r, fmt.Errorf("insufficient stock for product '%s'. Available quantity: %d", item.Name, item.StockQuantity))
 			return
 		}
 
-		totalPrice += float64(productsCount[item.ID]) * (item.Price - item.Discount)
-		productsPrice[item.ID] = item.Price - item.Discount
+		// EffectivePrice already accounts for an active sale window.
+		totalPrice += float64(productsCount[item.ID]) * item.EffectivePrice
+		productsPrice[item.ID] = item.EffectivePrice
 
 	}
 
@@ -135,16 +137,22 @@ func (app *application) handleCheckout(w http.ResponseWriter, r *http.Request) {
 	}
 
 	order := &store.Order{
-		UserID:      user.ID,
-		TotalAmount: totalPrice,
-		PromoCode:   form.PromoCode,
-		Status:      store.PendingOrderStatus,
+		UserID:            user.ID,
+		TotalAmount:       totalPrice,
+		PromoCode:         form.PromoCode,
+		Status:            store.PendingOrderStatus,
+		ShippingAddressId: address.ID,
 	}
 
 	err = app.store.Orders.Create(r.Context(), app.store.Products, order, cartItems)
 
 	if err != nil {
-		app.serverErrorResponse(w, r, fmt.Errorf("failed to create order: %w", err))
+		switch {
+		case errors.Is(err, store.ErrInsufficientStock):
+			app.conflictResponse(w, r, "one or more products no longer have enough stock")
+		default:
+			app.serverErrorResponse(w, r, fmt.Errorf("failed to create order: %w", err))
+		}
 		return
 	}
 
@@ -159,7 +167,8 @@ func (app *application) handleCheckout(w http.ResponseWriter, r *http.Request) {
 	response := envelope{
 		"message": "order created successfully",
 		"data": map[string]interface{}{
-			"order_id": order.ID,
+			"order_id":    order.ID,
+			"backordered": order.Backordered,
 			"payment_options": []string{
 				"stripe",
 				"paypal",
@@ -283,6 +292,7 @@ func (app *application) handleStripeWebhook(w http.ResponseWriter, r *http.Reque
 			Amount:        float64(session.AmountTotal / 100), // Convert from cents to dollars
 			TransactionID: paymentID,
 			Status:        status,
+			ClientURL:     app.cfg.clientURL,
 		})
 
 		if err != nil {
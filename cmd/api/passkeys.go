@@ -0,0 +1,483 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/devphaseX/buyr-api.git/internal/passkey"
+	"github.com/devphaseX/buyr-api.git/internal/store"
+	"github.com/devphaseX/buyr-api.git/internal/store/cache"
+)
+
+// passkeyChallengePayload is kept in a token between the begin and finish
+// steps of a WebAuthn ceremony.
+type passkeyChallengePayload struct {
+	Session    []byte `json:"session"`
+	RememberMe bool   `json:"remember_me"`
+}
+
+// passkeyUser loads the user's registered credentials in the shape the
+// ceremonies expect.
+func (app *application) passkeyUser(ctx context.Context, user *store.User) (*passkey.User, []*store.WebAuthnCredential, error) {
+	credentials, err := app.store.WebAuthnCredentials.GetByUserID(ctx, user.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	passkeyUser := &passkey.User{
+		ID:          user.ID,
+		Name:        user.Email,
+		DisplayName: app.displayName(ctx, user),
+	}
+
+	for _, credential := range credentials {
+		passkeyUser.Credentials = append(passkeyUser.Credentials, passkey.Credential{
+			ID:              credential.CredentialID,
+			PublicKey:       credential.PublicKey,
+			AttestationType: credential.AttestationType,
+			Transports:      credential.Transports,
+			AAGUID:          credential.AAGUID,
+			SignCount:       uint32(credential.SignCount),
+			BackupEligible:  credential.BackupEligible,
+			BackupState:     credential.BackupState,
+		})
+	}
+
+	return passkeyUser, credentials, nil
+}
+
+// recordPasskeyUse stores the counter of the credential an assertion was
+// made with.
+func (app *application) recordPasskeyUse(ctx context.Context, credentials []*store.WebAuthnCredential, used *passkey.Credential) error {
+	for _, credential := range credentials {
+		if bytes.Equal(credential.CredentialID, used.ID) {
+			credential.SignCount = int64(used.SignCount)
+			credential.BackupState = used.BackupState
+			return app.store.WebAuthnCredentials.RecordUse(ctx, credential)
+		}
+	}
+
+	return store.ErrRecordNotFound
+}
+
+// twoFactorMethods lists the second factors a user can complete sign-in with.
+func (app *application) twoFactorMethods(ctx context.Context, user *store.User) ([]store.TwoFactorType, error) {
+	methods := codeTwoFactorMethods(user)
+
+	credentials, err := app.store.WebAuthnCredentials.GetByUserID(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(credentials) > 0 {
+		methods = append(methods, store.WebAuthnFactorType)
+	}
+
+	return methods, nil
+}
+
+// issuePasskeyChallenge keeps the ceremony state in a token and returns it
+// with the options for the browser.
+func (app *application) issuePasskeyChallenge(w http.ResponseWriter, r *http.Request, userID string, scope cache.TokenScope, options json.RawMessage, session []byte, rememberMe bool) {
+	payload, err := json.Marshal(passkeyChallengePayload{Session: session, RememberMe: rememberMe})
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	token, err := app.cacheStore.Tokens.New(userID, app.cfg.webauthn.timeout, scope, payload)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if err := app.cacheStore.Tokens.Insert(r.Context(), token); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.successResponse(w, http.StatusOK, envelope{
+		"options":        options,
+		"webauthn_token": token.Plaintext,
+	})
+}
+
+// readPasskeyChallenge fetches and decodes the state stored by
+// issuePasskeyChallenge.
+func (app *application) readPasskeyChallenge(ctx context.Context, scope cache.TokenScope, plaintext string) (*cache.Token, *passkeyChallengePayload, error) {
+	token, err := app.cacheStore.Tokens.Get(ctx, scope, plaintext)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var payload passkeyChallengePayload
+	if err := json.Unmarshal(token.Data, &payload); err != nil {
+		return nil, nil, err
+	}
+
+	return token, &payload, nil
+}
+
+func (app *application) beginPasskeyRegistration(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromCtx(r)
+
+	passkeyUser, _, err := app.passkeyUser(r.Context(), user.User)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	options, session, err := app.passkey.BeginRegistration(passkeyUser)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.issuePasskeyChallenge(w, r, user.ID, cache.PasskeyRegisterTokenScope, options, session, false)
+}
+
+type finishPasskeyRegistrationForm struct {
+	WebAuthnToken string          `json:"webauthn_token" validate:"required"`
+	Name          string          `json:"name" validate:"required,max=100"`
+	Credential    json.RawMessage `json:"credential" validate:"required"`
+}
+
+func (app *application) finishPasskeyRegistration(w http.ResponseWriter, r *http.Request) {
+	var (
+		form finishPasskeyRegistrationForm
+		user = getUserFromCtx(r)
+	)
+
+	if err := app.readJSON(w, r, &form); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := validate.Struct(form); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	token, challenge, err := app.readPasskeyChallenge(r.Context(), cache.PasskeyRegisterTokenScope, form.WebAuthnToken)
+	if err != nil || token.UserID != user.ID {
+		app.unauthorizedResponse(w, r, "invalid or expired passkey registration")
+		return
+	}
+
+	passkeyUser, _, err := app.passkeyUser(r.Context(), user.User)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	registered, err := app.passkey.FinishRegistration(passkeyUser, challenge.Session, form.Credential)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := app.cacheStore.Tokens.DeleteAllForUser(r.Context(), cache.PasskeyRegisterTokenScope, user.ID); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	credential := &store.WebAuthnCredential{
+		UserID:          user.ID,
+		CredentialID:    registered.ID,
+		PublicKey:       registered.PublicKey,
+		AttestationType: registered.AttestationType,
+		Transports:      registered.Transports,
+		AAGUID:          registered.AAGUID,
+		SignCount:       int64(registered.SignCount),
+		BackupEligible:  registered.BackupEligible,
+		BackupState:     registered.BackupState,
+		Name:            form.Name,
+	}
+
+	if err := app.store.WebAuthnCredentials.Create(r.Context(), credential); err != nil {
+		switch {
+		case errors.Is(err, store.ErrDuplicateWebAuthnCredential):
+			app.conflictResponse(w, r, err.Error())
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.successResponse(w, http.StatusCreated, envelope{
+		"message":    "passkey registered",
+		"credential": credential,
+	})
+}
+
+func (app *application) getPasskeys(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromCtx(r)
+
+	credentials, err := app.store.WebAuthnCredentials.GetByUserID(r.Context(), user.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.successResponse(w, http.StatusOK, envelope{
+		"credentials": credentials,
+	})
+}
+
+func (app *application) deletePasskey(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromCtx(r)
+	credentialID := app.readStringID(r, "credentialID")
+
+	err := app.store.WebAuthnCredentials.Delete(r.Context(), credentialID, user.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrRecordNotFound):
+			app.notFoundResponse(w, r, "passkey not found")
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.successResponse(w, http.StatusOK, envelope{
+		"message": "passkey removed",
+		"id":      credentialID,
+	})
+}
+
+type beginLogin2faPasskeyForm struct {
+	MfaToken string `json:"mfa_token" validate:"required"`
+}
+
+// beginLogin2faPasskey starts a passkey assertion as the second step of a
+// password sign-in.
+func (app *application) beginLogin2faPasskey(w http.ResponseWriter, r *http.Request) {
+	var form beginLogin2faPasskeyForm
+
+	if err := app.readJSON(w, r, &form); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := validate.Struct(form); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	token, err := app.cacheStore.Tokens.Get(r.Context(), cache.Login2faTokenScope, form.MfaToken)
+	if err != nil {
+		app.unauthorizedResponse(w, r, "invalid or expired 2FA token")
+		return
+	}
+
+	var signin2faPayload signIn2faPayload
+	if err := json.Unmarshal(token.Data, &signin2faPayload); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	user, err := app.getUser(r.Context(), token.UserID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if app.loginBlocked(w, r, user.ID) {
+		return
+	}
+
+	passkeyUser, _, err := app.passkeyUser(r.Context(), user.User)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if len(passkeyUser.Credentials) == 0 {
+		app.forbiddenResponse(w, r, "no passkey registered")
+		return
+	}
+
+	options, session, err := app.passkey.BeginLogin(passkeyUser)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.issuePasskeyChallenge(w, r, user.ID, cache.PasskeyLogin2faTokenScope, options, session, signin2faPayload.RememberMe)
+}
+
+type verifyLogin2faPasskeyForm struct {
+	MfaToken      string          `json:"mfa_token" validate:"required"`
+	WebAuthnToken string          `json:"webauthn_token" validate:"required"`
+	Credential    json.RawMessage `json:"credential" validate:"required"`
+}
+
+func (app *application) verifyLogin2faPasskey(w http.ResponseWriter, r *http.Request) {
+	var form verifyLogin2faPasskeyForm
+
+	if err := app.readJSON(w, r, &form); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := validate.Struct(form); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	mfaToken, err := app.cacheStore.Tokens.Get(r.Context(), cache.Login2faTokenScope, form.MfaToken)
+	if err != nil {
+		app.unauthorizedResponse(w, r, "invalid or expired 2FA token")
+		return
+	}
+
+	token, challenge, err := app.readPasskeyChallenge(r.Context(), cache.PasskeyLogin2faTokenScope, form.WebAuthnToken)
+	if err != nil || token.UserID != mfaToken.UserID {
+		app.unauthorizedResponse(w, r, "invalid or expired passkey challenge")
+		return
+	}
+
+	user, err := app.getUser(r.Context(), token.UserID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	attempt, ok := app.reserveLoginAttempt(w, r, user.User)
+	if !ok {
+		return
+	}
+
+	defer attempt.release(r.Context())
+
+	passkeyUser, credentials, err := app.passkeyUser(r.Context(), user.User)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	used, err := app.passkey.FinishLogin(passkeyUser, challenge.Session, form.Credential)
+	if err != nil {
+		attempt.failed(w, r, func() { app.unauthorizedResponse(w, r, "passkey verification failed") })
+		return
+	}
+
+	if err := app.recordPasskeyUse(r.Context(), credentials, used); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	for _, scope := range []cache.TokenScope{cache.Login2faTokenScope, cache.PasskeyLogin2faTokenScope} {
+		if err := app.cacheStore.Tokens.DeleteAllForUser(r.Context(), scope, user.ID); err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+	}
+
+	attempt.succeeded(r.Context())
+	app.createUserSessionAndSetCookies(w, r, user.User, challenge.RememberMe)
+}
+
+type beginPasskeySignInForm struct {
+	RememberMe bool `json:"remember_me"`
+}
+
+// beginPasskeySignIn starts a passwordless sign-in. The account is not known
+// until the authenticator answers with a discoverable credential.
+func (app *application) beginPasskeySignIn(w http.ResponseWriter, r *http.Request) {
+	var form beginPasskeySignInForm
+
+	if err := app.readJSON(w, r, &form); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	options, session, err := app.passkey.BeginPasswordlessLogin()
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.issuePasskeyChallenge(w, r, "", cache.PasskeySignInTokenScope, options, session, form.RememberMe)
+}
+
+type finishPasskeySignInForm struct {
+	WebAuthnToken string          `json:"webauthn_token" validate:"required"`
+	Credential    json.RawMessage `json:"credential" validate:"required"`
+}
+
+func (app *application) finishPasskeySignIn(w http.ResponseWriter, r *http.Request) {
+	var form finishPasskeySignInForm
+
+	if err := app.readJSON(w, r, &form); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := validate.Struct(form); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	token, challenge, err := app.readPasskeyChallenge(r.Context(), cache.PasskeySignInTokenScope, form.WebAuthnToken)
+	if err != nil {
+		app.unauthorizedResponse(w, r, "invalid or expired passkey challenge")
+		return
+	}
+
+	// the challenge is single use whatever the outcome
+	if err := app.cacheStore.Tokens.Delete(r.Context(), token); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	var (
+		user        *AuthInfo
+		credentials []*store.WebAuthnCredential
+	)
+
+	lookup := func(userHandle, _ []byte) (*passkey.User, error) {
+		var err error
+
+		user, err = app.getUser(r.Context(), string(userHandle))
+		if err != nil {
+			return nil, err
+		}
+
+		var passkeyUser *passkey.User
+
+		passkeyUser, credentials, err = app.passkeyUser(r.Context(), user.User)
+		return passkeyUser, err
+	}
+
+	_, used, err := app.passkey.FinishPasswordlessLogin(lookup, challenge.Session, form.Credential)
+	if err != nil {
+		if user == nil {
+			app.unauthorizedResponse(w, r, "passkey verification failed")
+			return
+		}
+
+		attempt, ok := app.reserveLoginAttempt(w, r, user.User)
+		if !ok {
+			return
+		}
+
+		attempt.failed(w, r, func() { app.unauthorizedResponse(w, r, "passkey verification failed") })
+		return
+	}
+
+	if app.loginBlocked(w, r, user.ID) {
+		return
+	}
+
+	if err := app.recordPasskeyUse(r.Context(), credentials, used); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.loginSucceeded(r.Context(), user.ID)
+	app.createUserSessionAndSetCookies(w, r, user.User, challenge.RememberMe)
+}
@@ -10,6 +10,18 @@ type AuthInfo struct {
 	*store.User
 	AdminUser   *store.AdminUser
 	IsAnonymous bool
+	// SessionID is the session the access token was issued for.
+	SessionID string
+	// APIKey is set instead of SessionID when the request authenticated
+	// with an API key.
+	APIKey *store.APIKey
+	// OAuthGrant is set instead of SessionID when the request was made by a
+	// third-party app the user authorized.
+	OAuthGrant *store.OAuthGrant
+
+	// scopesGranted records that a RequireScopes check passed for the API
+	// key or app. Both are denied on routes that never check scopes.
+	scopesGranted bool
 
 	// Calculated properties
 	IsSuperAdmin   bool
@@ -34,6 +46,23 @@ func (a *AuthInfo) populateAdminFlags() {
 	}
 }
 
+// IsScoped reports whether the request authenticated with an API key or a
+// third-party app token, which are limited to the scopes they were granted
+func (a *AuthInfo) IsScoped() bool {
+	return a.APIKey != nil || a.OAuthGrant != nil
+}
+
+func (a *AuthInfo) hasScopes(scopes ...store.APIKeyScope) bool {
+	switch {
+	case a.APIKey != nil:
+		return a.APIKey.HasScopes(scopes...)
+	case a.OAuthGrant != nil:
+		return a.OAuthGrant.HasScopes(scopes...)
+	default:
+		return true
+	}
+}
+
 func (a *AuthInfo) CanManageUsers() bool {
 	return a.IsManagerAdmin || a.IsSuperAdmin
 }
@@ -111,6 +140,23 @@ func RequireLevels(allowedLevels ...store.AdminLevel) PermissionCheck {
 	}
 }
 
+// RequireScopes opens a route to API keys and third-party apps granted all of
+// the scopes. Requests authenticated with a session are not limited by scopes.
+func RequireScopes(scopes ...store.APIKeyScope) PermissionCheck {
+	return func(a *AuthInfo) bool {
+		if !a.IsScoped() {
+			return true
+		}
+
+		if !a.hasScopes(scopes...) {
+			return false
+		}
+
+		a.scopesGranted = true
+		return true
+	}
+}
+
 func (app *application) CheckPermissions(checks ...PermissionCheck) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -127,6 +173,11 @@ func (app *application) CheckPermissions(checks ...PermissionCheck) func(http.Ha
 				}
 			}
 
+			if authInfo.IsScoped() && !authInfo.scopesGranted {
+				app.forbiddenResponse(w, r, "this resource is not available to API keys or third-party apps")
+				return
+			}
+
 			next.ServeHTTP(w, r)
 		})
 	}
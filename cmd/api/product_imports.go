@@ -0,0 +1,175 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/devphaseX/buyr-api.git/internal/productio"
+	"github.com/devphaseX/buyr-api.git/internal/store"
+	"github.com/devphaseX/buyr-api.git/worker"
+	"github.com/hibiken/asynq"
+)
+
+const maxProductImportSize = MB * 10
+
+type importProductsForm struct {
+	Format string `form:"format" validate:"omitempty,oneof=csv ndjson"`
+	DryRun bool   `form:"dry_run"`
+}
+
+func (app *application) importProducts(w http.ResponseWriter, r *http.Request) {
+	var form importProductsForm
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxProductImportSize+MB)
+
+	if err := app.decodeForm(r, &form, maxProductImportSize); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := validate.Struct(form); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		app.badRequestResponse(w, r, errors.New("file is required"))
+		return
+	}
+	defer file.Close()
+
+	var format productio.Format
+
+	if form.Format != "" {
+		format, err = productio.ParseFormat(form.Format)
+	} else {
+		format, err = productio.FormatFromFilename(header.Filename)
+	}
+
+	if err != nil {
+		app.badRequestResponse(w, r, errors.New("file must be a csv or ndjson document"))
+		return
+	}
+
+	payload, err := io.ReadAll(file)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if len(payload) == 0 {
+		app.badRequestResponse(w, r, errors.New("file is empty"))
+		return
+	}
+
+	user := getUserFromCtx(r)
+
+	vendorUser, err := app.store.Users.GetVendorUserByID(r.Context(), user.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	productImport := &store.ProductImport{
+		VendorID: vendorUser.ID,
+		Format:   string(format),
+		DryRun:   form.DryRun,
+		Payload:  payload,
+	}
+
+	if err := app.store.ProductImports.Create(r.Context(), productImport); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.taskDistributor.DistributeTaskProcessProductImport(r.Context(),
+		&worker.PayloadProcessProductImport{
+			ImportID: productImport.ID,
+		}, asynq.Queue(worker.QueueDefault))
+
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.successResponse(w, http.StatusAccepted, envelope{
+		"import": productImport,
+	})
+}
+
+func (app *application) getProductImport(w http.ResponseWriter, r *http.Request) {
+	var (
+		user     = getUserFromCtx(r)
+		importID = app.readStringID(r, "importID")
+	)
+
+	vendorUser, err := app.store.Users.GetVendorUserByID(r.Context(), user.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	productImport, err := app.store.ProductImports.GetVendorImportByID(r.Context(), vendorUser.ID, importID)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrRecordNotFound):
+			app.notFoundResponse(w, r, "product import not found")
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.successResponse(w, http.StatusOK, envelope{
+		"import": productImport,
+	})
+}
+
+func (app *application) exportProducts(w http.ResponseWriter, r *http.Request) {
+	formatParam := r.URL.Query().Get("format")
+	if formatParam == "" {
+		formatParam = string(productio.CSVFormat)
+	}
+
+	format, err := productio.ParseFormat(formatParam)
+	if err != nil {
+		app.badRequestResponse(w, r, errors.New("format must be csv or ndjson"))
+		return
+	}
+
+	user := getUserFromCtx(r)
+
+	vendorUser, err := app.store.Users.GetVendorUserByID(r.Context(), user.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	writer, err := productio.NewWriter(format, w)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	filename := fmt.Sprintf("products-%s.%s", time.Now().Format("20060102"), format)
+
+	w.Header().Set("Content-Type", format.ContentType())
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	w.WriteHeader(http.StatusOK)
+
+	err = app.store.Products.StreamVendorProducts(r.Context(), vendorUser.ID, writer.Write)
+
+	if err == nil {
+		err = writer.Flush()
+	}
+
+	// headers are already sent at this point, so the best we can do is log and
+	// let the client notice the truncated body.
+	if err != nil {
+		app.logger.Errorw("failed to export products", "vendor_id", vendorUser.ID, "err", err)
+	}
+}
@@ -0,0 +1,350 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/devphaseX/buyr-api.git/internal/store"
+	"github.com/devphaseX/buyr-api.git/internal/validator"
+)
+
+func (app *application) getModerationQueue(w http.ResponseWriter, r *http.Request) {
+	fq := store.PaginateQueryFilter{
+		Page:         1,
+		PageSize:     20,
+		Sort:         "submitted_at",
+		SortSafelist: []string{"submitted_at", "-submitted_at", "created_at", "-created_at"},
+	}
+
+	if err := fq.Parse(r); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	items, metadata, err := app.store.Products.GetModerationQueue(r.Context(), fq)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.successResponse(w, http.StatusOK, envelope{
+		"products": items,
+		"metadata": metadata,
+	})
+}
+
+func (app *application) approveProduct(w http.ResponseWriter, r *http.Request) {
+	var (
+		user      = getUserFromCtx(r)
+		productID = app.readStringID(r, "productID")
+	)
+
+	moderation, err := app.store.Products.Approve(r.Context(), productID, user.AdminUser.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrRecordNotFound):
+			app.notFoundResponse(w, r, "pending product not found")
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.recordModerationDecision(r, user, store.ProductApprovedAuditEventType, moderation, "")
+	app.notifyProductVendor(moderation, fmt.Sprintf("Your product %q has been approved.", moderation.ProductName))
+
+	app.successResponse(w, http.StatusOK, envelope{
+		"message":    "product approved successfully",
+		"moderation": moderation,
+	})
+}
+
+type rejectProductForm struct {
+	Reason string `json:"reason" validate:"required,min=3,max=1000"`
+}
+
+func (app *application) rejectProduct(w http.ResponseWriter, r *http.Request) {
+	var (
+		user      = getUserFromCtx(r)
+		productID = app.readStringID(r, "productID")
+		form      rejectProductForm
+	)
+
+	if err := app.readJSON(w, r, &form); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := validate.Struct(form); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	moderation, err := app.store.Products.Reject(r.Context(), productID, user.AdminUser.ID, form.Reason)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrRecordNotFound):
+			app.notFoundResponse(w, r, "pending product not found")
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.recordModerationDecision(r, user, store.ProductRejectedAuditEventType, moderation, form.Reason)
+	app.notifyProductVendor(moderation, fmt.Sprintf("Your product %q was rejected: %s", moderation.ProductName, form.Reason))
+
+	app.successResponse(w, http.StatusOK, envelope{
+		"message":    "product rejected successfully",
+		"moderation": moderation,
+	})
+}
+
+// getProductModeration lets the owning vendor see the review state of a
+// product, including the rejection reason and past decisions.
+func (app *application) getProductModeration(w http.ResponseWriter, r *http.Request) {
+	var (
+		user      = getUserFromCtx(r)
+		productID = app.readStringID(r, "productID")
+	)
+
+	moderation, err := app.store.Products.GetModeration(r.Context(), productID)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrRecordNotFound):
+			app.notFoundResponse(w, r, "product not found")
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if user.IsVendor() {
+		vendorUser, err := app.store.Users.GetVendorUserByID(r.Context(), user.ID)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
+		if vendorUser.ID != moderation.VendorID {
+			app.notFoundResponse(w, r, "product not found")
+			return
+		}
+	}
+
+	app.successResponse(w, http.StatusOK, envelope{
+		"moderation": moderation,
+	})
+}
+
+type updateProductForm struct {
+	Name          string  `json:"name" validate:"required,max=255"`
+	Description   string  `json:"description" validate:"required"`
+	StockQuantity int     `json:"stock_quantity" validate:"gte=0"`
+	Discount      float64 `json:"discount" validate:"gte=0,lte=100"`
+	Price         float64 `json:"price" validate:"required,gt=0"`
+	CategoryID    string  `json:"category_id" validate:"required"`
+}
+
+func (app *application) updateProduct(w http.ResponseWriter, r *http.Request) {
+	var (
+		user      = getUserFromCtx(r)
+		productID = app.readStringID(r, "productID")
+		form      updateProductForm
+	)
+
+	if err := app.readJSON(w, r, &form); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := validate.Struct(form); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	vendorUser, err := app.store.Users.GetVendorUserByID(r.Context(), user.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	before, err := app.store.Products.GetProductByID(r.Context(), productID)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrRecordNotFound):
+			app.notFoundResponse(w, r, "product not found")
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if before.VendorID != vendorUser.ID {
+		app.notFoundResponse(w, r, "product not found")
+		return
+	}
+
+	category, err := app.store.Category.GetByID(r.Context(), form.CategoryID)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrRecordNotFound):
+			app.notFoundResponse(w, r, "category not found")
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if !category.Visible {
+		app.notFoundResponse(w, r, "category not found")
+		return
+	}
+
+	product := &store.Product{
+		ID:            productID,
+		VendorID:      vendorUser.ID,
+		Name:          form.Name,
+		Description:   form.Description,
+		StockQuantity: form.StockQuantity,
+		Discount:      form.Discount,
+		Price:         form.Price,
+		CategoryID:    form.CategoryID,
+	}
+
+	if product.CategoryID != before.CategoryID {
+		features, err := app.store.Products.GetFeatures(r.Context(), productID)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
+		schema, err := app.store.CategoryAttributes.GetEffectiveSchema(r.Context(), category.ID)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
+		attributeErrors, attributes := schema.Validate(features)
+		if len(attributeErrors) != 0 {
+			var validationErrors validator.ValidationErrors
+			for name, message := range attributeErrors {
+				validationErrors.AddFieldError("features."+name, message)
+			}
+			app.badRequestResponse(w, r, &validationErrors)
+			return
+		}
+
+		product.Attributes = attributes
+	}
+
+	if err := app.store.Products.UpdateDetails(r.Context(), product); err != nil {
+		switch {
+		case errors.Is(err, store.ErrRecordNotFound):
+			app.notFoundResponse(w, r, "product not found")
+		case errors.Is(err, store.ErrProductCategoryNotFound):
+			app.notFoundResponse(w, r, "category not exist")
+		case errors.Is(err, store.ErrInsufficientStock):
+			app.conflictResponse(w, r, "stock quantity cannot be lower than the stock held in warehouses")
+		case errors.Is(err, store.ErrInvalidSalePrice):
+			app.badRequestResponse(w, r, errors.New("price must be higher than the product's sale price, end the sale first"))
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.queueWishlistAlertsAfterChange(r.Context(), before)
+
+	response := envelope{
+		"product": product,
+	}
+
+	if before.Status == store.ApprovedProductStatus && product.Status == store.PendingProductStatus {
+		response["message"] = "product changes submitted for review, it is hidden from the catalog until approved"
+	}
+
+	app.successResponse(w, http.StatusOK, response)
+}
+
+func (app *application) resubmitProduct(w http.ResponseWriter, r *http.Request) {
+	var (
+		user      = getUserFromCtx(r)
+		productID = app.readStringID(r, "productID")
+	)
+
+	vendorUser, err := app.store.Users.GetVendorUserByID(r.Context(), user.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	moderation, err := app.store.Products.Resubmit(r.Context(), productID, vendorUser.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrRecordNotFound):
+			app.notFoundResponse(w, r, "product not found")
+		case errors.Is(err, store.ErrProductNotRejected):
+			app.conflictResponse(w, r, err.Error())
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.successResponse(w, http.StatusOK, envelope{
+		"message":    "product resubmitted for review",
+		"moderation": moderation,
+	})
+}
+
+func (app *application) recordModerationDecision(r *http.Request, reviewer *AuthInfo, eventType store.AuditEventType, moderation *store.ProductModeration, reason string) {
+	details, _ := json.Marshal(map[string]string{
+		"product_id": moderation.ProductID,
+		"vendor_id":  moderation.VendorID,
+		"status":     string(moderation.Status),
+	})
+
+	event := store.AuditEvent{
+		EventType:   eventType,
+		PerformedBy: reviewer.AdminUser.ID,
+		Reason:      reason,
+		Details:     details,
+		AccessLevel: store.AdminLevelManager.GetRank(),
+		Timestamp:   time.Now().UTC(),
+		IPAddress:   r.RemoteAddr,
+		UserAgent:   r.UserAgent(),
+	}
+
+	app.background(func() {
+		vendorUser, err := app.store.Users.GetVendorByID(context.Background(), moderation.VendorID)
+		if err == nil {
+			event.AccountID = vendorUser.UserID
+		}
+
+		if err := app.store.AuditLogs.LogEvent(context.Background(), event); err != nil {
+			app.logger.Error("failed to log audit event", "error", err)
+		}
+	})
+}
+
+func (app *application) notifyProductVendor(moderation *store.ProductModeration, message string) {
+	app.background(func() {
+		vendorUser, err := app.store.Users.GetVendorByID(context.Background(), moderation.VendorID)
+		if err != nil {
+			app.logger.Error("failed to load vendor for notification", "error", err)
+			return
+		}
+
+		if err := app.store.Notifications.Create(context.Background(), &store.Notification{
+			UserID:  vendorUser.UserID,
+			Message: message,
+		}); err != nil {
+			app.logger.Error("failed to create notification", "error", err)
+		}
+	})
+}
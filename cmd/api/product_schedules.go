@@ -0,0 +1,259 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/devphaseX/buyr-api.git/internal/store"
+	"github.com/devphaseX/buyr-api.git/internal/validator"
+)
+
+type productScheduleForm struct {
+	PublishAt   *time.Time `json:"publish_at"`
+	UnpublishAt *time.Time `json:"unpublish_at"`
+}
+
+func (form *productScheduleForm) validate() error {
+	var validationErrors validator.ValidationErrors
+
+	if form.PublishAt != nil && form.UnpublishAt != nil && !form.UnpublishAt.After(*form.PublishAt) {
+		validationErrors.AddFieldError("unpublish_at", "must be after publish_at")
+	}
+
+	if form.UnpublishAt != nil && form.UnpublishAt.Before(time.Now()) {
+		validationErrors.AddFieldError("unpublish_at", "must be in the future")
+	}
+
+	if len(validationErrors.FieldErrors()) != 0 {
+		return &validationErrors
+	}
+
+	return nil
+}
+
+// setProductSchedule sets when a product goes live and when it is taken down.
+// The scheduler applies both and then clears them.
+func (app *application) setProductSchedule(w http.ResponseWriter, r *http.Request) {
+	var (
+		user      = getUserFromCtx(r)
+		productID = app.readStringID(r, "productID")
+		form      productScheduleForm
+	)
+
+	if err := app.readJSON(w, r, &form); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := form.validate(); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	vendorUser, err := app.store.Users.GetVendorUserByID(r.Context(), user.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	product, err := app.store.Products.SetSchedule(r.Context(), productID, vendorUser.ID, form.PublishAt, form.UnpublishAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrRecordNotFound):
+			app.notFoundResponse(w, r, "product not found")
+		case errors.Is(err, store.ErrInvalidPublishWindow):
+			app.badRequestResponse(w, r, err)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.successResponse(w, http.StatusOK, envelope{
+		"product": product,
+	})
+}
+
+type productSaleForm struct {
+	SalePrice    float64    `json:"sale_price" validate:"required,gt=0"`
+	SaleStartsAt *time.Time `json:"sale_starts_at"`
+	SaleEndsAt   *time.Time `json:"sale_ends_at"`
+}
+
+func (form *productSaleForm) validate() error {
+	if err := validate.Struct(form); err != nil {
+		return err
+	}
+
+	var validationErrors validator.ValidationErrors
+
+	if form.SaleStartsAt != nil && form.SaleEndsAt != nil && !form.SaleEndsAt.After(*form.SaleStartsAt) {
+		validationErrors.AddFieldError("sale_ends_at", "must be after sale_starts_at")
+	}
+
+	if form.SaleEndsAt != nil && form.SaleEndsAt.Before(time.Now()) {
+		validationErrors.AddFieldError("sale_ends_at", "must be in the future")
+	}
+
+	if len(validationErrors.FieldErrors()) != 0 {
+		return &validationErrors
+	}
+
+	return nil
+}
+
+// setProductSale puts a product on sale. Without a start time the sale is
+// active immediately and without an end time it runs until removed.
+func (app *application) setProductSale(w http.ResponseWriter, r *http.Request) {
+	var (
+		user      = getUserFromCtx(r)
+		productID = app.readStringID(r, "productID")
+		form      productSaleForm
+	)
+
+	if err := app.readJSON(w, r, &form); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := form.validate(); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	vendorUser, err := app.store.Users.GetVendorUserByID(r.Context(), user.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	before, err := app.store.Products.GetProductByID(r.Context(), productID)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrRecordNotFound):
+			app.notFoundResponse(w, r, "product not found")
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	product, err := app.store.Products.SetSale(r.Context(), productID, vendorUser.ID, &form.SalePrice, form.SaleStartsAt, form.SaleEndsAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrRecordNotFound):
+			app.notFoundResponse(w, r, "product not found")
+		case errors.Is(err, store.ErrInvalidSalePrice), errors.Is(err, store.ErrInvalidSaleWindow):
+			app.badRequestResponse(w, r, err)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.queueWishlistAlerts(before, product)
+
+	app.successResponse(w, http.StatusOK, envelope{
+		"product": product,
+	})
+}
+
+func (app *application) removeProductSale(w http.ResponseWriter, r *http.Request) {
+	var (
+		user      = getUserFromCtx(r)
+		productID = app.readStringID(r, "productID")
+	)
+
+	vendorUser, err := app.store.Users.GetVendorUserByID(r.Context(), user.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	product, err := app.store.Products.SetSale(r.Context(), productID, vendorUser.ID, nil, nil, nil)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrRecordNotFound):
+			app.notFoundResponse(w, r, "product not found")
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.successResponse(w, http.StatusOK, envelope{
+		"product": product,
+	})
+}
+
+type productBackorderForm struct {
+	AllowBackorder    bool       `json:"allow_backorder"`
+	BackorderShipsAt  *time.Time `json:"backorder_ships_at"`
+	PreorderReleaseAt *time.Time `json:"preorder_release_at"`
+}
+
+func (form *productBackorderForm) validate() error {
+	var validationErrors validator.ValidationErrors
+
+	if form.BackorderShipsAt != nil && !form.AllowBackorder {
+		validationErrors.AddFieldError("backorder_ships_at", "requires allow_backorder")
+	}
+
+	if form.BackorderShipsAt != nil && form.BackorderShipsAt.Before(time.Now()) {
+		validationErrors.AddFieldError("backorder_ships_at", "must be in the future")
+	}
+
+	if form.PreorderReleaseAt != nil && form.PreorderReleaseAt.Before(time.Now()) {
+		validationErrors.AddFieldError("preorder_release_at", "must be in the future")
+	}
+
+	if len(validationErrors.FieldErrors()) != 0 {
+		return &validationErrors
+	}
+
+	return nil
+}
+
+// setProductBackorder lets a product keep selling once out of stock, either
+// as a backorder shipping when stock is received or as a pre-order shipping
+// on its release date.
+func (app *application) setProductBackorder(w http.ResponseWriter, r *http.Request) {
+	var (
+		user      = getUserFromCtx(r)
+		productID = app.readStringID(r, "productID")
+		form      productBackorderForm
+	)
+
+	if err := app.readJSON(w, r, &form); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := form.validate(); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	vendorUser, err := app.store.Users.GetVendorUserByID(r.Context(), user.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	product, err := app.store.Products.SetBackorder(r.Context(), productID, vendorUser.ID,
+		form.AllowBackorder, form.BackorderShipsAt, form.PreorderReleaseAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrRecordNotFound):
+			app.notFoundResponse(w, r, "product not found")
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.successResponse(w, http.StatusOK, envelope{
+		"product": product,
+	})
+}
@@ -7,6 +7,7 @@ import (
 
 	"github.com/devphaseX/buyr-api.git/internal/store"
 	"github.com/devphaseX/buyr-api.git/internal/store/modelfilter"
+	"github.com/devphaseX/buyr-api.git/internal/validator"
 )
 
 type CreateProductImageRequest struct {
@@ -76,6 +77,7 @@ func (app *application) createProduct(w http.ResponseWriter, r *http.Request) {
 		Name:          form.Name,
 		Description:   form.Description,
 		StockQuantity: form.StockQuantity,
+		Status:        store.PendingProductStatus,
 		VendorID:      vendorUser.ID,
 		Discount:      form.Discount,
 		Price:         form.Price,
@@ -110,6 +112,24 @@ func (app *application) createProduct(w http.ResponseWriter, r *http.Request) {
 
 	product.Features = productFeatures
 
+	schema, err := app.store.CategoryAttributes.GetEffectiveSchema(r.Context(), category.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	attributeErrors, attributes := schema.Validate(product.Features)
+	if len(attributeErrors) != 0 {
+		var validationErrors validator.ValidationErrors
+		for name, message := range attributeErrors {
+			validationErrors.AddFieldError("features."+name, message)
+		}
+		app.badRequestResponse(w, r, &validationErrors)
+		return
+	}
+
+	product.Attributes = attributes
+
 	if err := app.store.Products.Create(r.Context(), product); err != nil {
 		switch {
 		case errors.Is(err, store.ErrProductCategoryNotFound):
@@ -188,48 +208,6 @@ func (app *application) unPublishProduct(w http.ResponseWriter, r *http.Request)
 	app.successResponse(w, http.StatusOK, response)
 }
 
-func (app *application) approveProduct(w http.ResponseWriter, r *http.Request) {
-	productID := app.readStringID(r, "productID")
-
-	if err := app.store.Products.Approve(r.Context(), productID); err != nil {
-		switch {
-		case errors.Is(err, store.ErrRecordNotFound):
-			app.notFoundResponse(w, r, "product not found")
-		default:
-			app.serverErrorResponse(w, r, err)
-		}
-		return
-	}
-
-	response := envelope{
-		"message": "product unpublished successfully",
-		"id":      productID,
-	}
-	// Return success response
-	app.successResponse(w, http.StatusOK, response)
-}
-
-func (app *application) rejectProduct(w http.ResponseWriter, r *http.Request) {
-	productID := app.readStringID(r, "productID")
-
-	if err := app.store.Products.Reject(r.Context(), productID); err != nil {
-		switch {
-		case errors.Is(err, store.ErrRecordNotFound):
-			app.notFoundResponse(w, r, "product not found")
-		default:
-			app.serverErrorResponse(w, r, err)
-		}
-		return
-	}
-
-	response := envelope{
-		"message": "product unpublished successfully",
-		"id":      productID,
-	}
-	// Return success response
-	app.successResponse(w, http.StatusOK, response)
-}
-
 func (app *application) getProduct(w http.ResponseWriter, r *http.Request) {
 	productID := app.readStringID(r, "productID")
 	user := getUserFromCtx(r)
@@ -307,6 +285,11 @@ func (app *application) getProduct(w http.ResponseWriter, r *http.Request) {
 	app.successResponse(w, http.StatusOK, response)
 }
 
+// productSortSafelist includes best_rated, a Bayesian average that keeps
+// products with only a handful of reviews from topping the list.
+var productSortSafelist = []string{"created_at", "-created_at", "rating_avg", "-rating_avg",
+	"rating_count", "-rating_count", "best_rated", "-best_rated"}
+
 func (app *application) getProducts(w http.ResponseWriter, r *http.Request) {
 	user := getUserFromCtx(r)
 	var vendorID *string
@@ -324,7 +307,7 @@ func (app *application) getProducts(w http.ResponseWriter, r *http.Request) {
 		Page:         1,
 		PageSize:     20,
 		Sort:         "created_at",
-		SortSafelist: []string{"created_at", "-created_at"},
+		SortSafelist: productSortSafelist,
 		Filters: &modelfilter.GetProductsFilter{
 			VendorID:  vendorID,
 			AdminView: user.IsAdmin(),
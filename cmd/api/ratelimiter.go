@@ -195,9 +195,21 @@ func (app *application) setRateLimit() {
 		}},
 	})
 
-	// POST /v1/auth/google
+	// GET /v1/auth/oauth/{provider}
 	addEndpoint(ratelimiter.EndpointConfig{
-		Path:   "/v1/auth/google",
+		Path:   "/v1/auth/oauth/{provider}",
+		Method: "GET",
+		Rules: []ratelimiter.RateLimitRule{{
+			Strategy: ratelimiter.AnonymousStrategy,
+			Limit:    10,
+			Period:   time.Minute,
+			KeyFunc:  ipBaseRateLimiterGetter,
+		}},
+	})
+
+	// POST /v1/auth/oauth/{provider}
+	addEndpoint(ratelimiter.EndpointConfig{
+		Path:   "/v1/auth/oauth/{provider}",
 		Method: "POST",
 		Rules: []ratelimiter.RateLimitRule{{
 			Strategy: ratelimiter.AnonymousStrategy,
@@ -207,9 +219,21 @@ func (app *application) setRateLimit() {
 		}},
 	})
 
-	// POST /v1/auth/google/callback
+	// GET /v1/auth/oauth/{provider}/callback
+	addEndpoint(ratelimiter.EndpointConfig{
+		Path:   "/v1/auth/oauth/{provider}/callback",
+		Method: "GET",
+		Rules: []ratelimiter.RateLimitRule{{
+			Strategy: ratelimiter.AnonymousStrategy,
+			Limit:    10,
+			Period:   time.Minute,
+			KeyFunc:  ipBaseRateLimiterGetter,
+		}},
+	})
+
+	// POST /v1/auth/oauth/{provider}/callback
 	addEndpoint(ratelimiter.EndpointConfig{
-		Path:   "/v1/auth/google/callback",
+		Path:   "/v1/auth/oauth/{provider}/callback",
 		Method: "POST",
 		Rules: []ratelimiter.RateLimitRule{{
 			Strategy: ratelimiter.AnonymousStrategy,
@@ -323,6 +347,71 @@ func (app *application) setRateLimit() {
 		}},
 	})
 
+	// POST /v1/users/api-keys (requires authentication)
+	addEndpoint(ratelimiter.EndpointConfig{
+		Path:   "/v1/users/api-keys",
+		Method: "POST",
+		Rules: []ratelimiter.RateLimitRule{{
+			Strategy:  ratelimiter.AuthenticatedStrategy,
+			Limit:     5,
+			Period:    time.Minute,
+			KeyFunc:   userBaseRateLimiterGetter,
+			Condition: ratelimiter.IsAuthenticated,
+		}},
+	})
+
+	// ================== OAuth Server Endpoints ==================
+
+	// POST /v1/oauth/token
+	addEndpoint(ratelimiter.EndpointConfig{
+		Path:   "/v1/oauth/token",
+		Method: "POST",
+		Rules: []ratelimiter.RateLimitRule{{
+			Strategy: ratelimiter.AnonymousStrategy,
+			Limit:    30,
+			Period:   time.Minute,
+			KeyFunc:  ipBaseRateLimiterGetter,
+		}},
+	})
+
+	// POST /v1/oauth/introspect
+	addEndpoint(ratelimiter.EndpointConfig{
+		Path:   "/v1/oauth/introspect",
+		Method: "POST",
+		Rules: []ratelimiter.RateLimitRule{{
+			Strategy: ratelimiter.AnonymousStrategy,
+			Limit:    60,
+			Period:   time.Minute,
+			KeyFunc:  ipBaseRateLimiterGetter,
+		}},
+	})
+
+	// POST /v1/oauth/authorize (requires authentication)
+	addEndpoint(ratelimiter.EndpointConfig{
+		Path:   "/v1/oauth/authorize",
+		Method: "POST",
+		Rules: []ratelimiter.RateLimitRule{{
+			Strategy:  ratelimiter.AuthenticatedStrategy,
+			Limit:     10,
+			Period:    time.Minute,
+			KeyFunc:   userBaseRateLimiterGetter,
+			Condition: ratelimiter.IsAuthenticated,
+		}},
+	})
+
+	// POST /v1/oauth/clients (requires authentication)
+	addEndpoint(ratelimiter.EndpointConfig{
+		Path:   "/v1/oauth/clients",
+		Method: "POST",
+		Rules: []ratelimiter.RateLimitRule{{
+			Strategy:  ratelimiter.AuthenticatedStrategy,
+			Limit:     5,
+			Period:    time.Minute,
+			KeyFunc:   userBaseRateLimiterGetter,
+			Condition: ratelimiter.IsAuthenticated,
+		}},
+	})
+
 	// ================== File Endpoints ==================
 
 	// POST /v1/files/image
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/devphaseX/buyr-api.git/internal/store"
+)
+
+type reportReviewForm struct {
+	Reason  store.ReviewReportReason `json:"reason" validate:"required,oneof=spam offensive off_topic fake other"`
+	Details string                   `json:"details" validate:"required_if=Reason other,max=1000"`
+}
+
+func (app *application) reportReview(w http.ResponseWriter, r *http.Request) {
+	var (
+		user      = getUserFromCtx(r)
+		productID = app.readStringID(r, "productID")
+		reviewID  = app.readStringID(r, "reviewID")
+		form      reportReviewForm
+	)
+
+	if err := app.readJSON(w, r, &form); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := validate.Struct(form); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if _, err := app.store.Reviews.GetByID(r.Context(), productID, reviewID); err != nil {
+		switch {
+		case errors.Is(err, store.ErrRecordNotFound):
+			app.notFoundResponse(w, r, "review not found")
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	report := &store.ReviewReport{
+		ReviewID:   reviewID,
+		ReporterID: user.ID,
+		Reason:     form.Reason,
+		Details:    form.Details,
+	}
+
+	if err := app.store.Reviews.Report(r.Context(), report); err != nil {
+		switch {
+		case errors.Is(err, store.ErrRecordNotFound):
+			app.notFoundResponse(w, r, "review not found")
+		case errors.Is(err, store.ErrOwnReviewReport):
+			app.forbiddenResponse(w, r, err.Error())
+		case errors.Is(err, store.ErrDuplicateReviewReport):
+			app.conflictResponse(w, r, err.Error())
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.successResponse(w, http.StatusCreated, envelope{
+		"report":  report,
+		"message": "review reported successfully",
+	})
+}
+
+func (app *application) getReviewModerationQueue(w http.ResponseWriter, r *http.Request) {
+	fq := store.PaginateQueryFilter{
+		Page:         1,
+		PageSize:     20,
+		Sort:         "-open_reports",
+		SortSafelist: []string{"open_reports", "-open_reports", "created_at", "-created_at"},
+	}
+
+	if err := fq.Parse(r); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	items, metadata, err := app.store.Reviews.GetModerationQueue(r.Context(), fq)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.successResponse(w, http.StatusOK, envelope{
+		"reviews":  items,
+		"metadata": metadata,
+	})
+}
+
+type moderateReviewForm struct {
+	Reason string `json:"reason" validate:"max=1000"`
+}
+
+var reviewModerationAuditEvents = map[store.ReviewModerationAction]store.AuditEventType{
+	store.HideReviewModerationAction:    store.ReviewHiddenAuditEventType,
+	store.RestoreReviewModerationAction: store.ReviewRestoredAuditEventType,
+	store.DismissReviewModerationAction: store.ReviewDismissedAuditEventType,
+}
+
+func (app *application) hideReview(w http.ResponseWriter, r *http.Request) {
+	app.moderateReview(w, r, store.HideReviewModerationAction)
+}
+
+func (app *application) restoreReview(w http.ResponseWriter, r *http.Request) {
+	app.moderateReview(w, r, store.RestoreReviewModerationAction)
+}
+
+func (app *application) dismissReviewReports(w http.ResponseWriter, r *http.Request) {
+	app.moderateReview(w, r, store.DismissReviewModerationAction)
+}
+
+func (app *application) moderateReview(w http.ResponseWriter, r *http.Request, action store.ReviewModerationAction) {
+	var (
+		user     = getUserFromCtx(r)
+		reviewID = app.readStringID(r, "reviewID")
+		form     moderateReviewForm
+	)
+
+	if r.ContentLength != 0 {
+		if err := app.readJSON(w, r, &form); err != nil {
+			app.badRequestResponse(w, r, err)
+			return
+		}
+	}
+
+	if err := validate.Struct(form); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if action == store.HideReviewModerationAction && form.Reason == "" {
+		app.badRequestResponse(w, r, errors.New("reason is required to hide a review"))
+		return
+	}
+
+	moderation, err := app.store.Reviews.Moderate(r.Context(), reviewID, user.AdminUser.ID, action, form.Reason)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrRecordNotFound):
+			app.notFoundResponse(w, r, "review not found")
+		case errors.Is(err, store.ErrNoOpenReviewReports):
+			app.conflictResponse(w, r, err.Error())
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	details, _ := json.Marshal(map[string]any{
+		"review_id":      moderation.Review.ID,
+		"product_id":     moderation.Review.ProductID,
+		"status":         moderation.Review.Status,
+		"reports_closed": moderation.ReportsClosed,
+	})
+
+	event := store.AuditEvent{
+		EventType:   reviewModerationAuditEvents[action],
+		AccountID:   moderation.Review.UserID,
+		PerformedBy: user.AdminUser.ID,
+		Reason:      form.Reason,
+		Details:     details,
+		AccessLevel: store.AdminLevelManager.GetRank(),
+		Timestamp:   time.Now().UTC(),
+		IPAddress:   r.RemoteAddr,
+		UserAgent:   r.UserAgent(),
+	}
+
+	app.background(func() {
+		if err := app.store.AuditLogs.LogEvent(context.Background(), event); err != nil {
+			app.logger.Error("failed to log audit event", "error", err)
+		}
+	})
+
+	app.successResponse(w, http.StatusOK, envelope{
+		"moderation": moderation,
+	})
+}
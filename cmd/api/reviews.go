@@ -1,12 +1,35 @@
 package main
 
 import (
+	"context"
 	"errors"
+	"mime/multipart"
 	"net/http"
+	"path/filepath"
+
+	"github.com/devphaseX/buyr-api.git/internal/contentfilter"
+	"github.com/devphaseX/buyr-api.git/internal/db"
 
 	"github.com/devphaseX/buyr-api.git/internal/store"
+	"github.com/devphaseX/buyr-api.git/internal/store/modelfilter"
 )
 
+// holdSuspiciousReview keeps reviews with links or profanity out of listings
+// until a moderator looks at them.
+func holdSuspiciousReview(review *store.Review) {
+	result := contentfilter.Check(review.Comment)
+
+	if !result.Flagged() {
+		review.Status = store.PublishedReviewStatus
+		review.ModerationReason = nil
+		return
+	}
+
+	reason := result.String()
+	review.Status = store.HeldReviewStatus
+	review.ModerationReason = &reason
+}
+
 type createCommentRequest struct {
 	Rating  int    `json:"rating" validate:"min=1,max=5"`
 	Comment string `json:"comment" validate:"required,max=500"`
@@ -47,14 +70,28 @@ func (app *application) createReview(w http.ResponseWriter, r *http.Request) {
 		Comment:   form.Comment,
 	}
 
+	holdSuspiciousReview(review)
+
 	if err := app.store.Reviews.Create(r.Context(), review); err != nil {
-		app.serverErrorResponse(w, r, err)
+		switch {
+		case errors.Is(err, store.ErrReviewRequiresPurchase):
+			app.forbiddenResponse(w, r, err.Error())
+		case errors.Is(err, store.ErrDuplicateReview):
+			app.conflictResponse(w, r, err.Error())
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
 		return
 	}
 
+	message := "Review created successfully"
+	if review.Status == store.HeldReviewStatus {
+		message = "Review submitted and is awaiting moderation"
+	}
+
 	response := envelope{
 		"review":  review,
-		"message": "Review created successfully",
+		"message": message,
 	}
 
 	app.successResponse(w, http.StatusCreated, response)
@@ -67,7 +104,8 @@ func (app *application) getProductReviews(w http.ResponseWriter, r *http.Request
 		Page:         1,
 		PageSize:     20,
 		Sort:         "created_at",
-		SortSafelist: []string{"created_at", "-created_at"},
+		SortSafelist: []string{"created_at", "-created_at", "helpful_count", "-helpful_count", "rating", "-rating"},
+		Filters:      &modelfilter.GetReviewsFilter{},
 	}
 
 	if err := fq.Parse(r); err != nil {
@@ -140,6 +178,327 @@ func (app *application) removeReview(w http.ResponseWriter, r *http.Request) {
 	app.successResponse(w, http.StatusOK, response)
 }
 
+func (app *application) updateReview(w http.ResponseWriter, r *http.Request) {
+	var (
+		user      = getUserFromCtx(r)
+		productID = app.readStringID(r, "productID")
+		reviewID  = app.readStringID(r, "reviewID")
+		form      createCommentRequest
+	)
+
+	if err := app.readJSON(w, r, &form); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := validate.Struct(form); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	review := &store.Review{
+		ID:        reviewID,
+		UserID:    user.ID,
+		ProductID: productID,
+		Rating:    form.Rating,
+		Comment:   form.Comment,
+	}
+
+	holdSuspiciousReview(review)
+
+	if err := app.store.Reviews.Update(r.Context(), review); err != nil {
+		switch {
+		case errors.Is(err, store.ErrRecordNotFound):
+			app.notFoundResponse(w, r, "review not found")
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.successResponse(w, http.StatusOK, envelope{
+		"review":  review,
+		"message": "review updated successfully",
+	})
+}
+
+func (app *application) removeOwnReview(w http.ResponseWriter, r *http.Request) {
+	var (
+		user      = getUserFromCtx(r)
+		productID = app.readStringID(r, "productID")
+		reviewID  = app.readStringID(r, "reviewID")
+	)
+
+	if err := app.store.Reviews.DeleteByAuthor(r.Context(), productID, reviewID, user.ID); err != nil {
+		switch {
+		case errors.Is(err, store.ErrRecordNotFound):
+			app.notFoundResponse(w, r, "review not found")
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.successResponse(w, http.StatusOK, envelope{
+		"message": "review deleted successfully",
+		"id":      reviewID,
+	})
+}
+
+type replyReviewForm struct {
+	Reply string `json:"reply" validate:"required,max=1000"`
+}
+
+// replyToReview posts the owning vendor's single public reply to a review.
+func (app *application) replyToReview(w http.ResponseWriter, r *http.Request) {
+	var (
+		user      = getUserFromCtx(r)
+		productID = app.readStringID(r, "productID")
+		reviewID  = app.readStringID(r, "reviewID")
+		form      replyReviewForm
+	)
+
+	if err := app.readJSON(w, r, &form); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := validate.Struct(form); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	vendorUser, err := app.store.Users.GetVendorUserByID(r.Context(), user.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	review, err := app.store.Reviews.Reply(r.Context(), productID, reviewID, vendorUser.ID, form.Reply)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrRecordNotFound):
+			app.notFoundResponse(w, r, "review not found")
+		case errors.Is(err, store.ErrReviewAlreadyReplied):
+			app.conflictResponse(w, r, err.Error())
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.successResponse(w, http.StatusOK, envelope{
+		"review":  review,
+		"message": "reply posted successfully",
+	})
+}
+
+// uploadReviewMedia attaches images from the multipart "images" field to
+// the author's own review.
+func (app *application) uploadReviewMedia(w http.ResponseWriter, r *http.Request) {
+	var (
+		user      = getUserFromCtx(r)
+		productID = app.readStringID(r, "productID")
+		reviewID  = app.readStringID(r, "reviewID")
+	)
+
+	review, err := app.store.Reviews.GetByID(r.Context(), productID, reviewID)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrRecordNotFound):
+			app.notFoundResponse(w, r, "review not found")
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if review.UserID != user.ID {
+		app.notFoundResponse(w, r, "review not found")
+		return
+	}
+
+	if err := r.ParseMultipartForm(MB * 10); err != nil {
+		app.badRequestResponse(w, r, errors.New("invalid body paylaod"))
+		return
+	}
+
+	files := r.MultipartForm.File["images"]
+
+	if len(files) == 0 {
+		app.badRequestResponse(w, r, errors.New("at least one image is required"))
+		return
+	}
+
+	existing, err := app.store.Reviews.CountMedia(r.Context(), review.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	// refuse the request before anything is stored
+	if existing+len(files) > store.MaxReviewMedia {
+		app.badRequestResponse(w, r, store.ErrReviewMediaLimit)
+		return
+	}
+
+	for _, header := range files {
+		if !isImage(header) {
+			app.badRequestResponse(w, r, errors.New("only image files (JPEG, PNG, GIF) are allowed"))
+			return
+		}
+	}
+
+	urls := []string{}
+
+	for _, header := range files {
+		url, err := app.uploadReviewImage(r.Context(), header)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
+		urls = append(urls, url)
+	}
+
+	media, err := app.store.Reviews.AddMedia(r.Context(), review.ID, user.ID, urls)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrRecordNotFound):
+			app.notFoundResponse(w, r, "review not found")
+		case errors.Is(err, store.ErrReviewMediaLimit):
+			app.badRequestResponse(w, r, err)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.successResponse(w, http.StatusCreated, envelope{
+		"media": media,
+	})
+}
+
+// uploadReviewImage stores one image of a review and returns its url
+func (app *application) uploadReviewImage(ctx context.Context, header *multipart.FileHeader) (string, error) {
+	file, err := header.Open()
+	if err != nil {
+		return "", err
+	}
+
+	defer file.Close()
+
+	fileName := db.GenerateULID() + filepath.Ext(header.Filename)
+
+	return app.fileobject.UploadFile(ctx, "reviews", fileName, file)
+}
+
+func (app *application) removeReviewMedia(w http.ResponseWriter, r *http.Request) {
+	var (
+		user     = getUserFromCtx(r)
+		reviewID = app.readStringID(r, "reviewID")
+		mediaID  = app.readStringID(r, "mediaID")
+	)
+
+	if err := app.store.Reviews.RemoveMedia(r.Context(), reviewID, mediaID, user.ID); err != nil {
+		switch {
+		case errors.Is(err, store.ErrRecordNotFound):
+			app.notFoundResponse(w, r, "media not found")
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.successResponse(w, http.StatusOK, envelope{
+		"message": "media deleted successfully",
+		"id":      mediaID,
+	})
+}
+
+type voteReviewForm struct {
+	Helpful *bool `json:"helpful" validate:"required"`
+}
+
+func (app *application) voteReview(w http.ResponseWriter, r *http.Request) {
+	var (
+		user      = getUserFromCtx(r)
+		productID = app.readStringID(r, "productID")
+		reviewID  = app.readStringID(r, "reviewID")
+		form      voteReviewForm
+	)
+
+	if err := app.readJSON(w, r, &form); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := validate.Struct(form); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if _, err := app.store.Reviews.GetByID(r.Context(), productID, reviewID); err != nil {
+		switch {
+		case errors.Is(err, store.ErrRecordNotFound):
+			app.notFoundResponse(w, r, "review not found")
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	review, err := app.store.Reviews.Vote(r.Context(), reviewID, user.ID, *form.Helpful)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrRecordNotFound):
+			app.notFoundResponse(w, r, "review not found")
+		case errors.Is(err, store.ErrOwnReviewVote):
+			app.forbiddenResponse(w, r, err.Error())
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.successResponse(w, http.StatusOK, envelope{
+		"review": review,
+	})
+}
+
+func (app *application) removeReviewVote(w http.ResponseWriter, r *http.Request) {
+	var (
+		user      = getUserFromCtx(r)
+		productID = app.readStringID(r, "productID")
+		reviewID  = app.readStringID(r, "reviewID")
+	)
+
+	if _, err := app.store.Reviews.GetByID(r.Context(), productID, reviewID); err != nil {
+		switch {
+		case errors.Is(err, store.ErrRecordNotFound):
+			app.notFoundResponse(w, r, "review not found")
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	review, err := app.store.Reviews.RemoveVote(r.Context(), reviewID, user.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrRecordNotFound):
+			app.notFoundResponse(w, r, "vote not found")
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.successResponse(w, http.StatusOK, envelope{
+		"review": review,
+	})
+}
+
 func (app *application) getReviewRatingAnalytics(w http.ResponseWriter, r *http.Request) {
 	productID := app.readStringID(r, "productID")
 
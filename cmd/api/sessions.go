@@ -0,0 +1,212 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/devphaseX/buyr-api.git/internal/store"
+	"github.com/devphaseX/buyr-api.git/worker"
+	"github.com/hibiken/asynq"
+)
+
+// getSession loads the session an access token was issued for, preferring
+// the cache. Revoking a session drops its cache entry, so a revoked session
+// is never served from here.
+func (app *application) getSession(ctx context.Context, sessionID string) (*store.Session, error) {
+	if app.cfg.redisCfg.enabled {
+		session, err := app.cacheStore.Sessions.Get(ctx, sessionID)
+
+		if !(err == nil || errors.Is(err, store.ErrRecordNotFound)) {
+			app.logger.Errorf("Error fetching session from cache: %v", err)
+			return nil, err
+		}
+
+		if session != nil {
+			return session, nil
+		}
+	}
+
+	session, _, err := app.store.Sessions.GetSessionByID(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	if app.cfg.redisCfg.enabled {
+		if err := app.cacheStore.Sessions.Set(ctx, session); err != nil {
+			app.logger.Errorf("Error caching session: %v", err)
+		}
+	}
+
+	return session, nil
+}
+
+// forgetSessions removes revoked sessions from the cache.
+func (app *application) forgetSessions(ctx context.Context, sessionIDs ...string) error {
+	if !app.cfg.redisCfg.enabled {
+		return nil
+	}
+
+	return app.cacheStore.Sessions.Delete(ctx, sessionIDs...)
+}
+
+func (app *application) getUserSessions(w http.ResponseWriter, r *http.Request) {
+	fq := store.PaginateQueryFilter{
+		Page:         1,
+		PageSize:     20,
+		Sort:         "-created_at",
+		SortSafelist: []string{"created_at", "-created_at", "last_used", "-last_used"},
+	}
+
+	if err := fq.Parse(r); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	user := getUserFromCtx(r)
+
+	sessions, metadata, err := app.store.Sessions.GetSessionsByUserID(r.Context(), user.ID, false, fq)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	for i := range sessions {
+		sessions[i].Current = sessions[i].ID == user.SessionID
+	}
+
+	app.successResponse(w, http.StatusOK, envelope{
+		"sessions": sessions,
+		"metadata": metadata,
+	})
+}
+
+func (app *application) revokeUserSession(w http.ResponseWriter, r *http.Request) {
+	var (
+		user      = getUserFromCtx(r)
+		sessionID = app.readStringID(r, "sessionID")
+	)
+
+	err := app.store.Sessions.InvalidateUserSession(r.Context(), user.ID, sessionID)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrRecordNotFound):
+			app.notFoundResponse(w, r, "session not found")
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if err := app.forgetSessions(r.Context(), sessionID); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if sessionID == user.SessionID {
+		app.clearAuthCookies(w)
+	}
+
+	app.successResponse(w, http.StatusOK, envelope{
+		"message": "session revoked",
+		"id":      sessionID,
+	})
+}
+
+// revokeOtherSessions signs the user out everywhere but the current session.
+func (app *application) revokeOtherSessions(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromCtx(r)
+
+	sessionIDs, err := app.store.Sessions.InvalidateOtherSessions(r.Context(), user.ID, user.SessionID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if err := app.forgetSessions(r.Context(), sessionIDs...); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.successResponse(w, http.StatusOK, envelope{
+		"message": "signed out of all other sessions",
+		"revoked": len(sessionIDs),
+	})
+}
+
+func (app *application) signOut(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromCtx(r)
+
+	if err := app.store.Sessions.InvalidateSession(r.Context(), user.SessionID); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if err := app.forgetSessions(r.Context(), user.SessionID); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.clearAuthCookies(w)
+
+	app.successResponse(w, http.StatusOK, envelope{
+		"message": "signed out",
+	})
+}
+
+// revokeReusedSession handles a refresh token presented after it was already
+// rotated. Either the legitimate client or an attacker holds a stolen copy and
+// there is no telling which, so the session and every token issued from it
+// are revoked, the event is audited and the user is warned by email.
+func (app *application) revokeReusedSession(r *http.Request, session *store.Session, user *store.User) {
+	ctx := r.Context()
+
+	if err := app.store.Sessions.InvalidateSession(ctx, session.ID); err != nil {
+		app.logger.Errorw("failed to revoke reused session", "session_id", session.ID, "error", err)
+	}
+
+	if err := app.forgetSessions(ctx, session.ID); err != nil {
+		app.logger.Errorw("failed to drop cached session", "session_id", session.ID, "error", err)
+	}
+
+	detectedAt := time.Now().UTC()
+
+	details, _ := json.Marshal(map[string]any{
+		"session_id":         session.ID,
+		"session_ip":         session.IP,
+		"session_user_agent": session.UserAgent,
+	})
+
+	app.background(func() {
+		if err := app.store.AuditLogs.LogEvent(context.Background(), store.AuditEvent{
+			EventType:       store.RefreshTokenReusedAuditEventType,
+			AccountID:       user.ID,
+			PerformedBy:     store.SystemAuditActor,
+			PerformedByType: store.SystemAuditActorType,
+			Reason:          "refresh token reused; session revoked",
+			Details:         details,
+			AccessLevel:     store.AdminLevelSupport.GetRank(),
+			Timestamp:       detectedAt,
+			IPAddress:       r.RemoteAddr,
+			UserAgent:       r.UserAgent(),
+		}); err != nil {
+			app.logger.Errorw("failed to log audit event", "error", err)
+		}
+	})
+
+	err := app.taskDistributor.DistributeTaskSendSuspiciousActivityEmail(ctx, &worker.PayloadSendSuspiciousActivityEmail{
+		Username:   app.displayName(ctx, user),
+		Email:      user.Email,
+		SessionID:  session.ID,
+		IP:         r.RemoteAddr,
+		UserAgent:  r.UserAgent(),
+		DetectedAt: detectedAt,
+		ClientURL:  app.cfg.clientURL,
+	}, asynq.MaxRetry(3), asynq.Queue(worker.QueueCritical))
+
+	if err != nil {
+		app.logger.Errorw("failed to enqueue suspicious activity email", "user_id", user.ID, "error", err)
+	}
+}
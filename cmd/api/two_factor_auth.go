@@ -1,11 +1,18 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"net/http"
+	"slices"
+	"time"
 
 	"github.com/devphaseX/buyr-api.git/internal/encrypt"
 	"github.com/devphaseX/buyr-api.git/internal/store"
+	"github.com/devphaseX/buyr-api.git/internal/store/cache"
+	"github.com/devphaseX/buyr-api.git/worker"
+	"github.com/hibiken/asynq"
 )
 
 func (app *application) setup2fa(w http.ResponseWriter, r *http.Request) {
@@ -176,3 +183,321 @@ func (app *application) resetRecoveryCodes(w http.ResponseWriter, r *http.Reques
 	})
 
 }
+
+// logTwoFactorEvent records a change to a user's second factors, made by the
+// user or by an admin.
+func (app *application) logTwoFactorEvent(r *http.Request, eventType store.AuditEventType, accountID, performedBy string, actorType store.AuditActorType, reason string) {
+	app.background(func() {
+		if err := app.store.AuditLogs.LogEvent(context.Background(), store.AuditEvent{
+			EventType:       eventType,
+			AccountID:       accountID,
+			PerformedBy:     performedBy,
+			PerformedByType: actorType,
+			Reason:          reason,
+			AccessLevel:     store.AdminLevelSupport.GetRank(),
+			Timestamp:       time.Now().UTC(),
+			IPAddress:       r.RemoteAddr,
+			UserAgent:       r.UserAgent(),
+		}); err != nil {
+			app.logger.Errorw("failed to log audit event", "error", err)
+		}
+	})
+}
+
+type confirm2faForm struct {
+	Password     string `json:"password" validate:"required"`
+	Code         string `json:"code" validate:"required_without=RecoveryCode,omitempty,len=6"`
+	RecoveryCode string `json:"recovery_code" validate:"required_without=Code,omitempty,len=10"`
+}
+
+// confirm2fa checks the password and either a TOTP code or one of the
+// recovery codes before the authenticator is turned off or replaced. Failed
+// attempts count towards the account lockout like a sign-in, and a recovery
+// code is used up once accepted. It writes the response and returns nil when
+// the check fails.
+func (app *application) confirm2fa(w http.ResponseWriter, r *http.Request) *AuthInfo {
+	var form confirm2faForm
+
+	if err := app.readJSON(w, r, &form); err != nil {
+		app.badRequestResponse(w, r, err)
+		return nil
+	}
+
+	if err := validate.Struct(form); err != nil {
+		app.badRequestResponse(w, r, err)
+		return nil
+	}
+
+	user, err := app.getUser(r.Context(), getUserFromCtx(r).ID, true)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return nil
+	}
+
+	if !user.TwoFactorAuthEnabled {
+		app.forbiddenResponse(w, r, "2fa not enabled")
+		return nil
+	}
+
+	attempt, ok := app.reserveLoginAttempt(w, r, user.User)
+	if !ok {
+		return nil
+	}
+
+	defer attempt.release(r.Context())
+
+	if match := app.withPasswordAccess(r, form.Password); !match {
+		attempt.failed(w, r, func() { app.forbiddenResponse(w, r, "password not a match") })
+		return nil
+	}
+
+	if form.Code != "" {
+		valid, err := app.verify2faCode(r.Context(), user.User, store.TotpFactorType, form.Code, "")
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return nil
+		}
+
+		if !valid {
+			attempt.failed(w, r, func() { app.invalid2faCodeResponse(w, r) })
+			return nil
+		}
+
+		attempt.succeeded(r.Context())
+		return user
+	}
+
+	recoveryCodes, err := encrypt.DecryptRecoveryCodes(user.RecoveryCodes, app.cfg.encryptConfig.masterSecretKey)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return nil
+	}
+
+	index := slices.Index(recoveryCodes, form.RecoveryCode)
+	if index == -1 {
+		attempt.failed(w, r, func() { app.unauthorizedResponse(w, r, "invalid recovery code") })
+		return nil
+	}
+
+	// codes are encrypted one by one, so the used one is removed by its
+	// ciphertext, and only one of several concurrent requests gets to
+	err = app.store.Users.ConsumeRecoveryCode(r.Context(), user.ID, user.RecoveryCodes[index])
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrRecordNotFound):
+			attempt.failed(w, r, func() { app.unauthorizedResponse(w, r, "invalid recovery code") })
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return nil
+	}
+
+	attempt.succeeded(r.Context())
+	return user
+}
+
+func (app *application) disable2fa(w http.ResponseWriter, r *http.Request) {
+	user := app.confirm2fa(w, r)
+	if user == nil {
+		return
+	}
+
+	if err := app.store.Users.DisableTwoFactorAuth(r.Context(), user.ID); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.logTwoFactorEvent(r, store.TwoFactorDisabledAuditEventType, user.ID, user.ID, store.UserAuditActorType, "authenticator app 2fa disabled by user")
+
+	app.successResponse(w, http.StatusOK, envelope{
+		"message": "2fa disabled",
+	})
+}
+
+// reenroll2fa starts binding a new authenticator. The current one keeps
+// working until the new secret is confirmed with a code from the new device.
+func (app *application) reenroll2fa(w http.ResponseWriter, r *http.Request) {
+	user := app.confirm2fa(w, r)
+	if user == nil {
+		return
+	}
+
+	secret, qr, err := app.totp.GenerateSecret(app.cfg.authConfig.totpIssuerName, app.displayName(r.Context(), user.User), 256)
+	if err != nil {
+		app.serverErrorResponse(w, r, fmt.Errorf("failed to generate TOTP secret: %w", err))
+		return
+	}
+
+	encryptedSecret, err := encrypt.EncryptSecret(secret, app.cfg.encryptConfig.masterSecretKey)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if err := app.cacheStore.Tokens.DeleteAllForUser(r.Context(), cache.TotpReenrollTokenScope, user.ID); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	token, err := app.cacheStore.Tokens.New(user.ID, time.Minute*10, cache.TotpReenrollTokenScope, []byte(encryptedSecret))
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if err := app.cacheStore.Tokens.Insert(r.Context(), token); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.successResponse(w, http.StatusOK, envelope{
+		"secret":         secret,
+		"qr":             qr,
+		"reenroll_token": token.Plaintext,
+	})
+}
+
+type verifyReenroll2faForm struct {
+	ReenrollToken string `json:"reenroll_token" validate:"required"`
+	Code          string `json:"code" validate:"min=6,max=6"`
+}
+
+func (app *application) verifyReenroll2fa(w http.ResponseWriter, r *http.Request) {
+	var (
+		form verifyReenroll2faForm
+		user = getUserFromCtx(r)
+	)
+
+	if err := app.readJSON(w, r, &form); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := validate.Struct(form); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	token, err := app.cacheStore.Tokens.Get(r.Context(), cache.TotpReenrollTokenScope, form.ReenrollToken)
+	if err != nil || token.UserID != user.ID {
+		app.notFoundResponse(w, r, "token invalid or expired")
+		return
+	}
+
+	secret, err := encrypt.DecryptSecret(string(token.Data), app.cfg.encryptConfig.masterSecretKey)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if !app.totp.VerifyCode(secret, form.Code) {
+		app.invalid2faCodeResponse(w, r)
+		return
+	}
+
+	recoveryCodes, err := encrypt.GenerateRecoveryCodes(10, 10)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	encryptedCodes, err := encrypt.EncryptRecoveryCodes(recoveryCodes, app.cfg.encryptConfig.masterSecretKey)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if err := app.store.Users.EnableTwoFactorAuth(r.Context(), user.ID, string(token.Data), encryptedCodes); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if err := app.cacheStore.Tokens.DeleteAllForUser(r.Context(), cache.TotpReenrollTokenScope, user.ID); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.logTwoFactorEvent(r, store.TwoFactorReenrolledAuditEventType, user.ID, user.ID, store.UserAuditActorType, "authenticator app replaced by user")
+
+	app.successResponse(w, http.StatusOK, envelope{
+		"message":        "new authenticator bound",
+		"recovery_codes": recoveryCodes,
+	})
+}
+
+type reset2faRequest struct {
+	Reason string `json:"reason" validate:"required"`
+}
+
+// reset2fa lets a super admin clear every second factor of a user who lost
+// access to all of them, after verifying their identity out of band. The
+// user is emailed so an unrequested reset does not go unnoticed.
+func (app *application) reset2fa(w http.ResponseWriter, r *http.Request) {
+	var (
+		form   reset2faRequest
+		admin  = getUserFromCtx(r)
+		userID = app.readStringID(r, "userID")
+	)
+
+	if err := app.readJSON(w, r, &form); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := validate.Struct(form); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	user, err := app.store.Users.GetByID(r.Context(), userID)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrRecordNotFound):
+			app.notFoundResponse(w, r, "user not found")
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if err := app.store.Users.DisableTwoFactorAuth(r.Context(), user.ID); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if err := app.store.Users.SetEmail2fa(r.Context(), user.ID, false); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if err := app.store.WebAuthnCredentials.DeleteAllForUser(r.Context(), user.ID); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	for _, scope := range []cache.TokenScope{cache.Login2faTokenScope, cache.TotpReenrollTokenScope, cache.Email2faCodeTokenScope} {
+		if err := app.cacheStore.Tokens.DeleteAllForUser(r.Context(), scope, user.ID); err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+	}
+
+	app.logTwoFactorEvent(r, store.TwoFactorResetAuditEventType, user.ID, admin.AdminUser.ID, store.AdminAuditActorType, form.Reason)
+
+	err = app.taskDistributor.DistributeTaskSendTwoFactorResetEmail(r.Context(), &worker.PayloadSendTwoFactorResetEmail{
+		Username:  app.displayName(r.Context(), user),
+		Email:     user.Email,
+		UserID:    user.ID,
+		ResetAt:   time.Now().UTC(),
+		ClientURL: app.cfg.clientURL,
+	}, asynq.MaxRetry(3), asynq.Queue(worker.QueueCritical))
+
+	if err != nil {
+		app.logger.Errorw("failed to enqueue 2fa reset email", "user_id", user.ID, "error", err)
+	}
+
+	app.successResponse(w, http.StatusOK, envelope{
+		"message": "2fa reset",
+		"user_id": user.ID,
+	})
+}
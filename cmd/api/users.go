@@ -88,6 +88,24 @@ func (app *application) getUser(ctx context.Context, userID string, forceDbFetch
 	return authInfo, nil
 }
 
+// displayName is how emails greet a user: the business name of a vendor,
+// otherwise their full name, falling back to the email address.
+func (app *application) displayName(ctx context.Context, user *store.User) string {
+	profile, err := app.store.Users.FlattenUser(ctx, user)
+	if err != nil {
+		return user.Email
+	}
+
+	switch {
+	case profile.BusinessName != "":
+		return profile.BusinessName
+	case profile.FirstName != "":
+		return fmt.Sprintf("%s %s", profile.FirstName, profile.LastName)
+	default:
+		return user.Email
+	}
+}
+
 func (app *application) getNormalUsers(w http.ResponseWriter, r *http.Request) {
 	fq := store.PaginateQueryFilter{
 		Page:         1,
@@ -150,7 +168,13 @@ func (app *application) changePassword(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if user.User.TwoFactorAuthEnabled {
+	account, err := app.getUser(r.Context(), user.ID, true)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if methods := codeTwoFactorMethods(account.User); len(methods) > 0 {
 
 		payload, err := json.Marshal(&changePassword2faPayload{
 			Email:       user.Email,
@@ -176,7 +200,7 @@ func (app *application) changePassword(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		app.required2faCodeResponse(w, r, token.Plaintext, []store.TwoFactorType{store.TotpFactorType})
+		app.required2faCodeResponse(w, r, token.Plaintext, methods)
 		return
 	}
 
@@ -197,8 +221,9 @@ func (app *application) changePassword(w http.ResponseWriter, r *http.Request) {
 }
 
 type verifyChangePassword2faRequest struct {
-	MfaToken string `json:"mfa_token" validate:"required"`
-	Code     string `json:"code" validate:"min=6,max=6"`
+	MfaToken string              `json:"mfa_token" validate:"required"`
+	Code     string              `json:"code" validate:"min=6,max=6"`
+	Method   store.TwoFactorType `json:"method" validate:"omitempty,oneof=totp email"`
 }
 
 func (app *application) verifyChangePassword2fa(w http.ResponseWriter, r *http.Request) {
@@ -243,6 +268,23 @@ func (app *application) verifyChangePassword2fa(w http.ResponseWriter, r *http.R
 		return
 	}
 
+	account, err := app.getUser(r.Context(), user.ID, true)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	verified, err := app.verify2faCode(r.Context(), account.User, form.Method, form.Code, cache.ChangePassword2faTokenScope)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if !verified {
+		app.invalid2faCodeResponse(w, r)
+		return
+	}
+
 	if err := user.User.Password.Set(payload.NewPassword); err != nil {
 		app.serverErrorResponse(w, r, err)
 		return
@@ -311,7 +353,13 @@ func (app *application) initiateEmailChange(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	if user.TwoFactorAuthEnabled {
+	account, err := app.getUser(r.Context(), user.ID, true)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if methods := codeTwoFactorMethods(account.User); len(methods) > 0 {
 		payload, _ := json.Marshal(initialEmailChangePayload{Email: form.NewEmail})
 
 		token, err := app.cacheStore.Tokens.New(user.ID, time.Minute*30, cache.ChangeEmail2faTokenScope, payload)
@@ -328,7 +376,7 @@ func (app *application) initiateEmailChange(w http.ResponseWriter, r *http.Reque
 			return
 		}
 
-		app.required2faCodeResponse(w, r, token.Plaintext, []store.TwoFactorType{store.TotpFactorType})
+		app.required2faCodeResponse(w, r, token.Plaintext, methods)
 		return
 	}
 
@@ -384,8 +432,9 @@ func (app *application) sendEmailChangeToken(w http.ResponseWriter, r *http.Requ
 }
 
 type verifyEmailChange2faRequest struct {
-	MfaToken string `json:"mfa_token" validate:"required"`
-	Code     string `json:"code" validate:"min=6,max=6"`
+	MfaToken string              `json:"mfa_token" validate:"required"`
+	Code     string              `json:"code" validate:"min=6,max=6"`
+	Method   store.TwoFactorType `json:"method" validate:"omitempty,oneof=totp email"`
 }
 
 func (app *application) verifyEmailChange2fa(w http.ResponseWriter, r *http.Request) {
@@ -427,7 +476,13 @@ func (app *application) verifyEmailChange2fa(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
-	verified, err := app.verifyTOTP(user.User, form.Code)
+	account, err := app.getUser(r.Context(), user.ID, true)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	verified, err := app.verify2faCode(r.Context(), account.User, form.Method, form.Code, cache.ChangeEmail2faTokenScope)
 
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
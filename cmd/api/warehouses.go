@@ -0,0 +1,234 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/devphaseX/buyr-api.git/internal/store"
+)
+
+type createWarehouseRequest struct {
+	Name          string `json:"name" validate:"required,max=100"`
+	StreetAddress string `json:"street_address" validate:"required,max=255"`
+	City          string `json:"city" validate:"required,max=100"`
+	State         string `json:"state" validate:"required,max=100"`
+	PostalCode    string `json:"postal_code" validate:"required,max=20"`
+	Country       string `json:"country" validate:"required,max=100"`
+	IsActive      *bool  `json:"is_active"`
+}
+
+type updateWarehouseRequest struct {
+	Name          *string `json:"name" validate:"omitempty,min=1,max=100"`
+	StreetAddress *string `json:"street_address" validate:"omitempty,min=1,max=255"`
+	City          *string `json:"city" validate:"omitempty,min=1,max=100"`
+	State         *string `json:"state" validate:"omitempty,min=1,max=100"`
+	PostalCode    *string `json:"postal_code" validate:"omitempty,min=1,max=20"`
+	Country       *string `json:"country" validate:"omitempty,min=1,max=100"`
+	IsActive      *bool   `json:"is_active"`
+}
+
+func (app *application) createWarehouse(w http.ResponseWriter, r *http.Request) {
+	var (
+		user = getUserFromCtx(r)
+		form createWarehouseRequest
+	)
+
+	if err := app.readJSON(w, r, &form); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := validate.Struct(form); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	vendorUser, err := app.store.Users.GetVendorUserByID(r.Context(), user.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	warehouse := &store.Warehouse{
+		VendorID:      vendorUser.ID,
+		Name:          form.Name,
+		StreetAddress: form.StreetAddress,
+		City:          form.City,
+		State:         form.State,
+		PostalCode:    form.PostalCode,
+		Country:       form.Country,
+		IsActive:      form.IsActive == nil || *form.IsActive,
+	}
+
+	if err := app.store.Warehouses.Create(r.Context(), warehouse); err != nil {
+		switch {
+		case errors.Is(err, store.ErrDuplicateWarehouseName):
+			app.conflictResponse(w, r, err.Error())
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.successResponse(w, http.StatusCreated, envelope{"warehouse": warehouse})
+}
+
+func (app *application) getWarehouses(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromCtx(r)
+
+	vendorUser, err := app.store.Users.GetVendorUserByID(r.Context(), user.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	warehouses, err := app.store.Warehouses.GetByVendorID(r.Context(), vendorUser.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.successResponse(w, http.StatusOK, envelope{"warehouses": warehouses})
+}
+
+func (app *application) getWarehouse(w http.ResponseWriter, r *http.Request) {
+	var (
+		user        = getUserFromCtx(r)
+		warehouseID = app.readStringID(r, "warehouseID")
+	)
+
+	vendorUser, err := app.store.Users.GetVendorUserByID(r.Context(), user.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	warehouse, err := app.store.Warehouses.GetByID(r.Context(), warehouseID, vendorUser.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrWarehouseNotFound):
+			app.notFoundResponse(w, r, err.Error())
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	stock, err := app.store.Warehouses.GetStock(r.Context(), warehouse.ID, vendorUser.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.successResponse(w, http.StatusOK, envelope{
+		"warehouse": warehouse,
+		"stock":     stock,
+	})
+}
+
+func (app *application) updateWarehouse(w http.ResponseWriter, r *http.Request) {
+	var (
+		user        = getUserFromCtx(r)
+		warehouseID = app.readStringID(r, "warehouseID")
+		form        updateWarehouseRequest
+	)
+
+	if err := app.readJSON(w, r, &form); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := validate.Struct(form); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	vendorUser, err := app.store.Users.GetVendorUserByID(r.Context(), user.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	warehouse, err := app.store.Warehouses.GetByID(r.Context(), warehouseID, vendorUser.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrWarehouseNotFound):
+			app.notFoundResponse(w, r, err.Error())
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if form.Name != nil {
+		warehouse.Name = *form.Name
+	}
+
+	if form.StreetAddress != nil {
+		warehouse.StreetAddress = *form.StreetAddress
+	}
+
+	if form.City != nil {
+		warehouse.City = *form.City
+	}
+
+	if form.State != nil {
+		warehouse.State = *form.State
+	}
+
+	if form.PostalCode != nil {
+		warehouse.PostalCode = *form.PostalCode
+	}
+
+	if form.Country != nil {
+		warehouse.Country = *form.Country
+	}
+
+	if form.IsActive != nil {
+		warehouse.IsActive = *form.IsActive
+	}
+
+	if err := app.store.Warehouses.Update(r.Context(), warehouse); err != nil {
+		switch {
+		case errors.Is(err, store.ErrWarehouseNotFound):
+			app.notFoundResponse(w, r, err.Error())
+		case errors.Is(err, store.ErrDuplicateWarehouseName):
+			app.conflictResponse(w, r, err.Error())
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.successResponse(w, http.StatusOK, envelope{"warehouse": warehouse})
+}
+
+func (app *application) deleteWarehouse(w http.ResponseWriter, r *http.Request) {
+	var (
+		user        = getUserFromCtx(r)
+		warehouseID = app.readStringID(r, "warehouseID")
+	)
+
+	vendorUser, err := app.store.Users.GetVendorUserByID(r.Context(), user.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if err := app.store.Warehouses.Delete(r.Context(), warehouseID, vendorUser.ID); err != nil {
+		switch {
+		case errors.Is(err, store.ErrWarehouseNotFound):
+			app.notFoundResponse(w, r, err.Error())
+		case errors.Is(err, store.ErrWarehouseHasStock):
+			app.conflictResponse(w, r, "move the stock out of this warehouse before deleting it")
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.successResponse(w, http.StatusOK, envelope{
+		"message": "warehouse deleted successfully",
+		"id":      warehouseID,
+	})
+}
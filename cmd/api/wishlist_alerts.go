@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+
+	"github.com/devphaseX/buyr-api.git/internal/store"
+	"github.com/devphaseX/buyr-api.git/worker"
+)
+
+// queueWishlistAlerts compares a product before and after a vendor change and
+// enqueues price-drop or back-in-stock alerts for users who wishlisted it.
+func (app *application) queueWishlistAlerts(before, after *store.Product) {
+	var kinds []store.WishlistAlertKind
+
+	if before.StockQuantity <= 0 && after.StockQuantity > 0 {
+		kinds = append(kinds, store.BackInStockWishlistAlertKind)
+	}
+
+	if after.EffectivePrice < before.EffectivePrice {
+		kinds = append(kinds, store.PriceDropWishlistAlertKind)
+	}
+
+	for _, kind := range kinds {
+		payload := &worker.PayloadSendWishlistAlerts{
+			ProductID: after.ID,
+			Kind:      kind,
+			ClientURL: app.cfg.clientURL,
+		}
+
+		app.background(func() {
+			if err := app.taskDistributor.DistributeTaskSendWishlistAlerts(context.Background(), payload); err != nil {
+				app.logger.Error("failed to enqueue wishlist alerts", "product_id", payload.ProductID, "error", err)
+			}
+		})
+	}
+}
+
+// queueWishlistAlertsAfterChange reloads the product after a successful
+// change and queues alerts against the snapshot taken before it.
+func (app *application) queueWishlistAlertsAfterChange(ctx context.Context, before *store.Product) {
+	after, err := app.store.Products.GetProductByID(ctx, before.ID)
+	if err != nil {
+		app.logger.Error("failed to reload product for wishlist alerts", "product_id", before.ID, "error", err)
+		return
+	}
+
+	app.queueWishlistAlerts(before, after)
+}
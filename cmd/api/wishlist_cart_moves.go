@@ -0,0 +1,97 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/devphaseX/buyr-api.git/internal/store"
+)
+
+type moveWishlistItemsToCartRequest struct {
+	ItemIDs []string `json:"item_ids" validate:"required,min=1,max=50,unique,dive,required"`
+}
+
+type moveCartItemsToWishlistRequest struct {
+	ItemIDs      []string `json:"item_ids" validate:"required,min=1,max=50,unique,dive,required"`
+	CollectionID string   `json:"collection_id"`
+}
+
+func (app *application) moveWishlistItemsToCart(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromCtx(r)
+
+	var form moveWishlistItemsToCartRequest
+
+	if err := app.readJSON(w, r, &form); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := validate.Struct(form); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	cart, err := app.store.Carts.GetCartByUserID(user.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	results, err := app.store.CartItems.MoveFromWishlist(r.Context(), cart.ID, user.ID, form.ItemIDs)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.writeItemMoveResults(w, results)
+}
+
+func (app *application) moveCartItemsToWishlist(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromCtx(r)
+
+	var form moveCartItemsToWishlistRequest
+
+	if err := app.readJSON(w, r, &form); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := validate.Struct(form); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	cart, err := app.store.Carts.GetCartByUserID(user.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	results, err := app.store.CartItems.MoveToWishlist(r.Context(), cart.ID, user.ID, form.CollectionID, form.ItemIDs)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrWishlistCollectionNotFound):
+			app.notFoundResponse(w, r, err.Error())
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.writeItemMoveResults(w, results)
+}
+
+func (app *application) writeItemMoveResults(w http.ResponseWriter, results []*store.ItemMoveResult) {
+	moved := 0
+	for _, result := range results {
+		if result.Moved() {
+			moved++
+		}
+	}
+
+	app.successResponse(w, http.StatusOK, envelope{
+		"moved":   moved,
+		"failed":  len(results) - moved,
+		"results": results,
+	})
+}
@@ -0,0 +1,333 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/devphaseX/buyr-api.git/internal/store"
+)
+
+type createWishlistCollectionRequest struct {
+	Name       string                   `json:"name" validate:"required,max=100"`
+	Visibility store.WishlistVisibility `json:"visibility" validate:"omitempty,oneof=private unlisted public"`
+	Registry   bool                     `json:"registry"`
+}
+
+type updateWishlistCollectionRequest struct {
+	Name       *string                   `json:"name" validate:"omitempty,min=1,max=100"`
+	Visibility *store.WishlistVisibility `json:"visibility" validate:"omitempty,oneof=private unlisted public"`
+	Registry   *bool                     `json:"registry"`
+}
+
+var wishlistItemSortSafelist = []string{"created_at", "-created_at"}
+
+func (app *application) createWishlistCollection(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromCtx(r)
+
+	var form createWishlistCollectionRequest
+
+	if err := app.readJSON(w, r, &form); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := validate.Struct(form); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if form.Visibility == "" {
+		form.Visibility = store.PrivateWishlistVisibility
+	}
+
+	collection := &store.WishlistCollection{
+		UserID:     user.ID,
+		Name:       form.Name,
+		Visibility: form.Visibility,
+		Registry:   form.Registry,
+	}
+
+	if err := app.store.WishlistCollections.Create(r.Context(), collection); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.successResponse(w, http.StatusCreated, envelope{"wishlist": collection})
+}
+
+func (app *application) getWishlistCollections(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromCtx(r)
+
+	collections, err := app.store.WishlistCollections.GetByUserID(r.Context(), user.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.successResponse(w, http.StatusOK, envelope{"wishlists": collections})
+}
+
+func (app *application) getWishlistCollection(w http.ResponseWriter, r *http.Request) {
+	var (
+		user         = getUserFromCtx(r)
+		collectionID = app.readStringID(r, "collectionID")
+	)
+
+	collection, err := app.store.WishlistCollections.GetByID(r.Context(), collectionID, user.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrWishlistCollectionNotFound):
+			app.notFoundResponse(w, r, err.Error())
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.writeWishlistCollectionItems(w, r, collection, user.ID)
+}
+
+func (app *application) updateWishlistCollection(w http.ResponseWriter, r *http.Request) {
+	var (
+		user         = getUserFromCtx(r)
+		collectionID = app.readStringID(r, "collectionID")
+		form         updateWishlistCollectionRequest
+	)
+
+	if err := app.readJSON(w, r, &form); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := validate.Struct(form); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	collection, err := app.store.WishlistCollections.GetByID(r.Context(), collectionID, user.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrWishlistCollectionNotFound):
+			app.notFoundResponse(w, r, err.Error())
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if form.Name != nil {
+		collection.Name = *form.Name
+	}
+
+	if form.Visibility != nil {
+		collection.Visibility = *form.Visibility
+	}
+
+	if form.Registry != nil {
+		collection.Registry = *form.Registry
+	}
+
+	if err := app.store.WishlistCollections.Update(r.Context(), collection); err != nil {
+		switch {
+		case errors.Is(err, store.ErrWishlistCollectionNotFound):
+			app.notFoundResponse(w, r, err.Error())
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.successResponse(w, http.StatusOK, envelope{"wishlist": collection})
+}
+
+func (app *application) deleteWishlistCollection(w http.ResponseWriter, r *http.Request) {
+	var (
+		user         = getUserFromCtx(r)
+		collectionID = app.readStringID(r, "collectionID")
+	)
+
+	if err := app.store.WishlistCollections.Delete(r.Context(), collectionID, user.ID); err != nil {
+		switch {
+		case errors.Is(err, store.ErrWishlistCollectionNotFound):
+			app.notFoundResponse(w, r, err.Error())
+		case errors.Is(err, store.ErrDefaultWishlistCollection):
+			app.conflictResponse(w, r, err.Error())
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.successResponse(w, http.StatusOK, envelope{
+		"message": "wishlist deleted successfully",
+		"id":      collectionID,
+	})
+}
+
+func (app *application) rotateWishlistShareToken(w http.ResponseWriter, r *http.Request) {
+	var (
+		user         = getUserFromCtx(r)
+		collectionID = app.readStringID(r, "collectionID")
+	)
+
+	collection, err := app.store.WishlistCollections.RotateShareToken(r.Context(), collectionID, user.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrWishlistCollectionNotFound):
+			app.notFoundResponse(w, r, err.Error())
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.successResponse(w, http.StatusOK, envelope{"wishlist": collection})
+}
+
+func (app *application) getPublicWishlists(w http.ResponseWriter, r *http.Request) {
+	fq := store.PaginateQueryFilter{
+		Page:         1,
+		PageSize:     20,
+		Sort:         "-created_at",
+		SortSafelist: []string{"created_at", "-created_at", "name", "-name"},
+	}
+
+	if err := fq.Parse(r); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	collections, metadata, err := app.store.WishlistCollections.GetPublic(r.Context(), fq)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	for _, collection := range collections {
+		collection.ShareToken = ""
+	}
+
+	app.successResponse(w, http.StatusOK, envelope{
+		"wishlists": collections,
+		"metadata":  metadata,
+	})
+}
+
+func (app *application) getSharedWishlist(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromCtx(r)
+
+	collection, ok := app.readSharedWishlist(w, r)
+	if !ok {
+		return
+	}
+
+	var viewerID string
+	if !user.IsAnonymous {
+		viewerID = user.ID
+	}
+
+	if viewerID != collection.UserID {
+		collection.ShareToken = ""
+	}
+
+	app.writeWishlistCollectionItems(w, r, collection, viewerID)
+}
+
+func (app *application) claimWishlistItem(w http.ResponseWriter, r *http.Request) {
+	var (
+		user   = getUserFromCtx(r)
+		itemID = app.readStringID(r, "itemID")
+	)
+
+	collection, ok := app.readSharedWishlist(w, r)
+	if !ok {
+		return
+	}
+
+	claim, err := app.store.WishlistCollections.Claim(r.Context(), collection, itemID, user.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrRecordNotFound):
+			app.notFoundResponse(w, r, "item not found")
+		case errors.Is(err, store.ErrWishlistNotRegistry), errors.Is(err, store.ErrOwnWishlistClaim):
+			app.forbiddenResponse(w, r, err.Error())
+		case errors.Is(err, store.ErrWishlistItemClaimed):
+			app.conflictResponse(w, r, err.Error())
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.successResponse(w, http.StatusCreated, envelope{"claim": claim})
+}
+
+func (app *application) unclaimWishlistItem(w http.ResponseWriter, r *http.Request) {
+	var (
+		user   = getUserFromCtx(r)
+		itemID = app.readStringID(r, "itemID")
+	)
+
+	collection, ok := app.readSharedWishlist(w, r)
+	if !ok {
+		return
+	}
+
+	if err := app.store.WishlistCollections.Unclaim(r.Context(), collection, itemID, user.ID); err != nil {
+		switch {
+		case errors.Is(err, store.ErrRecordNotFound):
+			app.notFoundResponse(w, r, "claim not found")
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.successResponse(w, http.StatusOK, envelope{
+		"message": "claim removed successfully",
+		"id":      itemID,
+	})
+}
+
+func (app *application) readSharedWishlist(w http.ResponseWriter, r *http.Request) (*store.WishlistCollection, bool) {
+	shareToken := app.readStringID(r, "shareToken")
+
+	collection, err := app.store.WishlistCollections.GetByShareToken(r.Context(), shareToken)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrWishlistCollectionNotFound):
+			app.notFoundResponse(w, r, err.Error())
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return nil, false
+	}
+
+	return collection, true
+}
+
+func (app *application) writeWishlistCollectionItems(w http.ResponseWriter, r *http.Request, collection *store.WishlistCollection, viewerID string) {
+	fq := store.PaginateQueryFilter{
+		Page:         1,
+		PageSize:     20,
+		Sort:         "-created_at",
+		SortSafelist: wishlistItemSortSafelist,
+	}
+
+	if err := fq.Parse(r); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	items, metadata, err := app.store.WishlistCollections.GetItems(r.Context(), collection, viewerID, fq)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.successResponse(w, http.StatusOK, envelope{
+		"wishlist": collection,
+		"items":    items,
+		"metadata": metadata,
+	})
+}
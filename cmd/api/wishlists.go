@@ -8,7 +8,8 @@ import (
 )
 
 type addProductWishlistRequest struct {
-	ProductID string `json:"product_id" validate:"required"`
+	ProductID    string `json:"product_id" validate:"required"`
+	CollectionID string `json:"collection_id"`
 }
 
 func (app *application) addProductToWhitelist(w http.ResponseWriter, r *http.Request) {
@@ -41,8 +42,9 @@ func (app *application) addProductToWhitelist(w http.ResponseWriter, r *http.Req
 	}
 
 	whitelist := &store.Wishlist{
-		UserID:    user.ID,
-		ProductID: product.ID,
+		UserID:       user.ID,
+		ProductID:    product.ID,
+		CollectionID: form.CollectionID,
 	}
 
 	if err := app.store.Wishlists.AddItem(r.Context(), whitelist); err != nil {
@@ -50,6 +52,9 @@ func (app *application) addProductToWhitelist(w http.ResponseWriter, r *http.Req
 		case errors.Is(err, store.ErrProductWishlistedAlready):
 			app.conflictResponse(w, r, err.Error())
 
+		case errors.Is(err, store.ErrWishlistCollectionNotFound):
+			app.notFoundResponse(w, r, err.Error())
+
 		default:
 			app.serverErrorResponse(w, r, err)
 		}
@@ -17,6 +17,8 @@ var (
 type AuthToken interface {
 	GenerateAccessToken(userID string, sessionID string, expiry time.Duration) (string, error)
 	GenerateRefreshToken(sessionID string, version int, expiry time.Duration) (string, error)
+	GenerateClientAccessToken(userID, grantID, clientID string, scopes []string, expiry time.Duration) (string, error)
+	GenerateClientRefreshToken(grantID, clientID string, version int, expiry time.Duration) (string, error)
 	ValidateAccessToken(tokenString string) (*AccessPayload, error)
 	ValidateRefreshToken(tokenString string) (*RefreshPayload, error)
 }
@@ -25,6 +27,11 @@ type AuthToken interface {
 type AccessPayload struct {
 	UserID    string `json:"user_id"`
 	SessionID string `json:"session_id"`
+	// GrantID, ClientID and Scopes are set instead of SessionID on tokens
+	// issued to a third-party app the user authorized.
+	GrantID  string   `json:"grant_id,omitempty"`
+	ClientID string   `json:"client_id,omitempty"`
+	Scopes   []string `json:"scopes,omitempty"`
 	jwt.RegisteredClaims
 }
 
@@ -39,6 +46,20 @@ func NewAccessPayload(userId string, sessionId string, expiry time.Duration) *Ac
 	}
 }
 
+func NewClientAccessPayload(userID, grantID, clientID string, scopes []string, expiry time.Duration) *AccessPayload {
+	payload := NewAccessPayload(userID, "", expiry)
+	payload.GrantID = grantID
+	payload.ClientID = clientID
+	payload.Scopes = scopes
+
+	return payload
+}
+
+// IsClientToken reports whether the token was issued to a third-party app
+func (p *AccessPayload) IsClientToken() bool {
+	return p.GrantID != ""
+}
+
 func (p *AccessPayload) Valid() error {
 	if time.Now().After(p.ExpiresAt.Time) {
 		return ErrExpiredToken
@@ -51,6 +72,9 @@ func (p *AccessPayload) Valid() error {
 type RefreshPayload struct {
 	SessionID string `json:"session_id"`
 	Version   int    `json:"version"`
+	// ClientID is set on refresh tokens of a third-party app, SessionID then
+	// holds the grant the token belongs to.
+	ClientID string `json:"client_id,omitempty"`
 	jwt.RegisteredClaims
 }
 
@@ -65,6 +89,18 @@ func NewRefreshPayload(sessionId string, version int, expiry time.Duration) *Ref
 	}
 }
 
+func NewClientRefreshPayload(grantID, clientID string, version int, expiry time.Duration) *RefreshPayload {
+	payload := NewRefreshPayload(grantID, version, expiry)
+	payload.ClientID = clientID
+
+	return payload
+}
+
+// IsClientToken reports whether the token was issued to a third-party app
+func (p *RefreshPayload) IsClientToken() bool {
+	return p.ClientID != ""
+}
+
 func (p *RefreshPayload) Valid() error {
 	if time.Now().After(p.ExpiresAt.Time) {
 		return ErrExpiredToken
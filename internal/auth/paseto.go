@@ -76,6 +76,32 @@ func (t *PasetoToken) GenerateRefreshToken(sessionID string, version int, refres
 	return token, nil
 }
 
+// GenerateClientAccessToken creates a PASETO access token for a third-party
+// app, limited to the scopes the user granted it
+func (t *PasetoToken) GenerateClientAccessToken(userID, grantID, clientID string, scopes []string, accessExpiry time.Duration) (string, error) {
+	payload := NewClientAccessPayload(userID, grantID, clientID, scopes, accessExpiry)
+
+	token, err := t.paseto.Encrypt(t.accessKey, payload, nil)
+	if err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// GenerateClientRefreshToken creates a PASETO refresh token for a third-party
+// app
+func (t *PasetoToken) GenerateClientRefreshToken(grantID, clientID string, version int, refreshExpiry time.Duration) (string, error) {
+	payload := NewClientRefreshPayload(grantID, clientID, version, refreshExpiry)
+
+	token, err := t.paseto.Encrypt(t.refreshKey, payload, nil)
+	if err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
 // ValidateAccessToken validates a PASETO access token
 func (t *PasetoToken) ValidateAccessToken(tokenString string) (*AccessPayload, error) {
 	var payload AccessPayload
@@ -0,0 +1,59 @@
+// Package contentfilter flags user generated text that should be reviewed by
+// a moderator before it is shown publicly.
+package contentfilter
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+var (
+	linkPattern = regexp.MustCompile(`(?i)(https?://|www\.)\S+|\b[a-z0-9-]+\.(com|net|org|io|co|biz|info|ru|xyz|top|link|click)\b`)
+
+	// blockedWords is matched against whole words after folding case and
+	// common digit substitutions.
+	blockedWords = map[string]struct{}{
+		"fuck": {}, "fucking": {}, "shit": {}, "bitch": {}, "bastard": {},
+		"asshole": {}, "dick": {}, "cunt": {}, "whore": {}, "slut": {},
+		"retard": {}, "nigger": {}, "faggot": {},
+	}
+
+	leetReplacer = strings.NewReplacer("0", "o", "1", "i", "3", "e", "4", "a", "5", "s", "7", "t", "@", "a", "$", "s")
+)
+
+// Result lists why a piece of text was flagged. An empty Reasons means the
+// text is clean.
+type Result struct {
+	Reasons []string
+}
+
+func (r Result) Flagged() bool {
+	return len(r.Reasons) > 0
+}
+
+func (r Result) String() string {
+	return strings.Join(r.Reasons, ", ")
+}
+
+// Check inspects text for links and profanity.
+func Check(text string) Result {
+	var result Result
+
+	if linkPattern.MatchString(text) {
+		result.Reasons = append(result.Reasons, "contains a link")
+	}
+
+	words := strings.FieldsFunc(leetReplacer.Replace(strings.ToLower(text)), func(r rune) bool {
+		return !unicode.IsLetter(r)
+	})
+
+	for _, word := range words {
+		if _, ok := blockedWords[word]; ok {
+			result.Reasons = append(result.Reasons, "contains profanity")
+			break
+		}
+	}
+
+	return result
+}
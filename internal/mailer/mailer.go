@@ -11,6 +11,12 @@ var (
 	VendorActivationTemplate     = "vendor_activation_email.tmpl"
 	AdminOnboardTemplate         = "admin_activation_email.tmpl"
 	VerifyEmailTemplate          = "verify_email.tmpl"
+	WishlistAlertTemplate        = "wishlist_alert_email.tmpl"
+	LowStockAlertTemplate        = "low_stock_alert_email.tmpl"
+	SuspiciousActivityTemplate   = "suspicious_activity_email.tmpl"
+	AccountLockedTemplate        = "account_locked_email.tmpl"
+	TwoFactorCodeTemplate        = "two_factor_code_email.tmpl"
+	TwoFactorResetTemplate       = "two_factor_reset_email.tmpl"
 )
 
 type Client interface {
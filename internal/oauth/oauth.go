@@ -0,0 +1,182 @@
+package oauth
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+// ErrUnknownProvider is returned when no provider is registered under a name
+var ErrUnknownProvider = errors.New("oauth: unknown provider")
+
+// defaultHTTPClient is used to reach providers when Config has no client
+var defaultHTTPClient = &http.Client{Timeout: 10 * time.Second}
+
+// UserInfo is the profile an identity provider returns for the signed in
+// account. ID is the provider's stable subject identifier.
+type UserInfo struct {
+	ID            string
+	Name          string
+	Email         string
+	Image         string
+	EmailVerified bool
+}
+
+// Config holds the client credentials registered with a provider.
+// HTTPClient is used for every call to the provider when set.
+type Config struct {
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+	HTTPClient   *http.Client
+}
+
+// Provider is an OAuth 2.0 identity provider users can sign in with. Every
+// authorization request carries a PKCE challenge derived from verifier, and
+// the same verifier must be passed to Exchange. OpenID Connect providers bind
+// the ID token to nonce, other providers ignore it.
+type Provider interface {
+	Name() string
+	AuthCodeURL(state, verifier, nonce string) string
+	Exchange(ctx context.Context, code, verifier string) (*oauth2.Token, error)
+	UserInfo(ctx context.Context, token *oauth2.Token, nonce string) (*UserInfo, error)
+	// FormPost reports whether the provider returns to the callback with a
+	// cross-site form post rather than a redirect.
+	FormPost() bool
+}
+
+// userInfoFunc loads the profile of the account a token was issued for
+type userInfoFunc func(ctx context.Context, client *http.Client, token *oauth2.Token) (*UserInfo, error)
+
+// provider implements Provider on top of an oauth2.Config
+type provider struct {
+	name        string
+	config      *oauth2.Config
+	httpClient  *http.Client
+	authOptions []oauth2.AuthCodeOption
+	formPost    bool
+	userInfo    userInfoFunc
+}
+
+func (p *provider) Name() string {
+	return p.name
+}
+
+func (p *provider) FormPost() bool {
+	return p.formPost
+}
+
+func (p *provider) AuthCodeURL(state, verifier, nonce string) string {
+	options := append([]oauth2.AuthCodeOption{oauth2.S256ChallengeOption(verifier)}, p.authOptions...)
+	return p.config.AuthCodeURL(state, options...)
+}
+
+func (p *provider) Exchange(ctx context.Context, code, verifier string) (*oauth2.Token, error) {
+	return p.config.Exchange(p.context(ctx), code, oauth2.VerifierOption(verifier))
+}
+
+func (p *provider) UserInfo(ctx context.Context, token *oauth2.Token, nonce string) (*UserInfo, error) {
+	ctx = p.context(ctx)
+
+	info, err := p.userInfo(ctx, p.config.Client(ctx, token), token)
+	if err != nil {
+		return nil, fmt.Errorf("oauth: %s: %w", p.name, err)
+	}
+
+	if info.ID == "" {
+		return nil, fmt.Errorf("oauth: %s: profile has no subject", p.name)
+	}
+
+	return info, nil
+}
+
+// context makes the oauth2 package use the configured HTTP client
+func (p *provider) context(ctx context.Context) context.Context {
+	if p.httpClient == nil {
+		return ctx
+	}
+
+	return context.WithValue(ctx, oauth2.HTTPClient, p.httpClient)
+}
+
+func httpClient(cfg Config) *http.Client {
+	if cfg.HTTPClient != nil {
+		return cfg.HTTPClient
+	}
+
+	return defaultHTTPClient
+}
+
+// GenerateVerifier returns a new PKCE code verifier
+func GenerateVerifier() string {
+	return oauth2.GenerateVerifier()
+}
+
+// GenerateNonce returns a random value binding an ID token to the
+// authorization request it was issued for
+func GenerateNonce() string {
+	data := make([]byte, 32)
+	if _, err := rand.Read(data); err != nil {
+		panic(err)
+	}
+
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// Registry holds the providers configured for the application keyed by name
+type Registry struct {
+	providers map[string]Provider
+}
+
+func NewRegistry() *Registry {
+	return &Registry{providers: make(map[string]Provider)}
+}
+
+// Register adds a provider, replacing any registered under the same name
+func (r *Registry) Register(p Provider) {
+	r.providers[p.Name()] = p
+}
+
+func (r *Registry) Get(name string) (Provider, error) {
+	p, ok := r.providers[name]
+	if !ok {
+		return nil, ErrUnknownProvider
+	}
+
+	return p, nil
+}
+
+// Names lists the registered providers in alphabetical order
+func (r *Registry) Names() []string {
+	names := make([]string, 0, len(r.providers))
+	for name := range r.providers {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	return names
+}
+
+// getJSON decodes the JSON body of an authenticated GET request
+func getJSON(client *http.Client, url string, dst any) error {
+	resp, err := client.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: unexpected status %s", url, resp.Status)
+	}
+
+	return json.NewDecoder(resp.Body).Decode(dst)
+}
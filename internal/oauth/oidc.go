@@ -0,0 +1,274 @@
+package oauth
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"golang.org/x/oauth2"
+)
+
+var (
+	// ErrNonceMismatch is returned when an ID token was not issued for the
+	// authorization request that is being completed
+	ErrNonceMismatch = errors.New("id token nonce does not match the request")
+)
+
+// idTokenLeeway absorbs clock skew between the provider and the server
+const idTokenLeeway = time.Minute
+
+// signingAlgorithms are the ID token algorithms accepted when the provider
+// does not narrow them down. Symmetric and unsigned tokens are never valid.
+var signingAlgorithms = []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512"}
+
+// discoveryDocument is the subset of the OpenID provider metadata used to
+// configure a client
+type discoveryDocument struct {
+	Issuer                           string   `json:"issuer"`
+	AuthorizationEndpoint            string   `json:"authorization_endpoint"`
+	TokenEndpoint                    string   `json:"token_endpoint"`
+	UserinfoEndpoint                 string   `json:"userinfo_endpoint"`
+	JWKSURI                          string   `json:"jwks_uri"`
+	IDTokenSigningAlgValuesSupported []string `json:"id_token_signing_alg_values_supported"`
+}
+
+// oidcProvider signs users in with OpenID Connect. The profile is read from
+// the ID token after checking its signature against the keys the provider
+// publishes, its issuer, audience, lifetime and nonce.
+type oidcProvider struct {
+	*provider
+	issuer           string
+	userinfoEndpoint string
+	algorithms       []string
+	keys             *keySet
+}
+
+// NewOIDC configures an OpenID Connect provider from the discovery document
+// published under issuer
+func NewOIDC(ctx context.Context, name, issuer string, cfg Config) (Provider, error) {
+	return newOIDC(ctx, name, issuer, cfg, []string{"openid", "email", "profile"})
+}
+
+func newOIDC(ctx context.Context, name, issuer string, cfg Config, defaultScopes []string) (*oidcProvider, error) {
+	client := httpClient(cfg)
+
+	doc, err := discover(ctx, client, issuer)
+	if err != nil {
+		return nil, fmt.Errorf("oauth: %s: %w", name, err)
+	}
+
+	if len(cfg.Scopes) > 0 && !slices.Contains(cfg.Scopes, "openid") {
+		cfg.Scopes = append([]string{"openid"}, cfg.Scopes...)
+	}
+
+	endpoint := oauth2.Endpoint{
+		AuthURL:  doc.AuthorizationEndpoint,
+		TokenURL: doc.TokenEndpoint,
+	}
+
+	algorithms := signingAlgorithms
+	if len(doc.IDTokenSigningAlgValuesSupported) > 0 {
+		algorithms = slices.DeleteFunc(slices.Clone(doc.IDTokenSigningAlgValuesSupported), func(alg string) bool {
+			return !slices.Contains(signingAlgorithms, alg)
+		})
+	}
+
+	if len(algorithms) == 0 {
+		return nil, fmt.Errorf("oauth: %s: provider supports no asymmetric id token algorithm", name)
+	}
+
+	return &oidcProvider{
+		provider:         newProvider(name, cfg, endpoint, defaultScopes, nil),
+		issuer:           doc.Issuer,
+		userinfoEndpoint: doc.UserinfoEndpoint,
+		algorithms:       algorithms,
+		keys:             newKeySet(doc.JWKSURI, client),
+	}, nil
+}
+
+// discover fetches the provider metadata and checks that it was published for
+// issuer
+func discover(ctx context.Context, client *http.Client, issuer string) (*discoveryDocument, error) {
+	wellKnown := strings.TrimSuffix(issuer, "/") + "/.well-known/openid-configuration"
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, wellKnown, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("discovery failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("discovery failed: unexpected status %s", resp.Status)
+	}
+
+	var doc discoveryDocument
+	if err := json.NewDecoder(resp.Body).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("invalid discovery document: %w", err)
+	}
+
+	if doc.Issuer != issuer {
+		return nil, fmt.Errorf("discovery issuer %q does not match %q", doc.Issuer, issuer)
+	}
+
+	if doc.AuthorizationEndpoint == "" || doc.TokenEndpoint == "" || doc.JWKSURI == "" {
+		return nil, errors.New("discovery document is missing endpoints")
+	}
+
+	return &doc, nil
+}
+
+func (p *oidcProvider) AuthCodeURL(state, verifier, nonce string) string {
+	options := []oauth2.AuthCodeOption{
+		oauth2.S256ChallengeOption(verifier),
+		oauth2.SetAuthURLParam("nonce", nonce),
+	}
+
+	return p.config.AuthCodeURL(state, append(options, p.authOptions...)...)
+}
+
+// UserInfo verifies the ID token of the token response. The userinfo
+// endpoint is only consulted when the ID token carries no email, and its
+// answer must be about the same subject.
+func (p *oidcProvider) UserInfo(ctx context.Context, token *oauth2.Token, nonce string) (*UserInfo, error) {
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok || rawIDToken == "" {
+		return nil, fmt.Errorf("oauth: %s: token response has no id_token", p.name)
+	}
+
+	claims, err := p.verifyIDToken(ctx, rawIDToken, nonce)
+	if err != nil {
+		return nil, fmt.Errorf("oauth: %s: %w", p.name, err)
+	}
+
+	info := claims.userInfo()
+
+	if info.Email == "" && p.userinfoEndpoint != "" {
+		ctx = p.context(ctx)
+
+		var profile standardClaims
+		if err := getJSON(p.config.Client(ctx, token), p.userinfoEndpoint, &profile); err != nil {
+			return nil, fmt.Errorf("oauth: %s: %w", p.name, err)
+		}
+
+		if profile.Subject != info.ID {
+			return nil, fmt.Errorf("oauth: %s: userinfo subject does not match the id token", p.name)
+		}
+
+		info = profile.userInfo()
+	}
+
+	return info, nil
+}
+
+// idTokenClaims are the claims read from a verified ID token
+type idTokenClaims struct {
+	Name            string   `json:"name"`
+	Email           string   `json:"email"`
+	EmailVerified   flexBool `json:"email_verified"`
+	Picture         string   `json:"picture"`
+	Nonce           string   `json:"nonce"`
+	AuthorizedParty string   `json:"azp"`
+	jwt.RegisteredClaims
+}
+
+func (c *idTokenClaims) userInfo() *UserInfo {
+	return &UserInfo{
+		ID:            c.Subject,
+		Name:          c.Name,
+		Email:         c.Email,
+		Image:         c.Picture,
+		EmailVerified: bool(c.EmailVerified),
+	}
+}
+
+func (p *oidcProvider) verifyIDToken(ctx context.Context, rawIDToken, nonce string) (*idTokenClaims, error) {
+	parser := jwt.NewParser(
+		jwt.WithValidMethods(p.algorithms),
+		jwt.WithIssuer(p.issuer),
+		jwt.WithAudience(p.config.ClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithLeeway(idTokenLeeway),
+	)
+
+	var claims idTokenClaims
+
+	_, err := parser.ParseWithClaims(rawIDToken, &claims, func(token *jwt.Token) (any, error) {
+		kid, _ := token.Header["kid"].(string)
+		return p.keys.get(ctx, kid)
+	})
+
+	if err != nil {
+		return nil, fmt.Errorf("invalid id token: %w", err)
+	}
+
+	if claims.Subject == "" {
+		return nil, errors.New("invalid id token: missing subject")
+	}
+
+	// A token issued to several audiences must name this client as the party
+	// it was issued to
+	if len(claims.Audience) > 1 && claims.AuthorizedParty == "" ||
+		claims.AuthorizedParty != "" && claims.AuthorizedParty != p.config.ClientID {
+		return nil, errors.New("invalid id token: not issued to this client")
+	}
+
+	if nonce == "" || subtle.ConstantTimeCompare([]byte(claims.Nonce), []byte(nonce)) != 1 {
+		return nil, ErrNonceMismatch
+	}
+
+	return &claims, nil
+}
+
+// standardClaims are the OpenID Connect claims describing the end user
+type standardClaims struct {
+	Subject       string   `json:"sub"`
+	Name          string   `json:"name"`
+	Email         string   `json:"email"`
+	EmailVerified flexBool `json:"email_verified"`
+	Picture       string   `json:"picture"`
+}
+
+func (c standardClaims) userInfo() *UserInfo {
+	return &UserInfo{
+		ID:            c.Subject,
+		Name:          c.Name,
+		Email:         c.Email,
+		Image:         c.Picture,
+		EmailVerified: bool(c.EmailVerified),
+	}
+}
+
+// flexBool accepts both JSON booleans and the "true"/"false" strings some
+// providers send for boolean claims
+type flexBool bool
+
+func (b *flexBool) UnmarshalJSON(data []byte) error {
+	var value any
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+
+	switch v := value.(type) {
+	case bool:
+		*b = flexBool(v)
+	case string:
+		*b = flexBool(v == "true")
+	default:
+		*b = false
+	}
+
+	return nil
+}
@@ -0,0 +1,429 @@
+package oauth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"golang.org/x/oauth2"
+)
+
+const testClientID = "buyr-client"
+
+type signingKey struct {
+	kid string
+	key *ecdsa.PrivateKey
+}
+
+func newSigningKey(t *testing.T, kid string) *signingKey {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+
+	return &signingKey{kid: kid, key: key}
+}
+
+func (k *signingKey) jwk() map[string]string {
+	return map[string]string{
+		"kty": "EC",
+		"kid": k.kid,
+		"use": "sig",
+		"crv": "P-256",
+		"x":   base64.RawURLEncoding.EncodeToString(k.key.X.FillBytes(make([]byte, 32))),
+		"y":   base64.RawURLEncoding.EncodeToString(k.key.Y.FillBytes(make([]byte, 32))),
+	}
+}
+
+// testIssuer is an OpenID provider serving a discovery document and a JWKS
+// whose keys can be rotated during a test
+type testIssuer struct {
+	*httptest.Server
+	t *testing.T
+
+	// advertisedIssuer overrides the issuer in the discovery document
+	advertisedIssuer string
+
+	mu          sync.Mutex
+	keys        []*signingKey
+	jwksFetches atomic.Int32
+}
+
+func newTestIssuer(t *testing.T, keys ...*signingKey) *testIssuer {
+	t.Helper()
+
+	issuer := &testIssuer{t: t, keys: keys}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		advertised := issuer.advertisedIssuer
+		if advertised == "" {
+			advertised = issuer.URL
+		}
+
+		json.NewEncoder(w).Encode(map[string]any{
+			"issuer":                                advertised,
+			"authorization_endpoint":                issuer.URL + "/authorize",
+			"token_endpoint":                        issuer.URL + "/token",
+			"jwks_uri":                              issuer.URL + "/jwks",
+			"id_token_signing_alg_values_supported": []string{"ES256"},
+		})
+	})
+
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		issuer.jwksFetches.Add(1)
+
+		issuer.mu.Lock()
+		defer issuer.mu.Unlock()
+
+		keys := make([]map[string]string, len(issuer.keys))
+		for i, key := range issuer.keys {
+			keys[i] = key.jwk()
+		}
+
+		json.NewEncoder(w).Encode(map[string]any{"keys": keys})
+	})
+
+	issuer.Server = httptest.NewServer(mux)
+	t.Cleanup(issuer.Close)
+
+	return issuer
+}
+
+func (i *testIssuer) rotate(keys ...*signingKey) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	i.keys = keys
+}
+
+func (i *testIssuer) provider(ctx context.Context) (*oidcProvider, error) {
+	return newOIDC(ctx, "test", i.URL, Config{
+		ClientID:     testClientID,
+		ClientSecret: "secret",
+		RedirectURL:  "https://buyr.test/callback",
+		HTTPClient:   i.Client(),
+	}, []string{"openid", "email"})
+}
+
+// claims returns valid ID token claims for nonce that tests then break
+func (i *testIssuer) claims(nonce string) jwt.MapClaims {
+	now := time.Now()
+
+	return jwt.MapClaims{
+		"iss":            i.URL,
+		"sub":            "subject-1",
+		"aud":            testClientID,
+		"exp":            now.Add(time.Hour).Unix(),
+		"iat":            now.Unix(),
+		"nonce":          nonce,
+		"email":          "jane@buyr.test",
+		"email_verified": true,
+	}
+}
+
+func sign(t *testing.T, key *signingKey, claims jwt.MapClaims) *oauth2.Token {
+	t.Helper()
+
+	token := jwt.NewWithClaims(jwt.SigningMethodES256, claims)
+	token.Header["kid"] = key.kid
+
+	raw, err := token.SignedString(key.key)
+	if err != nil {
+		t.Fatalf("failed to sign id token: %v", err)
+	}
+
+	return (&oauth2.Token{AccessToken: "access"}).WithExtra(map[string]any{"id_token": raw})
+}
+
+func TestDiscoveryRejectsIssuerMismatch(t *testing.T) {
+	issuer := newTestIssuer(t, newSigningKey(t, "key-1"))
+	issuer.advertisedIssuer = "https://accounts.evil.test"
+
+	_, err := issuer.provider(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "does not match") {
+		t.Fatalf("err = %v, want issuer mismatch", err)
+	}
+}
+
+func TestUserInfoVerifiesIDToken(t *testing.T) {
+	key := newSigningKey(t, "key-1")
+	issuer := newTestIssuer(t, key)
+
+	p, err := issuer.provider(context.Background())
+	if err != nil {
+		t.Fatalf("newOIDC: %v", err)
+	}
+
+	info, err := p.UserInfo(context.Background(), sign(t, key, issuer.claims("nonce-1")), "nonce-1")
+	if err != nil {
+		t.Fatalf("UserInfo: %v", err)
+	}
+
+	if info.ID != "subject-1" || info.Email != "jane@buyr.test" || !info.EmailVerified {
+		t.Fatalf("info = %+v", info)
+	}
+}
+
+func TestUserInfoRejectsInvalidIDTokens(t *testing.T) {
+	key := newSigningKey(t, "key-1")
+	issuer := newTestIssuer(t, key)
+
+	p, err := issuer.provider(context.Background())
+	if err != nil {
+		t.Fatalf("newOIDC: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		modify func(claims jwt.MapClaims)
+		nonce  string
+		want   error
+	}{
+		{
+			name:   "nonce mismatch",
+			modify: func(claims jwt.MapClaims) { claims["nonce"] = "other-nonce" },
+			nonce:  "nonce-1",
+			want:   ErrNonceMismatch,
+		},
+		{
+			name:   "missing nonce",
+			modify: func(claims jwt.MapClaims) { delete(claims, "nonce") },
+			nonce:  "nonce-1",
+			want:   ErrNonceMismatch,
+		},
+		{
+			name:   "no nonce expected",
+			modify: func(claims jwt.MapClaims) {},
+			nonce:  "",
+			want:   ErrNonceMismatch,
+		},
+		{
+			name:   "wrong audience",
+			modify: func(claims jwt.MapClaims) { claims["aud"] = "another-client" },
+			nonce:  "nonce-1",
+			want:   jwt.ErrTokenInvalidAudience,
+		},
+		{
+			name:   "wrong issuer",
+			modify: func(claims jwt.MapClaims) { claims["iss"] = "https://accounts.evil.test" },
+			nonce:  "nonce-1",
+			want:   jwt.ErrTokenInvalidIssuer,
+		},
+		{
+			name:   "expired",
+			modify: func(claims jwt.MapClaims) { claims["exp"] = time.Now().Add(-2 * idTokenLeeway).Unix() },
+			nonce:  "nonce-1",
+			want:   jwt.ErrTokenExpired,
+		},
+		{
+			name:   "missing expiry",
+			modify: func(claims jwt.MapClaims) { delete(claims, "exp") },
+			nonce:  "nonce-1",
+			want:   jwt.ErrTokenRequiredClaimMissing,
+		},
+		{
+			name: "azp names another client",
+			modify: func(claims jwt.MapClaims) {
+				claims["aud"] = []string{testClientID, "another-client"}
+				claims["azp"] = "another-client"
+			},
+			nonce: "nonce-1",
+		},
+		{
+			name:   "several audiences without azp",
+			modify: func(claims jwt.MapClaims) { claims["aud"] = []string{testClientID, "another-client"} },
+			nonce:  "nonce-1",
+		},
+		{
+			name:   "azp names another client with a single audience",
+			modify: func(claims jwt.MapClaims) { claims["azp"] = "another-client" },
+			nonce:  "nonce-1",
+		},
+		{
+			name:   "missing subject",
+			modify: func(claims jwt.MapClaims) { delete(claims, "sub") },
+			nonce:  "nonce-1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := issuer.claims("nonce-1")
+			tt.modify(claims)
+
+			_, err := p.UserInfo(context.Background(), sign(t, key, claims), tt.nonce)
+			if err == nil {
+				t.Fatal("UserInfo accepted the id token")
+			}
+
+			if tt.want != nil && !errors.Is(err, tt.want) {
+				t.Fatalf("err = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestUserInfoRejectsUnexpectedAlgorithm(t *testing.T) {
+	key := newSigningKey(t, "key-1")
+	issuer := newTestIssuer(t, key)
+
+	p, err := issuer.provider(context.Background())
+	if err != nil {
+		t.Fatalf("newOIDC: %v", err)
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, issuer.claims("nonce-1"))
+	token.Header["kid"] = key.kid
+
+	raw, err := token.SignedString([]byte("shared-secret"))
+	if err != nil {
+		t.Fatalf("failed to sign id token: %v", err)
+	}
+
+	idToken := (&oauth2.Token{AccessToken: "access"}).WithExtra(map[string]any{"id_token": raw})
+
+	if _, err := p.UserInfo(context.Background(), idToken, "nonce-1"); !errors.Is(err, jwt.ErrTokenSignatureInvalid) {
+		t.Fatalf("err = %v, want %v", err, jwt.ErrTokenSignatureInvalid)
+	}
+}
+
+func TestUserInfoReportsUnverifiedEmail(t *testing.T) {
+	key := newSigningKey(t, "key-1")
+	issuer := newTestIssuer(t, key)
+
+	p, err := issuer.provider(context.Background())
+	if err != nil {
+		t.Fatalf("newOIDC: %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		value any
+		want  bool
+	}{
+		{name: "false", value: false, want: false},
+		{name: "string false", value: "false", want: false},
+		{name: "missing", value: nil, want: false},
+		{name: "string true", value: "true", want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := issuer.claims("nonce-1")
+			claims["email_verified"] = tt.value
+			if tt.value == nil {
+				delete(claims, "email_verified")
+			}
+
+			info, err := p.UserInfo(context.Background(), sign(t, key, claims), "nonce-1")
+			if err != nil {
+				t.Fatalf("UserInfo: %v", err)
+			}
+
+			if info.EmailVerified != tt.want {
+				t.Fatalf("EmailVerified = %t, want %t", info.EmailVerified, tt.want)
+			}
+		})
+	}
+}
+
+func TestJWKSRotation(t *testing.T) {
+	oldKey := newSigningKey(t, "key-1")
+	newKey := newSigningKey(t, "key-2")
+	issuer := newTestIssuer(t, oldKey)
+
+	p, err := issuer.provider(context.Background())
+	if err != nil {
+		t.Fatalf("newOIDC: %v", err)
+	}
+
+	if _, err := p.UserInfo(context.Background(), sign(t, oldKey, issuer.claims("nonce-1")), "nonce-1"); err != nil {
+		t.Fatalf("UserInfo with the original key: %v", err)
+	}
+
+	if got := issuer.jwksFetches.Load(); got != 1 {
+		t.Fatalf("jwks fetches = %d, want 1", got)
+	}
+
+	issuer.rotate(newKey)
+
+	// the key set was just fetched, so an unknown key id must not trigger
+	// another fetch yet
+	_, err = p.UserInfo(context.Background(), sign(t, newKey, issuer.claims("nonce-1")), "nonce-1")
+	if !errors.Is(err, errUnknownSigningKey) {
+		t.Fatalf("err = %v, want %v", err, errUnknownSigningKey)
+	}
+
+	if got := issuer.jwksFetches.Load(); got != 1 {
+		t.Fatalf("jwks fetches within the refresh interval = %d, want 1", got)
+	}
+
+	p.keys.mu.Lock()
+	p.keys.fetchedAt = time.Now().Add(-jwksMinRefreshInterval - time.Second)
+	p.keys.mu.Unlock()
+
+	if _, err := p.UserInfo(context.Background(), sign(t, newKey, issuer.claims("nonce-1")), "nonce-1"); err != nil {
+		t.Fatalf("UserInfo with the rotated key: %v", err)
+	}
+
+	if got := issuer.jwksFetches.Load(); got != 2 {
+		t.Fatalf("jwks fetches after rotation = %d, want 2", got)
+	}
+
+	// the retired key is gone from the set
+	_, err = p.UserInfo(context.Background(), sign(t, oldKey, issuer.claims("nonce-1")), "nonce-1")
+	if !errors.Is(err, errUnknownSigningKey) {
+		t.Fatalf("err = %v, want %v", err, errUnknownSigningKey)
+	}
+}
+
+func TestJWKSUnknownKeyIsThrottled(t *testing.T) {
+	key := newSigningKey(t, "key-1")
+	issuer := newTestIssuer(t, key)
+
+	p, err := issuer.provider(context.Background())
+	if err != nil {
+		t.Fatalf("newOIDC: %v", err)
+	}
+
+	unknown := newSigningKey(t, "key-unknown")
+
+	for range 5 {
+		_, err := p.UserInfo(context.Background(), sign(t, unknown, issuer.claims("nonce-1")), "nonce-1")
+		if !errors.Is(err, errUnknownSigningKey) {
+			t.Fatalf("err = %v, want %v", err, errUnknownSigningKey)
+		}
+	}
+
+	if got := issuer.jwksFetches.Load(); got != 1 {
+		t.Fatalf("jwks fetches = %d, want 1", got)
+	}
+
+	p.keys.mu.Lock()
+	p.keys.fetchedAt = time.Now().Add(-jwksMinRefreshInterval - time.Second)
+	p.keys.mu.Unlock()
+
+	_, err = p.UserInfo(context.Background(), sign(t, unknown, issuer.claims("nonce-1")), "nonce-1")
+	if !errors.Is(err, errUnknownSigningKey) {
+		t.Fatalf("err = %v, want %v", err, errUnknownSigningKey)
+	}
+
+	if got := issuer.jwksFetches.Load(); got != 2 {
+		t.Fatalf("jwks fetches after the refresh interval = %d, want 2", got)
+	}
+}
@@ -0,0 +1,128 @@
+package oauth
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/facebook"
+	"golang.org/x/oauth2/github"
+)
+
+func newProvider(name string, cfg Config, endpoint oauth2.Endpoint, defaultScopes []string, userInfo userInfoFunc) *provider {
+	scopes := cfg.Scopes
+	if len(scopes) == 0 {
+		scopes = defaultScopes
+	}
+
+	return &provider{
+		name:       name,
+		httpClient: httpClient(cfg),
+		config: &oauth2.Config{
+			ClientID:     cfg.ClientID,
+			ClientSecret: cfg.ClientSecret,
+			RedirectURL:  cfg.RedirectURL,
+			Scopes:       scopes,
+			Endpoint:     endpoint,
+		},
+		userInfo: userInfo,
+	}
+}
+
+// NewGoogle signs users in with Google through OpenID Connect
+func NewGoogle(ctx context.Context, cfg Config) (Provider, error) {
+	return newOIDC(ctx, "google", "https://accounts.google.com", cfg, []string{"openid", "email", "profile"})
+}
+
+// NewGitHub signs users in with GitHub. The public profile email may be
+// empty or unverified, so the primary verified address is looked up instead.
+func NewGitHub(cfg Config) Provider {
+	return newProvider("github", cfg, github.Endpoint, []string{"read:user", "user:email"},
+		func(ctx context.Context, client *http.Client, token *oauth2.Token) (*UserInfo, error) {
+			var profile struct {
+				ID        int64  `json:"id"`
+				Login     string `json:"login"`
+				Name      string `json:"name"`
+				AvatarURL string `json:"avatar_url"`
+			}
+
+			if err := getJSON(client, "https://api.github.com/user", &profile); err != nil {
+				return nil, err
+			}
+
+			var emails []struct {
+				Email    string `json:"email"`
+				Primary  bool   `json:"primary"`
+				Verified bool   `json:"verified"`
+			}
+
+			if err := getJSON(client, "https://api.github.com/user/emails", &emails); err != nil {
+				return nil, err
+			}
+
+			info := &UserInfo{
+				ID:    strconv.FormatInt(profile.ID, 10),
+				Name:  profile.Name,
+				Image: profile.AvatarURL,
+			}
+
+			if info.Name == "" {
+				info.Name = profile.Login
+			}
+
+			for _, email := range emails {
+				if email.Primary {
+					info.Email = email.Email
+					info.EmailVerified = email.Verified
+					break
+				}
+			}
+
+			return info, nil
+		})
+}
+
+// NewFacebook signs users in with Facebook. The Graph API does not say
+// whether the email was confirmed, so it is never reported as verified.
+func NewFacebook(cfg Config) Provider {
+	return newProvider("facebook", cfg, facebook.Endpoint, []string{"email", "public_profile"},
+		func(ctx context.Context, client *http.Client, token *oauth2.Token) (*UserInfo, error) {
+			var profile struct {
+				ID      string `json:"id"`
+				Name    string `json:"name"`
+				Email   string `json:"email"`
+				Picture struct {
+					Data struct {
+						URL string `json:"url"`
+					} `json:"data"`
+				} `json:"picture"`
+			}
+
+			if err := getJSON(client, "https://graph.facebook.com/me?fields=id,name,email,picture.type(large)", &profile); err != nil {
+				return nil, err
+			}
+
+			return &UserInfo{
+				ID:    profile.ID,
+				Name:  profile.Name,
+				Email: profile.Email,
+				Image: profile.Picture.Data.URL,
+			}, nil
+		})
+}
+
+// NewApple signs users in with Apple through OpenID Connect. ClientSecret is
+// the ES256 signed JWT generated from the team's private key. Apple has no
+// userinfo endpoint, so the profile is only read from the ID token, and it
+// posts the callback as a form when name or email are requested.
+func NewApple(ctx context.Context, cfg Config) (Provider, error) {
+	p, err := newOIDC(ctx, "apple", "https://appleid.apple.com", cfg, []string{"openid", "name", "email"})
+	if err != nil {
+		return nil, err
+	}
+
+	p.authOptions = []oauth2.AuthCodeOption{oauth2.SetAuthURLParam("response_mode", "form_post")}
+	p.formPost = true
+	return p, nil
+}
@@ -0,0 +1,252 @@
+package passkey
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+)
+
+// Config describes the relying party the ceremonies run for
+type Config struct {
+	RPID          string
+	RPDisplayName string
+	RPOrigins     []string
+	Timeout       time.Duration
+}
+
+// passkeyImpl implements the Passkey interface
+type passkeyImpl struct {
+	webauthn *webauthn.WebAuthn
+}
+
+// New creates a new instance of the Passkey implementation
+func New(cfg Config) (Passkey, error) {
+	timeout := webauthn.TimeoutConfig{
+		Enforce:    true,
+		Timeout:    cfg.Timeout,
+		TimeoutUVD: cfg.Timeout,
+	}
+
+	w, err := webauthn.New(&webauthn.Config{
+		RPID:          cfg.RPID,
+		RPDisplayName: cfg.RPDisplayName,
+		RPOrigins:     cfg.RPOrigins,
+		AuthenticatorSelection: protocol.AuthenticatorSelection{
+			ResidentKey:      protocol.ResidentKeyRequirementPreferred,
+			UserVerification: protocol.VerificationPreferred,
+		},
+		Timeouts: webauthn.TimeoutsConfig{
+			Login:        timeout,
+			Registration: timeout,
+		},
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &passkeyImpl{webauthn: w}, nil
+}
+
+// BeginRegistration starts registering a new credential, excluding the ones
+// the user already has so the same authenticator is not added twice
+func (p *passkeyImpl) BeginRegistration(user *User) (json.RawMessage, []byte, error) {
+	u := webauthnUser{user}
+
+	var exclusions []protocol.CredentialDescriptor
+	for _, credential := range u.WebAuthnCredentials() {
+		exclusions = append(exclusions, credential.Descriptor())
+	}
+
+	creation, session, err := p.webauthn.BeginRegistration(u, webauthn.WithExclusions(exclusions))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return encodeCeremony(creation, session)
+}
+
+// FinishRegistration verifies the attestation and returns the new credential
+func (p *passkeyImpl) FinishRegistration(user *User, session, response []byte) (*Credential, error) {
+	var sessionData webauthn.SessionData
+	if err := json.Unmarshal(session, &sessionData); err != nil {
+		return nil, err
+	}
+
+	parsed, err := protocol.ParseCredentialCreationResponseBytes(response)
+	if err != nil {
+		return nil, err
+	}
+
+	credential, err := p.webauthn.CreateCredential(webauthnUser{user}, sessionData, parsed)
+	if err != nil {
+		return nil, err
+	}
+
+	return fromWebauthnCredential(credential), nil
+}
+
+// BeginLogin starts an assertion limited to the user's own credentials
+func (p *passkeyImpl) BeginLogin(user *User) (json.RawMessage, []byte, error) {
+	assertion, session, err := p.webauthn.BeginLogin(webauthnUser{user})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return encodeCeremony(assertion, session)
+}
+
+// FinishLogin verifies an assertion and returns the credential used with its
+// updated signature counter
+func (p *passkeyImpl) FinishLogin(user *User, session, response []byte) (*Credential, error) {
+	var sessionData webauthn.SessionData
+	if err := json.Unmarshal(session, &sessionData); err != nil {
+		return nil, err
+	}
+
+	parsed, err := protocol.ParseCredentialRequestResponseBytes(response)
+	if err != nil {
+		return nil, err
+	}
+
+	credential, err := p.webauthn.ValidateLogin(webauthnUser{user}, sessionData, parsed)
+	if err != nil {
+		return nil, err
+	}
+
+	if credential.Authenticator.CloneWarning {
+		return nil, ErrClonedAuthenticator
+	}
+
+	return fromWebauthnCredential(credential), nil
+}
+
+// BeginPasswordlessLogin starts an assertion for a discoverable credential.
+// The authenticator must verify the user since the passkey stands in for
+// both the password and the second factor.
+func (p *passkeyImpl) BeginPasswordlessLogin() (json.RawMessage, []byte, error) {
+	assertion, session, err := p.webauthn.BeginDiscoverableLogin(webauthn.WithUserVerification(protocol.VerificationRequired))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return encodeCeremony(assertion, session)
+}
+
+// FinishPasswordlessLogin verifies a discoverable assertion and returns the
+// account it belongs to
+func (p *passkeyImpl) FinishPasswordlessLogin(lookup UserLookup, session, response []byte) (*User, *Credential, error) {
+	var sessionData webauthn.SessionData
+	if err := json.Unmarshal(session, &sessionData); err != nil {
+		return nil, nil, err
+	}
+
+	parsed, err := protocol.ParseCredentialRequestResponseBytes(response)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	handler := func(rawID, userHandle []byte) (webauthn.User, error) {
+		user, err := lookup(userHandle, rawID)
+		if err != nil {
+			return nil, err
+		}
+
+		return webauthnUser{user}, nil
+	}
+
+	u, credential, err := p.webauthn.ValidatePasskeyLogin(handler, sessionData, parsed)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if credential.Authenticator.CloneWarning {
+		return nil, nil, ErrClonedAuthenticator
+	}
+
+	return u.(webauthnUser).User, fromWebauthnCredential(credential), nil
+}
+
+func encodeCeremony(options any, session *webauthn.SessionData) (json.RawMessage, []byte, error) {
+	encodedOptions, err := json.Marshal(options)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	encodedSession, err := json.Marshal(session)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return encodedOptions, encodedSession, nil
+}
+
+// webauthnUser adapts User to the webauthn.User interface
+type webauthnUser struct {
+	*User
+}
+
+func (u webauthnUser) WebAuthnID() []byte {
+	return []byte(u.ID)
+}
+
+func (u webauthnUser) WebAuthnName() string {
+	return u.Name
+}
+
+func (u webauthnUser) WebAuthnDisplayName() string {
+	return u.DisplayName
+}
+
+func (u webauthnUser) WebAuthnCredentials() []webauthn.Credential {
+	credentials := make([]webauthn.Credential, 0, len(u.Credentials))
+
+	for _, c := range u.Credentials {
+		var flags protocol.AuthenticatorFlags
+		if c.BackupEligible {
+			flags |= protocol.FlagBackupEligible
+		}
+		if c.BackupState {
+			flags |= protocol.FlagBackupState
+		}
+
+		transports := make([]protocol.AuthenticatorTransport, len(c.Transports))
+		for i, transport := range c.Transports {
+			transports[i] = protocol.AuthenticatorTransport(transport)
+		}
+
+		credentials = append(credentials, webauthn.Credential{
+			ID:              c.ID,
+			PublicKey:       c.PublicKey,
+			AttestationType: c.AttestationType,
+			Transport:       transports,
+			Flags:           webauthn.NewCredentialFlags(flags),
+			Authenticator: webauthn.Authenticator{
+				AAGUID:    c.AAGUID,
+				SignCount: c.SignCount,
+			},
+		})
+	}
+
+	return credentials
+}
+
+func fromWebauthnCredential(c *webauthn.Credential) *Credential {
+	transports := make([]string, len(c.Transport))
+	for i, transport := range c.Transport {
+		transports[i] = string(transport)
+	}
+
+	return &Credential{
+		ID:              c.ID,
+		PublicKey:       c.PublicKey,
+		AttestationType: c.AttestationType,
+		Transports:      transports,
+		AAGUID:          c.Authenticator.AAGUID,
+		SignCount:       c.Authenticator.SignCount,
+		BackupEligible:  c.Flags.BackupEligible,
+		BackupState:     c.Flags.BackupState,
+	}
+}
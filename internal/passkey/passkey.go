@@ -0,0 +1,49 @@
+package passkey
+
+import (
+	"encoding/json"
+	"errors"
+)
+
+// ErrClonedAuthenticator is returned when an assertion carries a signature
+// counter that did not move forward, which suggests the key was copied.
+var ErrClonedAuthenticator = errors.New("passkey: authenticator signature counter went backwards")
+
+// Credential is the part of a registered public key credential needed to
+// verify later assertions.
+type Credential struct {
+	ID              []byte
+	PublicKey       []byte
+	AttestationType string
+	Transports      []string
+	AAGUID          []byte
+	SignCount       uint32
+	BackupEligible  bool
+	BackupState     bool
+}
+
+// User is the account a ceremony runs for. ID is used as the WebAuthn user
+// handle so it must never change for the account.
+type User struct {
+	ID          string
+	Name        string
+	DisplayName string
+	Credentials []Credential
+}
+
+// UserLookup resolves the owner of a credential during a passwordless login
+// from the user handle and credential id sent by the authenticator.
+type UserLookup func(userHandle, credentialID []byte) (*User, error)
+
+// Passkey defines the interface for WebAuthn ceremonies. Begin methods return
+// the options to hand to navigator.credentials and opaque session state to
+// keep until the matching Finish call. Finish methods take the JSON encoded
+// PublicKeyCredential produced by the authenticator.
+type Passkey interface {
+	BeginRegistration(user *User) (options json.RawMessage, session []byte, err error)
+	FinishRegistration(user *User, session, response []byte) (*Credential, error)
+	BeginLogin(user *User) (options json.RawMessage, session []byte, err error)
+	FinishLogin(user *User, session, response []byte) (*Credential, error)
+	BeginPasswordlessLogin() (options json.RawMessage, session []byte, err error)
+	FinishPasswordlessLogin(lookup UserLookup, session, response []byte) (*User, *Credential, error)
+}
@@ -0,0 +1,464 @@
+package passkey
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-webauthn/webauthn/protocol/webauthncbor"
+	"github.com/go-webauthn/webauthn/protocol/webauthncose"
+)
+
+const (
+	testRPID   = "buyr.test"
+	testOrigin = "https://buyr.test"
+)
+
+const (
+	flagUserPresent  byte = 0x01
+	flagUserVerified byte = 0x04
+	flagAttestedData byte = 0x40
+)
+
+var b64 = base64.RawURLEncoding
+
+// authenticator is a software authenticator holding a single ES256 key. It
+// produces the JSON a browser would hand back from navigator.credentials.
+type authenticator struct {
+	t            *testing.T
+	key          *ecdsa.PrivateKey
+	credentialID []byte
+	userHandle   []byte
+	signCount    uint32
+	origin       string
+}
+
+func newAuthenticator(t *testing.T) *authenticator {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+
+	credentialID := make([]byte, 16)
+	if _, err := rand.Read(credentialID); err != nil {
+		t.Fatalf("failed to generate credential id: %v", err)
+	}
+
+	return &authenticator{t: t, key: key, credentialID: credentialID, origin: testOrigin}
+}
+
+// challenge reads the challenge out of the options returned by a Begin call
+func (a *authenticator) challenge(options json.RawMessage) string {
+	a.t.Helper()
+
+	var parsed struct {
+		PublicKey struct {
+			Challenge string `json:"challenge"`
+		} `json:"publicKey"`
+	}
+
+	if err := json.Unmarshal(options, &parsed); err != nil {
+		a.t.Fatalf("failed to parse options: %v", err)
+	}
+
+	return parsed.PublicKey.Challenge
+}
+
+func (a *authenticator) clientData(kind, challenge string) []byte {
+	a.t.Helper()
+
+	data, err := json.Marshal(map[string]string{
+		"type":      kind,
+		"challenge": challenge,
+		"origin":    a.origin,
+	})
+
+	if err != nil {
+		a.t.Fatalf("failed to encode client data: %v", err)
+	}
+
+	return data
+}
+
+func (a *authenticator) authData(flags byte, attested []byte) []byte {
+	rpIDHash := sha256.Sum256([]byte(testRPID))
+
+	data := append([]byte{}, rpIDHash[:]...)
+	data = append(data, flags)
+	data = binary.BigEndian.AppendUint32(data, a.signCount)
+
+	return append(data, attested...)
+}
+
+// register answers a registration ceremony with a "none" attestation
+func (a *authenticator) register(options json.RawMessage) []byte {
+	a.t.Helper()
+
+	var parsed struct {
+		PublicKey struct {
+			User struct {
+				ID string `json:"id"`
+			} `json:"user"`
+		} `json:"publicKey"`
+	}
+
+	if err := json.Unmarshal(options, &parsed); err != nil {
+		a.t.Fatalf("failed to parse creation options: %v", err)
+	}
+
+	userHandle, err := b64.DecodeString(parsed.PublicKey.User.ID)
+	if err != nil {
+		a.t.Fatalf("failed to decode user handle: %v", err)
+	}
+	a.userHandle = userHandle
+
+	publicKey, err := webauthncbor.Marshal(webauthncose.EC2PublicKeyData{
+		PublicKeyData: webauthncose.PublicKeyData{
+			KeyType:   int64(webauthncose.EllipticKey),
+			Algorithm: int64(webauthncose.AlgES256),
+		},
+		Curve:  1, // P-256
+		XCoord: a.key.X.FillBytes(make([]byte, 32)),
+		YCoord: a.key.Y.FillBytes(make([]byte, 32)),
+	})
+
+	if err != nil {
+		a.t.Fatalf("failed to encode public key: %v", err)
+	}
+
+	attested := make([]byte, 16) // zero AAGUID
+	attested = binary.BigEndian.AppendUint16(attested, uint16(len(a.credentialID)))
+	attested = append(attested, a.credentialID...)
+	attested = append(attested, publicKey...)
+
+	attestationObject, err := webauthncbor.Marshal(struct {
+		Format       string         `cbor:"fmt"`
+		AttStatement map[string]any `cbor:"attStmt"`
+		AuthData     []byte         `cbor:"authData"`
+	}{
+		Format:       "none",
+		AttStatement: map[string]any{},
+		AuthData:     a.authData(flagUserPresent|flagUserVerified|flagAttestedData, attested),
+	})
+
+	if err != nil {
+		a.t.Fatalf("failed to encode attestation object: %v", err)
+	}
+
+	return a.encode(map[string]string{
+		"clientDataJSON":    b64.EncodeToString(a.clientData("webauthn.create", a.challenge(options))),
+		"attestationObject": b64.EncodeToString(attestationObject),
+	})
+}
+
+// assert answers a login ceremony, signing with the next counter value
+func (a *authenticator) assert(options json.RawMessage) []byte {
+	a.t.Helper()
+
+	a.signCount++
+
+	return a.assertWithCount(options, a.signCount)
+}
+
+func (a *authenticator) assertWithCount(options json.RawMessage, signCount uint32) []byte {
+	a.t.Helper()
+
+	a.signCount = signCount
+
+	clientData := a.clientData("webauthn.get", a.challenge(options))
+	authData := a.authData(flagUserPresent|flagUserVerified, nil)
+	clientDataHash := sha256.Sum256(clientData)
+	digest := sha256.Sum256(append(append([]byte{}, authData...), clientDataHash[:]...))
+
+	signature, err := ecdsa.SignASN1(rand.Reader, a.key, digest[:])
+	if err != nil {
+		a.t.Fatalf("failed to sign assertion: %v", err)
+	}
+
+	return a.encode(map[string]string{
+		"clientDataJSON":    b64.EncodeToString(clientData),
+		"authenticatorData": b64.EncodeToString(authData),
+		"signature":         b64.EncodeToString(signature),
+		"userHandle":        b64.EncodeToString(a.userHandle),
+	})
+}
+
+func (a *authenticator) encode(response map[string]string) []byte {
+	a.t.Helper()
+
+	encoded, err := json.Marshal(map[string]any{
+		"id":       b64.EncodeToString(a.credentialID),
+		"rawId":    b64.EncodeToString(a.credentialID),
+		"type":     "public-key",
+		"response": response,
+	})
+
+	if err != nil {
+		a.t.Fatalf("failed to encode credential: %v", err)
+	}
+
+	return encoded
+}
+
+func newTestPasskey(t *testing.T) Passkey {
+	t.Helper()
+
+	p, err := New(Config{
+		RPID:          testRPID,
+		RPDisplayName: "Buyr",
+		RPOrigins:     []string{testOrigin},
+		Timeout:       time.Minute,
+	})
+
+	if err != nil {
+		t.Fatalf("failed to create passkey: %v", err)
+	}
+
+	return p
+}
+
+// registerCredential runs a full registration ceremony and stores the new
+// credential on the user
+func registerCredential(t *testing.T, p Passkey, user *User, a *authenticator) *Credential {
+	t.Helper()
+
+	options, session, err := p.BeginRegistration(user)
+	if err != nil {
+		t.Fatalf("BeginRegistration: %v", err)
+	}
+
+	credential, err := p.FinishRegistration(user, session, a.register(options))
+	if err != nil {
+		t.Fatalf("FinishRegistration: %v", err)
+	}
+
+	user.Credentials = append(user.Credentials, *credential)
+
+	return credential
+}
+
+func newTestUser() *User {
+	return &User{ID: "01JTESTUSER0000000000000000", Name: "jane@buyr.test", DisplayName: "Jane"}
+}
+
+func TestRegistrationAndLogin(t *testing.T) {
+	p := newTestPasskey(t)
+	user := newTestUser()
+	a := newAuthenticator(t)
+
+	credential := registerCredential(t, p, user, a)
+
+	if !bytes.Equal(credential.ID, a.credentialID) {
+		t.Fatalf("credential id = %x, want %x", credential.ID, a.credentialID)
+	}
+
+	for want := uint32(1); want <= 2; want++ {
+		options, session, err := p.BeginLogin(user)
+		if err != nil {
+			t.Fatalf("BeginLogin: %v", err)
+		}
+
+		used, err := p.FinishLogin(user, session, a.assert(options))
+		if err != nil {
+			t.Fatalf("FinishLogin: %v", err)
+		}
+
+		if used.SignCount != want {
+			t.Fatalf("sign count = %d, want %d", used.SignCount, want)
+		}
+
+		user.Credentials[0].SignCount = used.SignCount
+	}
+}
+
+func TestBeginRegistrationExcludesExistingCredentials(t *testing.T) {
+	p := newTestPasskey(t)
+	user := newTestUser()
+	a := newAuthenticator(t)
+
+	registerCredential(t, p, user, a)
+
+	options, _, err := p.BeginRegistration(user)
+	if err != nil {
+		t.Fatalf("BeginRegistration: %v", err)
+	}
+
+	var parsed struct {
+		PublicKey struct {
+			ExcludeCredentials []struct {
+				ID string `json:"id"`
+			} `json:"excludeCredentials"`
+		} `json:"publicKey"`
+	}
+
+	if err := json.Unmarshal(options, &parsed); err != nil {
+		t.Fatalf("failed to parse options: %v", err)
+	}
+
+	excluded := parsed.PublicKey.ExcludeCredentials
+	if len(excluded) != 1 || excluded[0].ID != b64.EncodeToString(a.credentialID) {
+		t.Fatalf("excludeCredentials = %+v, want the registered credential", excluded)
+	}
+}
+
+func TestFinishRegistrationRejectsWrongOrigin(t *testing.T) {
+	p := newTestPasskey(t)
+	user := newTestUser()
+	a := newAuthenticator(t)
+	a.origin = "https://evil.test"
+
+	options, session, err := p.BeginRegistration(user)
+	if err != nil {
+		t.Fatalf("BeginRegistration: %v", err)
+	}
+
+	if _, err := p.FinishRegistration(user, session, a.register(options)); err == nil {
+		t.Fatal("FinishRegistration accepted a response from another origin")
+	}
+}
+
+func TestFinishLoginRejectsOtherSession(t *testing.T) {
+	p := newTestPasskey(t)
+	user := newTestUser()
+	a := newAuthenticator(t)
+
+	registerCredential(t, p, user, a)
+
+	options, _, err := p.BeginLogin(user)
+	if err != nil {
+		t.Fatalf("BeginLogin: %v", err)
+	}
+
+	_, otherSession, err := p.BeginLogin(user)
+	if err != nil {
+		t.Fatalf("BeginLogin: %v", err)
+	}
+
+	if _, err := p.FinishLogin(user, otherSession, a.assert(options)); err == nil {
+		t.Fatal("FinishLogin accepted an assertion for another challenge")
+	}
+}
+
+func TestFinishLoginRejectsUnknownKey(t *testing.T) {
+	p := newTestPasskey(t)
+	user := newTestUser()
+	a := newAuthenticator(t)
+
+	registerCredential(t, p, user, a)
+
+	// same credential id, different private key
+	impostor := newAuthenticator(t)
+	impostor.credentialID = a.credentialID
+	impostor.userHandle = a.userHandle
+
+	options, session, err := p.BeginLogin(user)
+	if err != nil {
+		t.Fatalf("BeginLogin: %v", err)
+	}
+
+	if _, err := p.FinishLogin(user, session, impostor.assert(options)); err == nil {
+		t.Fatal("FinishLogin accepted a signature from another key")
+	}
+}
+
+func TestFinishLoginRejectsClonedAuthenticator(t *testing.T) {
+	p := newTestPasskey(t)
+	user := newTestUser()
+	a := newAuthenticator(t)
+
+	registerCredential(t, p, user, a)
+	user.Credentials[0].SignCount = 5
+
+	for _, signCount := range []uint32{5, 3} {
+		options, session, err := p.BeginLogin(user)
+		if err != nil {
+			t.Fatalf("BeginLogin: %v", err)
+		}
+
+		_, err = p.FinishLogin(user, session, a.assertWithCount(options, signCount))
+		if !errors.Is(err, ErrClonedAuthenticator) {
+			t.Fatalf("sign count %d after 5: err = %v, want ErrClonedAuthenticator", signCount, err)
+		}
+	}
+}
+
+func TestPasswordlessLogin(t *testing.T) {
+	p := newTestPasskey(t)
+	user := newTestUser()
+	a := newAuthenticator(t)
+
+	registerCredential(t, p, user, a)
+
+	lookup := func(userHandle, credentialID []byte) (*User, error) {
+		if string(userHandle) != user.ID {
+			return nil, errors.New("unknown user")
+		}
+
+		return user, nil
+	}
+
+	options, session, err := p.BeginPasswordlessLogin()
+	if err != nil {
+		t.Fatalf("BeginPasswordlessLogin: %v", err)
+	}
+
+	var parsed struct {
+		PublicKey struct {
+			UserVerification string `json:"userVerification"`
+		} `json:"publicKey"`
+	}
+
+	if err := json.Unmarshal(options, &parsed); err != nil {
+		t.Fatalf("failed to parse options: %v", err)
+	}
+
+	if parsed.PublicKey.UserVerification != "required" {
+		t.Fatalf("userVerification = %q, want required", parsed.PublicKey.UserVerification)
+	}
+
+	found, used, err := p.FinishPasswordlessLogin(lookup, session, a.assert(options))
+	if err != nil {
+		t.Fatalf("FinishPasswordlessLogin: %v", err)
+	}
+
+	if found.ID != user.ID {
+		t.Fatalf("user = %q, want %q", found.ID, user.ID)
+	}
+
+	if used.SignCount != 1 {
+		t.Fatalf("sign count = %d, want 1", used.SignCount)
+	}
+}
+
+func TestPasswordlessLoginRejectsClonedAuthenticator(t *testing.T) {
+	p := newTestPasskey(t)
+	user := newTestUser()
+	a := newAuthenticator(t)
+
+	registerCredential(t, p, user, a)
+	user.Credentials[0].SignCount = 10
+
+	lookup := func(userHandle, credentialID []byte) (*User, error) {
+		return user, nil
+	}
+
+	options, session, err := p.BeginPasswordlessLogin()
+	if err != nil {
+		t.Fatalf("BeginPasswordlessLogin: %v", err)
+	}
+
+	_, _, err = p.FinishPasswordlessLogin(lookup, session, a.assertWithCount(options, 4))
+	if !errors.Is(err, ErrClonedAuthenticator) {
+		t.Fatalf("err = %v, want ErrClonedAuthenticator", err)
+	}
+}
@@ -0,0 +1,383 @@
+package productio
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/devphaseX/buyr-api.git/internal/store"
+)
+
+type Format string
+
+var (
+	CSVFormat    Format = "csv"
+	NDJSONFormat Format = "ndjson"
+)
+
+var (
+	ErrUnsupportedFormat = errors.New("unsupported file format")
+	ErrMissingHeader     = errors.New("missing csv header row")
+)
+
+// imageSeparator joins multiple image urls within a single csv cell.
+const imageSeparator = "|"
+
+// csvHeader is the column layout shared by import and export so an exported
+// catalog can be fed straight back into an import. Rows that carry an id
+// update that product instead of creating a new one.
+var csvHeader = []string{
+	"id", "name", "description", "price", "discount", "stock_quantity",
+	"category_id", "images", "features",
+}
+
+type Feature struct {
+	Title          string                   `json:"title" validate:"required,max=255"`
+	View           store.ProductFeatureView `json:"view" validate:"required,oneof=table list bullet"`
+	FeatureEntries map[string]interface{}   `json:"feature_entries" validate:"required"`
+}
+
+type Row struct {
+	ID            string     `json:"id,omitempty"`
+	Name          string     `json:"name" validate:"required,max=255"`
+	Description   string     `json:"description" validate:"required"`
+	Price         float64    `json:"price" validate:"required,gt=0"`
+	Discount      float64    `json:"discount" validate:"gte=0,lte=100"`
+	StockQuantity int        `json:"stock_quantity" validate:"gte=0"`
+	CategoryID    string     `json:"category_id" validate:"required"`
+	Images        []string   `json:"images" validate:"required,min=1,dive,url"`
+	Features      []*Feature `json:"features" validate:"dive"`
+}
+
+// Record is a single decoded line of an import file. Err is set when the
+// line could not be decoded into a Row.
+type Record struct {
+	Line int
+	Row  *Row
+	Err  error
+}
+
+// FormatFromFilename infers the import format from a file extension.
+func FormatFromFilename(filename string) (Format, error) {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".csv":
+		return CSVFormat, nil
+	case ".ndjson", ".jsonl":
+		return NDJSONFormat, nil
+	}
+
+	return "", ErrUnsupportedFormat
+}
+
+func ParseFormat(s string) (Format, error) {
+	switch Format(strings.ToLower(s)) {
+	case CSVFormat:
+		return CSVFormat, nil
+	case NDJSONFormat:
+		return NDJSONFormat, nil
+	}
+
+	return "", ErrUnsupportedFormat
+}
+
+func (f Format) ContentType() string {
+	if f == CSVFormat {
+		return "text/csv"
+	}
+
+	return "application/x-ndjson"
+}
+
+// Decode reads every record in r. Malformed lines are reported through
+// Record.Err rather than aborting the whole file.
+func Decode(format Format, r io.Reader) ([]*Record, error) {
+	switch format {
+	case CSVFormat:
+		return decodeCSV(r)
+	case NDJSONFormat:
+		return decodeNDJSON(r)
+	}
+
+	return nil, ErrUnsupportedFormat
+}
+
+func decodeCSV(r io.Reader) ([]*Record, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, ErrMissingHeader
+		}
+		return nil, err
+	}
+
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+
+	for _, name := range []string{"name", "price", "category_id"} {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("csv header is missing %q column", name)
+		}
+	}
+
+	var (
+		records []*Record
+		line    = 1
+	)
+
+	for {
+		fields, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		line++
+
+		if err != nil {
+			records = append(records, &Record{Line: line, Err: err})
+			continue
+		}
+
+		row, err := rowFromCSV(columns, fields)
+		records = append(records, &Record{Line: line, Row: row, Err: err})
+	}
+
+	return records, nil
+}
+
+func rowFromCSV(columns map[string]int, fields []string) (*Row, error) {
+	get := func(name string) string {
+		i, ok := columns[name]
+		if !ok || i >= len(fields) {
+			return ""
+		}
+		return strings.TrimSpace(fields[i])
+	}
+
+	row := &Row{
+		ID:          get("id"),
+		Name:        get("name"),
+		Description: get("description"),
+		CategoryID:  get("category_id"),
+	}
+
+	var err error
+
+	if v := get("price"); v != "" {
+		if row.Price, err = strconv.ParseFloat(v, 64); err != nil {
+			return nil, fmt.Errorf("invalid price %q", v)
+		}
+	}
+
+	if v := get("discount"); v != "" {
+		if row.Discount, err = strconv.ParseFloat(v, 64); err != nil {
+			return nil, fmt.Errorf("invalid discount %q", v)
+		}
+	}
+
+	if v := get("stock_quantity"); v != "" {
+		if row.StockQuantity, err = strconv.Atoi(v); err != nil {
+			return nil, fmt.Errorf("invalid stock_quantity %q", v)
+		}
+	}
+
+	if v := get("images"); v != "" {
+		for _, url := range strings.Split(v, imageSeparator) {
+			if url = strings.TrimSpace(url); url != "" {
+				row.Images = append(row.Images, url)
+			}
+		}
+	}
+
+	if v := get("features"); v != "" {
+		if err := json.Unmarshal([]byte(v), &row.Features); err != nil {
+			return nil, fmt.Errorf("invalid features json: %w", err)
+		}
+	}
+
+	return row, nil
+}
+
+func decodeNDJSON(r io.Reader) ([]*Record, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	var (
+		records []*Record
+		line    int
+	)
+
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+
+		if text == "" {
+			continue
+		}
+
+		row := &Row{}
+		if err := json.Unmarshal([]byte(text), row); err != nil {
+			records = append(records, &Record{Line: line, Err: fmt.Errorf("invalid json: %w", err)})
+			continue
+		}
+
+		records = append(records, &Record{Line: line, Row: row})
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return records, nil
+}
+
+// Product converts a decoded row into a pending store.Product owned by
+// vendorID. A row with an id keeps it so the import updates that product.
+func (row *Row) Product(vendorID string) *store.Product {
+	product := &store.Product{
+		ID:            row.ID,
+		Name:          row.Name,
+		Description:   row.Description,
+		Price:         row.Price,
+		Discount:      row.Discount,
+		StockQuantity: row.StockQuantity,
+		CategoryID:    row.CategoryID,
+		VendorID:      vendorID,
+		Status:        store.PendingProductStatus,
+	}
+
+	for i, url := range row.Images {
+		product.Images = append(product.Images, &store.ProductImage{
+			URL:       url,
+			IsPrimary: i == 0,
+		})
+	}
+
+	for _, feature := range row.Features {
+		product.Features = append(product.Features, &store.ProductFeature{
+			Title:          feature.Title,
+			View:           feature.View,
+			FeatureEntries: feature.FeatureEntries,
+		})
+	}
+
+	return product
+}
+
+// RowFromProduct is the inverse of Row.Product and is used by exports.
+func RowFromProduct(product *store.Product) *Row {
+	row := &Row{
+		ID:            product.ID,
+		Name:          product.Name,
+		Description:   product.Description,
+		Price:         product.Price,
+		Discount:      product.Discount,
+		StockQuantity: product.StockQuantity,
+		CategoryID:    product.CategoryID,
+		Images:        []string{},
+		Features:      []*Feature{},
+	}
+
+	for _, image := range product.Images {
+		if image.IsPrimary {
+			row.Images = append([]string{image.URL}, row.Images...)
+			continue
+		}
+		row.Images = append(row.Images, image.URL)
+	}
+
+	for _, feature := range product.Features {
+		row.Features = append(row.Features, &Feature{
+			Title:          feature.Title,
+			View:           feature.View,
+			FeatureEntries: feature.FeatureEntries,
+		})
+	}
+
+	return row
+}
+
+type Writer interface {
+	Write(product *store.Product) error
+	Flush() error
+}
+
+func NewWriter(format Format, w io.Writer) (Writer, error) {
+	switch format {
+	case CSVFormat:
+		return &csvWriter{w: csv.NewWriter(w)}, nil
+	case NDJSONFormat:
+		bw := bufio.NewWriter(w)
+		return &ndjsonWriter{w: bw, enc: json.NewEncoder(bw)}, nil
+	}
+
+	return nil, ErrUnsupportedFormat
+}
+
+type csvWriter struct {
+	w             *csv.Writer
+	headerWritten bool
+}
+
+func (cw *csvWriter) Write(product *store.Product) error {
+	if !cw.headerWritten {
+		if err := cw.w.Write(csvHeader); err != nil {
+			return err
+		}
+		cw.headerWritten = true
+	}
+
+	row := RowFromProduct(product)
+
+	features, err := json.Marshal(row.Features)
+	if err != nil {
+		return err
+	}
+
+	return cw.w.Write([]string{
+		row.ID,
+		row.Name,
+		row.Description,
+		strconv.FormatFloat(row.Price, 'f', -1, 64),
+		strconv.FormatFloat(row.Discount, 'f', -1, 64),
+		strconv.Itoa(row.StockQuantity),
+		row.CategoryID,
+		strings.Join(row.Images, imageSeparator),
+		string(features),
+	})
+}
+
+func (cw *csvWriter) Flush() error {
+	if !cw.headerWritten {
+		if err := cw.w.Write(csvHeader); err != nil {
+			return err
+		}
+		cw.headerWritten = true
+	}
+
+	cw.w.Flush()
+	return cw.w.Error()
+}
+
+type ndjsonWriter struct {
+	w   *bufio.Writer
+	enc *json.Encoder
+}
+
+func (nw *ndjsonWriter) Write(product *store.Product) error {
+	return nw.enc.Encode(RowFromProduct(product))
+}
+
+func (nw *ndjsonWriter) Flush() error {
+	return nw.w.Flush()
+}
@@ -0,0 +1,262 @@
+package store
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base32"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/devphaseX/buyr-api.git/internal/db"
+	"github.com/lib/pq"
+)
+
+// APIKeyPrefix starts every API key so leaked keys are easy to recognise
+const APIKeyPrefix = "buyr_"
+
+// APIKeyScope limits what an API key, or a third-party app a user
+// authorized, can do on the user's behalf
+type APIKeyScope string
+
+var (
+	ProductsReadScope   APIKeyScope = "products:read"
+	ProductsWriteScope  APIKeyScope = "products:write"
+	InventoryReadScope  APIKeyScope = "inventory:read"
+	InventoryWriteScope APIKeyScope = "inventory:write"
+	OrdersReadScope     APIKeyScope = "orders:read"
+)
+
+// APIKeyScopes lists every scope a key can be granted
+var APIKeyScopes = []APIKeyScope{
+	ProductsReadScope,
+	ProductsWriteScope,
+	InventoryReadScope,
+	InventoryWriteScope,
+	OrdersReadScope,
+}
+
+// APIKey lets a user's own systems call the API without a session. Only a
+// hash of the key is stored; the plaintext is shown once when it is created.
+type APIKey struct {
+	ID         string        `json:"id"`
+	UserID     string        `json:"user_id"`
+	Name       string        `json:"name"`
+	Prefix     string        `json:"prefix"`
+	KeyHash    []byte        `json:"-"`
+	Scopes     []APIKeyScope `json:"scopes"`
+	LastUsedAt *time.Time    `json:"last_used_at"`
+	LastUsedIP *string       `json:"last_used_ip"`
+	ExpiresAt  *time.Time    `json:"expires_at"`
+	CreatedAt  time.Time     `json:"created_at"`
+}
+
+// HasScopes reports whether the key was granted all of the scopes
+func (k *APIKey) HasScopes(scopes ...APIKeyScope) bool {
+	return hasScopes(k.Scopes, scopes...)
+}
+
+func (k *APIKey) IsExpired() bool {
+	return k.ExpiresAt != nil && time.Now().After(*k.ExpiresAt)
+}
+
+// GenerateAPIKey returns a new random key and the hash to store for it
+func GenerateAPIKey() (plaintext string, hash []byte, err error) {
+	randomBytes := make([]byte, 32)
+
+	if _, err := rand.Read(randomBytes); err != nil {
+		return "", nil, err
+	}
+
+	plaintext = APIKeyPrefix + strings.ToLower(base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(randomBytes))
+
+	return plaintext, HashAPIKey(plaintext), nil
+}
+
+func HashAPIKey(plaintext string) []byte {
+	hash := sha256.Sum256([]byte(plaintext))
+	return hash[:]
+}
+
+type APIKeyStore interface {
+	Create(ctx context.Context, key *APIKey) error
+	GetByHash(ctx context.Context, hash []byte) (*APIKey, error)
+	GetByUserID(ctx context.Context, userID string) ([]*APIKey, error)
+	RecordUse(ctx context.Context, id, ip string) error
+	Delete(ctx context.Context, id, userID string) error
+}
+
+type APIKeyModel struct {
+	db *sql.DB
+}
+
+func NewAPIKeyModel(db *sql.DB) APIKeyStore {
+	return &APIKeyModel{db}
+}
+
+func (m *APIKeyModel) Create(ctx context.Context, key *APIKey) error {
+	query := `INSERT INTO api_keys(id, user_id, name, prefix, key_hash, scopes, expires_at)
+			  VALUES ($1, $2, $3, $4, $5, $6, $7)
+			  RETURNING created_at`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	key.ID = db.GenerateULID()
+
+	args := []any{key.ID, key.UserID, key.Name, key.Prefix, key.KeyHash,
+		pq.Array(scopesToStrings(key.Scopes)), key.ExpiresAt}
+
+	return m.db.QueryRowContext(ctx, query, args...).Scan(&key.CreatedAt)
+}
+
+func (m *APIKeyModel) GetByHash(ctx context.Context, hash []byte) (*APIKey, error) {
+	query := `SELECT id, user_id, name, prefix, key_hash, scopes, last_used_at, last_used_ip, expires_at, created_at
+			  FROM api_keys
+			  WHERE key_hash = $1`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	key, err := scanAPIKey(m.db.QueryRowContext(ctx, query, hash))
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return key, nil
+}
+
+func (m *APIKeyModel) GetByUserID(ctx context.Context, userID string) ([]*APIKey, error) {
+	query := `SELECT id, user_id, name, prefix, key_hash, scopes, last_used_at, last_used_ip, expires_at, created_at
+			  FROM api_keys
+			  WHERE user_id = $1
+			  ORDER BY created_at DESC`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := m.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	keys := []*APIKey{}
+
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		keys = append(keys, key)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return keys, nil
+}
+
+func (m *APIKeyModel) RecordUse(ctx context.Context, id, ip string) error {
+	query := `UPDATE api_keys SET last_used_at = NOW(), last_used_ip = $2 WHERE id = $1`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := m.db.ExecContext(ctx, query, id, ip)
+	return err
+}
+
+func (m *APIKeyModel) Delete(ctx context.Context, id, userID string) error {
+	query := `DELETE FROM api_keys WHERE id = $1 AND user_id = $2`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	result, err := m.db.ExecContext(ctx, query, id, userID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanAPIKey(row rowScanner) (*APIKey, error) {
+	var (
+		key    APIKey
+		scopes []string
+	)
+
+	err := row.Scan(&key.ID, &key.UserID, &key.Name, &key.Prefix, &key.KeyHash, pq.Array(&scopes),
+		&key.LastUsedAt, &key.LastUsedIP, &key.ExpiresAt, &key.CreatedAt)
+
+	if err != nil {
+		return nil, err
+	}
+
+	key.Scopes = stringsToScopes(scopes)
+
+	return &key, nil
+}
+
+func hasScopes(granted []APIKeyScope, scopes ...APIKeyScope) bool {
+	for _, scope := range scopes {
+		if !slices.Contains(granted, scope) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// ParseScopes reads a space separated scope list as used by OAuth
+func ParseScopes(value string) []APIKeyScope {
+	return stringsToScopes(strings.Fields(value))
+}
+
+// FormatScopes joins scopes into a space separated list as used by OAuth
+func FormatScopes(scopes []APIKeyScope) string {
+	return strings.Join(scopesToStrings(scopes), " ")
+}
+
+func stringsToScopes(values []string) []APIKeyScope {
+	scopes := make([]APIKeyScope, len(values))
+	for i, value := range values {
+		scopes[i] = APIKeyScope(value)
+	}
+
+	return scopes
+}
+
+func scopesToStrings(scopes []APIKeyScope) []string {
+	values := make([]string, len(scopes))
+	for i, scope := range scopes {
+		values[i] = string(scope)
+	}
+
+	return values
+}
@@ -13,24 +13,48 @@ import (
 type AuditEventType string
 
 var (
-	AccountDisableAuditEventType AuditEventType = "account_disabled"
-	AccountEnabledAuditEventType AuditEventType = "account_enabled"
-	ChangeRoleAuditEventType     AuditEventType = "change_role"
+	AccountDisableAuditEventType      AuditEventType = "account_disabled"
+	AccountEnabledAuditEventType      AuditEventType = "account_enabled"
+	ChangeRoleAuditEventType          AuditEventType = "change_role"
+	ProductApprovedAuditEventType     AuditEventType = "product_approved"
+	ProductRejectedAuditEventType     AuditEventType = "product_rejected"
+	ReviewHiddenAuditEventType        AuditEventType = "review_hidden"
+	ReviewRestoredAuditEventType      AuditEventType = "review_restored"
+	ReviewDismissedAuditEventType     AuditEventType = "review_reports_dismissed"
+	RefreshTokenReusedAuditEventType  AuditEventType = "refresh_token_reused"
+	LoginLockoutClearedAuditEventType AuditEventType = "login_lockout_cleared"
+	TwoFactorDisabledAuditEventType   AuditEventType = "two_factor_disabled"
+	TwoFactorReenrolledAuditEventType AuditEventType = "two_factor_reenrolled"
+	TwoFactorResetAuditEventType      AuditEventType = "two_factor_reset"
 )
 
+// AuditActorType says what PerformedBy refers to: an admin_users id, a users
+// id for changes users make to their own account, or the system itself.
+type AuditActorType string
+
+var (
+	AdminAuditActorType  AuditActorType = "admin"
+	UserAuditActorType   AuditActorType = "user"
+	SystemAuditActorType AuditActorType = "system"
+)
+
+// SystemAuditActor is the PerformedBy of events the system records on its own
+const SystemAuditActor = "system"
+
 type AuditEvent struct {
-	ID          string         `json:"id"`
-	EventType   AuditEventType `json:"event_type"`
-	AccountID   string         `json:"account_id"`
-	PerformedBy string         `json:"performed_by"`
-	Reason      string         `json:"reason"`
-	Details     []byte         `json:"details"`
-	Timestamp   time.Time      `json:"timestamp"`
-	AccessLevel int            `json:"admin_level_access"`
-	IPAddress   string         `json:"ip_address"`
-	UserAgent   string         `json:"user_agent"`
-	CreatedAt   time.Time      `json:"created_at"`
-	UpdatedAt   time.Time      `json:"updated_at"`
+	ID              string         `json:"id"`
+	EventType       AuditEventType `json:"event_type"`
+	AccountID       string         `json:"account_id"`
+	PerformedBy     string         `json:"performed_by"`
+	PerformedByType AuditActorType `json:"performed_by_type"`
+	Reason          string         `json:"reason"`
+	Details         []byte         `json:"details"`
+	Timestamp       time.Time      `json:"timestamp"`
+	AccessLevel     int            `json:"admin_level_access"`
+	IPAddress       string         `json:"ip_address"`
+	UserAgent       string         `json:"user_agent"`
+	CreatedAt       time.Time      `json:"created_at"`
+	UpdatedAt       time.Time      `json:"updated_at"`
 }
 
 type AuditEventStore interface {
@@ -50,42 +74,63 @@ func NewAuditEventModel(db *sql.DB) AuditEventStore {
 func (s *AuditEventModel) LogEvent(ctx context.Context, event AuditEvent) error {
 	event.ID = db.GenerateULID()
 	query := `
-		INSERT INTO audit_events (id, event_type, performed_by, reason, details, timestamp, ip_address, user_agent)
-		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		INSERT INTO audit_events (id, event_type, account_id, performed_by, reason, details, timestamp,
+			admin_level_access, ip_address, user_agent, performed_by_type)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
 	`
+
+	if event.PerformedByType == "" {
+		event.PerformedByType = AdminAuditActorType
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
 	_, err := s.db.ExecContext(ctx, query,
 		event.ID,
 		event.EventType,
+		event.AccountID,
 		event.PerformedBy,
 		event.Reason,
 		event.Details,
 		event.Timestamp,
+		event.AccessLevel,
 		event.IPAddress,
 		event.UserAgent,
+		event.PerformedByType,
 	)
 	return err
 }
 
+// AuditEventWithAdmin is an audit event with who performed it. AdminUser is
+// set for events performed by an admin and User for events users performed
+// on their own account.
 type AuditEventWithAdmin struct {
 	AuditEvent
-	AdminUser AdminUser `json:"admin_user"`
+	AdminUser *AdminUser `json:"admin_user"`
+	User      *User      `json:"user,omitempty"`
 }
 
+const auditEventColumns = `
+	a.id, a.event_type, COALESCE(a.account_id, ''), a.performed_by, a.performed_by_type, a.reason, a.details,
+	a.timestamp, a.admin_level_access, a.ip_address, a.user_agent, a.created_at, a.updated_at,
+	au.id, au.first_name, au.last_name, au.admin_level,
+	u.id, u.email, u.avatar_url, u.role, u.email_verified_at, u.is_active, u.created_at, u.updated_at`
+
+// auditEventActorJoins joins the admin or user who performed an event, and
+// nothing for events of the system
+const auditEventActorJoins = `
+	LEFT JOIN admin_users au ON a.performed_by_type = 'admin' AND au.id = a.performed_by
+	LEFT JOIN users u ON u.id = CASE WHEN a.performed_by_type = 'user' THEN a.performed_by ELSE au.user_id END`
+
 func (s *AuditEventModel) GetAuditLogs(ctx context.Context, filter PaginateQueryFilter, adminLevel AdminLevel) ([]*AuditEventWithAdmin, Metadata, error) {
 	query := fmt.Sprintf(`
-		SELECT
-			count(a.id) OVER(),
-			a.id, a.event_type, a.account_id, a.performed_by, a.reason, a.details, a.timestamp,
-			a.admin_level_access, a.ip_address, a.user_agent, a.created_at, a.updated_at,
-			au.first_name, au.last_name, au.admin_level,
-			u.id, u.email, u.avatar_url, u.role, u.email_verified_at, u.is_active, u.created_at, u.updated_at
-		FROM audit_events a
-		JOIN admin_users au ON a.performed_by = au.id
-		JOIN users u ON au.user_id = u.id
+		SELECT count(a.id) OVER(), %s
+		FROM audit_events a %s
 		WHERE a.admin_level_access = $1
-		ORDER BY au.%s %s
+		ORDER BY a.%s %s
 		LIMIT $2 OFFSET $3
-	`, filter.SortColumn(), filter.SortDirection())
+	`, auditEventColumns, auditEventActorJoins, filter.SortColumn(), filter.SortDirection())
 
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
@@ -102,74 +147,12 @@ func (s *AuditEventModel) GetAuditLogs(ctx context.Context, filter PaginateQuery
 	)
 
 	for rows.Next() {
-		var (
-			event           AuditEvent
-			details         []byte
-			createdAt       time.Time
-			updatedAt       time.Time
-			timestamp       time.Time
-			adminUser       AdminUser
-			user            User
-			emailVerifiedAt sql.NullTime
-			avatarURL       sql.NullString
-			isActive        sql.NullBool
-		)
-
-		err := rows.Scan(
-			&totalRecords,
-			&event.ID,
-			&event.EventType,
-			&event.AccountID,
-			&event.PerformedBy,
-			&event.Reason,
-			&details,
-			&timestamp,
-			&event.AccessLevel,
-			&event.IPAddress,
-			&event.UserAgent,
-			&createdAt,
-			&updatedAt,
-			&adminUser.FirstName,
-			&adminUser.LastName,
-			&adminUser.AdminLevel,
-			&user.ID,
-			&user.Email,
-			&avatarURL,
-			&user.Role,
-			&emailVerifiedAt,
-			&isActive,
-			&user.CreatedAt,
-			&user.UpdatedAt,
-		)
-
+		auditLog, err := scanAuditEventWithAdmin(rows, &totalRecords)
 		if err != nil {
 			return nil, Metadata{}, fmt.Errorf("failed to scan audit log row: %w", err)
 		}
 
-		// Convert binary details to a readable format (if needed).
-		event.Details = details
-		event.Timestamp = timestamp
-		event.CreatedAt = createdAt
-		event.UpdatedAt = updatedAt
-
-		// Populate the User and AdminUser fields.
-		if emailVerifiedAt.Valid {
-			user.EmailVerifiedAt = &emailVerifiedAt.Time
-		}
-		if avatarURL.Valid {
-			user.AvatarURL = avatarURL.String
-		}
-		if isActive.Valid {
-			user.IsActive = isActive.Bool
-		}
-
-		adminUser.User = user
-
-		// Combine the audit event with the admin user details.
-		auditLogs = append(auditLogs, &AuditEventWithAdmin{
-			AuditEvent: event,
-			AdminUser:  adminUser,
-		})
+		auditLogs = append(auditLogs, auditLog)
 	}
 
 	if err = rows.Err(); err != nil {
@@ -183,91 +166,113 @@ func (s *AuditEventModel) GetAuditLogs(ctx context.Context, filter PaginateQuery
 }
 
 func (s *AuditEventModel) GetAuditLogByID(ctx context.Context, id string) (*AuditEventWithAdmin, error) {
-	query := `
-		SELECT
-			a.id, a.event_type, a.account_id, a.performed_by, a.reason, a.details, a.timestamp,
-			a.admin_level_access, a.ip_address, a.user_agent, a.created_at, a.updated_at,
-			au.first_name, au.last_name, au.admin_level,
-			u.id, u.email, u.avatar_url, u.role, u.email_verified_at, u.is_active, u.created_at, u.updated_at
-		FROM audit_logs a
-		JOIN admin_users au ON a.performed_by = au.id
-		JOIN users u ON au.user_id = u.id
+	query := fmt.Sprintf(`
+		SELECT %s
+		FROM audit_events a %s
 		WHERE a.id = $1
-	`
+	`, auditEventColumns, auditEventActorJoins)
 
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
 
+	auditLog, err := scanAuditEventWithAdmin(s.db.QueryRowContext(ctx, query, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrRecordNotFound
+		}
+		return nil, fmt.Errorf("failed to fetch audit log: %w", err)
+	}
+
+	return auditLog, nil
+}
+
+// scanAuditEventWithAdmin reads auditEventColumns, after any leading columns
+// given in dest
+func scanAuditEventWithAdmin(row rowScanner, dest ...any) (*AuditEventWithAdmin, error) {
 	var (
 		event           AuditEvent
-		details         []byte
-		createdAt       time.Time
-		updatedAt       time.Time
-		timestamp       time.Time
-		adminUser       AdminUser
-		user            User
-		emailVerifiedAt sql.NullTime
+		adminID         sql.NullString
+		firstName       sql.NullString
+		lastName        sql.NullString
+		adminLevel      sql.NullString
+		userID          sql.NullString
+		email           sql.NullString
 		avatarURL       sql.NullString
+		role            sql.NullString
+		emailVerifiedAt sql.NullTime
 		isActive        sql.NullBool
+		userCreatedAt   sql.NullTime
+		userUpdatedAt   sql.NullTime
 	)
 
-	err := s.db.QueryRowContext(ctx, query, id).Scan(
+	err := row.Scan(append(dest,
 		&event.ID,
 		&event.EventType,
 		&event.AccountID,
 		&event.PerformedBy,
+		&event.PerformedByType,
 		&event.Reason,
-		&details,
-		&timestamp,
+		&event.Details,
+		&event.Timestamp,
 		&event.AccessLevel,
 		&event.IPAddress,
 		&event.UserAgent,
-		&createdAt,
-		&updatedAt,
-		&adminUser.FirstName,
-		&adminUser.LastName,
-		&adminUser.AdminLevel,
-		&user.ID,
-		&user.Email,
+		&event.CreatedAt,
+		&event.UpdatedAt,
+		&adminID,
+		&firstName,
+		&lastName,
+		&adminLevel,
+		&userID,
+		&email,
 		&avatarURL,
-		&user.Role,
+		&role,
 		&emailVerifiedAt,
 		&isActive,
-		&user.CreatedAt,
-		&user.UpdatedAt,
-	)
+		&userCreatedAt,
+		&userUpdatedAt,
+	)...)
 
 	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return nil, ErrRecordNotFound
-		}
-		return nil, fmt.Errorf("failed to fetch audit log: %w", err)
+		return nil, err
 	}
 
-	// Convert binary details to a readable format (if needed).
-	event.Details = details
-	event.Timestamp = timestamp
-	event.CreatedAt = createdAt
-	event.UpdatedAt = updatedAt
+	auditLog := &AuditEventWithAdmin{AuditEvent: event}
 
-	// Populate the User and AdminUser fields.
-	if emailVerifiedAt.Valid {
-		user.EmailVerifiedAt = &emailVerifiedAt.Time
-	}
-	if avatarURL.Valid {
-		user.AvatarURL = avatarURL.String
-	}
-	if isActive.Valid {
-		user.IsActive = isActive.Bool
+	var user *User
+
+	if userID.Valid {
+		user = &User{
+			ID:        userID.String,
+			Email:     email.String,
+			AvatarURL: avatarURL.String,
+			Role:      Role(role.String),
+			IsActive:  isActive.Bool,
+			CreatedAt: userCreatedAt.Time,
+			UpdatedAt: userUpdatedAt.Time,
+		}
+
+		if emailVerifiedAt.Valid {
+			user.EmailVerifiedAt = &emailVerifiedAt.Time
+		}
 	}
 
-	adminUser.User = user
+	switch {
+	case adminID.Valid:
+		auditLog.AdminUser = &AdminUser{
+			ID:         adminID.String,
+			FirstName:  firstName.String,
+			LastName:   lastName.String,
+			AdminLevel: AdminLevel(adminLevel.String),
+			UserID:     userID.String,
+		}
 
-	// Combine the audit event with the admin user details.
-	auditLogWithAdmin := &AuditEventWithAdmin{
-		AuditEvent: event,
-		AdminUser:  adminUser,
+		if user != nil {
+			auditLog.AdminUser.User = *user
+		}
+	case event.PerformedByType == UserAuditActorType:
+		auditLog.User = user
 	}
 
-	return auditLogWithAdmin, nil
+	return auditLog, nil
 }
@@ -0,0 +1,146 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+)
+
+// outstandingBackorderSQL matches order items aliased as oi that were taken
+// without stock and have not been filled yet.
+const outstandingBackorderSQL = `oi.backordered AND oi.backorder_filled_at IS NULL`
+
+// holdBackorderedOrder moves a freshly paid order to backordered when some
+// of its items are still waiting on stock, then tries to fill them right
+// away.
+func holdBackorderedOrder(ctx context.Context, tx *sql.Tx, orderID string) error {
+	_, err := tx.ExecContext(ctx, `
+		UPDATE orders SET status = $2
+		WHERE id = $1 AND EXISTS (
+			SELECT 1 FROM order_items oi WHERE oi.order_id = orders.id AND `+outstandingBackorderSQL+`)`,
+		orderID, BackorderedOrderStatus)
+
+	if err != nil {
+		return err
+	}
+
+	return fillOrderBackorders(ctx, tx, orderID)
+}
+
+// fillOrderBackorders hands stock of every product in an order to the
+// backorders waiting on it, for when the order gives stock back or needs
+// some itself.
+func fillOrderBackorders(ctx context.Context, tx *sql.Tx, orderID string) error {
+	rows, err := tx.QueryContext(ctx, `SELECT DISTINCT product_id FROM order_items WHERE order_id = $1 ORDER BY product_id`, orderID)
+	if err != nil {
+		return err
+	}
+
+	var productIDs []string
+
+	for rows.Next() {
+		var productID string
+
+		if err := rows.Scan(&productID); err != nil {
+			rows.Close()
+			return err
+		}
+
+		productIDs = append(productIDs, productID)
+	}
+
+	rows.Close()
+
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, productID := range productIDs {
+		if err := fillProductBackorders(ctx, tx, productID); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// fillProductBackorders sells available stock of a product to paid orders
+// waiting on it, oldest order first. It stops at the first order it cannot
+// fill so a large early backorder is not overtaken by later small ones.
+// Orders with nothing left outstanding move on to processing.
+func fillProductBackorders(ctx context.Context, tx *sql.Tx, productID string) error {
+	rows, err := tx.QueryContext(ctx, `
+		SELECT oi.order_id, oi.quantity, COALESCE(o.shipping_address_id, '')
+		FROM order_items oi
+		JOIN orders o ON o.id = oi.order_id
+		WHERE oi.product_id = $1 AND `+outstandingBackorderSQL+` AND o.status = $2
+		ORDER BY o.created_at, o.id
+		FOR UPDATE OF oi`, productID, BackorderedOrderStatus)
+
+	if err != nil {
+		return err
+	}
+
+	type backorder struct {
+		orderID   string
+		quantity  int
+		addressID string
+	}
+
+	var waiting []backorder
+
+	for rows.Next() {
+		var item backorder
+
+		if err := rows.Scan(&item.orderID, &item.quantity, &item.addressID); err != nil {
+			rows.Close()
+			return err
+		}
+
+		waiting = append(waiting, item)
+	}
+
+	rows.Close()
+
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, item := range waiting {
+		destination, err := orderDestination(ctx, tx, item.addressID)
+		if err != nil {
+			return err
+		}
+
+		err = allocateOrderItem(ctx, tx, item.orderID, "", productID, item.quantity, destination, "backorder filled")
+		if err != nil {
+			if errors.Is(err, ErrInsufficientStock) {
+				return nil
+			}
+			return err
+		}
+
+		if _, err := releaseOrderReservations(ctx, tx, item.orderID, "backorder filled", true); err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, `UPDATE order_items SET backorder_filled_at = NOW() WHERE order_id = $1 AND product_id = $2`,
+			item.orderID, productID)
+
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, `
+			UPDATE orders SET status = $2
+			WHERE id = $1 AND status = $3 AND NOT EXISTS (
+				SELECT 1 FROM order_items oi WHERE oi.order_id = orders.id AND `+outstandingBackorderSQL+`)`,
+			item.orderID, ProcessingOrderStatus, BackorderedOrderStatus)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
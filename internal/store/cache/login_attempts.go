@@ -0,0 +1,221 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// LockoutPolicy controls how failed sign-ins slow down and then lock an
+// account. The first FreeAttempts failures cost nothing; each one after that
+// doubles the wait from BaseDelay up to MaxDelay, and MaxAttempts failures
+// lock the account for LockoutDuration. Failures are forgotten after Window
+// without another one.
+type LockoutPolicy struct {
+	FreeAttempts    int
+	MaxAttempts     int
+	BaseDelay       time.Duration
+	MaxDelay        time.Duration
+	LockoutDuration time.Duration
+	Window          time.Duration
+}
+
+// LoginLockout is the failed sign-in state of an account. Locked is set for a
+// full lockout rather than a backoff delay.
+type LoginLockout struct {
+	UserID        string     `json:"user_id"`
+	Email         string     `json:"email"`
+	Failures      int        `json:"failures"`
+	Locked        bool       `json:"locked"`
+	LockedUntil   *time.Time `json:"locked_until"`
+	LastFailureAt *time.Time `json:"last_failure_at"`
+}
+
+// RetryAfter is how long the account must wait before the next attempt, zero
+// when it may try now.
+func (l *LoginLockout) RetryAfter() time.Duration {
+	if l.LockedUntil == nil {
+		return 0
+	}
+
+	return max(time.Until(*l.LockedUntil), 0)
+}
+
+type RedisLoginAttemptModel struct {
+	client *redis.Client
+}
+
+func NewRedisLoginAttemptModel(client *redis.Client) LoginAttemptStore {
+	return &RedisLoginAttemptModel{client}
+}
+
+const loginLockoutsKey = "login_lockouts"
+
+func createLoginAttemptKey(userID string) string {
+	return fmt.Sprintf("login_attempts:%s", userID)
+}
+
+func (m *RedisLoginAttemptModel) Get(ctx context.Context, userID string) (*LoginLockout, error) {
+	fields, err := m.client.HGetAll(ctx, createLoginAttemptKey(userID)).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	lockout := &LoginLockout{
+		UserID: userID,
+		Email:  fields["email"],
+		Locked: fields["locked"] == "1",
+	}
+
+	lockout.Failures, _ = strconv.Atoi(fields["failures"])
+	lockout.LockedUntil = parseUnixField(fields["locked_until"])
+	lockout.LastFailureAt = parseUnixField(fields["last_failure_at"])
+
+	return lockout, nil
+}
+
+// reserveAttemptScript counts a sign-in attempt as failed before it is
+// checked, unless the account is still waiting out a delay or lockout. The
+// wait for the next attempt follows the policy described on LockoutPolicy.
+// Starting a new lockout forgets that the previous one was notified.
+var reserveAttemptScript = redis.NewScript(`
+local now = tonumber(ARGV[3])
+local lockedUntil = tonumber(redis.call('HGET', KEYS[1], 'locked_until') or '')
+
+if lockedUntil and lockedUntil * 1000 > now then
+	return 0
+end
+
+local failures = redis.call('HINCRBY', KEYS[1], 'failures', 1)
+local free = tonumber(ARGV[4])
+local wait = 0
+local locked = 0
+
+if failures >= tonumber(ARGV[5]) then
+	locked = 1
+	wait = tonumber(ARGV[8])
+elseif failures > free then
+	wait = math.min(tonumber(ARGV[6]) * 2 ^ (failures - free - 1), tonumber(ARGV[7]))
+end
+
+local lockUntil = ''
+if wait > 0 then
+	lockUntil = math.floor((now + wait) / 1000)
+end
+
+redis.call('HSET', KEYS[1], 'email', ARGV[2], 'locked', locked,
+	'last_failure_at', math.floor(now / 1000), 'locked_until', lockUntil)
+redis.call('PEXPIRE', KEYS[1], ARGV[9])
+
+if locked == 1 then
+	redis.call('HDEL', KEYS[1], 'lock_notified')
+	redis.call('ZADD', KEYS[2], lockUntil, ARGV[1])
+end
+
+return 1
+`)
+
+// refundAttemptScript takes back an attempt that turned out not to fail. The
+// attempt was only allowed because the account was not waiting, so the wait
+// it started is lifted again.
+var refundAttemptScript = redis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 0 then
+	return 0
+end
+
+if redis.call('HINCRBY', KEYS[1], 'failures', -1) <= 0 then
+	redis.call('DEL', KEYS[1])
+else
+	redis.call('HSET', KEYS[1], 'locked', 0, 'locked_until', '')
+	redis.call('HDEL', KEYS[1], 'lock_notified')
+end
+
+redis.call('ZREM', KEYS[2], ARGV[1])
+
+return 1
+`)
+
+// Reserve counts a sign-in attempt against the account before its
+// credentials are checked, so that parallel attempts cannot all get past the
+// lockout. It reports false without counting anything while the account has
+// to wait. An attempt that does not fail must be given back with Refund, or
+// the account cleared with Clear.
+func (m *RedisLoginAttemptModel) Reserve(ctx context.Context, userID, email string, policy LockoutPolicy) (*LoginLockout, bool, error) {
+	keys := []string{createLoginAttemptKey(userID), loginLockoutsKey}
+	args := []any{userID, email, time.Now().UnixMilli(), policy.FreeAttempts, policy.MaxAttempts,
+		policy.BaseDelay.Milliseconds(), policy.MaxDelay.Milliseconds(), policy.LockoutDuration.Milliseconds(),
+		max(policy.Window, policy.LockoutDuration).Milliseconds()}
+
+	reserved, err := reserveAttemptScript.Run(ctx, m.client, keys, args...).Bool()
+	if err != nil {
+		return nil, false, err
+	}
+
+	lockout, err := m.Get(ctx, userID)
+	if err != nil {
+		return nil, false, err
+	}
+
+	return lockout, reserved, nil
+}
+
+// Refund gives back an attempt counted by Reserve that did not fail.
+func (m *RedisLoginAttemptModel) Refund(ctx context.Context, userID string) error {
+	return refundAttemptScript.Run(ctx, m.client, []string{createLoginAttemptKey(userID), loginLockoutsKey}, userID).Err()
+}
+
+// MarkLockNotified reports true once per lockout, for the caller that gets to
+// tell the user about it.
+func (m *RedisLoginAttemptModel) MarkLockNotified(ctx context.Context, userID string) (bool, error) {
+	return m.client.HSetNX(ctx, createLoginAttemptKey(userID), "lock_notified", 1).Result()
+}
+
+func (m *RedisLoginAttemptModel) Clear(ctx context.Context, userID string) error {
+	pipe := m.client.TxPipeline()
+	pipe.Del(ctx, createLoginAttemptKey(userID))
+	pipe.ZRem(ctx, loginLockoutsKey, userID)
+
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// GetLocked returns the accounts currently locked out, dropping lockouts that
+// have already run out.
+func (m *RedisLoginAttemptModel) GetLocked(ctx context.Context) ([]*LoginLockout, error) {
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+
+	if err := m.client.ZRemRangeByScore(ctx, loginLockoutsKey, "-inf", "("+now).Err(); err != nil {
+		return nil, err
+	}
+
+	userIDs, err := m.client.ZRangeByScore(ctx, loginLockoutsKey, &redis.ZRangeBy{Min: now, Max: "+inf"}).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	lockouts := make([]*LoginLockout, 0, len(userIDs))
+
+	for _, userID := range userIDs {
+		lockout, err := m.Get(ctx, userID)
+		if err != nil {
+			return nil, err
+		}
+
+		lockouts = append(lockouts, lockout)
+	}
+
+	return lockouts, nil
+}
+
+func parseUnixField(value string) *time.Time {
+	seconds, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return nil
+	}
+
+	t := time.Unix(seconds, 0)
+	return &t
+}
@@ -0,0 +1,66 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/devphaseX/buyr-api.git/internal/store"
+	"github.com/redis/go-redis/v9"
+)
+
+type RedisSessionModel struct {
+	client *redis.Client
+}
+
+func NewRedisSessionModel(client *redis.Client) SessionStore {
+	return &RedisSessionModel{client}
+}
+
+func createSessionCacheKey(sessionID string) string {
+	return fmt.Sprintf("session-%v", sessionID)
+}
+
+func (s *RedisSessionModel) Get(ctx context.Context, sessionID string) (*store.Session, error) {
+	data, err := s.client.Get(ctx, createSessionCacheKey(sessionID)).Result()
+
+	if err == redis.Nil {
+		return nil, store.ErrRecordNotFound
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	session := &store.Session{}
+	if err := json.Unmarshal([]byte(data), session); err != nil {
+		return nil, err
+	}
+
+	return session, nil
+}
+
+func (s *RedisSessionModel) Set(ctx context.Context, session *store.Session) error {
+	json, err := json.Marshal(session)
+
+	if err != nil {
+		return err
+	}
+
+	return s.client.SetEx(ctx, createSessionCacheKey(session.ID), json, UserExpTime).Err()
+}
+
+// Delete drops cached sessions so a revoked session is rejected on its next
+// request rather than when the cache entry expires.
+func (s *RedisSessionModel) Delete(ctx context.Context, sessionIDs ...string) error {
+	if len(sessionIDs) == 0 {
+		return nil
+	}
+
+	keys := make([]string, len(sessionIDs))
+	for i, sessionID := range sessionIDs {
+		keys[i] = createSessionCacheKey(sessionID)
+	}
+
+	return s.client.Del(ctx, keys...).Err()
+}
@@ -5,6 +5,8 @@ import (
 	"crypto/rand"
 	"crypto/sha256"
 	"encoding/base32"
+	"fmt"
+	"math/big"
 	"time"
 
 	"github.com/devphaseX/buyr-api.git/internal/store"
@@ -20,6 +22,15 @@ const (
 	ChangePassword2faTokenScope TokenScope = "change_password_2fa"
 	ChangeEmail2faTokenScope    TokenScope = "change_email_2fa"
 	ChangeEmailTokenScope       TokenScope = "change_email"
+	UnlockAccountTokenScope     TokenScope = "unlock_account"
+	PasskeyRegisterTokenScope   TokenScope = "passkey_register"
+	PasskeyLogin2faTokenScope   TokenScope = "passkey_login_2fa"
+	PasskeySignInTokenScope     TokenScope = "passkey_sign_in"
+	Email2faCodeTokenScope      TokenScope = "email_2fa_code"
+	Email2faSetupTokenScope     TokenScope = "email_2fa_setup"
+	TotpReenrollTokenScope      TokenScope = "totp_reenroll"
+	OAuthStateTokenScope        TokenScope = "oauth_state"
+	OAuthCodeTokenScope         TokenScope = "oauth_code"
 )
 
 var (
@@ -39,6 +50,12 @@ type TokenStore interface {
 	New(userID string, ttl time.Duration, scope TokenScope, data []byte) (*Token, error)
 	Insert(ctx context.Context, token *Token) error
 	Get(ctx context.Context, scope TokenScope, tokenKey string) (*Token, error)
+	Delete(ctx context.Context, token *Token) error
+	Consume(ctx context.Context, scope TokenScope, tokenKey string) (*Token, error)
+	RecordConsumer(ctx context.Context, token *Token, consumer string) (bool, error)
+	TakeConsumer(ctx context.Context, scope TokenScope, tokenKey string) (string, error)
+	NewCode(userID string, ttl time.Duration, scope TokenScope, data []byte) (*Token, error)
+	GetCode(ctx context.Context, scope TokenScope, userID, code string) (*Token, error)
 	DeleteAllForUser(ctx context.Context, scope TokenScope, userID string) error
 }
 
@@ -47,15 +64,34 @@ type UserStore interface {
 	Set(ctx context.Context, user *store.User) error
 }
 
+type SessionStore interface {
+	Get(ctx context.Context, sessionID string) (*store.Session, error)
+	Set(ctx context.Context, session *store.Session) error
+	Delete(ctx context.Context, sessionIDs ...string) error
+}
+
+type LoginAttemptStore interface {
+	Get(ctx context.Context, userID string) (*LoginLockout, error)
+	Reserve(ctx context.Context, userID, email string, policy LockoutPolicy) (*LoginLockout, bool, error)
+	Refund(ctx context.Context, userID string) error
+	MarkLockNotified(ctx context.Context, userID string) (bool, error)
+	Clear(ctx context.Context, userID string) error
+	GetLocked(ctx context.Context) ([]*LoginLockout, error)
+}
+
 type Storage struct {
-	Tokens TokenStore
-	Users  UserStore
+	Tokens        TokenStore
+	Users         UserStore
+	Sessions      SessionStore
+	LoginAttempts LoginAttemptStore
 }
 
 func NewRedisStorage(rdb *redis.Client) *Storage {
 	return &Storage{
-		Tokens: NewRedisTokenModel(rdb),
-		Users:  NewRedisUserModel(rdb),
+		Tokens:        NewRedisTokenModel(rdb),
+		Users:         NewRedisUserModel(rdb),
+		Sessions:      NewRedisSessionModel(rdb),
+		LoginAttempts: NewRedisLoginAttemptModel(rdb),
 	}
 }
 
@@ -81,3 +117,30 @@ func generateToken(userID string, ttl time.Duration, scope TokenScope, data []by
 
 	return token, nil
 }
+
+// generateCode creates a short numeric token that can be typed from an email.
+// A six digit code is easy to guess across all users, so the hash is salted
+// with the user id and codes are looked up per user.
+func generateCode(userID string, ttl time.Duration, scope TokenScope, data []byte) (*Token, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(1_000_000))
+	if err != nil {
+		return nil, err
+	}
+
+	token := &Token{
+		Plaintext: fmt.Sprintf("%06d", n.Int64()),
+		UserID:    userID,
+		Expiry:    time.Now().Add(ttl),
+		Scope:     scope,
+		Data:      data,
+	}
+
+	token.Hash = hashCode(userID, token.Plaintext)
+
+	return token, nil
+}
+
+func hashCode(userID, code string) []byte {
+	hash := sha256.Sum256([]byte(userID + ":" + code))
+	return hash[:]
+}
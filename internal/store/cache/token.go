@@ -24,6 +24,10 @@ func createTokenKey(scope TokenScope, identifier string) string {
 	return fmt.Sprintf("%s:%s", scope, identifier)
 }
 
+func createConsumedTokenKey(scope TokenScope, identifier string) string {
+	return fmt.Sprintf("%s:consumed:%s", scope, identifier)
+}
+
 func createUserTokenSetKey(scope TokenScope, userID string) string {
 	return fmt.Sprintf("%s:user_tokens:%s", scope, userID)
 }
@@ -89,6 +93,136 @@ func (s *RedisTokenModel) Get(ctx context.Context, scope TokenScope, tokenKey st
 	return token, nil
 }
 
+func (m *RedisTokenModel) NewCode(userID string, ttl time.Duration, scope TokenScope, data []byte) (*Token, error) {
+	return generateCode(userID, ttl, scope, data)
+}
+
+// GetCode looks up a code issued by NewCode for the given user.
+func (m *RedisTokenModel) GetCode(ctx context.Context, scope TokenScope, userID, code string) (*Token, error) {
+	key := createTokenKey(scope, hex.EncodeToString(hashCode(userID, code)))
+	data, err := m.client.Get(ctx, key).Result()
+
+	if err == redis.Nil {
+		return nil, store.ErrRecordNotFound
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	token := &Token{}
+	if err := json.Unmarshal([]byte(data), token); err != nil {
+		return nil, err
+	}
+
+	return token, nil
+}
+
+// Delete removes a single token, for tokens that must only be used once but
+// are not tied to a user, such as a passwordless sign-in challenge.
+func (m *RedisTokenModel) Delete(ctx context.Context, token *Token) error {
+	tokenHash := hex.EncodeToString(token.Hash)
+
+	err := m.client.Del(ctx, createTokenKey(token.Scope, tokenHash)).Err()
+	if err != nil {
+		return fmt.Errorf("failed to delete token: %w", err)
+	}
+
+	err = m.client.SRem(ctx, createUserTokenSetKey(token.Scope, token.UserID), tokenHash).Err()
+	if err != nil {
+		return fmt.Errorf("failed to remove token hash from user token set: %w", err)
+	}
+
+	return nil
+}
+
+// consumeTokenScript removes a token and, when it was there, leaves an empty
+// consumed marker in its place for as long as the token would have lived.
+var consumeTokenScript = redis.NewScript(`
+local ttl = redis.call('PTTL', KEYS[1])
+local data = redis.call('GETDEL', KEYS[1])
+
+if not data then
+	return false
+end
+
+if ttl > 0 then
+	redis.call('SET', KEYS[2], '', 'PX', ttl)
+end
+
+return data
+`)
+
+// Consume removes a single use token and returns it, so that of several
+// concurrent callers presenting the same token only one gets it back. The
+// others get ErrRecordNotFound and can use TakeConsumer to find out what the
+// token was used for.
+func (m *RedisTokenModel) Consume(ctx context.Context, scope TokenScope, tokenKey string) (*Token, error) {
+	hash := sha256.Sum256([]byte(tokenKey))
+	hashedKey := hex.EncodeToString(hash[:])
+
+	keys := []string{createTokenKey(scope, hashedKey), createConsumedTokenKey(scope, hashedKey)}
+
+	data, err := consumeTokenScript.Run(ctx, m.client, keys).Text()
+	if err == redis.Nil {
+		return nil, store.ErrRecordNotFound
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	token := &Token{}
+	if err := json.Unmarshal([]byte(data), token); err != nil {
+		return nil, err
+	}
+
+	err = m.client.SRem(ctx, createUserTokenSetKey(scope, token.UserID), hashedKey).Err()
+	if err != nil {
+		return nil, fmt.Errorf("failed to remove token hash from user token set: %w", err)
+	}
+
+	return token, nil
+}
+
+// RecordConsumer notes what a consumed token was used for, such as the grant
+// issued for an authorization code. It reports false when the token was
+// presented again in the meantime and TakeConsumer already claimed the
+// marker, in which case the caller should undo what it issued.
+func (m *RedisTokenModel) RecordConsumer(ctx context.Context, token *Token, consumer string) (bool, error) {
+	key := createConsumedTokenKey(token.Scope, hex.EncodeToString(token.Hash))
+
+	err := m.client.SetArgs(ctx, key, consumer, redis.SetArgs{Mode: "XX", KeepTTL: true}).Err()
+	if err == redis.Nil {
+		return false, nil
+	}
+
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// TakeConsumer returns what a consumed token was used for, empty when its
+// consumer has not recorded it yet, and removes the marker so a later
+// RecordConsumer call learns the token was replayed. It returns
+// ErrRecordNotFound for a token that was never consumed or has expired.
+func (m *RedisTokenModel) TakeConsumer(ctx context.Context, scope TokenScope, tokenKey string) (string, error) {
+	hash := sha256.Sum256([]byte(tokenKey))
+
+	consumer, err := m.client.GetDel(ctx, createConsumedTokenKey(scope, hex.EncodeToString(hash[:]))).Result()
+	if err == redis.Nil {
+		return "", store.ErrRecordNotFound
+	}
+
+	if err != nil {
+		return "", err
+	}
+
+	return consumer, nil
+}
+
 func (m *RedisTokenModel) DeleteAllForUser(ctx context.Context, scope TokenScope, userID string) error {
 	// Create the key for the user's token set
 	userTokenSetKey := createUserTokenSetKey(scope, userID)
@@ -107,6 +107,8 @@ type CartItemStore interface {
 	GetCartItems(ctx context.Context, cartID, vendorID string, filter PaginateQueryFilter) ([]*VendorGroupCartItem, Metadata, error)
 	SetItemQuantity(ctx context.Context, cartID, cartItemID string, quantity int) error
 	GetItemsByIDS(ctx context.Context, cartID string, ids []string) ([]*CartItem, error)
+	MoveFromWishlist(ctx context.Context, cartID, userID string, wishlistItemIDs []string) ([]*ItemMoveResult, error)
+	MoveToWishlist(ctx context.Context, cartID, userID, collectionID string, cartItemIDs []string) ([]*ItemMoveResult, error)
 }
 
 type CartItemModel struct {
@@ -172,6 +174,7 @@ type CartItemDetails struct {
 		VendorID            string        `json:"vendor_id"`
 		Discount            float64       `json:"discount"`
 		Price               float64       `json:"price"`
+		EffectivePrice      float64       `json:"effective_price"`
 		CategoryID          string        `json:"category_id"`
 		CreatedAt           time.Time     `json:"created_at"`
 		UpdatedAt           time.Time     `json:"updated_at"`
@@ -196,7 +199,7 @@ func (m *CartItemModel) GetItemByID(ctx context.Context, cartID, itemID string)
 		SELECT
 			ci.id, ci.cart_id, ci.product_id, ci.added_at, ci.quantity, ci.created_at, ci.updated_at,
 			p.id, p.name, p.description, p.stock_quantity, p.status, pi.url, p.published,
-			p.total_items_sold_count, p.vendor_id, p.discount, p.price, p.category_id, p.created_at, p.updated_at,
+			p.total_items_sold_count, p.vendor_id, p.discount, p.price, ` + effectivePriceSQL + `, p.category_id, p.created_at, p.updated_at,
 			v.id, v.business_name, v.business_address, v.contact_number, u.avatar_url, v.user_id,
 			v.city, v.country, v.created_at, v.updated_at
 		FROM cart_items ci
@@ -222,7 +225,7 @@ func (m *CartItemModel) GetItemByID(ctx context.Context, cartID, itemID string)
 		&details.Product.ID, &details.Product.Name, &details.Product.Description, &details.Product.StockQuantity,
 		&details.Product.Status, &productAvatarURL, &details.Product.Published,
 		&details.Product.TotalItemsSoldCount, &details.Product.VendorID, &details.Product.Discount,
-		&details.Product.Price, &details.Product.CategoryID, &details.Product.CreatedAt, &details.Product.UpdatedAt,
+		&details.Product.Price, &details.Product.EffectivePrice, &details.Product.CategoryID, &details.Product.CreatedAt, &details.Product.UpdatedAt,
 		&details.Vendor.ID, &details.Vendor.BusinessName, &details.Vendor.BusinessAddress,
 		&details.Vendor.ContactNumber, &vendorAvatarURL, &details.Vendor.UserID,
 		&details.Vendor.City, &details.Vendor.Country, &details.Vendor.CreatedAt, &details.Vendor.UpdatedAt,
@@ -277,7 +280,7 @@ func (m *CartItemModel) GetItems(ctx context.Context, cartID string) ([]*CartIte
 		SELECT count(ci.id) OVER(),
 			ci.id, ci.cart_id, ci.product_id, ci.added_at, ci.quantity, ci.created_at, ci.updated_at,
 			p.id, p.name, p.description, p.stock_quantity, p.status,  pi.url, p.published,
-			p.total_items_sold_count, p.vendor_id,  p.discount, p.price, p.category_id, p.created_at, p.updated_at,
+			p.total_items_sold_count, p.vendor_id, p.discount, p.price, ` + effectivePriceSQL + `, p.category_id, p.created_at, p.updated_at,
 			v.id, v.business_name, v.business_address, v.contact_number, u.avatar_url, v.user_id,
 			v.city, v.country, v.created_at, v.updated_at
 		FROM cart_items ci
@@ -316,7 +319,7 @@ func (m *CartItemModel) GetItems(ctx context.Context, cartID string) ([]*CartIte
 			&details.Product.ID, &details.Product.Name, &details.Product.Description, &details.Product.StockQuantity,
 			&details.Product.Status, &productAvatarURL, &details.Product.Published,
 			&details.Product.TotalItemsSoldCount, &details.Product.VendorID, &details.Product.Discount,
-			&details.Product.Price, &details.Product.CategoryID, &details.Product.CreatedAt, &details.Product.UpdatedAt,
+			&details.Product.Price, &details.Product.EffectivePrice, &details.Product.CategoryID, &details.Product.CreatedAt, &details.Product.UpdatedAt,
 			&details.Vendor.ID, &details.Vendor.BusinessName, &details.Vendor.BusinessAddress,
 			&details.Vendor.ContactNumber, &vendorAvatarURL, &details.Vendor.UserID,
 			&details.Vendor.City, &details.Vendor.Country, &details.Vendor.CreatedAt, &details.Vendor.UpdatedAt,
@@ -357,6 +360,7 @@ type VendorGroupCartItem struct {
 		VendorID            string        `json:"vendor_id"`
 		Discount            float64       `json:"discount"`
 		Price               float64       `json:"price"`
+		EffectivePrice      float64       `json:"effective_price"`
 		CategoryID          string        `json:"category_id"`
 		CreatedAt           time.Time     `json:"created_at"`
 		UpdatedAt           time.Time     `json:"updated_at"`
@@ -402,6 +406,7 @@ func (m *CartItemModel) GetGroupCartItems(ctx context.Context, cartID string,
 				p.total_items_sold_count,
 				p.discount,
 				p.price,
+				` + effectivePriceSQL + ` AS effective_price,
 				p.category_id,
 				p.created_at AS product_created_at,
 				p.updated_at AS product_updated_at,
@@ -452,6 +457,7 @@ func (m *CartItemModel) GetGroupCartItems(ctx context.Context, cartID string,
 							'total_items_sold_count', vi.total_items_sold_count,
 							'discount', vi.discount,
 							'price', vi.price,
+							'effective_price', vi.effective_price,
 							'category_id', vi.category_id,
 							'created_at', vi.product_created_at,
 							'updated_at', vi.product_updated_at,
@@ -557,6 +563,7 @@ func (m *CartItemModel) GetCartItems(ctx context.Context, cartID, vendorID strin
                 p.total_items_sold_count,
                 p.discount,
                 p.price,
+                ` + effectivePriceSQL + ` AS effective_price,
                 p.category_id,
                 p.created_at AS product_created_at,
                 p.updated_at AS product_updated_at,
@@ -589,6 +596,7 @@ func (m *CartItemModel) GetCartItems(ctx context.Context, cartID, vendorID strin
                 'total_items_sold_count', vi.total_items_sold_count,
                 'discount', vi.discount,
                 'price', vi.price,
+                'effective_price', vi.effective_price,
                 'category_id', vi.category_id,
                 'created_at', vi.product_created_at,
                 'updated_at', vi.product_updated_at,
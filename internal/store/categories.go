@@ -5,19 +5,51 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/devphaseX/buyr-api.git/internal/db"
+	"github.com/lib/pq"
+)
+
+var (
+	ErrDuplicateCategorySlug  = errors.New("category slug already in use")
+	ErrCategoryParentNotFound = errors.New("parent category not found")
+	ErrCategoryCycle          = errors.New("category cannot be moved beneath itself or its descendants")
+	ErrCategoryHasChildren    = errors.New("category has subcategories")
 )
 
 type Category struct {
-	ID               string    `json:"id"`
-	Name             string    `json:"name"`
-	Description      string    `json:"description"`
-	Visible          bool      `json:"visible"`
-	CreatedByAdminID string    `json:"created_by_admin_id,omitempty"`
-	CreatedAt        time.Time `json:"created_at"`
-	UpdatedAt        time.Time `json:"updated_at"`
+	ID               string      `json:"id"`
+	ParentID         *string     `json:"parent_id"`
+	Name             string      `json:"name"`
+	Slug             string      `json:"slug"`
+	Description      string      `json:"description"`
+	Visible          bool        `json:"visible"`
+	Depth            int         `json:"depth"`
+	Path             string      `json:"-"`
+	CreatedByAdminID string      `json:"created_by_admin_id,omitempty"`
+	Children         []*Category `json:"children,omitempty"`
+	CreatedAt        time.Time   `json:"created_at"`
+	UpdatedAt        time.Time   `json:"updated_at"`
+}
+
+// CategoryCrumb is a single ancestor entry in a category breadcrumb trail.
+type CategoryCrumb struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	Slug  string `json:"slug"`
+	Depth int    `json:"depth"`
+}
+
+// AncestorIDs returns the ids on the materialized path, root first, excluding
+// the category itself.
+func (c *Category) AncestorIDs() []string {
+	ids := strings.Split(strings.Trim(c.Path, "/"), "/")
+	if len(ids) == 0 {
+		return nil
+	}
+	return ids[:len(ids)-1]
 }
 
 type CategoryStore interface {
@@ -27,6 +59,10 @@ type CategoryStore interface {
 	GetPublicCategories(ctx context.Context, filter PaginateQueryFilter) ([]*Category, Metadata, error)
 	GetAdminCategoryView(ctx context.Context, filter PaginateQueryFilter) ([]*AdminCategoryView, Metadata, error)
 	SetCategoryVisibility(ctx context.Context, categoryID string, visibility bool) error
+	GetBySlug(ctx context.Context, slug string) (*Category, error)
+	Move(ctx context.Context, categoryID string, parentID *string) (*Category, error)
+	GetBreadcrumbs(ctx context.Context, category *Category) ([]*CategoryCrumb, error)
+	GetVisibleTree(ctx context.Context, rootID *string) ([]*Category, error)
 }
 
 type CategoryModel struct {
@@ -37,10 +73,46 @@ func NewCategoryModel(db *sql.DB) CategoryStore {
 	return &CategoryModel{db}
 }
 
+func categoryConstraintError(err error) error {
+	var pgErr *pq.Error
+	if errors.As(err, &pgErr) {
+		switch pgErr.Constraint {
+		case "category_slug_key":
+			return ErrDuplicateCategorySlug
+		case "category_parent_id_fk":
+			return ErrCategoryParentNotFound
+		}
+	}
+
+	return err
+}
+
+// lockCategory reads the row with FOR UPDATE so concurrent moves cannot
+// interleave and produce a cycle.
+func lockCategory(ctx context.Context, tx *sql.Tx, categoryID string) (*Category, error) {
+	query := `SELECT id, parent_id, path, depth FROM category WHERE id = $1 FOR UPDATE`
+
+	category := &Category{}
+
+	err := tx.QueryRowContext(ctx, query, categoryID).Scan(&category.ID, &category.ParentID,
+		&category.Path, &category.Depth)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return category, nil
+}
+
 func (m *CategoryModel) Create(ctx context.Context, category *Category) error {
 
-	query := `INSERT INTO category(id, name, description,visible, created_by_admin_id)
-			 VALUES($1, $2, $3, $4, $5)
+	query := `INSERT INTO category(id, parent_id, name, slug, description, visible, path, depth, created_by_admin_id)
+			 VALUES($1, $2, $3, $4, $5, $6, $7, $8, $9)
 			  RETURNING id, created_at, updated_at
 	`
 
@@ -49,21 +121,46 @@ func (m *CategoryModel) Create(ctx context.Context, category *Category) error {
 
 	id := db.GenerateULID()
 
-	args := []any{id, category.Name, category.Description, category.Visible, category.CreatedByAdminID}
+	return withTrx(m.db, ctx, func(tx *sql.Tx) error {
+		category.Path = "/" + id + "/"
+		category.Depth = 0
+
+		if category.ParentID != nil {
+			parent, err := lockCategory(ctx, tx, *category.ParentID)
+			if err != nil {
+				if errors.Is(err, ErrRecordNotFound) {
+					return ErrCategoryParentNotFound
+				}
+				return err
+			}
 
-	err := m.db.QueryRowContext(ctx, query, args...).Scan(&category.ID, &category.CreatedAt, &category.UpdatedAt)
+			category.Path = parent.Path + id + "/"
+			category.Depth = parent.Depth + 1
+		}
 
-	if err != nil {
-		return fmt.Errorf("failed to create category: %w", err)
-	}
+		args := []any{id, category.ParentID, category.Name, category.Slug, category.Description,
+			category.Visible, category.Path, category.Depth, category.CreatedByAdminID}
 
-	return nil
+		err := tx.QueryRowContext(ctx, query, args...).Scan(&category.ID, &category.CreatedAt, &category.UpdatedAt)
+
+		if err != nil {
+			err = categoryConstraintError(err)
+			switch {
+			case errors.Is(err, ErrDuplicateCategorySlug), errors.Is(err, ErrCategoryParentNotFound):
+				return err
+			default:
+				return fmt.Errorf("failed to create category: %w", err)
+			}
+		}
+
+		return nil
+	})
 }
 
 func (m *CategoryModel) GetPublicCategories(ctx context.Context, filter PaginateQueryFilter) ([]*Category, Metadata, error) {
 	query := fmt.Sprintf(
 		`
-			SELECT count(id) over(), id, name, description,visible, created_at, updated_at FROM category
+			SELECT count(id) over(), id, parent_id, name, slug, description, visible, depth, path, created_at, updated_at FROM category
 		    WHERE visible = true
 			ORDER BY %s %s
 			LIMIT $1 OFFSET $2
@@ -92,9 +189,13 @@ func (m *CategoryModel) GetPublicCategories(ctx context.Context, filter Paginate
 		err := rows.Scan(
 			&totalRecord,
 			&category.ID,
+			&category.ParentID,
 			&category.Name,
+			&category.Slug,
 			&category.Description,
 			&category.Visible,
+			&category.Depth,
+			&category.Path,
 			&category.CreatedAt,
 			&category.UpdatedAt)
 
@@ -125,9 +226,13 @@ func (m *CategoryModel) GetAdminCategoryView(ctx context.Context, filter Paginat
         SELECT
             count(c.id) OVER(),
             c.id,
+            c.parent_id,
             c.name,
+            c.slug,
             c.description,
             c.visible,
+            c.depth,
+            c.path,
             c.created_at,
             c.updated_at,
             au.id AS admin_user_id,
@@ -198,9 +303,13 @@ func (m *CategoryModel) GetAdminCategoryView(ctx context.Context, filter Paginat
 		err := rows.Scan(
 			&totalRecord,
 			&category.ID,
+			&category.ParentID,
 			&category.Name,
+			&category.Slug,
 			&category.Description,
 			&category.Visible,
+			&category.Depth,
+			&category.Path,
 			&category.CreatedAt,
 			&category.UpdatedAt,
 			&adminUserID,
@@ -271,6 +380,10 @@ func (m *CategoryModel) RemoveByID(ctx context.Context, categoryID string) error
 	res, err := m.db.ExecContext(ctx, query, categoryID)
 
 	if err != nil {
+		var pgErr *pq.Error
+		if errors.As(err, &pgErr) && pgErr.Constraint == "category_parent_id_fk" {
+			return ErrCategoryHasChildren
+		}
 		return err
 	}
 
@@ -307,26 +420,34 @@ func (m *CategoryModel) SetCategoryVisibility(ctx context.Context, categoryID st
 	return nil
 }
 
-func (m *CategoryModel) GetByID(ctx context.Context, categoryID string) (*Category, error) {
+func (m *CategoryModel) getCategory(ctx context.Context, where string, arg any) (*Category, error) {
 	query := `SELECT
  				c.id,
+            	c.parent_id,
             	c.name,
+            	c.slug,
             	c.description,
             	c.visible,
+            	c.depth,
+            	c.path,
             	c.created_at,
             	c.updated_at
              FROM category c
-             WHERE c.id = $1`
+             WHERE ` + where
 
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
 
 	category := &Category{}
-	err := m.db.QueryRowContext(ctx, query, categoryID).Scan(
+	err := m.db.QueryRowContext(ctx, query, arg).Scan(
 		&category.ID,
+		&category.ParentID,
 		&category.Name,
+		&category.Slug,
 		&category.Description,
 		&category.Visible,
+		&category.Depth,
+		&category.Path,
 		&category.CreatedAt,
 		&category.UpdatedAt,
 	)
@@ -344,3 +465,145 @@ func (m *CategoryModel) GetByID(ctx context.Context, categoryID string) (*Catego
 
 	return category, nil
 }
+
+func (m *CategoryModel) GetByID(ctx context.Context, categoryID string) (*Category, error) {
+	return m.getCategory(ctx, "c.id = $1", categoryID)
+}
+
+func (m *CategoryModel) GetBySlug(ctx context.Context, slug string) (*Category, error) {
+	return m.getCategory(ctx, "c.slug = $1", slug)
+}
+
+// Move re-parents a category, rewriting the materialized path of the category
+// and every descendant. A nil parentID turns the category into a root.
+func (m *CategoryModel) Move(ctx context.Context, categoryID string, parentID *string) (*Category, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	err := withTrx(m.db, ctx, func(tx *sql.Tx) error {
+		category, err := lockCategory(ctx, tx, categoryID)
+		if err != nil {
+			return err
+		}
+
+		var (
+			newPath  = "/" + category.ID + "/"
+			newDepth = 0
+		)
+
+		if parentID != nil {
+			parent, err := lockCategory(ctx, tx, *parentID)
+			if err != nil {
+				if errors.Is(err, ErrRecordNotFound) {
+					return ErrCategoryParentNotFound
+				}
+				return err
+			}
+
+			if strings.HasPrefix(parent.Path, category.Path) {
+				return ErrCategoryCycle
+			}
+
+			newPath = parent.Path + category.ID + "/"
+			newDepth = parent.Depth + 1
+		}
+
+		query := `UPDATE category
+				  SET path = $1 || substr(path, length($2) + 1),
+				      depth = depth + $3,
+				      parent_id = CASE WHEN id = $4 THEN $5 ELSE parent_id END
+				  WHERE path LIKE $2 || '%'`
+
+		_, err = tx.ExecContext(ctx, query, newPath, category.Path, newDepth-category.Depth,
+			category.ID, parentID)
+
+		return err
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return m.GetByID(ctx, categoryID)
+}
+
+func (m *CategoryModel) GetBreadcrumbs(ctx context.Context, category *Category) ([]*CategoryCrumb, error) {
+	query := `SELECT id, name, slug, depth FROM category WHERE id = ANY($1) ORDER BY depth`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	ids := append(category.AncestorIDs(), category.ID)
+
+	rows, err := m.db.QueryContext(ctx, query, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	crumbs := []*CategoryCrumb{}
+
+	for rows.Next() {
+		crumb := &CategoryCrumb{}
+		if err := rows.Scan(&crumb.ID, &crumb.Name, &crumb.Slug, &crumb.Depth); err != nil {
+			return nil, err
+		}
+		crumbs = append(crumbs, crumb)
+	}
+
+	return crumbs, rows.Err()
+}
+
+// GetVisibleTree returns visible categories nested under their parents. When
+// rootID is set only that category's subtree is returned. A hidden category
+// hides its whole subtree.
+func (m *CategoryModel) GetVisibleTree(ctx context.Context, rootID *string) ([]*Category, error) {
+	query := `SELECT c.id, c.parent_id, c.name, c.slug, c.description, c.visible, c.depth, c.path,
+			  c.created_at, c.updated_at
+			  FROM category c
+			  WHERE c.visible = true
+			  AND ($1::text IS NULL OR c.path LIKE (SELECT path FROM category WHERE id = $1) || '%')
+			  ORDER BY c.depth, c.name`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := m.db.QueryContext(ctx, query, rootID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var (
+		byID  = map[string]*Category{}
+		roots = []*Category{}
+	)
+
+	for rows.Next() {
+		category := &Category{}
+
+		err := rows.Scan(&category.ID, &category.ParentID, &category.Name, &category.Slug,
+			&category.Description, &category.Visible, &category.Depth, &category.Path,
+			&category.CreatedAt, &category.UpdatedAt)
+
+		if err != nil {
+			return nil, err
+		}
+
+		byID[category.ID] = category
+
+		isRoot := category.ParentID == nil || (rootID != nil && category.ID == *rootID)
+
+		if isRoot {
+			roots = append(roots, category)
+			continue
+		}
+
+		// rows are ordered by depth so a visible parent is always seen first.
+		if parent, ok := byID[*category.ParentID]; ok {
+			parent.Children = append(parent.Children, category)
+		}
+	}
+
+	return roots, rows.Err()
+}
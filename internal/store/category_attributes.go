@@ -0,0 +1,344 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/devphaseX/buyr-api.git/internal/db"
+	"github.com/lib/pq"
+)
+
+var (
+	ErrDuplicateCategoryAttribute = errors.New("category already has an attribute with this name")
+)
+
+type CategoryAttributeType string
+
+var (
+	TextAttributeType    CategoryAttributeType = "text"
+	NumberAttributeType  CategoryAttributeType = "number"
+	BooleanAttributeType CategoryAttributeType = "boolean"
+	EnumAttributeType    CategoryAttributeType = "enum"
+)
+
+type CategoryAttribute struct {
+	ID            string                `json:"id"`
+	CategoryID    string                `json:"category_id"`
+	Name          string                `json:"name"`
+	Type          CategoryAttributeType `json:"type"`
+	Unit          string                `json:"unit,omitempty"`
+	AllowedValues []string              `json:"allowed_values"`
+	Required      bool                  `json:"required"`
+	CreatedAt     time.Time             `json:"created_at"`
+	UpdatedAt     time.Time             `json:"updated_at"`
+}
+
+// ProductAttributeValue is the typed copy of a feature entry that matched a
+// category attribute. It backs attribute filtering on the product listing.
+type ProductAttributeValue struct {
+	AttributeID string   `json:"attribute_id"`
+	Name        string   `json:"name"`
+	ValueText   string   `json:"value"`
+	ValueNumber *float64 `json:"-"`
+}
+
+type CategoryAttributes []*CategoryAttribute
+
+// Validate checks the merged feature entries of a product against the schema.
+// It returns per-attribute errors and, when valid, the typed values to store.
+// Entries that do not belong to the schema are left alone.
+func (attrs CategoryAttributes) Validate(features []*ProductFeature) (map[string]string, []*ProductAttributeValue) {
+	entries := map[string]interface{}{}
+	for _, feature := range features {
+		for key, value := range feature.FeatureEntries {
+			entries[strings.ToLower(strings.TrimSpace(key))] = value
+		}
+	}
+
+	var (
+		errs   = map[string]string{}
+		values = []*ProductAttributeValue{}
+	)
+
+	for _, attr := range attrs {
+		raw, ok := entries[strings.ToLower(attr.Name)]
+
+		if !ok || raw == nil || raw == "" {
+			if attr.Required {
+				errs[attr.Name] = fmt.Sprintf("%s is a required attribute", attr.Name)
+			}
+			continue
+		}
+
+		value := &ProductAttributeValue{AttributeID: attr.ID, Name: attr.Name}
+
+		switch attr.Type {
+		case NumberAttributeType:
+			n, ok := raw.(float64)
+			if !ok {
+				errs[attr.Name] = fmt.Sprintf("%s must be a number", attr.Name)
+				continue
+			}
+			value.ValueNumber = &n
+			value.ValueText = strconv.FormatFloat(n, 'f', -1, 64)
+
+		case BooleanAttributeType:
+			b, ok := raw.(bool)
+			if !ok {
+				errs[attr.Name] = fmt.Sprintf("%s must be true or false", attr.Name)
+				continue
+			}
+			value.ValueText = strconv.FormatBool(b)
+
+		case EnumAttributeType:
+			s, ok := raw.(string)
+			if !ok || !slices.Contains(attr.AllowedValues, s) {
+				errs[attr.Name] = fmt.Sprintf("%s must be one of: %s", attr.Name, strings.Join(attr.AllowedValues, ", "))
+				continue
+			}
+			value.ValueText = s
+
+		default:
+			s, ok := raw.(string)
+			if !ok {
+				errs[attr.Name] = fmt.Sprintf("%s must be text", attr.Name)
+				continue
+			}
+			value.ValueText = s
+		}
+
+		values = append(values, value)
+	}
+
+	if len(errs) != 0 {
+		return errs, nil
+	}
+
+	return nil, values
+}
+
+// Lookup finds an attribute by case-insensitive name.
+func (attrs CategoryAttributes) Lookup(name string) *CategoryAttribute {
+	for _, attr := range attrs {
+		if strings.EqualFold(attr.Name, name) {
+			return attr
+		}
+	}
+	return nil
+}
+
+type CategoryAttributeStore interface {
+	Create(ctx context.Context, attribute *CategoryAttribute) error
+	Update(ctx context.Context, attribute *CategoryAttribute) error
+	Delete(ctx context.Context, categoryID, attributeID string) error
+	GetByID(ctx context.Context, categoryID, attributeID string) (*CategoryAttribute, error)
+	GetByCategoryID(ctx context.Context, categoryID string) (CategoryAttributes, error)
+	GetEffectiveSchema(ctx context.Context, categoryID string) (CategoryAttributes, error)
+}
+
+type CategoryAttributeModel struct {
+	db *sql.DB
+}
+
+func NewCategoryAttributeModel(db *sql.DB) CategoryAttributeStore {
+	return &CategoryAttributeModel{db}
+}
+
+func categoryAttributeError(err error) error {
+	var pgErr *pq.Error
+	if errors.As(err, &pgErr) {
+		switch pgErr.Constraint {
+		case "category_attributes_category_id_name_key":
+			return ErrDuplicateCategoryAttribute
+		case "category_attributes_category_id_fk":
+			return ErrRecordNotFound
+		}
+	}
+
+	return err
+}
+
+func (m *CategoryAttributeModel) Create(ctx context.Context, attribute *CategoryAttribute) error {
+	query := `INSERT INTO category_attributes(id, category_id, name, type, unit, allowed_values, required)
+			  VALUES ($1, $2, $3, $4, $5, $6, $7)
+			  RETURNING id, created_at, updated_at`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	id := db.GenerateULID()
+
+	args := []any{id, attribute.CategoryID, attribute.Name, attribute.Type,
+		sql.NullString{String: attribute.Unit, Valid: attribute.Unit != ""},
+		pq.Array(attribute.AllowedValues), attribute.Required}
+
+	err := m.db.QueryRowContext(ctx, query, args...).Scan(&attribute.ID, &attribute.CreatedAt, &attribute.UpdatedAt)
+
+	if err != nil {
+		return categoryAttributeError(err)
+	}
+
+	return nil
+}
+
+func (m *CategoryAttributeModel) Update(ctx context.Context, attribute *CategoryAttribute) error {
+	query := `UPDATE category_attributes
+			  SET name = $1, type = $2, unit = $3, allowed_values = $4, required = $5
+			  WHERE id = $6 AND category_id = $7
+			  RETURNING updated_at`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	args := []any{attribute.Name, attribute.Type,
+		sql.NullString{String: attribute.Unit, Valid: attribute.Unit != ""},
+		pq.Array(attribute.AllowedValues), attribute.Required, attribute.ID, attribute.CategoryID}
+
+	err := m.db.QueryRowContext(ctx, query, args...).Scan(&attribute.UpdatedAt)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrRecordNotFound
+		default:
+			return categoryAttributeError(err)
+		}
+	}
+
+	return nil
+}
+
+func (m *CategoryAttributeModel) Delete(ctx context.Context, categoryID, attributeID string) error {
+	query := `DELETE FROM category_attributes WHERE id = $1 AND category_id = $2`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	result, err := m.db.ExecContext(ctx, query, attributeID, categoryID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+func scanCategoryAttributes(rows *sql.Rows) (CategoryAttributes, error) {
+	attributes := CategoryAttributes{}
+
+	for rows.Next() {
+		var (
+			attribute = &CategoryAttribute{}
+			unit      sql.NullString
+		)
+
+		err := rows.Scan(&attribute.ID, &attribute.CategoryID, &attribute.Name, &attribute.Type,
+			&unit, pq.Array(&attribute.AllowedValues), &attribute.Required,
+			&attribute.CreatedAt, &attribute.UpdatedAt)
+
+		if err != nil {
+			return nil, err
+		}
+
+		attribute.Unit = unit.String
+		attributes = append(attributes, attribute)
+	}
+
+	return attributes, rows.Err()
+}
+
+func (m *CategoryAttributeModel) GetByID(ctx context.Context, categoryID, attributeID string) (*CategoryAttribute, error) {
+	query := `SELECT id, category_id, name, type, unit, allowed_values, required, created_at, updated_at
+			  FROM category_attributes WHERE id = $1 AND category_id = $2`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := m.db.QueryContext(ctx, query, attributeID, categoryID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	attributes, err := scanCategoryAttributes(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(attributes) == 0 {
+		return nil, ErrRecordNotFound
+	}
+
+	return attributes[0], nil
+}
+
+func (m *CategoryAttributeModel) GetByCategoryID(ctx context.Context, categoryID string) (CategoryAttributes, error) {
+	query := `SELECT id, category_id, name, type, unit, allowed_values, required, created_at, updated_at
+			  FROM category_attributes WHERE category_id = $1 ORDER BY name`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := m.db.QueryContext(ctx, query, categoryID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanCategoryAttributes(rows)
+}
+
+// GetEffectiveSchema returns the attributes that apply to a category: its own
+// plus those inherited from every ancestor. A closer category wins when two
+// levels declare the same name.
+func (m *CategoryAttributeModel) GetEffectiveSchema(ctx context.Context, categoryID string) (CategoryAttributes, error) {
+	query := `SELECT DISTINCT ON (lower(ca.name))
+			  ca.id, ca.category_id, ca.name, ca.type, ca.unit, ca.allowed_values, ca.required,
+			  ca.created_at, ca.updated_at
+			  FROM category_attributes ca
+			  JOIN category anc ON anc.id = ca.category_id
+			  JOIN category c ON c.id = $1
+			  WHERE c.path LIKE anc.path || '%'
+			  ORDER BY lower(ca.name), anc.depth DESC`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := m.db.QueryContext(ctx, query, categoryID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanCategoryAttributes(rows)
+}
+
+func createProductAttributeValues(ctx context.Context, tx *sql.Tx, productID string, values []*ProductAttributeValue) error {
+	query := `INSERT INTO product_attribute_values(product_id, attribute_id, value_text, value_number)
+			  VALUES ($1, $2, $3, $4)`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	for _, value := range values {
+		if _, err := tx.ExecContext(ctx, query, productID, value.AttributeID, value.ValueText, value.ValueNumber); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
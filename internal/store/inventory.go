@@ -0,0 +1,717 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/devphaseX/buyr-api.git/internal/db"
+	"github.com/devphaseX/buyr-api.git/internal/store/modelfilter"
+	"github.com/lib/pq"
+)
+
+var (
+	ErrInsufficientStock = errors.New("insufficient stock")
+)
+
+type InventoryMovementKind string
+
+var (
+	RestockInventoryMovement     InventoryMovementKind = "restock"
+	SaleInventoryMovement        InventoryMovementKind = "sale"
+	ReturnInventoryMovement      InventoryMovementKind = "return"
+	AdjustmentInventoryMovement  InventoryMovementKind = "adjustment"
+	ReservationInventoryMovement InventoryMovementKind = "reservation"
+)
+
+// InventoryMovement is one entry of a product's stock ledger. Quantity is the
+// signed change applied to stock_quantity and StockAfter the resulting level.
+// A checkout reserves stock with a negative reservation; once paid the
+// reservation is released and replaced by a sale, otherwise it is released.
+type InventoryMovement struct {
+	ID        string `json:"id"`
+	ProductID string `json:"product_id"`
+	// WarehouseID is the location whose stock moved; empty for the vendor's
+	// unassigned stock.
+	WarehouseID string                `json:"warehouse_id,omitempty"`
+	Kind        InventoryMovementKind `json:"kind"`
+	Quantity    int                   `json:"quantity"`
+	StockAfter  int                   `json:"stock_after"`
+	ActorID     string                `json:"actor_id,omitempty"`
+	OrderID     string                `json:"order_id,omitempty"`
+	Reason      string                `json:"reason,omitempty"`
+	CreatedAt   time.Time             `json:"created_at"`
+}
+
+type LowStockProduct struct {
+	ID            string `json:"id"`
+	Name          string `json:"name"`
+	StockQuantity int    `json:"stock_quantity"`
+	Threshold     int    `json:"threshold"`
+}
+
+type LowStockAlert struct {
+	VendorID     string
+	BusinessName string
+	Email        string
+	Products     []*LowStockProduct
+}
+
+type InventoryStore interface {
+	Adjust(ctx context.Context, vendorID string, movement *InventoryMovement) error
+	GetMovements(ctx context.Context, productID, vendorID string, filter PaginateQueryFilter) ([]*InventoryMovement, Metadata, error)
+	SetLowStockThreshold(ctx context.Context, productID, vendorID string, threshold *int) error
+	GetLowStockVendorIDs(ctx context.Context, defaultThreshold int) ([]string, error)
+	GetLowStockAlert(ctx context.Context, vendorID string, defaultThreshold int) (*LowStockAlert, error)
+	MarkLowStockAlerted(ctx context.Context, productIDs []string) error
+}
+
+type InventoryModel struct {
+	db *sql.DB
+}
+
+func NewInventoryModel(db *sql.DB) InventoryStore {
+	return &InventoryModel{db}
+}
+
+// recordInventoryMovement applies movement.Quantity to the product's stock and
+// appends the movement to the ledger. A movement on a warehouse also moves
+// that location's stock; one without takes from the unassigned stock, the part
+// of the total not held by any location. Neither may go negative.
+func recordInventoryMovement(ctx context.Context, tx *sql.Tx, movement *InventoryMovement) error {
+	if movement.WarehouseID != "" {
+		if err := moveWarehouseStock(ctx, tx, movement); err != nil {
+			return err
+		}
+	}
+
+	query := `
+		UPDATE products SET stock_quantity = stock_quantity + $1
+		WHERE id = $2 AND stock_quantity + $1 >= 0`
+
+	if movement.WarehouseID == "" && movement.Quantity < 0 {
+		query += ` AND stock_quantity + $1 >= (
+			SELECT COALESCE(SUM(quantity), 0) FROM warehouse_stock WHERE product_id = $2)`
+	}
+
+	err := tx.QueryRowContext(ctx, query+` RETURNING stock_quantity`,
+		movement.Quantity, movement.ProductID).Scan(&movement.StockAfter)
+
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+
+		var exists bool
+		if err := tx.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM products WHERE id = $1)`,
+			movement.ProductID).Scan(&exists); err != nil {
+			return err
+		}
+
+		if !exists {
+			return ErrRecordNotFound
+		}
+
+		return ErrInsufficientStock
+	}
+
+	return insertInventoryMovement(ctx, tx, movement)
+}
+
+func moveWarehouseStock(ctx context.Context, tx *sql.Tx, movement *InventoryMovement) error {
+	if movement.Quantity > 0 {
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO warehouse_stock(warehouse_id, product_id, quantity)
+			VALUES ($1, $2, $3)
+			ON CONFLICT (warehouse_id, product_id)
+			DO UPDATE SET quantity = warehouse_stock.quantity + EXCLUDED.quantity, updated_at = NOW()`,
+			movement.WarehouseID, movement.ProductID, movement.Quantity)
+
+		return err
+	}
+
+	result, err := tx.ExecContext(ctx, `
+		UPDATE warehouse_stock SET quantity = quantity + $1, updated_at = NOW()
+		WHERE warehouse_id = $2 AND product_id = $3 AND quantity + $1 >= 0`,
+		movement.Quantity, movement.WarehouseID, movement.ProductID)
+
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrInsufficientStock
+	}
+
+	return nil
+}
+
+// insertInventoryMovement appends a movement whose effect on stock has
+// already been applied.
+func insertInventoryMovement(ctx context.Context, tx *sql.Tx, movement *InventoryMovement) error {
+	movement.ID = db.GenerateULID()
+
+	query := `INSERT INTO inventory_movements(id, product_id, warehouse_id, kind, quantity, stock_after, actor_id, order_id, reason)
+			  VALUES ($1, $2, NULLIF($3, ''), $4, $5, $6, NULLIF($7, ''), NULLIF($8, ''), NULLIF($9, ''))
+			  RETURNING created_at`
+
+	return tx.QueryRowContext(ctx, query, movement.ID, movement.ProductID, movement.WarehouseID, movement.Kind,
+		movement.Quantity, movement.StockAfter, movement.ActorID, movement.OrderID, movement.Reason).
+		Scan(&movement.CreatedAt)
+}
+
+// openInventoryLedger records the stock a product was created with as its
+// first restock.
+func openInventoryLedger(ctx context.Context, tx *sql.Tx, product *Product) error {
+	if product.StockQuantity == 0 {
+		return nil
+	}
+
+	var actorID string
+	err := tx.QueryRowContext(ctx, `SELECT user_id FROM vendor_users WHERE id = $1`, product.VendorID).Scan(&actorID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+
+	return insertInventoryMovement(ctx, tx, &InventoryMovement{
+		ProductID:  product.ID,
+		Kind:       RestockInventoryMovement,
+		Quantity:   product.StockQuantity,
+		StockAfter: product.StockQuantity,
+		ActorID:    actorID,
+		Reason:     "initial stock",
+	})
+}
+
+// reserveOrderStock holds stock for every item of a new order. Each item is
+// fulfilled from the locations nearest to the shipping address, split across
+// several when no single one has enough, with the vendor's unassigned stock
+// covering the rest; see allocateOrderItem. Items of products that accept
+// backorders are taken without stock when there is not enough, and the order
+// is flagged as backordered.
+func reserveOrderStock(ctx context.Context, tx *sql.Tx, order *Order, cartItems []*CartItem) error {
+	destination, err := orderDestination(ctx, tx, order.ShippingAddressId)
+	if err != nil {
+		return err
+	}
+
+	for _, item := range cartItems {
+		var (
+			allowBackorder bool
+			shipsAt        *time.Time
+			releaseAt      *time.Time
+		)
+
+		err := tx.QueryRowContext(ctx, `SELECT allow_backorder, backorder_ships_at, preorder_release_at FROM products WHERE id = $1`,
+			item.ProductID).Scan(&allowBackorder, &shipsAt, &releaseAt)
+
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return ErrRecordNotFound
+			}
+			return err
+		}
+
+		preorder := releaseAt != nil && releaseAt.After(time.Now())
+
+		err = allocateOrderItem(ctx, tx, order.ID, order.UserID, item.ProductID, item.Quantity, destination, "checkout")
+
+		switch {
+		case err == nil:
+			if !preorder {
+				continue
+			}
+
+			_, err = tx.ExecContext(ctx, `UPDATE order_items SET preorder = true, expected_ship_at = $1
+				WHERE order_id = $2 AND product_id = $3`, releaseAt, order.ID, item.ProductID)
+
+		case errors.Is(err, ErrInsufficientStock) && (allowBackorder || preorder):
+			expectedShipAt := shipsAt
+			if preorder {
+				expectedShipAt = releaseAt
+			}
+
+			order.Backordered = true
+
+			_, err = tx.ExecContext(ctx, `UPDATE order_items SET backordered = true, preorder = $1, expected_ship_at = $2
+				WHERE order_id = $3 AND product_id = $4`, preorder, expectedShipAt, order.ID, item.ProductID)
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	if !order.Backordered {
+		return nil
+	}
+
+	_, err = tx.ExecContext(ctx, `UPDATE orders SET backordered = true WHERE id = $1`, order.ID)
+
+	return err
+}
+
+// orderDestination loads the parts of a shipping address used to pick the
+// nearest location. An order without an address matches no location better
+// than another.
+func orderDestination(ctx context.Context, tx *sql.Tx, addressID string) (*Address, error) {
+	destination := &Address{}
+
+	if addressID == "" {
+		return destination, nil
+	}
+
+	err := tx.QueryRowContext(ctx, `
+		SELECT COALESCE(city, ''), COALESCE(state, ''), COALESCE(postal_code, ''), COALESCE(country, '')
+		FROM addresses WHERE id = $1`, addressID).
+		Scan(&destination.City, &destination.State, &destination.PostalCode, &destination.Country)
+
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+
+	return destination, nil
+}
+
+// allocateOrderItem reserves quantity of a product for an order. A single
+// location holding the whole quantity is preferred, the nearest one to
+// destination first; otherwise the quantity is split across locations,
+// nearest first, and whatever they cannot cover comes from the vendor's
+// unassigned stock. Every location used is recorded as an allocation of the
+// order item, and the first one on the item itself. It returns
+// ErrInsufficientStock without side effects when the stock is not there.
+func allocateOrderItem(ctx context.Context, tx *sql.Tx, orderID, actorID, productID string, quantity int, destination *Address, reason string) error {
+	locations, err := warehousesWithStock(ctx, tx, productID, destination)
+	if err != nil {
+		return err
+	}
+
+	var allocations []*OrderItemAllocation
+
+	for _, location := range locations {
+		if location.Quantity >= quantity {
+			allocations = []*OrderItemAllocation{{WarehouseID: location.WarehouseID, Quantity: quantity}}
+			break
+		}
+	}
+
+	if allocations == nil {
+		remaining := quantity
+
+		for _, location := range locations {
+			if remaining == 0 {
+				break
+			}
+
+			take := min(location.Quantity, remaining)
+			allocations = append(allocations, &OrderItemAllocation{WarehouseID: location.WarehouseID, Quantity: take})
+			remaining -= take
+		}
+
+		if remaining > 0 {
+			var unassigned int
+
+			err := tx.QueryRowContext(ctx, `
+				SELECT p.stock_quantity - COALESCE((SELECT SUM(quantity) FROM warehouse_stock WHERE product_id = p.id), 0)
+				FROM products p WHERE p.id = $1
+				FOR UPDATE`, productID).Scan(&unassigned)
+
+			switch {
+			case errors.Is(err, sql.ErrNoRows):
+				return ErrRecordNotFound
+			case err != nil:
+				return err
+			case unassigned < remaining:
+				return ErrInsufficientStock
+			}
+
+			allocations = append(allocations, &OrderItemAllocation{Quantity: remaining})
+		}
+	}
+
+	for _, allocation := range allocations {
+		err := recordInventoryMovement(ctx, tx, &InventoryMovement{
+			ProductID:   productID,
+			WarehouseID: allocation.WarehouseID,
+			Kind:        ReservationInventoryMovement,
+			Quantity:    -allocation.Quantity,
+			ActorID:     actorID,
+			OrderID:     orderID,
+			Reason:      reason,
+		})
+
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO order_item_allocations(order_item_id, warehouse_id, quantity)
+			SELECT id, NULLIF($3, ''), $4 FROM order_items WHERE order_id = $1 AND product_id = $2`,
+			orderID, productID, allocation.WarehouseID, allocation.Quantity)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(allocations) == 0 || allocations[0].WarehouseID == "" {
+		return nil
+	}
+
+	_, err = tx.ExecContext(ctx, `UPDATE order_items SET warehouse_id = $1 WHERE order_id = $2 AND product_id = $3`,
+		allocations[0].WarehouseID, orderID, productID)
+
+	return err
+}
+
+// warehousesWithStock ranks the active locations holding a product by how
+// closely they match the destination: same country, then state, then city
+// and postal code. Locations that match equally are ordered by the stock
+// they hold, so fewer of them are needed to fill an order.
+func warehousesWithStock(ctx context.Context, tx *sql.Tx, productID string, destination *Address) ([]*OrderItemAllocation, error) {
+	query := `
+		SELECT ws.warehouse_id, ws.quantity
+		FROM warehouse_stock ws
+		JOIN warehouses w ON w.id = ws.warehouse_id
+		WHERE ws.product_id = $1 AND ws.quantity > 0 AND w.is_active
+		ORDER BY
+			(lower(w.country) = lower($2))::int
+			+ (lower(w.country) = lower($2) AND lower(w.state) = lower($3))::int
+			+ (lower(w.country) = lower($2) AND lower(w.state) = lower($3) AND lower(w.city) = lower($4))::int
+			+ (lower(w.country) = lower($2) AND lower(w.postal_code) = lower($5))::int DESC,
+			ws.quantity DESC
+		FOR UPDATE OF ws
+	`
+
+	rows, err := tx.QueryContext(ctx, query, productID, destination.Country, destination.State,
+		destination.City, destination.PostalCode)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var locations []*OrderItemAllocation
+
+	for rows.Next() {
+		location := &OrderItemAllocation{}
+
+		if err := rows.Scan(&location.WarehouseID, &location.Quantity); err != nil {
+			return nil, err
+		}
+
+		locations = append(locations, location)
+	}
+
+	return locations, rows.Err()
+}
+
+// releaseOrderReservations gives back whatever stock an order still holds.
+// When sold is true the released stock is immediately taken again as a sale.
+// Releasing twice is a no-op, as only outstanding reservations are counted.
+// It returns the products that were out of stock until the release.
+func releaseOrderReservations(ctx context.Context, tx *sql.Tx, orderID, reason string, sold bool) ([]string, error) {
+	rows, err := tx.QueryContext(ctx, `
+		SELECT product_id, COALESCE(warehouse_id, ''), -SUM(quantity)
+		FROM inventory_movements
+		WHERE order_id = $1 AND kind = $2
+		GROUP BY product_id, warehouse_id
+		HAVING SUM(quantity) < 0
+		ORDER BY product_id`, orderID, ReservationInventoryMovement)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var held []*InventoryMovement
+
+	for rows.Next() {
+		reservation := &InventoryMovement{}
+
+		if err := rows.Scan(&reservation.ProductID, &reservation.WarehouseID, &reservation.Quantity); err != nil {
+			rows.Close()
+			return nil, err
+		}
+
+		held = append(held, reservation)
+	}
+
+	rows.Close()
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var restocked []string
+
+	for _, reservation := range held {
+		release := &InventoryMovement{
+			ProductID:   reservation.ProductID,
+			WarehouseID: reservation.WarehouseID,
+			Kind:        ReservationInventoryMovement,
+			Quantity:    reservation.Quantity,
+			OrderID:     orderID,
+			Reason:      reason,
+		}
+
+		if err := recordInventoryMovement(ctx, tx, release); err != nil {
+			return nil, err
+		}
+
+		if !sold {
+			if release.StockAfter-release.Quantity <= 0 && !slices.Contains(restocked, release.ProductID) {
+				restocked = append(restocked, release.ProductID)
+			}
+			continue
+		}
+
+		err := recordInventoryMovement(ctx, tx, &InventoryMovement{
+			ProductID:   reservation.ProductID,
+			WarehouseID: reservation.WarehouseID,
+			Kind:        SaleInventoryMovement,
+			Quantity:    -reservation.Quantity,
+			OrderID:     orderID,
+		})
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return restocked, nil
+}
+
+// Adjust records a manual restock, return or adjustment made by a vendor on
+// one of their products.
+func (m *InventoryModel) Adjust(ctx context.Context, vendorID string, movement *InventoryMovement) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	return withTrx(m.db, ctx, func(tx *sql.Tx) error {
+		var owned bool
+
+		err := tx.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM products WHERE id = $1 AND vendor_id = $2)`,
+			movement.ProductID, vendorID).Scan(&owned)
+
+		if err != nil {
+			return err
+		}
+
+		if !owned {
+			return ErrRecordNotFound
+		}
+
+		if movement.WarehouseID != "" {
+			err := tx.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM warehouses WHERE id = $1 AND vendor_id = $2)`,
+				movement.WarehouseID, vendorID).Scan(&owned)
+
+			if err != nil {
+				return err
+			}
+
+			if !owned {
+				return ErrWarehouseNotFound
+			}
+		}
+
+		if err := recordInventoryMovement(ctx, tx, movement); err != nil {
+			return err
+		}
+
+		if movement.Quantity <= 0 {
+			return nil
+		}
+
+		return fillProductBackorders(ctx, tx, movement.ProductID)
+	})
+}
+
+func (m *InventoryModel) GetMovements(ctx context.Context, productID, vendorID string, filter PaginateQueryFilter) ([]*InventoryMovement, Metadata, error) {
+	query := `
+		SELECT count(im.id) OVER(), im.id, im.product_id, COALESCE(im.warehouse_id, ''), im.kind, im.quantity, im.stock_after,
+			COALESCE(im.actor_id, ''), COALESCE(im.order_id, ''), COALESCE(im.reason, ''), im.created_at
+		FROM inventory_movements im
+		JOIN products p ON p.id = im.product_id
+		WHERE im.product_id = $3 AND p.vendor_id = $4
+	`
+
+	args := []any{filter.Limit(), filter.Offset(), productID, vendorID}
+
+	if dataFilter, ok := filter.Filters.(*modelfilter.GetInventoryMovementsFilter); ok && dataFilter.Kind != "" {
+		args = append(args, dataFilter.Kind)
+		query += fmt.Sprintf(" AND im.kind = $%d", len(args))
+	}
+
+	query += fmt.Sprintf(" ORDER BY im.%s %s, im.id %s LIMIT $1 OFFSET $2",
+		filter.SortColumn(), filter.SortDirection(), filter.SortDirection())
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := m.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, Metadata{}, fmt.Errorf("failed to query inventory movements: %w", err)
+	}
+	defer rows.Close()
+
+	var (
+		movements    = []*InventoryMovement{}
+		totalRecords int
+	)
+
+	for rows.Next() {
+		movement := &InventoryMovement{}
+
+		err := rows.Scan(&totalRecords, &movement.ID, &movement.ProductID, &movement.WarehouseID, &movement.Kind, &movement.Quantity,
+			&movement.StockAfter, &movement.ActorID, &movement.OrderID, &movement.Reason, &movement.CreatedAt)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		movements = append(movements, movement)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := calculateMetadata(totalRecords, filter.Page, filter.PageSize)
+
+	return movements, metadata, nil
+}
+
+// SetLowStockThreshold overrides the default low-stock threshold of a
+// product. A nil threshold falls back to the default.
+func (m *InventoryModel) SetLowStockThreshold(ctx context.Context, productID, vendorID string, threshold *int) error {
+	query := `UPDATE products SET low_stock_threshold = $1, low_stock_alerted_at = NULL
+			  WHERE id = $2 AND vendor_id = $3`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	result, err := m.db.ExecContext(ctx, query, threshold, productID, vendorID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// GetLowStockVendorIDs returns vendors with at least one product at or below
+// its threshold that has not been alerted yet. Products that have recovered
+// above their threshold are re-armed first so the next drop alerts again.
+func (m *InventoryModel) GetLowStockVendorIDs(ctx context.Context, defaultThreshold int) ([]string, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := m.db.ExecContext(ctx, `
+		UPDATE products SET low_stock_alerted_at = NULL
+		WHERE low_stock_alerted_at IS NOT NULL AND stock_quantity > COALESCE(low_stock_threshold, $1)`,
+		defaultThreshold)
+
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := m.db.QueryContext(ctx, `
+		SELECT DISTINCT vendor_id FROM products
+		WHERE low_stock_alerted_at IS NULL AND stock_quantity <= COALESCE(low_stock_threshold, $1)`,
+		defaultThreshold)
+
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var vendorIDs []string
+
+	for rows.Next() {
+		var vendorID string
+
+		if err := rows.Scan(&vendorID); err != nil {
+			return nil, err
+		}
+
+		vendorIDs = append(vendorIDs, vendorID)
+	}
+
+	return vendorIDs, rows.Err()
+}
+
+func (m *InventoryModel) GetLowStockAlert(ctx context.Context, vendorID string, defaultThreshold int) (*LowStockAlert, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	alert := &LowStockAlert{VendorID: vendorID}
+
+	err := m.db.QueryRowContext(ctx, `
+		SELECT v.business_name, u.email
+		FROM vendor_users v
+		JOIN users u ON u.id = v.user_id
+		WHERE v.id = $1`, vendorID).Scan(&alert.BusinessName, &alert.Email)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	rows, err := m.db.QueryContext(ctx, `
+		SELECT id, name, stock_quantity, COALESCE(low_stock_threshold, $2)
+		FROM products
+		WHERE vendor_id = $1 AND low_stock_alerted_at IS NULL
+			AND stock_quantity <= COALESCE(low_stock_threshold, $2)
+		ORDER BY stock_quantity, name`, vendorID, defaultThreshold)
+
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		product := &LowStockProduct{}
+
+		if err := rows.Scan(&product.ID, &product.Name, &product.StockQuantity, &product.Threshold); err != nil {
+			return nil, err
+		}
+
+		alert.Products = append(alert.Products, product)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return alert, nil
+}
+
+func (m *InventoryModel) MarkLowStockAlerted(ctx context.Context, productIDs []string) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := m.db.ExecContext(ctx, `UPDATE products SET low_stock_alerted_at = NOW() WHERE id = ANY($1::text[])`,
+		pq.Array(productIDs))
+
+	return err
+}
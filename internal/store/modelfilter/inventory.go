@@ -0,0 +1,24 @@
+package modelfilter
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+)
+
+var inventoryMovementKinds = []string{"restock", "sale", "return", "adjustment", "reservation"}
+
+type GetInventoryMovementsFilter struct {
+	Kind string
+}
+
+func (f *GetInventoryMovementsFilter) ParseFilters(r *http.Request) error {
+	if value := r.URL.Query().Get("kind"); value != "" {
+		if !slices.Contains(inventoryMovementKinds, value) {
+			return errors.New("kind must be one of restock, sale, return, adjustment or reservation")
+		}
+		f.Kind = value
+	}
+
+	return nil
+}
@@ -1,11 +1,29 @@
 package modelfilter
 
-import "net/http"
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// AttributeFilter narrows products by a typed category attribute. Values are
+// matched exactly; Min and Max apply to number attributes.
+type AttributeFilter struct {
+	Name   string
+	Values []string
+	Min    *float64
+	Max    *float64
+}
 
 type GetProductsFilter struct {
 	VendorID   *string
 	AdminView  bool
 	ProductIds []string
+	// Category is an id or slug; products in any descendant category match too.
+	Category *string
+	// Attributes is parsed from attr.<name>=a,b, attr.<name>.min and attr.<name>.max
+	Attributes []*AttributeFilter
 }
 
 func (f *GetProductsFilter) ParseFilters(r *http.Request) error {
@@ -17,5 +35,59 @@ func (f *GetProductsFilter) ParseFilters(r *http.Request) error {
 		f.VendorID = &vendorID
 	}
 
+	if category := query.Get("category"); f.Category == nil && len(category) > 0 {
+		f.Category = &category
+	}
+
+	attributes := map[string]*AttributeFilter{}
+
+	for key, values := range query {
+		name, ok := strings.CutPrefix(key, "attr.")
+		if !ok || len(values) == 0 || values[0] == "" {
+			continue
+		}
+
+		bound := ""
+		if n, ok := strings.CutSuffix(name, ".min"); ok {
+			name, bound = n, "min"
+		} else if n, ok := strings.CutSuffix(name, ".max"); ok {
+			name, bound = n, "max"
+		}
+
+		if name == "" {
+			continue
+		}
+
+		attr, ok := attributes[name]
+		if !ok {
+			attr = &AttributeFilter{Name: name}
+			attributes[name] = attr
+		}
+
+		if bound == "" {
+			for _, value := range strings.Split(values[0], ",") {
+				if value = strings.TrimSpace(value); value != "" {
+					attr.Values = append(attr.Values, value)
+				}
+			}
+			continue
+		}
+
+		n, err := strconv.ParseFloat(values[0], 64)
+		if err != nil {
+			return fmt.Errorf("%s must be a number", key)
+		}
+
+		if bound == "min" {
+			attr.Min = &n
+		} else {
+			attr.Max = &n
+		}
+	}
+
+	for _, attr := range attributes {
+		f.Attributes = append(f.Attributes, attr)
+	}
+
 	return nil
 }
@@ -0,0 +1,35 @@
+package modelfilter
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+)
+
+type GetReviewsFilter struct {
+	// Rating limits results to a single star bucket, 1 to 5.
+	Rating    *int
+	WithMedia bool
+}
+
+func (f *GetReviewsFilter) ParseFilters(r *http.Request) error {
+	query := r.URL.Query()
+
+	if value := query.Get("rating"); value != "" {
+		rating, err := strconv.Atoi(value)
+		if err != nil || rating < 1 || rating > 5 {
+			return errors.New("rating must be a number between 1 and 5")
+		}
+		f.Rating = &rating
+	}
+
+	if value := query.Get("with_media"); value != "" {
+		withMedia, err := strconv.ParseBool(value)
+		if err != nil {
+			return errors.New("with_media must be a boolean")
+		}
+		f.WithMedia = withMedia
+	}
+
+	return nil
+}
@@ -1,6 +1,13 @@
 package store
 
-import "time"
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/devphaseX/buyr-api.git/internal/db"
+)
 
 type Notification struct {
 	ID        string    `json:"id"`
@@ -10,3 +17,99 @@ type Notification struct {
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 }
+
+type NotificationStore interface {
+	Create(ctx context.Context, notification *Notification) error
+	GetByUserID(ctx context.Context, userID string, filter PaginateQueryFilter) ([]*Notification, Metadata, error)
+	MarkAsRead(ctx context.Context, userID, notificationID string) error
+}
+
+type NotificationModel struct {
+	db *sql.DB
+}
+
+func NewNotificationModel(db *sql.DB) NotificationStore {
+	return &NotificationModel{db}
+}
+
+func (m *NotificationModel) Create(ctx context.Context, notification *Notification) error {
+	query := `INSERT INTO notifications(id, user_id, message, is_read)
+			  VALUES ($1, $2, $3, false)
+			  RETURNING id, created_at, updated_at`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	id := db.GenerateULID()
+
+	return m.db.QueryRowContext(ctx, query, id, notification.UserID, notification.Message).
+		Scan(&notification.ID, &notification.CreatedAt, &notification.UpdatedAt)
+}
+
+func (m *NotificationModel) GetByUserID(ctx context.Context, userID string, filter PaginateQueryFilter) ([]*Notification, Metadata, error) {
+	query := fmt.Sprintf(`
+		SELECT count(id) OVER(), id, user_id, message, COALESCE(is_read, false), created_at, updated_at
+		FROM notifications
+		WHERE user_id = $1
+		ORDER BY %s %s
+		LIMIT $2 OFFSET $3
+	`, filter.SortColumn(), filter.SortDirection())
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := m.db.QueryContext(ctx, query, userID, filter.Limit(), filter.Offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	var (
+		notifications = []*Notification{}
+		totalRecords  int
+	)
+
+	for rows.Next() {
+		notification := &Notification{}
+
+		err := rows.Scan(&totalRecords, &notification.ID, &notification.UserID, &notification.Message,
+			&notification.IsRead, &notification.CreatedAt, &notification.UpdatedAt)
+
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		notifications = append(notifications, notification)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := calculateMetadata(totalRecords, filter.Page, filter.PageSize)
+
+	return notifications, metadata, nil
+}
+
+func (m *NotificationModel) MarkAsRead(ctx context.Context, userID, notificationID string) error {
+	query := `UPDATE notifications SET is_read = true WHERE id = $1 AND user_id = $2`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	result, err := m.db.ExecContext(ctx, query, notificationID, userID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
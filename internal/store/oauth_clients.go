@@ -0,0 +1,217 @@
+package store
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/base32"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/devphaseX/buyr-api.git/internal/db"
+	"github.com/lib/pq"
+)
+
+// OAuthClient is a third-party app registered to act on behalf of the users
+// who authorize it. Public clients, such as mobile apps, have no secret and
+// rely on PKCE alone.
+type OAuthClient struct {
+	ID           string        `json:"id"`
+	UserID       string        `json:"user_id"`
+	Name         string        `json:"name"`
+	WebsiteURL   *string       `json:"website_url"`
+	SecretHash   []byte        `json:"-"`
+	RedirectURIs []string      `json:"redirect_uris"`
+	Scopes       []APIKeyScope `json:"scopes"`
+	CreatedAt    time.Time     `json:"created_at"`
+}
+
+func (c *OAuthClient) IsConfidential() bool {
+	return len(c.SecretHash) > 0
+}
+
+// VerifySecret reports whether secret is the client's secret
+func (c *OAuthClient) VerifySecret(secret string) bool {
+	if !c.IsConfidential() {
+		return false
+	}
+
+	hash := sha256.Sum256([]byte(secret))
+	return subtle.ConstantTimeCompare(hash[:], c.SecretHash) == 1
+}
+
+func (c *OAuthClient) HasScopes(scopes ...APIKeyScope) bool {
+	return hasScopes(c.Scopes, scopes...)
+}
+
+// GenerateClientSecret returns a new random client secret and the hash to
+// store for it
+func GenerateClientSecret() (plaintext string, hash []byte, err error) {
+	randomBytes := make([]byte, 32)
+
+	if _, err := rand.Read(randomBytes); err != nil {
+		return "", nil, err
+	}
+
+	plaintext = strings.ToLower(base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(randomBytes))
+	secretHash := sha256.Sum256([]byte(plaintext))
+
+	return plaintext, secretHash[:], nil
+}
+
+type OAuthClientStore interface {
+	Create(ctx context.Context, client *OAuthClient) error
+	GetByID(ctx context.Context, id string) (*OAuthClient, error)
+	GetByUserID(ctx context.Context, userID string) ([]*OAuthClient, error)
+	UpdateSecret(ctx context.Context, id, userID string, secretHash []byte) error
+	Delete(ctx context.Context, id, userID string) error
+}
+
+type OAuthClientModel struct {
+	db *sql.DB
+}
+
+func NewOAuthClientModel(db *sql.DB) OAuthClientStore {
+	return &OAuthClientModel{db}
+}
+
+func (m *OAuthClientModel) Create(ctx context.Context, client *OAuthClient) error {
+	query := `INSERT INTO oauth_clients(id, user_id, name, website_url, secret_hash, redirect_uris, scopes)
+			  VALUES ($1, $2, $3, $4, $5, $6, $7)
+			  RETURNING created_at`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	client.ID = db.GenerateULID()
+
+	args := []any{client.ID, client.UserID, client.Name, client.WebsiteURL, client.SecretHash,
+		pq.Array(client.RedirectURIs), pq.Array(scopesToStrings(client.Scopes))}
+
+	return m.db.QueryRowContext(ctx, query, args...).Scan(&client.CreatedAt)
+}
+
+func (m *OAuthClientModel) GetByID(ctx context.Context, id string) (*OAuthClient, error) {
+	query := `SELECT id, user_id, name, website_url, secret_hash, redirect_uris, scopes, created_at
+			  FROM oauth_clients
+			  WHERE id = $1`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	client, err := scanOAuthClient(m.db.QueryRowContext(ctx, query, id))
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return client, nil
+}
+
+func (m *OAuthClientModel) GetByUserID(ctx context.Context, userID string) ([]*OAuthClient, error) {
+	query := `SELECT id, user_id, name, website_url, secret_hash, redirect_uris, scopes, created_at
+			  FROM oauth_clients
+			  WHERE user_id = $1
+			  ORDER BY created_at DESC`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := m.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	clients := []*OAuthClient{}
+
+	for rows.Next() {
+		client, err := scanOAuthClient(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		clients = append(clients, client)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return clients, nil
+}
+
+// UpdateSecret replaces the secret of a confidential client. Public clients
+// and clients registered by someone else are reported as not found.
+func (m *OAuthClientModel) UpdateSecret(ctx context.Context, id, userID string, secretHash []byte) error {
+	query := `UPDATE oauth_clients SET secret_hash = $3
+			  WHERE id = $1 AND user_id = $2 AND secret_hash IS NOT NULL`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	result, err := m.db.ExecContext(ctx, query, id, userID, secretHash)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+func (m *OAuthClientModel) Delete(ctx context.Context, id, userID string) error {
+	query := `DELETE FROM oauth_clients WHERE id = $1 AND user_id = $2`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	result, err := m.db.ExecContext(ctx, query, id, userID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+func scanOAuthClient(row rowScanner) (*OAuthClient, error) {
+	var (
+		client OAuthClient
+		scopes []string
+	)
+
+	err := row.Scan(&client.ID, &client.UserID, &client.Name, &client.WebsiteURL, &client.SecretHash,
+		pq.Array(&client.RedirectURIs), pq.Array(&scopes), &client.CreatedAt)
+
+	if err != nil {
+		return nil, err
+	}
+
+	client.Scopes = stringsToScopes(scopes)
+
+	return &client, nil
+}
@@ -0,0 +1,203 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/devphaseX/buyr-api.git/internal/db"
+	"github.com/lib/pq"
+)
+
+// OAuthGrant is a user's authorization of a third-party app. Every access and
+// refresh token issued to the app carries the grant, so deleting it revokes
+// them all. The version works like a session's: each refresh moves it
+// forward and an older refresh token is treated as stolen.
+type OAuthGrant struct {
+	ID         string        `json:"id"`
+	ClientID   string        `json:"client_id"`
+	UserID     string        `json:"user_id"`
+	Scopes     []APIKeyScope `json:"scopes"`
+	Version    int           `json:"-"`
+	ExpiresAt  time.Time     `json:"expires_at"`
+	CreatedAt  time.Time     `json:"created_at"`
+	ClientName string        `json:"client_name,omitempty"`
+}
+
+func (g *OAuthGrant) HasScopes(scopes ...APIKeyScope) bool {
+	return hasScopes(g.Scopes, scopes...)
+}
+
+func (g *OAuthGrant) IsExpired() bool {
+	return time.Now().After(g.ExpiresAt)
+}
+
+type OAuthGrantStore interface {
+	Create(ctx context.Context, grant *OAuthGrant) error
+	GetByID(ctx context.Context, id string) (*OAuthGrant, error)
+	GetByUserID(ctx context.Context, userID string) ([]*OAuthGrant, error)
+	Rotate(ctx context.Context, grant *OAuthGrant, expiresAt time.Time) error
+	Delete(ctx context.Context, id string) error
+	DeleteForClient(ctx context.Context, userID, clientID string) error
+}
+
+type OAuthGrantModel struct {
+	db *sql.DB
+}
+
+func NewOAuthGrantModel(db *sql.DB) OAuthGrantStore {
+	return &OAuthGrantModel{db}
+}
+
+func (m *OAuthGrantModel) Create(ctx context.Context, grant *OAuthGrant) error {
+	query := `INSERT INTO oauth_grants(id, client_id, user_id, scopes, expires_at)
+			  VALUES ($1, $2, $3, $4, $5)
+			  RETURNING version, created_at`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	grant.ID = db.GenerateULID()
+
+	args := []any{grant.ID, grant.ClientID, grant.UserID, pq.Array(scopesToStrings(grant.Scopes)), grant.ExpiresAt}
+
+	return m.db.QueryRowContext(ctx, query, args...).Scan(&grant.Version, &grant.CreatedAt)
+}
+
+func (m *OAuthGrantModel) GetByID(ctx context.Context, id string) (*OAuthGrant, error) {
+	query := `SELECT g.id, g.client_id, g.user_id, g.scopes, g.version, g.expires_at, g.created_at, c.name
+			  FROM oauth_grants g
+			  INNER JOIN oauth_clients c ON c.id = g.client_id
+			  WHERE g.id = $1`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	grant, err := scanOAuthGrant(m.db.QueryRowContext(ctx, query, id))
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return grant, nil
+}
+
+// GetByUserID lists the apps a user has authorized that still hold a usable
+// refresh token
+func (m *OAuthGrantModel) GetByUserID(ctx context.Context, userID string) ([]*OAuthGrant, error) {
+	query := `SELECT g.id, g.client_id, g.user_id, g.scopes, g.version, g.expires_at, g.created_at, c.name
+			  FROM oauth_grants g
+			  INNER JOIN oauth_clients c ON c.id = g.client_id
+			  WHERE g.user_id = $1 AND g.expires_at > NOW()
+			  ORDER BY g.created_at DESC`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := m.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	grants := []*OAuthGrant{}
+
+	for rows.Next() {
+		grant, err := scanOAuthGrant(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		grants = append(grants, grant)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return grants, nil
+}
+
+// Rotate moves the grant to the next refresh token version and extends it.
+// The version only moves forward from the one the presented token carried,
+// so a concurrent refresh with the same token loses and is reported as reuse.
+func (m *OAuthGrantModel) Rotate(ctx context.Context, grant *OAuthGrant, expiresAt time.Time) error {
+	query := `UPDATE oauth_grants SET version = version + 1, expires_at = $3
+			  WHERE id = $1 AND version = $2
+			  RETURNING version`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	err := m.db.QueryRowContext(ctx, query, grant.ID, grant.Version, expiresAt).Scan(&grant.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrRefreshTokenReused
+		default:
+			return err
+		}
+	}
+
+	grant.ExpiresAt = expiresAt
+
+	return nil
+}
+
+func (m *OAuthGrantModel) Delete(ctx context.Context, id string) error {
+	query := `DELETE FROM oauth_grants WHERE id = $1`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := m.db.ExecContext(ctx, query, id)
+	return err
+}
+
+// DeleteForClient revokes every grant a user gave to an app
+func (m *OAuthGrantModel) DeleteForClient(ctx context.Context, userID, clientID string) error {
+	query := `DELETE FROM oauth_grants WHERE user_id = $1 AND client_id = $2`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	result, err := m.db.ExecContext(ctx, query, userID, clientID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+func scanOAuthGrant(row rowScanner) (*OAuthGrant, error) {
+	var (
+		grant  OAuthGrant
+		scopes []string
+	)
+
+	err := row.Scan(&grant.ID, &grant.ClientID, &grant.UserID, pq.Array(&scopes), &grant.Version,
+		&grant.ExpiresAt, &grant.CreatedAt, &grant.ClientName)
+
+	if err != nil {
+		return nil, err
+	}
+
+	grant.Scopes = stringsToScopes(scopes)
+
+	return &grant, nil
+}
@@ -3,6 +3,7 @@ package store
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"sync"
@@ -19,32 +20,58 @@ var (
 	ShippedOrderStatus    OrderStatus = "shipped"
 	DeliveredOrderStatus  OrderStatus = "delivered"
 	CancelledOrderStatus  OrderStatus = "cancelled"
+	// ExpiredOrderStatus is a checkout that was not paid in time. Its stock
+	// and promo code use have been given back.
+	ExpiredOrderStatus OrderStatus = "expired"
+	// BackorderedOrderStatus is a paid order still waiting on stock for at
+	// least one backordered item.
+	BackorderedOrderStatus OrderStatus = "backordered"
 )
 
 type Order struct {
-	ID                string      `json:"id"`
-	UserID            string      `json:"user_id"`
-	TotalAmount       float64     `json:"total_amount"`
-	PromoCode         string      `json:"promo_code"`
-	Discount          float64     `json:"discount"`
-	Status            OrderStatus `json:"status"`
-	Paid              bool        `json:"paid"`
-	ShippingAddressId string      `json:"shipping_address_id"`
-	PaymentMethod     string      `json:"payment_method"`
-	CreatedAt         time.Time   `json:"created_at"`
-	UpdatedAt         time.Time   `json:"updated_at"`
+	ID          string      `json:"id"`
+	UserID      string      `json:"user_id"`
+	TotalAmount float64     `json:"total_amount"`
+	PromoCode   string      `json:"promo_code"`
+	Discount    float64     `json:"discount"`
+	Status      OrderStatus `json:"status"`
+	Paid        bool        `json:"paid"`
+	// Backordered is set when any item was accepted without stock on hand.
+	Backordered       bool      `json:"backordered"`
+	ShippingAddressId string    `json:"shipping_address_id"`
+	PaymentMethod     string    `json:"payment_method"`
+	CreatedAt         time.Time `json:"created_at"`
+	UpdatedAt         time.Time `json:"updated_at"`
 }
 
 type OrderItem struct {
-	ID         string    `json:"id"`
-	OrderID    string    `json:"order_id"`
-	ProductID  string    `json:"product_id"`
-	Quantity   int       `json:"quantity"`
-	CartItemID string    `json:"-"`
-	Price      float64   `json:"price"`
-	CreatedAt  time.Time `json:"created_at"`
-	UpdatedAt  time.Time `json:"updated_at"`
-	Product    Product   `json:"product"`
+	ID         string `json:"id"`
+	OrderID    string `json:"order_id"`
+	ProductID  string `json:"product_id"`
+	Quantity   int    `json:"quantity"`
+	CartItemID string `json:"-"`
+	// WarehouseID is the vendor location fulfilling the item, empty when it
+	// ships from unassigned stock. When the quantity is split across
+	// locations it is the nearest one and Allocations lists them all.
+	WarehouseID string                 `json:"warehouse_id,omitempty"`
+	Allocations []*OrderItemAllocation `json:"allocations,omitempty"`
+	// Backordered items were accepted without stock and ship once it is
+	// received; Preorder items ship on the product's release date.
+	Backordered       bool       `json:"backordered"`
+	Preorder          bool       `json:"preorder"`
+	ExpectedShipAt    *time.Time `json:"expected_ship_at"`
+	BackorderFilledAt *time.Time `json:"backorder_filled_at,omitempty"`
+	Price             float64    `json:"price"`
+	CreatedAt         time.Time  `json:"created_at"`
+	UpdatedAt         time.Time  `json:"updated_at"`
+	Product           Product    `json:"product"`
+}
+
+// OrderItemAllocation is the part of an order item's quantity reserved at one
+// location. WarehouseID is empty for the vendor's unassigned stock.
+type OrderItemAllocation struct {
+	WarehouseID string `json:"warehouse_id,omitempty"`
+	Quantity    int    `json:"quantity"`
 }
 
 type OrderStore interface {
@@ -52,6 +79,7 @@ type OrderStore interface {
 	GetUserOrderByID(ctx context.Context, userId, id string) (*Order, error)
 	GetOrderByID(ctx context.Context, id string) (*Order, error)
 	UpdateStatus(ctx context.Context, orderID string, status OrderStatus) error
+	Expire(ctx context.Context, orderID string) ([]string, error)
 	GetAbandonedOrders(ctx context.Context, cutoffTime time.Time) ([]Order, error)
 	GetOrdersForUser(ctx context.Context, userID string, fq PaginateQueryFilter) ([]*Order, Metadata, error)
 	GetOrderForUserByID(ctx context.Context, userID, orderID string) (*UserOrder, error)
@@ -80,7 +108,7 @@ func NewOrderItemModel(db *sql.DB) OrderItemStore {
 func createOrder(ctx context.Context, tx *sql.Tx, order *Order) error {
 	order.ID = db.GenerateULID()
 	query := `INSERT INTO orders(id, user_id, total_amount, promo_code, discount, shipping_address_id, status, paid, payment_method)
-			 VALUES($1, $2, $3, $4, $5, $6, $7, $8) RETURNING created_at, updated_at
+			 VALUES($1, $2, $3, $4, $5, $6, $7, $8, $9) RETURNING created_at, updated_at
 	`
 
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
@@ -92,7 +120,7 @@ func createOrder(ctx context.Context, tx *sql.Tx, order *Order) error {
 	err := tx.QueryRowContext(ctx, query, args...).Scan(&order.CreatedAt, &order.UpdatedAt)
 
 	if err != nil {
-		return nil
+		return err
 	}
 
 	return nil
@@ -108,6 +136,10 @@ func (m *OrderModel) Create(ctx context.Context, productStore ProductStore, orde
 			return err
 		}
 
+		if err := reserveOrderStock(ctx, tx, order, cartItems); err != nil {
+			return err
+		}
+
 		return nil
 	})
 }
@@ -173,6 +205,7 @@ func (m *OrderModel) GetUserOrderByID(ctx context.Context, userId, id string) (*
 				discount,
 				status,
 				paid,
+				backordered,
 				payment_method,
 				created_at,
 				updated_at
@@ -191,7 +224,7 @@ func (m *OrderModel) GetUserOrderByID(ctx context.Context, userId, id string) (*
 	var paymentMethod sql.NullString
 
 	err := m.db.QueryRowContext(ctx, query, id, userId).Scan(&order.ID, &order.UserID, &order.TotalAmount, &promoCode, &discount, &order.Status, &order.Paid,
-		&paymentMethod, &order.CreatedAt, &order.UpdatedAt)
+		&order.Backordered, &paymentMethod, &order.CreatedAt, &order.UpdatedAt)
 
 	if err != nil {
 		switch {
@@ -227,6 +260,7 @@ func (m *OrderModel) GetOrderByID(ctx context.Context, id string) (*Order, error
 				discount,
 				status,
 				paid,
+				backordered,
 				payment_method,
 				created_at,
 				updated_at
@@ -241,7 +275,7 @@ func (m *OrderModel) GetOrderByID(ctx context.Context, id string) (*Order, error
 	order := &Order{}
 
 	err := m.db.QueryRowContext(ctx, query, id).Scan(&order.ID, &order.UserID, &order.TotalAmount, &order.PromoCode, &order.Discount, &order.Status, &order.Paid,
-		&order.PaymentMethod, &order.CreatedAt, &order.UpdatedAt)
+		&order.Backordered, &order.PaymentMethod, &order.CreatedAt, &order.UpdatedAt)
 
 	if err != nil {
 		switch {
@@ -281,14 +315,72 @@ func (m *OrderModel) UpdateStatus(ctx context.Context, orderID string, status Or
 	return nil
 }
 
+// Expire closes a checkout that was never paid. The promo code use and the
+// stock reserved by the order are given back in the same transaction, so a
+// failure leaves the order pending for the next run. It returns
+// ErrRecordNotFound when the order is no longer pending, and otherwise the
+// products that were out of stock until the release.
+func (m *OrderModel) Expire(ctx context.Context, orderID string) ([]string, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var restocked []string
+
+	err := withTrx(m.db, ctx, func(tx *sql.Tx) error {
+		var promoCode sql.NullString
+
+		err := tx.QueryRowContext(ctx, `UPDATE orders SET status = $2 WHERE id = $1 AND status = $3 AND NOT paid
+			RETURNING promo_code`, orderID, ExpiredOrderStatus, PendingOrderStatus).Scan(&promoCode)
+
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return ErrRecordNotFound
+			}
+			return err
+		}
+
+		if promoCode.String != "" {
+			_, err := tx.ExecContext(ctx, `UPDATE promos SET used_count = used_count - 1 WHERE code = $1 AND used_count > 0`,
+				promoCode.String)
+
+			if err != nil {
+				return err
+			}
+		}
+
+		if restocked, err = releaseOrderReservations(ctx, tx, orderID, "order expired", false); err != nil {
+			return err
+		}
+
+		return fillOrderBackorders(ctx, tx, orderID)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return restocked, nil
+}
+
 func (m *OrderItemModel) GetItemsByOrderID(ctx context.Context, orderID string) ([]*OrderItem, error) {
 	// SQL query to fetch OrderItems and their associated Products by orderID
 	query := `
         SELECT
-            oi.id, oi.order_id, oi.product_id, oi.quantity, oi.price, oi.created_at, oi.updated_at,
+            oi.id, oi.order_id, oi.product_id, oi.quantity, oi.price, COALESCE(oi.warehouse_id, ''),
+            oi.backordered, oi.preorder, oi.expected_ship_at, oi.backorder_filled_at,
+            oi.created_at, oi.updated_at,
             p.id, p.name, p.description, p.stock_quantity, p.status, p.published,
             p.total_items_sold_count, p.vendor_id, p.discount, p.price, p.category_id,
-            p.created_at, p.updated_at
+            p.created_at, p.updated_at,
+            COALESCE(
+                (SELECT json_agg(jsonb_build_object(
+                    'warehouse_id', COALESCE(a.warehouse_id, ''),
+                    'quantity', a.quantity
+                ) ORDER BY a.created_at)
+                FROM order_item_allocations a
+                WHERE a.order_item_id = oi.id),
+                '[]'
+            ) AS allocations
         FROM
             order_items oi
         JOIN
@@ -310,6 +402,7 @@ func (m *OrderItemModel) GetItemsByOrderID(ctx context.Context, orderID string)
 	for rows.Next() {
 		var orderItem OrderItem
 		var product Product
+		var allocationJSON string
 
 		// Scan the row into the OrderItem and Product structs
 		err := rows.Scan(
@@ -318,6 +411,11 @@ func (m *OrderItemModel) GetItemsByOrderID(ctx context.Context, orderID string)
 			&orderItem.ProductID,
 			&orderItem.Quantity,
 			&orderItem.Price,
+			&orderItem.WarehouseID,
+			&orderItem.Backordered,
+			&orderItem.Preorder,
+			&orderItem.ExpectedShipAt,
+			&orderItem.BackorderFilledAt,
 			&orderItem.CreatedAt,
 			&orderItem.UpdatedAt,
 			&product.ID,
@@ -333,11 +431,14 @@ func (m *OrderItemModel) GetItemsByOrderID(ctx context.Context, orderID string)
 			&product.CategoryID,
 			&product.CreatedAt,
 			&product.UpdatedAt,
+			&allocationJSON,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan order item and product: %w", err)
 		}
 
+		orderItem.Allocations = parseAllocations(allocationJSON)
+
 		// Assign the product to the order item
 		orderItem.Product = product
 
@@ -387,7 +488,7 @@ func (s *OrderModel) GetAbandonedOrders(ctx context.Context, cutoffTime time.Tim
 
 func (m *OrderModel) GetOrdersForUser(ctx context.Context, userID string, fq PaginateQueryFilter) ([]*Order, Metadata, error) {
 	query := `SELECT count(*) over(), id, user_id, total_amount, promo_code, discount, status, paid,
-				payment_method, shipping_address_id, created_at, updated_at
+				backordered, payment_method, shipping_address_id, created_at, updated_at
 				FROM orders WHERE user_id = $1`
 
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
@@ -418,7 +519,7 @@ func (m *OrderModel) GetOrdersForUser(ctx context.Context, userID string, fq Pag
 		)
 
 		err := rows.Scan(&totalRecords, &order.ID, &order.UserID, &order.TotalAmount, &promoCode, &discount, &order.Status,
-			&order.Paid, &paymentMethod, &shippingAddressID, &order.CreatedAt, &order.UpdatedAt,
+			&order.Paid, &order.Backordered, &paymentMethod, &shippingAddressID, &order.CreatedAt, &order.UpdatedAt,
 		)
 
 		if err != nil {
@@ -471,7 +572,7 @@ func (m *OrderModel) GetOrderForUserByID(ctx context.Context, userID, orderID st
 	orderQuery := `
 		SELECT
 			id, user_id, total_amount, promo_code, discount, status, paid,
-			payment_method, shipping_address_id, created_at, updated_at
+			backordered, payment_method, shipping_address_id, created_at, updated_at
 		FROM orders
 		WHERE id = $1 AND user_id = $2
 	`
@@ -490,7 +591,7 @@ func (m *OrderModel) GetOrderForUserByID(ctx context.Context, userID, orderID st
 	// Execute the order query.
 	err := m.db.QueryRowContext(ctx, orderQuery, orderID, userID).Scan(
 		&order.ID, &order.UserID, &order.TotalAmount, &promoCode, &discount, &order.Status,
-		&order.Paid, &paymentMethod, &shippingAddressID, &order.CreatedAt, &order.UpdatedAt,
+		&order.Paid, &order.Backordered, &paymentMethod, &shippingAddressID, &order.CreatedAt, &order.UpdatedAt,
 	)
 	if err != nil {
 		switch {
@@ -518,7 +619,8 @@ func (m *OrderModel) GetOrderForUserByID(ctx context.Context, userID, orderID st
 	// Query to fetch order items and their associated product details for the given order ID.
 	orderItemsQuery := `
 		SELECT
-			oi.id, oi.order_id, oi.product_id, oi.quantity, oi.price, oi.created_at, oi.updated_at,
+			oi.id, oi.order_id, oi.product_id, oi.quantity, oi.price, COALESCE(oi.warehouse_id, ''),
+			oi.backordered, oi.preorder, oi.expected_ship_at, oi.backorder_filled_at, oi.created_at, oi.updated_at,
 			p.id, p.name, p.description, p.stock_quantity, p.status, p.published,
 			p.total_items_sold_count, p.vendor_id, p.discount, p.price, p.category_id,
 			p.created_at, p.updated_at,
@@ -546,7 +648,16 @@ func (m *OrderModel) GetOrderForUserByID(ctx context.Context, userID, orderID st
 				FROM product_features pf
 				WHERE pf.product_id = p.id),
 				'[]'
-			) AS features
+			) AS features,
+			COALESCE(
+				(SELECT json_agg(jsonb_build_object(
+					'warehouse_id', COALESCE(a.warehouse_id, ''),
+					'quantity', a.quantity
+				) ORDER BY a.created_at)
+				FROM order_item_allocations a
+				WHERE a.order_item_id = oi.id),
+				'[]'
+			) AS allocations
 		FROM order_items oi
 		JOIN products p ON oi.product_id = p.id
 		WHERE oi.order_id = $1
@@ -563,19 +674,21 @@ func (m *OrderModel) GetOrderForUserByID(ctx context.Context, userID, orderID st
 	// Iterate over the rows and scan the data into the structs.
 	for rows.Next() {
 		var (
-			orderItem   OrderItem
-			product     Product
-			imageJSON   string
-			featureJSON string
+			orderItem      OrderItem
+			product        Product
+			imageJSON      string
+			featureJSON    string
+			allocationJSON string
 		)
 
 		err := rows.Scan(
 			&orderItem.ID, &orderItem.OrderID, &orderItem.ProductID, &orderItem.Quantity,
-			&orderItem.Price, &orderItem.CreatedAt, &orderItem.UpdatedAt,
+			&orderItem.Price, &orderItem.WarehouseID, &orderItem.Backordered, &orderItem.Preorder,
+			&orderItem.ExpectedShipAt, &orderItem.BackorderFilledAt, &orderItem.CreatedAt, &orderItem.UpdatedAt,
 			&product.ID, &product.Name, &product.Description, &product.StockQuantity,
 			&product.Status, &product.Published, &product.TotalItemsSoldCount, &product.VendorID,
 			&product.Discount, &product.Price, &product.CategoryID, &product.CreatedAt, &product.UpdatedAt,
-			&imageJSON, &featureJSON,
+			&imageJSON, &featureJSON, &allocationJSON,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan order item and product details: %w", err)
@@ -583,6 +696,7 @@ func (m *OrderModel) GetOrderForUserByID(ctx context.Context, userID, orderID st
 
 		product.Images = parseImages(imageJSON)
 		product.Features = parseFeatures(featureJSON)
+		orderItem.Allocations = parseAllocations(allocationJSON)
 
 		orderItems = append(orderItems, &OrderItemWithProductDetails{
 			OrderItem: orderItem,
@@ -603,3 +717,12 @@ func (m *OrderModel) GetOrderForUserByID(ctx context.Context, userID, orderID st
 
 	return userOrder, nil
 }
+
+// Helper function to parse order item allocations from JSON string
+func parseAllocations(allocationsJSON string) []*OrderItemAllocation {
+	var allocations []*OrderItemAllocation
+	if err := json.Unmarshal([]byte(allocationsJSON), &allocations); err != nil {
+		return nil
+	}
+	return allocations
+}
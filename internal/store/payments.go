@@ -3,6 +3,7 @@ package store
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"time"
 
 	"github.com/devphaseX/buyr-api.git/internal/db"
@@ -24,6 +25,9 @@ type Payment struct {
 	TransactionID string        `json:"transaction_id"`
 	CreatedAt     time.Time     `json:"created_at"`
 	UpdatedAt     time.Time     `json:"updated_at"`
+	// RestockedProductIDs lists the products that came back in stock when a
+	// failed payment released the order's reservations.
+	RestockedProductIDs []string `json:"-"`
 }
 
 type PaymentStore interface {
@@ -90,12 +94,36 @@ func setProcessingOrder(ctx context.Context, tx *sql.Tx, orderID string, paid bo
 	return nil
 }
 
+// Create records a payment and settles its order. A completed payment for an
+// order that expired in the meantime reserves the stock again first, so the
+// sale is still recorded.
 func (m *PaymentModel) Create(ctx context.Context, payment *Payment) error {
 	return withTrx(m.db, ctx, func(tx *sql.Tx) error {
 		if err := createPayment(ctx, tx, payment); err != nil {
 			return err
 		}
 
+		var status OrderStatus
+
+		err := tx.QueryRowContext(ctx, `SELECT status FROM orders WHERE id = $1 FOR UPDATE`, payment.OrderID).Scan(&status)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return ErrRecordNotFound
+			}
+			return err
+		}
+
+		if status == ExpiredOrderStatus {
+			// the order already gave back its stock and promo code use
+			if payment.Status != CompletedPaymentStatus {
+				return nil
+			}
+
+			if err := reserveExpiredOrder(ctx, tx, payment.OrderID); err != nil {
+				return err
+			}
+		}
+
 		if err := setProcessingOrder(ctx, tx, payment.OrderID, payment.Status == CompletedPaymentStatus); err != nil {
 			return err
 		}
@@ -106,8 +134,138 @@ func (m *PaymentModel) Create(ctx context.Context, payment *Payment) error {
 			if err != nil {
 				return err
 			}
+
+			_, err = releaseOrderReservations(ctx, tx, payment.OrderID, "payment completed", true)
+
+			if err != nil {
+				return err
+			}
+
+			return holdBackorderedOrder(ctx, tx, payment.OrderID)
 		}
 
-		return nil
+		restocked, err := releaseOrderReservations(ctx, tx, payment.OrderID, "payment failed", false)
+
+		if err != nil {
+			return err
+		}
+
+		payment.RestockedProductIDs = restocked
+
+		return fillOrderBackorders(ctx, tx, payment.OrderID)
 	})
 }
+
+// reserveExpiredOrder takes stock again for an order that expired before its
+// payment came through. The customer has paid, so items the stock can no
+// longer cover are backordered rather than refused, and the promo code use
+// given back on expiry is counted again.
+func reserveExpiredOrder(ctx context.Context, tx *sql.Tx, orderID string) error {
+	var (
+		userID    string
+		promoCode sql.NullString
+		addressID sql.NullString
+	)
+
+	err := tx.QueryRowContext(ctx, `SELECT user_id, promo_code, shipping_address_id FROM orders WHERE id = $1`,
+		orderID).Scan(&userID, &promoCode, &addressID)
+
+	if err != nil {
+		return err
+	}
+
+	if promoCode.String != "" {
+		_, err := tx.ExecContext(ctx, `UPDATE promos SET used_count = used_count + 1 WHERE code = $1`, promoCode.String)
+		if err != nil {
+			return err
+		}
+	}
+
+	destination, err := orderDestination(ctx, tx, addressID.String)
+	if err != nil {
+		return err
+	}
+
+	// items still waiting on a backorder never held stock
+	rows, err := tx.QueryContext(ctx, `
+		SELECT oi.id, oi.product_id, oi.quantity, p.backorder_ships_at
+		FROM order_items oi
+		JOIN products p ON p.id = oi.product_id
+		WHERE oi.order_id = $1 AND NOT (`+outstandingBackorderSQL+`)
+		ORDER BY oi.product_id`, orderID)
+
+	if err != nil {
+		return err
+	}
+
+	type expiredItem struct {
+		id        string
+		productID string
+		quantity  int
+		shipsAt   *time.Time
+	}
+
+	var items []expiredItem
+
+	for rows.Next() {
+		var item expiredItem
+
+		if err := rows.Scan(&item.id, &item.productID, &item.quantity, &item.shipsAt); err != nil {
+			rows.Close()
+			return err
+		}
+
+		items = append(items, item)
+	}
+
+	rows.Close()
+
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		DELETE FROM order_item_allocations
+		WHERE order_item_id IN (SELECT id FROM order_items WHERE order_id = $1)`, orderID)
+
+	if err != nil {
+		return err
+	}
+
+	var backordered bool
+
+	for _, item := range items {
+		_, err := tx.ExecContext(ctx, `UPDATE order_items SET warehouse_id = NULL WHERE id = $1`, item.id)
+		if err != nil {
+			return err
+		}
+
+		err = allocateOrderItem(ctx, tx, orderID, userID, item.productID, item.quantity, destination, "paid after expiry")
+		if err == nil {
+			continue
+		}
+
+		if !errors.Is(err, ErrInsufficientStock) {
+			return err
+		}
+
+		backordered = true
+
+		_, err = tx.ExecContext(ctx, `
+			UPDATE order_items SET backordered = true, backorder_filled_at = NULL,
+				expected_ship_at = COALESCE($1, expected_ship_at)
+			WHERE id = $2`, item.shipsAt, item.id)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	if !backordered {
+		return nil
+	}
+
+	_, err = tx.ExecContext(ctx, `UPDATE orders SET backordered = true WHERE id = $1`, orderID)
+
+	return err
+}
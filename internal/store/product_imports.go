@@ -0,0 +1,285 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/devphaseX/buyr-api.git/internal/db"
+)
+
+type ProductImportStatus string
+
+var (
+	PendingProductImportStatus    ProductImportStatus = "pending"
+	ProcessingProductImportStatus ProductImportStatus = "processing"
+	CompletedProductImportStatus  ProductImportStatus = "completed"
+	FailedProductImportStatus     ProductImportStatus = "failed"
+)
+
+type ProductImportRowStatus string
+
+var (
+	ValidProductImportRowStatus   ProductImportRowStatus = "valid"
+	CreatedProductImportRowStatus ProductImportRowStatus = "created"
+	UpdatedProductImportRowStatus ProductImportRowStatus = "updated"
+	InvalidProductImportRowStatus ProductImportRowStatus = "invalid"
+	FailedProductImportRowStatus  ProductImportRowStatus = "failed"
+)
+
+type ProductImportRowReport struct {
+	Line      int                    `json:"line"`
+	Status    ProductImportRowStatus `json:"status"`
+	ProductID string                 `json:"product_id,omitempty"`
+	Name      string                 `json:"name,omitempty"`
+	Errors    map[string]string      `json:"errors,omitempty"`
+}
+
+type ProductImport struct {
+	ID            string                    `json:"id"`
+	VendorID      string                    `json:"vendor_id"`
+	Format        string                    `json:"format"`
+	Status        ProductImportStatus       `json:"status"`
+	DryRun        bool                      `json:"dry_run"`
+	Payload       []byte                    `json:"-"`
+	TotalRows     int                       `json:"total_rows"`
+	SucceededRows int                       `json:"succeeded_rows"`
+	FailedRows    int                       `json:"failed_rows"`
+	Report        []*ProductImportRowReport `json:"report"`
+	CompletedAt   *time.Time                `json:"completed_at"`
+	CreatedAt     time.Time                 `json:"created_at"`
+	UpdatedAt     time.Time                 `json:"updated_at"`
+}
+
+type ProductImportStore interface {
+	Create(ctx context.Context, productImport *ProductImport) error
+	GetByID(ctx context.Context, id string) (*ProductImport, error)
+	GetVendorImportByID(ctx context.Context, vendorID, id string) (*ProductImport, error)
+	SetStatus(ctx context.Context, id string, status ProductImportStatus) error
+	Complete(ctx context.Context, productImport *ProductImport) error
+	GetAppliedRows(ctx context.Context, importID string) (map[int]*ProductImportRowReport, error)
+}
+
+type ProductImportModel struct {
+	db *sql.DB
+}
+
+func NewProductImportModel(db *sql.DB) ProductImportStore {
+	return &ProductImportModel{db}
+}
+
+func (m *ProductImportModel) Create(ctx context.Context, productImport *ProductImport) error {
+	query := `INSERT INTO product_imports(id, vendor_id, format, status, dry_run, payload)
+			  VALUES ($1, $2, $3, $4, $5, $6)
+			  RETURNING id, created_at, updated_at`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	id := db.GenerateULID()
+
+	if productImport.Status == "" {
+		productImport.Status = PendingProductImportStatus
+	}
+
+	args := []any{id, productImport.VendorID, productImport.Format, productImport.Status,
+		productImport.DryRun, productImport.Payload}
+
+	return m.db.QueryRowContext(ctx, query, args...).Scan(&productImport.ID,
+		&productImport.CreatedAt, &productImport.UpdatedAt)
+}
+
+func (m *ProductImportModel) getImport(ctx context.Context, query string, args ...any) (*ProductImport, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var (
+		productImport = &ProductImport{}
+		reportJSON    []byte
+		completedAt   sql.NullTime
+	)
+
+	err := m.db.QueryRowContext(ctx, query, args...).Scan(
+		&productImport.ID,
+		&productImport.VendorID,
+		&productImport.Format,
+		&productImport.Status,
+		&productImport.DryRun,
+		&productImport.Payload,
+		&productImport.TotalRows,
+		&productImport.SucceededRows,
+		&productImport.FailedRows,
+		&reportJSON,
+		&completedAt,
+		&productImport.CreatedAt,
+		&productImport.UpdatedAt,
+	)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	if completedAt.Valid {
+		productImport.CompletedAt = &completedAt.Time
+	}
+
+	productImport.Report = []*ProductImportRowReport{}
+	if err := json.Unmarshal(reportJSON, &productImport.Report); err != nil {
+		return nil, fmt.Errorf("failed to parse import report: %w", err)
+	}
+
+	return productImport, nil
+}
+
+func (m *ProductImportModel) GetByID(ctx context.Context, id string) (*ProductImport, error) {
+	query := `SELECT id, vendor_id, format, status, dry_run, payload, total_rows, succeeded_rows,
+			  failed_rows, report, completed_at, created_at, updated_at
+			  FROM product_imports WHERE id = $1`
+
+	return m.getImport(ctx, query, id)
+}
+
+func (m *ProductImportModel) GetVendorImportByID(ctx context.Context, vendorID, id string) (*ProductImport, error) {
+	query := `SELECT id, vendor_id, format, status, dry_run, payload, total_rows, succeeded_rows,
+			  failed_rows, report, completed_at, created_at, updated_at
+			  FROM product_imports WHERE id = $1 AND vendor_id = $2`
+
+	return m.getImport(ctx, query, id, vendorID)
+}
+
+func (m *ProductImportModel) SetStatus(ctx context.Context, id string, status ProductImportStatus) error {
+	query := `UPDATE product_imports SET status = $1 WHERE id = $2`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	result, err := m.db.ExecContext(ctx, query, status, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// Complete stores the final counts and per-row report. The raw payload is
+// dropped at this point since it is no longer needed.
+func (m *ProductImportModel) Complete(ctx context.Context, productImport *ProductImport) error {
+	query := `UPDATE product_imports
+			  SET status = $1, total_rows = $2, succeeded_rows = $3, failed_rows = $4,
+			  report = $5, payload = '', completed_at = NOW()
+			  WHERE id = $6
+			  RETURNING completed_at, updated_at`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	report, err := json.Marshal(productImport.Report)
+	if err != nil {
+		return fmt.Errorf("failed to serialize import report: %w", err)
+	}
+
+	var completedAt time.Time
+
+	err = m.db.QueryRowContext(ctx, query, productImport.Status, productImport.TotalRows,
+		productImport.SucceededRows, productImport.FailedRows, report, productImport.ID).
+		Scan(&completedAt, &productImport.UpdatedAt)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+
+	productImport.CompletedAt = &completedAt
+	productImport.Payload = nil
+
+	return nil
+}
+
+// GetAppliedRows returns the lines of an import that already created or
+// updated a product, keyed by line, so a retried import can skip them.
+func (m *ProductImportModel) GetAppliedRows(ctx context.Context, importID string) (map[int]*ProductImportRowReport, error) {
+	query := `SELECT line, product_id, status FROM product_import_rows WHERE import_id = $1`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := m.db.QueryContext(ctx, query, importID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	applied := map[int]*ProductImportRowReport{}
+
+	for rows.Next() {
+		report := &ProductImportRowReport{}
+
+		if err := rows.Scan(&report.Line, &report.ProductID, &report.Status); err != nil {
+			return nil, err
+		}
+
+		applied[report.Line] = report
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return applied, nil
+}
+
+// Import applies a single import line. A product with an ID is updated like
+// UpdateDetails, otherwise a new one is created. The line is recorded in the
+// same transaction so a retried import never applies it twice.
+func (m *ProductModel) Import(ctx context.Context, importID string, line int, product *Product) (ProductImportRowStatus, error) {
+	query := `INSERT INTO product_import_rows(import_id, line, product_id, status)
+			  VALUES ($1, $2, $3, $4)`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	status := CreatedProductImportRowStatus
+
+	err := withTrx(m.db, ctx, func(tx *sql.Tx) error {
+		if product.ID != "" {
+			status = UpdatedProductImportRowStatus
+
+			if err := updateDetails(ctx, tx, product); err != nil {
+				return err
+			}
+		} else if err := createWithDetails(ctx, tx, product); err != nil {
+			return err
+		}
+
+		_, err := tx.ExecContext(ctx, query, importID, line, product.ID, status)
+		return err
+	})
+
+	if err != nil {
+		return "", err
+	}
+
+	return status, nil
+}
@@ -0,0 +1,584 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/devphaseX/buyr-api.git/internal/db"
+	"github.com/lib/pq"
+)
+
+var (
+	ErrProductNotRejected = errors.New("only rejected products can be resubmitted")
+)
+
+type ModerationDecision string
+
+var (
+	ApprovedModerationDecision    ModerationDecision = "approved"
+	RejectedModerationDecision    ModerationDecision = "rejected"
+	ResubmittedModerationDecision ModerationDecision = "resubmitted"
+)
+
+type ProductModerationEvent struct {
+	ID         string             `json:"id"`
+	ProductID  string             `json:"product_id"`
+	Decision   ModerationDecision `json:"decision"`
+	Reason     string             `json:"reason,omitempty"`
+	ReviewerID *string            `json:"reviewer_id,omitempty"`
+	CreatedAt  time.Time          `json:"created_at"`
+}
+
+// ProductModeration is the review state of a single product together with
+// its decision history.
+type ProductModeration struct {
+	ProductID       string                    `json:"product_id"`
+	ProductName     string                    `json:"product_name"`
+	VendorID        string                    `json:"vendor_id"`
+	Status          ProductStatus             `json:"status"`
+	RejectionReason *string                   `json:"rejection_reason"`
+	ReviewedBy      *string                   `json:"reviewed_by"`
+	ReviewedAt      *time.Time                `json:"reviewed_at"`
+	SubmittedAt     time.Time                 `json:"submitted_at"`
+	History         []*ProductModerationEvent `json:"history,omitempty"`
+}
+
+type ModerationQueueItem struct {
+	ProductID          string    `json:"product_id"`
+	Name               string    `json:"name"`
+	Price              float64   `json:"price"`
+	CategoryID         string    `json:"category_id"`
+	VendorID           string    `json:"vendor_id"`
+	VendorBusinessName string    `json:"vendor_business_name"`
+	Resubmission       bool      `json:"resubmission"`
+	SubmittedAt        time.Time `json:"submitted_at"`
+	AgeSeconds         int64     `json:"age_seconds"`
+}
+
+func recordModerationEvent(ctx context.Context, tx *sql.Tx, event *ProductModerationEvent) error {
+	query := `INSERT INTO product_moderation_events(id, product_id, decision, reason, reviewer_id)
+			  VALUES ($1, $2, $3, $4, $5)
+			  RETURNING id, created_at`
+
+	id := db.GenerateULID()
+
+	return tx.QueryRowContext(ctx, query, id, event.ProductID, event.Decision,
+		sql.NullString{String: event.Reason, Valid: event.Reason != ""}, event.ReviewerID).
+		Scan(&event.ID, &event.CreatedAt)
+}
+
+func scanProductModeration(row *sql.Row) (*ProductModeration, error) {
+	moderation := &ProductModeration{}
+
+	var (
+		rejectionReason sql.NullString
+		reviewedBy      sql.NullString
+		reviewedAt      sql.NullTime
+	)
+
+	err := row.Scan(&moderation.ProductID, &moderation.ProductName, &moderation.VendorID,
+		&moderation.Status, &rejectionReason, &reviewedBy, &reviewedAt, &moderation.SubmittedAt)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	if rejectionReason.Valid {
+		moderation.RejectionReason = &rejectionReason.String
+	}
+
+	if reviewedBy.Valid {
+		moderation.ReviewedBy = &reviewedBy.String
+	}
+
+	if reviewedAt.Valid {
+		moderation.ReviewedAt = &reviewedAt.Time
+	}
+
+	return moderation, nil
+}
+
+// decide moves a pending product to approved or rejected and records the
+// decision in the same transaction.
+func (m *ProductModel) decide(ctx context.Context, productID, reviewerID string, decision ModerationDecision, reason string) (*ProductModeration, error) {
+	query := `
+		UPDATE products
+		SET status = $1,
+			published = CASE WHEN $1 = $6 THEN false ELSE published END,
+			rejection_reason = $2,
+			reviewed_by = $3,
+			reviewed_at = NOW()
+		WHERE id = $4 AND status = $5
+		RETURNING id, name, vendor_id, status, rejection_reason, reviewed_by, reviewed_at, submitted_at
+	`
+
+	status := ApprovedProductStatus
+	if decision == RejectedModerationDecision {
+		status = RejectedProductStatus
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var moderation *ProductModeration
+
+	err := withTrx(m.db, ctx, func(tx *sql.Tx) error {
+		var err error
+
+		row := tx.QueryRowContext(ctx, query, status,
+			sql.NullString{String: reason, Valid: reason != ""}, reviewerID,
+			productID, PendingProductStatus, RejectedProductStatus)
+
+		moderation, err = scanProductModeration(row)
+		if err != nil {
+			return err
+		}
+
+		return recordModerationEvent(ctx, tx, &ProductModerationEvent{
+			ProductID:  productID,
+			Decision:   decision,
+			Reason:     reason,
+			ReviewerID: &reviewerID,
+		})
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return moderation, nil
+}
+
+func (m *ProductModel) Approve(ctx context.Context, productID, reviewerID string) (*ProductModeration, error) {
+	return m.decide(ctx, productID, reviewerID, ApprovedModerationDecision, "")
+}
+
+func (m *ProductModel) Reject(ctx context.Context, productID, reviewerID, reason string) (*ProductModeration, error) {
+	return m.decide(ctx, productID, reviewerID, RejectedModerationDecision, reason)
+}
+
+// Resubmit puts a rejected product back into the moderation queue. The last
+// rejection reason is kept in the history but cleared from the product.
+func (m *ProductModel) Resubmit(ctx context.Context, productID, vendorID string) (*ProductModeration, error) {
+	query := `
+		UPDATE products
+		SET status = $1, rejection_reason = NULL, submitted_at = NOW()
+		WHERE id = $2 AND vendor_id = $3 AND status = $4
+		RETURNING id, name, vendor_id, status, rejection_reason, reviewed_by, reviewed_at, submitted_at
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var moderation *ProductModeration
+
+	err := withTrx(m.db, ctx, func(tx *sql.Tx) error {
+		var err error
+
+		row := tx.QueryRowContext(ctx, query, PendingProductStatus, productID, vendorID, RejectedProductStatus)
+
+		moderation, err = scanProductModeration(row)
+		if err != nil {
+			if !errors.Is(err, ErrRecordNotFound) {
+				return err
+			}
+
+			var status ProductStatus
+			err = tx.QueryRowContext(ctx, `SELECT status FROM products WHERE id = $1 AND vendor_id = $2`,
+				productID, vendorID).Scan(&status)
+
+			switch {
+			case errors.Is(err, sql.ErrNoRows):
+				return ErrRecordNotFound
+			case err != nil:
+				return err
+			default:
+				return ErrProductNotRejected
+			}
+		}
+
+		return recordModerationEvent(ctx, tx, &ProductModerationEvent{
+			ProductID: productID,
+			Decision:  ResubmittedModerationDecision,
+		})
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return moderation, nil
+}
+
+// UpdateDetails lets a vendor edit the catalog fields of their own product.
+// A change to the stock quantity goes through the inventory ledger as an
+// adjustment. Editing the name, description, price or category of an
+// approved product sends it back to the moderation queue, so the listing is
+// hidden until a reviewer approves the new details. Moving the product to
+// another category replaces its attribute values with product.Attributes,
+// which the caller validated against the new category's schema.
+func (m *ProductModel) UpdateDetails(ctx context.Context, product *Product) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	return withTrx(m.db, ctx, func(tx *sql.Tx) error {
+		return updateDetails(ctx, tx, product)
+	})
+}
+
+// updateDetails does the work of UpdateDetails inside tx. When product.Images
+// or product.Features is set, as it is for imports, those are replaced too
+// and count as reviewable changes when they differ from the current ones.
+func updateDetails(ctx context.Context, tx *sql.Tx, product *Product) error {
+	query := `
+		UPDATE products
+		SET name = $1, description = $2, price = $3, discount = $4,
+			category_id = $5, updated_at = NOW(),
+			status = CASE WHEN $8 THEN $9 ELSE status END,
+			submitted_at = CASE WHEN $8 THEN NOW() ELSE submitted_at END,
+			reviewed_by = CASE WHEN $8 THEN NULL ELSE reviewed_by END,
+			reviewed_at = CASE WHEN $8 THEN NULL ELSE reviewed_at END
+		WHERE id = $6 AND vendor_id = $7
+		RETURNING status, published, created_at, updated_at
+	`
+
+	var (
+		currentStock       int
+		actorID            string
+		currentName        string
+		currentDescription sql.NullString
+		currentPrice       sql.NullFloat64
+		currentCategoryID  sql.NullString
+		currentStatus      ProductStatus
+	)
+
+	err := tx.QueryRowContext(ctx, `
+		SELECT p.stock_quantity, v.user_id, p.name, p.description, p.price, p.category_id, p.status
+		FROM products p
+		JOIN vendor_users v ON v.id = p.vendor_id
+		WHERE p.id = $1 AND p.vendor_id = $2
+		FOR UPDATE OF p`, product.ID, product.VendorID).Scan(&currentStock, &actorID,
+		&currentName, &currentDescription, &currentPrice, &currentCategoryID, &currentStatus)
+
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrRecordNotFound
+		}
+		return err
+	}
+
+	var (
+		categoryChanged = product.CategoryID != currentCategoryID.String
+		imagesChanged   bool
+		featuresChanged bool
+	)
+
+	if product.Images != nil {
+		if imagesChanged, err = productImagesChanged(ctx, tx, product.ID, product.Images); err != nil {
+			return err
+		}
+	}
+
+	if product.Features != nil {
+		if featuresChanged, err = productFeaturesChanged(ctx, tx, product.ID, product.Features); err != nil {
+			return err
+		}
+	}
+
+	remoderate := currentStatus == ApprovedProductStatus &&
+		(product.Name != currentName ||
+			product.Description != currentDescription.String ||
+			product.Price != currentPrice.Float64 ||
+			categoryChanged || imagesChanged || featuresChanged)
+
+	args := []any{product.Name, product.Description, product.Price, product.Discount,
+		product.CategoryID, product.ID, product.VendorID, remoderate, PendingProductStatus}
+
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&product.Status, &product.Published,
+		&product.CreatedAt, &product.UpdatedAt)
+
+	if err != nil {
+		var pgErr *pq.Error
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrRecordNotFound
+		case errors.As(err, &pgErr) && pgErr.Constraint == "products_category_id_fk":
+			return ErrProductCategoryNotFound
+		default:
+			return scheduleConstraintError(err)
+		}
+	}
+
+	if imagesChanged {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM product_images WHERE product_id = $1`, product.ID); err != nil {
+			return err
+		}
+
+		if err := createProductImages(ctx, tx, product.ID, product.Images); err != nil {
+			return err
+		}
+	}
+
+	if featuresChanged {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM product_features WHERE product_id = $1`, product.ID); err != nil {
+			return err
+		}
+
+		if err := createProductFeatures(ctx, tx, product.ID, product.Features); err != nil {
+			return err
+		}
+	}
+
+	if categoryChanged || featuresChanged {
+		_, err = tx.ExecContext(ctx, `DELETE FROM product_attribute_values WHERE product_id = $1`, product.ID)
+		if err != nil {
+			return err
+		}
+
+		if err := createProductAttributeValues(ctx, tx, product.ID, product.Attributes); err != nil {
+			return err
+		}
+	}
+
+	if remoderate {
+		err = recordModerationEvent(ctx, tx, &ProductModerationEvent{
+			ProductID: product.ID,
+			Decision:  ResubmittedModerationDecision,
+			Reason:    "product details updated",
+		})
+
+		if err != nil {
+			return err
+		}
+	}
+
+	if product.StockQuantity == currentStock {
+		return nil
+	}
+
+	err = recordInventoryMovement(ctx, tx, &InventoryMovement{
+		ProductID: product.ID,
+		Kind:      AdjustmentInventoryMovement,
+		Quantity:  product.StockQuantity - currentStock,
+		ActorID:   actorID,
+		Reason:    "product details updated",
+	})
+
+	if err != nil || product.StockQuantity < currentStock {
+		return err
+	}
+
+	return fillProductBackorders(ctx, tx, product.ID)
+}
+
+// productImagesChanged compares image urls with the product's current ones.
+// Only the primary image's position matters.
+func productImagesChanged(ctx context.Context, tx *sql.Tx, productID string, images []*ProductImage) (bool, error) {
+	rows, err := tx.QueryContext(ctx, `SELECT url, is_primary FROM product_images WHERE product_id = $1`, productID)
+	if err != nil {
+		return false, err
+	}
+
+	defer rows.Close()
+
+	var current, updated []string
+
+	for rows.Next() {
+		var (
+			url       string
+			isPrimary sql.NullBool
+		)
+
+		if err := rows.Scan(&url, &isPrimary); err != nil {
+			return false, err
+		}
+
+		current = append(current, fmt.Sprintf("%t:%s", isPrimary.Bool, url))
+	}
+
+	if err := rows.Err(); err != nil {
+		return false, err
+	}
+
+	for _, image := range images {
+		updated = append(updated, fmt.Sprintf("%t:%s", image.IsPrimary, image.URL))
+	}
+
+	slices.Sort(current)
+	slices.Sort(updated)
+
+	return !slices.Equal(current, updated), nil
+}
+
+// productFeaturesChanged compares features with the product's current ones,
+// ignoring their order
+func productFeaturesChanged(ctx context.Context, tx *sql.Tx, productID string, features []*ProductFeature) (bool, error) {
+	rows, err := tx.QueryContext(ctx, `SELECT title, view, feature_entries FROM product_features WHERE product_id = $1`, productID)
+	if err != nil {
+		return false, err
+	}
+
+	defer rows.Close()
+
+	var current, updated []string
+
+	for rows.Next() {
+		var (
+			feature     ProductFeature
+			entriesJSON []byte
+		)
+
+		if err := rows.Scan(&feature.Title, &feature.View, &entriesJSON); err != nil {
+			return false, err
+		}
+
+		if err := json.Unmarshal(entriesJSON, &feature.FeatureEntries); err != nil {
+			return false, fmt.Errorf("failed to parse feature entries: %w", err)
+		}
+
+		key, err := featureKey(&feature)
+		if err != nil {
+			return false, err
+		}
+
+		current = append(current, key)
+	}
+
+	if err := rows.Err(); err != nil {
+		return false, err
+	}
+
+	for _, feature := range features {
+		key, err := featureKey(feature)
+		if err != nil {
+			return false, err
+		}
+
+		updated = append(updated, key)
+	}
+
+	slices.Sort(current)
+	slices.Sort(updated)
+
+	return !slices.Equal(current, updated), nil
+}
+
+func featureKey(feature *ProductFeature) (string, error) {
+	entries, err := json.Marshal(feature.FeatureEntries)
+	if err != nil {
+		return "", fmt.Errorf("failed to serialize feature entries: %w", err)
+	}
+
+	return fmt.Sprintf("%s:%s:%s", feature.Title, feature.View, entries), nil
+}
+
+func (m *ProductModel) GetModeration(ctx context.Context, productID string) (*ProductModeration, error) {
+	query := `SELECT id, name, vendor_id, status, rejection_reason, reviewed_by, reviewed_at, submitted_at
+			  FROM products WHERE id = $1`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	moderation, err := scanProductModeration(m.db.QueryRowContext(ctx, query, productID))
+	if err != nil {
+		return nil, err
+	}
+
+	historyQuery := `SELECT id, product_id, decision, reason, reviewer_id, created_at
+					 FROM product_moderation_events
+					 WHERE product_id = $1
+					 ORDER BY created_at DESC`
+
+	rows, err := m.db.QueryContext(ctx, historyQuery, productID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	moderation.History = []*ProductModerationEvent{}
+
+	for rows.Next() {
+		var (
+			event  = &ProductModerationEvent{}
+			reason sql.NullString
+		)
+
+		if err := rows.Scan(&event.ID, &event.ProductID, &event.Decision, &reason,
+			&event.ReviewerID, &event.CreatedAt); err != nil {
+			return nil, err
+		}
+
+		event.Reason = reason.String
+		moderation.History = append(moderation.History, event)
+	}
+
+	return moderation, rows.Err()
+}
+
+func (m *ProductModel) GetModerationQueue(ctx context.Context, filter PaginateQueryFilter) ([]*ModerationQueueItem, Metadata, error) {
+	query := fmt.Sprintf(`
+		SELECT
+			count(p.id) OVER(),
+			p.id, p.name, p.price, p.category_id, p.vendor_id, v.business_name,
+			EXISTS (
+				SELECT 1 FROM product_moderation_events e
+				WHERE e.product_id = p.id AND e.decision = $3
+			),
+			p.submitted_at,
+			EXTRACT(EPOCH FROM (NOW() - p.submitted_at))::bigint
+		FROM products p
+		JOIN vendor_users v ON v.id = p.vendor_id
+		WHERE p.status = $4
+		ORDER BY p.%s %s
+		LIMIT $1 OFFSET $2
+	`, filter.SortColumn(), filter.SortDirection())
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := m.db.QueryContext(ctx, query, filter.Limit(), filter.Offset(),
+		ResubmittedModerationDecision, PendingProductStatus)
+	if err != nil {
+		return nil, Metadata{}, fmt.Errorf("failed to query moderation queue: %w", err)
+	}
+	defer rows.Close()
+
+	var (
+		items        = []*ModerationQueueItem{}
+		totalRecords int
+		categoryID   sql.NullString
+	)
+
+	for rows.Next() {
+		item := &ModerationQueueItem{}
+
+		err := rows.Scan(&totalRecords, &item.ProductID, &item.Name, &item.Price, &categoryID,
+			&item.VendorID, &item.VendorBusinessName, &item.Resubmission,
+			&item.SubmittedAt, &item.AgeSeconds)
+
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		item.CategoryID = categoryID.String
+		items = append(items, item)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := calculateMetadata(totalRecords, filter.Page, filter.PageSize)
+
+	return items, metadata, nil
+}
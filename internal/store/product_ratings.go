@@ -0,0 +1,84 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+)
+
+// bayesianMinimumVotes is how many average-rated votes every product is
+// assumed to start with when ranking by best_rated, so a single five star
+// review does not outrank hundreds of four star ones.
+const bayesianMinimumVotes = 10
+
+// bestRatedSQL is the Bayesian average of a product aliased as p, pulled
+// towards the catalog-wide mean rating.
+var bestRatedSQL = fmt.Sprintf(`((p.rating_avg * p.rating_count + %[1]d * (
+		SELECT COALESCE(SUM(rating_avg * rating_count) / NULLIF(SUM(rating_count), 0), 0) FROM products
+	)) / (p.rating_count + %[1]d))`, bayesianMinimumVotes)
+
+// productRatingColumns selects the denormalized rating aggregates that
+// ProductRating.dest scans into.
+const productRatingColumns = `p.rating_avg, p.rating_count, p.rating_1_count, p.rating_2_count,
+	p.rating_3_count, p.rating_4_count, p.rating_5_count`
+
+// ProductRating is the aggregate of a product's published reviews, kept up to
+// date by refreshProductRating.
+type ProductRating struct {
+	Average float64
+	Count   int
+	Stars   [5]int
+}
+
+func (r *ProductRating) dest() []any {
+	return []any{&r.Average, &r.Count, &r.Stars[0], &r.Stars[1], &r.Stars[2], &r.Stars[3], &r.Stars[4]}
+}
+
+func (r ProductRating) MarshalJSON() ([]byte, error) {
+	stars := make(map[int]int, len(r.Stars))
+	for i, count := range r.Stars {
+		stars[i+1] = count
+	}
+
+	return json.Marshal(struct {
+		Average float64     `json:"average"`
+		Count   int         `json:"count"`
+		Stars   map[int]int `json:"stars"`
+	}{r.Average, r.Count, stars})
+}
+
+// refreshProductRating recomputes the rating aggregates of a product from its
+// published reviews. It must run in the same transaction as the review change.
+func refreshProductRating(ctx context.Context, tx *sql.Tx, productID string) error {
+	query := `
+		UPDATE products p
+		SET rating_count = s.total,
+			rating_avg = s.average,
+			rating_1_count = s.one,
+			rating_2_count = s.two,
+			rating_3_count = s.three,
+			rating_4_count = s.four,
+			rating_5_count = s.five
+		FROM (
+			SELECT
+				count(*) AS total,
+				COALESCE(avg(rating), 0) AS average,
+				count(*) FILTER (WHERE rating = 1) AS one,
+				count(*) FILTER (WHERE rating = 2) AS two,
+				count(*) FILTER (WHERE rating = 3) AS three,
+				count(*) FILTER (WHERE rating = 4) AS four,
+				count(*) FILTER (WHERE rating = 5) AS five
+			FROM reviews
+			WHERE product_id = $1 AND status = $2
+		) s
+		WHERE p.id = $1
+	`
+
+	_, err := tx.ExecContext(ctx, query, productID, PublishedReviewStatus)
+	if err != nil {
+		return fmt.Errorf("failed to refresh product rating: %w", err)
+	}
+
+	return nil
+}
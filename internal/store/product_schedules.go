@@ -0,0 +1,196 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+var (
+	ErrInvalidPublishWindow = errors.New("unpublish_at must be after publish_at")
+	ErrInvalidSaleWindow    = errors.New("sale_ends_at must be after sale_starts_at")
+	ErrInvalidSalePrice     = errors.New("sale price must be lower than the regular price")
+)
+
+// ScheduleResult reports how many products were touched by a single run of
+// ApplySchedules.
+type ScheduleResult struct {
+	Published    int64 `json:"published"`
+	Unpublished  int64 `json:"unpublished"`
+	SalesStarted int64 `json:"sales_started"`
+	SalesEnded   int64 `json:"sales_ended"`
+	// StartedSaleProductIDs lists the products whose sale began in this run
+	StartedSaleProductIDs []string `json:"-"`
+}
+
+func scheduleConstraintError(err error) error {
+	var pgErr *pq.Error
+	if !errors.As(err, &pgErr) {
+		return err
+	}
+
+	switch pgErr.Constraint {
+	case "products_publish_window_check":
+		return ErrInvalidPublishWindow
+	case "products_sale_window_check":
+		return ErrInvalidSaleWindow
+	case "products_sale_price_check":
+		return ErrInvalidSalePrice
+	default:
+		return err
+	}
+}
+
+func (m *ProductModel) updateSchedule(ctx context.Context, query string, args ...any) (*Product, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	product := &Product{}
+
+	dest := append([]any{&product.ID, &product.Name, &product.Price, &product.Discount,
+		&product.Published, &product.VendorID, &product.UpdatedAt}, product.scheduleDest()...)
+
+	err := m.db.QueryRowContext(ctx, query, args...).Scan(dest...)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, scheduleConstraintError(err)
+		}
+	}
+
+	return product, nil
+}
+
+// SetSchedule replaces the publish and unpublish times of a vendor's product.
+// Passing nil for either clears it.
+func (m *ProductModel) SetSchedule(ctx context.Context, productID, vendorID string, publishAt, unpublishAt *time.Time) (*Product, error) {
+	query := `
+		UPDATE products p
+		SET publish_at = $1, unpublish_at = $2, updated_at = NOW()
+		WHERE p.id = $3 AND p.vendor_id = $4
+		RETURNING p.id, p.name, p.price, p.discount, p.published, p.vendor_id, p.updated_at, ` + productScheduleColumns
+
+	return m.updateSchedule(ctx, query, publishAt, unpublishAt, productID, vendorID)
+}
+
+// SetSale replaces the sale price and window of a vendor's product. A nil
+// sale price removes the sale altogether. A sale that starts later is picked
+// up by ApplySchedules once its start time passes.
+func (m *ProductModel) SetSale(ctx context.Context, productID, vendorID string, salePrice *float64, startsAt, endsAt *time.Time) (*Product, error) {
+	query := `
+		UPDATE products p
+		SET sale_price = $1, sale_starts_at = $2, sale_ends_at = $3, updated_at = NOW(),
+			sale_started = COALESCE($2 <= NOW(), true)
+		WHERE p.id = $4 AND p.vendor_id = $5
+		RETURNING p.id, p.name, p.price, p.discount, p.published, p.vendor_id, p.updated_at, ` + productScheduleColumns
+
+	if salePrice == nil {
+		startsAt, endsAt = nil, nil
+	}
+
+	return m.updateSchedule(ctx, query, salePrice, startsAt, endsAt, productID, vendorID)
+}
+
+// SetBackorder replaces whether a vendor's product keeps selling once out of
+// stock and when such orders are expected to ship. A future releaseAt makes
+// the product a pre-order until that time.
+func (m *ProductModel) SetBackorder(ctx context.Context, productID, vendorID string, allow bool, shipsAt, releaseAt *time.Time) (*Product, error) {
+	query := `
+		UPDATE products p
+		SET allow_backorder = $1, backorder_ships_at = $2, preorder_release_at = $3, updated_at = NOW()
+		WHERE p.id = $4 AND p.vendor_id = $5
+		RETURNING p.id, p.name, p.price, p.discount, p.published, p.vendor_id, p.updated_at, ` + productScheduleColumns
+
+	if !allow {
+		shipsAt = nil
+	}
+
+	return m.updateSchedule(ctx, query, allow, shipsAt, releaseAt, productID, vendorID)
+}
+
+// ApplySchedules publishes and unpublishes products whose scheduled time has
+// passed, marks sales that have begun and clears sales that have ended.
+// Applied schedules are reset so a vendor's later manual change is not
+// overridden.
+func (m *ProductModel) ApplySchedules(ctx context.Context) (*ScheduleResult, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	result := &ScheduleResult{}
+
+	err := withTrx(m.db, ctx, func(tx *sql.Tx) error {
+		steps := []struct {
+			query    string
+			affected *int64
+		}{
+			{
+				query: `UPDATE products
+						SET published = true, publish_at = NULL, updated_at = NOW()
+						WHERE publish_at <= NOW() AND (unpublish_at IS NULL OR unpublish_at > NOW())`,
+				affected: &result.Published,
+			},
+			{
+				query: `UPDATE products
+						SET published = false, publish_at = NULL, unpublish_at = NULL, updated_at = NOW()
+						WHERE unpublish_at <= NOW()`,
+				affected: &result.Unpublished,
+			},
+			{
+				query: `UPDATE products
+						SET sale_price = NULL, sale_starts_at = NULL, sale_ends_at = NULL, sale_started = false, updated_at = NOW()
+						WHERE sale_ends_at <= NOW()`,
+				affected: &result.SalesEnded,
+			},
+		}
+
+		for _, step := range steps {
+			res, err := tx.ExecContext(ctx, step.query)
+			if err != nil {
+				return fmt.Errorf("failed to apply product schedules: %w", err)
+			}
+
+			if *step.affected, err = res.RowsAffected(); err != nil {
+				return err
+			}
+		}
+
+		rows, err := tx.QueryContext(ctx, `
+			UPDATE products
+			SET sale_started = true, updated_at = NOW()
+			WHERE sale_price IS NOT NULL AND NOT sale_started
+				AND sale_starts_at <= NOW() AND (sale_ends_at IS NULL OR sale_ends_at > NOW())
+			RETURNING id`)
+
+		if err != nil {
+			return fmt.Errorf("failed to start product sales: %w", err)
+		}
+
+		defer rows.Close()
+
+		for rows.Next() {
+			var productID string
+
+			if err := rows.Scan(&productID); err != nil {
+				return err
+			}
+
+			result.StartedSaleProductIDs = append(result.StartedSaleProductIDs, productID)
+		}
+
+		result.SalesStarted = int64(len(result.StartedSaleProductIDs))
+
+		return rows.Err()
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
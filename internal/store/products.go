@@ -6,6 +6,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"strings"
 	"sync"
 	"time"
 
@@ -35,21 +36,56 @@ var (
 )
 
 type Product struct {
-	ID                  string            `json:"id"`
-	Name                string            `json:"name"`
-	Description         string            `json:"description"`
-	Images              []*ProductImage   `json:"images"`
-	Features            []*ProductFeature `json:"features,omitempty"`
-	StockQuantity       int               `json:"stock_quantity"`
-	Status              ProductStatus     `json:"status"`
-	Published           bool              `json:"published"`
-	TotalItemsSoldCount int               `json:"total_items_sold_count"`
-	VendorID            string            `json:"vendor_id"`
-	Discount            float64           `json:"discount"`
-	Price               float64           `json:"price"`
-	CategoryID          string            `json:"category_id"`
-	CreatedAt           time.Time         `json:"created_at"`
-	UpdatedAt           time.Time         `json:"updated_at"`
+	ID                  string                   `json:"id"`
+	Name                string                   `json:"name"`
+	Description         string                   `json:"description"`
+	Images              []*ProductImage          `json:"images"`
+	Features            []*ProductFeature        `json:"features,omitempty"`
+	Attributes          []*ProductAttributeValue `json:"attributes,omitempty"`
+	PublishAt           *time.Time               `json:"publish_at"`
+	UnpublishAt         *time.Time               `json:"unpublish_at"`
+	SalePrice           *float64                 `json:"sale_price"`
+	SaleStartsAt        *time.Time               `json:"sale_starts_at"`
+	SaleEndsAt          *time.Time               `json:"sale_ends_at"`
+	EffectivePrice      float64                  `json:"effective_price"`
+	AllowBackorder      bool                     `json:"allow_backorder"`
+	BackorderShipsAt    *time.Time               `json:"backorder_ships_at"`
+	PreorderReleaseAt   *time.Time               `json:"preorder_release_at"`
+	Rating              ProductRating            `json:"rating"`
+	StockQuantity       int                      `json:"stock_quantity"`
+	Status              ProductStatus            `json:"status"`
+	Published           bool                     `json:"published"`
+	TotalItemsSoldCount int                      `json:"total_items_sold_count"`
+	VendorID            string                   `json:"vendor_id"`
+	Discount            float64                  `json:"discount"`
+	Price               float64                  `json:"price"`
+	CategoryID          string                   `json:"category_id"`
+	CreatedAt           time.Time                `json:"created_at"`
+	UpdatedAt           time.Time                `json:"updated_at"`
+}
+
+// effectivePriceSQL resolves the unit price of a product aliased as p: the sale
+// price while its window is open, otherwise the regular price less discount.
+const effectivePriceSQL = `(CASE WHEN p.sale_price IS NOT NULL
+		AND (p.sale_starts_at IS NULL OR p.sale_starts_at <= NOW())
+		AND (p.sale_ends_at IS NULL OR p.sale_ends_at > NOW())
+		THEN p.sale_price ELSE p.price - p.discount END)`
+
+// productScheduleColumns selects the publishing, sale window and backorder
+// columns that scheduleDest scans into.
+const productScheduleColumns = `p.publish_at, p.unpublish_at, p.sale_price, p.sale_starts_at, p.sale_ends_at, ` + effectivePriceSQL + `,
+	p.allow_backorder, p.backorder_ships_at, p.preorder_release_at`
+
+func (p *Product) scheduleDest() []any {
+	return []any{&p.PublishAt, &p.UnpublishAt, &p.SalePrice, &p.SaleStartsAt, &p.SaleEndsAt, &p.EffectivePrice,
+		&p.AllowBackorder, &p.BackorderShipsAt, &p.PreorderReleaseAt}
+}
+
+// AcceptsBackorder reports whether the product may be ordered beyond its
+// stock, either because the vendor allows backorders or because it is a
+// pre-order that has not been released yet.
+func (p *Product) AcceptsBackorder() bool {
+	return p.AllowBackorder || (p.PreorderReleaseAt != nil && p.PreorderReleaseAt.After(time.Now()))
 }
 
 type ProductImage struct {
@@ -92,12 +128,23 @@ type ProductStore interface {
 	Create(ctx context.Context, product *Product) error
 	Publish(ctx context.Context, productID string, vendorID string) error
 	Unpublish(ctx context.Context, productID string, vendorID string) error
-	Reject(ctx context.Context, productID string) error
-	Approve(ctx context.Context, productID string) error
+	Reject(ctx context.Context, productID, reviewerID, reason string) (*ProductModeration, error)
+	Approve(ctx context.Context, productID, reviewerID string) (*ProductModeration, error)
+	Resubmit(ctx context.Context, productID, vendorID string) (*ProductModeration, error)
+	UpdateDetails(ctx context.Context, product *Product) error
+	Import(ctx context.Context, importID string, line int, product *Product) (ProductImportRowStatus, error)
+	GetModeration(ctx context.Context, productID string) (*ProductModeration, error)
+	GetModerationQueue(ctx context.Context, filter PaginateQueryFilter) ([]*ModerationQueueItem, Metadata, error)
 	GetWithDetails(ctx context.Context, productID string) (*Product, error)
+	GetFeatures(ctx context.Context, productID string) ([]*ProductFeature, error)
 	GetProductByID(ctx context.Context, productID string) (*Product, error)
 	GetProductsByIDS(ctx context.Context, ids []string) ([]*Product, error)
 	GetProducts(ctx context.Context, filter PaginateQueryFilter) ([]*Product, Metadata, error)
+	StreamVendorProducts(ctx context.Context, vendorID string, fn func(*Product) error) error
+	SetSchedule(ctx context.Context, productID, vendorID string, publishAt, unpublishAt *time.Time) (*Product, error)
+	SetSale(ctx context.Context, productID, vendorID string, salePrice *float64, startsAt, endsAt *time.Time) (*Product, error)
+	SetBackorder(ctx context.Context, productID, vendorID string, allow bool, shipsAt, releaseAt *time.Time) (*Product, error)
+	ApplySchedules(ctx context.Context) (*ScheduleResult, error)
 }
 
 type ProductModel struct {
@@ -238,24 +285,32 @@ func createProductFeatures(ctx context.Context, tx *sql.Tx, productID string, fe
 
 func (m *ProductModel) Create(ctx context.Context, product *Product) error {
 	return withTrx(m.db, ctx, func(tx *sql.Tx) error {
-		if err := create(ctx, tx, product); err != nil {
-			return err
-		}
+		return createWithDetails(ctx, tx, product)
+	})
+}
 
-		if err := createProductImages(ctx, tx, product.ID, product.Images); err != nil {
-			return err
-		}
+func createWithDetails(ctx context.Context, tx *sql.Tx, product *Product) error {
+	if err := create(ctx, tx, product); err != nil {
+		return err
+	}
 
-		if err := createProductFeatures(ctx, tx, product.ID, product.Features); err != nil {
-			return err
-		}
+	if err := openInventoryLedger(ctx, tx, product); err != nil {
+		return err
+	}
 
-		return nil
-	})
+	if err := createProductImages(ctx, tx, product.ID, product.Images); err != nil {
+		return err
+	}
+
+	if err := createProductFeatures(ctx, tx, product.ID, product.Features); err != nil {
+		return err
+	}
+
+	return createProductAttributeValues(ctx, tx, product.ID, product.Attributes)
 }
 
 func (m *ProductModel) Publish(ctx context.Context, productID string, vendorID string) error {
-	query := `UPDATE products SET published = true WHERE id = $1 AND vendor_id = $2`
+	query := `UPDATE products SET published = true, publish_at = NULL WHERE id = $1 AND vendor_id = $2`
 
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
@@ -280,7 +335,7 @@ func (m *ProductModel) Publish(ctx context.Context, productID string, vendorID s
 }
 
 func (m *ProductModel) Unpublish(ctx context.Context, productID string, vendorID string) error {
-	query := `UPDATE products SET published = false WHERE id = $1 AND vendor_id = $2`
+	query := `UPDATE products SET published = false, unpublish_at = NULL WHERE id = $1 AND vendor_id = $2`
 
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
@@ -303,66 +358,11 @@ func (m *ProductModel) Unpublish(ctx context.Context, productID string, vendorID
 	return nil
 }
 
-func (m *ProductModel) Reject(ctx context.Context, productID string) error {
-	query := `
-		UPDATE products
-		SET status = $1, published = $2
-		WHERE id = $4 AND status = $5
-	`
-
-	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
-	defer cancel()
-
-	updatedAt := time.Now()
-	result, err := m.db.ExecContext(ctx, query, RejectedProductStatus, false, updatedAt, productID, PendingProductStatus)
-	if err != nil {
-		return fmt.Errorf("failed to reject product: %w", err)
-	}
-
-	rowsAffected, err := result.RowsAffected()
-	if err != nil {
-		return fmt.Errorf("failed to check rows affected: %w", err)
-	}
-
-	if rowsAffected == 0 {
-		return ErrRecordNotFound
-	}
-
-	return nil
-}
-
-func (m *ProductModel) Approve(ctx context.Context, productID string) error {
-	query := `
-		UPDATE products
-		SET status = $1
-		WHERE id = $3 AND status = $4
-	`
-
-	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
-	defer cancel()
-
-	updatedAt := time.Now()
-	result, err := m.db.ExecContext(ctx, query, ApprovedProductStatus, updatedAt, productID, PendingProductStatus)
-	if err != nil {
-		return fmt.Errorf("failed to approve product: %w", err)
-	}
-
-	rowsAffected, err := result.RowsAffected()
-	if err != nil {
-		return fmt.Errorf("failed to check rows affected: %w", err)
-	}
-
-	if rowsAffected == 0 {
-		return ErrRecordNotFound
-	}
-
-	return nil
-}
 func (s *ProductModel) GetWithDetails(ctx context.Context, productID string) (*Product, error) {
 	query := `
 		SELECT
 			p.id, p.name, p.description, p.stock_quantity, p.status, p.published, p.discount, p.price, p.category_id,
-			p.total_items_sold_count, p.vendor_id, p.created_at, p.updated_at,
+			p.total_items_sold_count, p.vendor_id, p.created_at, p.updated_at, ` + productScheduleColumns + `, ` + productRatingColumns + `,
 			COALESCE(
 				(SELECT json_agg(DISTINCT jsonb_build_object(
 					'id', pi.id,
@@ -387,7 +387,18 @@ func (s *ProductModel) GetWithDetails(ctx context.Context, productID string) (*P
 				FROM product_features pf
 				WHERE pf.product_id = p.id),
 				'[]'
-			) AS features
+			) AS features,
+			COALESCE(
+				(SELECT json_agg(jsonb_build_object(
+					'attribute_id', pav.attribute_id,
+					'name', ca.name,
+					'value', pav.value_text
+				) ORDER BY ca.name)
+				FROM product_attribute_values pav
+				JOIN category_attributes ca ON ca.id = pav.attribute_id
+				WHERE pav.product_id = p.id),
+				'[]'
+			) AS attributes
 		FROM
 			products p
 			LEFT JOIN category c ON c.id = p.category_id
@@ -396,15 +407,20 @@ func (s *ProductModel) GetWithDetails(ctx context.Context, productID string) (*P
 	`
 	row := s.db.QueryRowContext(ctx, query, productID)
 	var (
-		product     = &Product{}
-		imageJSON   string
-		featureJSON string
+		product       = &Product{}
+		imageJSON     string
+		featureJSON   string
+		attributeJSON string
 	)
-	err := row.Scan(&product.ID, &product.Name, &product.Description,
+	dest := []any{&product.ID, &product.Name, &product.Description,
 		&product.StockQuantity, &product.Status, &product.Published, &product.Discount, &product.Price,
 		&product.CategoryID, &product.TotalItemsSoldCount,
-		&product.VendorID, &product.CreatedAt, &product.UpdatedAt,
-		&imageJSON, &featureJSON)
+		&product.VendorID, &product.CreatedAt, &product.UpdatedAt}
+	dest = append(dest, product.scheduleDest()...)
+	dest = append(dest, product.Rating.dest()...)
+	dest = append(dest, &imageJSON, &featureJSON, &attributeJSON)
+
+	err := row.Scan(dest...)
 	if err != nil {
 		switch {
 		case errors.Is(err, sql.ErrNoRows):
@@ -415,9 +431,53 @@ func (s *ProductModel) GetWithDetails(ctx context.Context, productID string) (*P
 	}
 	product.Images = parseImages(imageJSON)
 	product.Features = parseFeatures(featureJSON)
+	product.Attributes = parseAttributes(attributeJSON)
 	return product, nil
 }
 
+// GetFeatures returns the features of a product regardless of whether its
+// category is visible
+func (s *ProductModel) GetFeatures(ctx context.Context, productID string) ([]*ProductFeature, error) {
+	query := `SELECT id, title, view, feature_entries, product_id
+			  FROM product_features
+			  WHERE product_id = $1`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, productID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	features := []*ProductFeature{}
+
+	for rows.Next() {
+		var (
+			feature     = &ProductFeature{}
+			entriesJSON []byte
+		)
+
+		if err := rows.Scan(&feature.ID, &feature.Title, &feature.View, &entriesJSON, &feature.ProductID); err != nil {
+			return nil, err
+		}
+
+		if err := json.Unmarshal(entriesJSON, &feature.FeatureEntries); err != nil {
+			return nil, fmt.Errorf("failed to parse feature entries: %w", err)
+		}
+
+		features = append(features, feature)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return features, nil
+}
+
 // Helper function to parse images from JSON string
 func parseImages(imagesJSON string) []*ProductImage {
 	var images []*ProductImage
@@ -435,14 +495,24 @@ func parseFeatures(featuresJSON string) []*ProductFeature {
 	}
 	return features
 }
+
+// Helper function to parse typed attribute values from JSON string
+func parseAttributes(attributesJSON string) []*ProductAttributeValue {
+	var attributes []*ProductAttributeValue
+	if err := json.Unmarshal([]byte(attributesJSON), &attributes); err != nil {
+		return nil
+	}
+	return attributes
+}
+
 func (s *ProductModel) GetProducts(ctx context.Context, filter PaginateQueryFilter) ([]*Product, Metadata, error) {
 	// Construct the SQL query with detailed comments explaining each part of the query.
-	query := fmt.Sprintf(`
+	query := `
 		SELECT
 			count(p.id) OVER(), -- Get the total number of records for pagination
 			p.id, p.name, p.description, p.stock_quantity, p.status, p.published,
 			p.discount, p.price, p.category_id, p.total_items_sold_count,
-			p.vendor_id, p.created_at, p.updated_at,
+			p.vendor_id, p.created_at, p.updated_at, ` + productScheduleColumns + `, ` + productRatingColumns + `,
 			COALESCE(
 				(SELECT json_agg(DISTINCT jsonb_build_object(
 					'id', pi.id,
@@ -499,20 +569,41 @@ func (s *ProductModel) GetProducts(ctx context.Context, filter PaginateQueryFilt
 			AND (
 	  			 $5::text[] IS NULL OR p.id = ANY($5::text[])
 			)
-		ORDER BY p.%s %s -- Sort by the specified column and direction
+
+			-- Category filtering: match the category and all of its descendants.
+			AND (
+				$6::text IS NULL OR c.path LIKE (
+					SELECT path FROM category WHERE id = $6 OR slug = $6
+				) || '%%'
+			)
+
+			-- Typed attribute filtering, one EXISTS clause per requested attribute.
+			%s
+		ORDER BY %s %s, p.id -- Sort by the specified column and direction
 		LIMIT $1 OFFSET $2 -- Pagination: limit and offset
-	`, ApprovedProductStatus, filter.SortColumn(), filter.SortDirection())
+	`
+
+	// Extract the filters from the PaginateQueryFilter.
+	dataFilter := filter.Filters.(*modelfilter.GetProductsFilter)
+
+	args := []any{filter.Limit(), filter.Offset(), dataFilter.VendorID, dataFilter.AdminView,
+		pq.Array(dataFilter.ProductIds), dataFilter.Category}
+
+	attributeClauses, args := buildAttributeFilterClauses(dataFilter.Attributes, args)
+
+	sortColumn := "p." + filter.SortColumn()
+	if filter.SortColumn() == "best_rated" {
+		sortColumn = bestRatedSQL
+	}
+
+	query = fmt.Sprintf(query, ApprovedProductStatus, attributeClauses, sortColumn, filter.SortDirection())
 
 	// Set a timeout for the query execution to avoid long-running queries.
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
 
-	// Extract the filters from the PaginateQueryFilter.
-	dataFilter := filter.Filters.(*modelfilter.GetProductsFilter)
-
 	// Execute the query with the provided filters.
-	rows, err := s.db.QueryContext(ctx, query, filter.Limit(), filter.Offset(),
-		dataFilter.VendorID, dataFilter.AdminView, pq.Array(dataFilter.ProductIds))
+	rows, err := s.db.QueryContext(ctx, query, args...)
 
 	if err != nil {
 		return nil, Metadata{}, fmt.Errorf("failed to query products: %w", err)
@@ -531,7 +622,7 @@ func (s *ProductModel) GetProducts(ctx context.Context, filter PaginateQueryFilt
 			imageJSON string
 		)
 
-		err := rows.Scan(
+		dest := []any{
 			&totalRecords,
 			&product.ID,
 			&product.Name,
@@ -546,8 +637,12 @@ func (s *ProductModel) GetProducts(ctx context.Context, filter PaginateQueryFilt
 			&product.VendorID,
 			&product.CreatedAt,
 			&product.UpdatedAt,
-			&imageJSON,
-		)
+		}
+		dest = append(dest, product.scheduleDest()...)
+		dest = append(dest, product.Rating.dest()...)
+		dest = append(dest, &imageJSON)
+
+		err := rows.Scan(dest...)
 
 		if err != nil {
 			return nil, Metadata{}, fmt.Errorf("failed to scan product row: %w", err)
@@ -569,19 +664,56 @@ func (s *ProductModel) GetProducts(ctx context.Context, filter PaginateQueryFilt
 	return products, metadata, nil
 }
 
+// buildAttributeFilterClauses turns attribute filters into EXISTS clauses,
+// appending their values to args so placeholders keep lining up.
+func buildAttributeFilterClauses(filters []*modelfilter.AttributeFilter, args []any) (string, []any) {
+	var clauses []string
+
+	for _, attr := range filters {
+		args = append(args, attr.Name)
+		conditions := []string{fmt.Sprintf("lower(ca.name) = lower($%d)", len(args))}
+
+		if len(attr.Values) != 0 {
+			args = append(args, pq.Array(attr.Values))
+			conditions = append(conditions, fmt.Sprintf("pav.value_text = ANY($%d::text[])", len(args)))
+		}
+
+		if attr.Min != nil {
+			args = append(args, *attr.Min)
+			conditions = append(conditions, fmt.Sprintf("pav.value_number >= $%d", len(args)))
+		}
+
+		if attr.Max != nil {
+			args = append(args, *attr.Max)
+			conditions = append(conditions, fmt.Sprintf("pav.value_number <= $%d", len(args)))
+		}
+
+		clauses = append(clauses, fmt.Sprintf(`AND EXISTS (
+				SELECT 1 FROM product_attribute_values pav
+				JOIN category_attributes ca ON ca.id = pav.attribute_id
+				WHERE pav.product_id = p.id AND %s
+			)`, strings.Join(conditions, " AND ")))
+	}
+
+	return strings.Join(clauses, "\n\t\t\t"), args
+}
+
 func (m *ProductModel) GetProductByID(ctx context.Context, productID string) (*Product, error) {
-	query := `SELECT id, name, description, stock_quantity, status, published, total_items_sold_count,vendor_id,
-			 discount,  price,category_id, created_at, updated_at  FROM products WHERE id = $1`
+	query := `SELECT p.id, p.name, p.description, p.stock_quantity, p.status, p.published, p.total_items_sold_count, p.vendor_id,
+			 p.discount, p.price, p.category_id, p.created_at, p.updated_at, ` + productScheduleColumns + `
+			 FROM products p WHERE p.id = $1`
 
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
 
 	product := &Product{}
 
-	err := m.db.QueryRowContext(ctx, query, productID).Scan(&product.ID, &product.Name, &product.Description,
+	dest := []any{&product.ID, &product.Name, &product.Description,
 		&product.StockQuantity, &product.Status, &product.Published,
 		&product.TotalItemsSoldCount, &product.VendorID, &product.Discount, &product.Price,
-		&product.CategoryID, &product.CreatedAt, &product.UpdatedAt)
+		&product.CategoryID, &product.CreatedAt, &product.UpdatedAt}
+
+	err := m.db.QueryRowContext(ctx, query, productID).Scan(append(dest, product.scheduleDest()...)...)
 
 	if err != nil {
 		switch {
@@ -614,3 +746,74 @@ func (m *ProductModel) GetProductsByIDS(ctx context.Context, ids []string) ([]*P
 
 	return products, nil
 }
+
+// StreamVendorProducts walks every product owned by the vendor, including its
+// images and features, without loading the full catalog into memory.
+func (m *ProductModel) StreamVendorProducts(ctx context.Context, vendorID string, fn func(*Product) error) error {
+	query := `
+		SELECT
+			p.id, p.name, p.description, p.stock_quantity, p.status, p.published, p.discount, p.price, p.category_id,
+			p.total_items_sold_count, p.vendor_id, p.created_at, p.updated_at,
+			COALESCE(
+				(SELECT json_agg(jsonb_build_object(
+					'id', pi.id,
+					'url', pi.url,
+					'is_primary', pi.is_primary,
+					'product_id', pi.product_id,
+					'created_at', pi.created_at,
+					'updated_at', pi.updated_at
+				) ORDER BY pi.is_primary DESC, pi.created_at)
+				FROM product_images pi
+				WHERE pi.product_id = p.id),
+				'[]'
+			) AS images,
+			COALESCE(
+				(SELECT json_agg(jsonb_build_object(
+					'id', pf.id,
+					'title', pf.title,
+					'view', pf.view,
+					'product_id', pf.product_id,
+					'feature_entries', pf.feature_entries
+				))
+				FROM product_features pf
+				WHERE pf.product_id = p.id),
+				'[]'
+			) AS features
+		FROM products p
+		WHERE p.vendor_id = $1
+		ORDER BY p.created_at
+	`
+
+	rows, err := m.db.QueryContext(ctx, query, vendorID)
+	if err != nil {
+		return fmt.Errorf("failed to query vendor products: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			product     = &Product{}
+			imageJSON   string
+			featureJSON string
+		)
+
+		err := rows.Scan(&product.ID, &product.Name, &product.Description,
+			&product.StockQuantity, &product.Status, &product.Published, &product.Discount, &product.Price,
+			&product.CategoryID, &product.TotalItemsSoldCount,
+			&product.VendorID, &product.CreatedAt, &product.UpdatedAt,
+			&imageJSON, &featureJSON)
+
+		if err != nil {
+			return fmt.Errorf("failed to scan product row: %w", err)
+		}
+
+		product.Images = parseImages(imageJSON)
+		product.Features = parseFeatures(featureJSON)
+
+		if err := fn(product); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
@@ -0,0 +1,208 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/devphaseX/buyr-api.git/internal/db"
+)
+
+const MaxReviewMedia = 5
+
+var (
+	ErrReviewMediaLimit = fmt.Errorf("a review can have at most %d images", MaxReviewMedia)
+	ErrOwnReviewVote    = errors.New("you cannot vote on your own review")
+)
+
+// CountMedia returns how many images a review has, so uploads that would
+// exceed MaxReviewMedia can be refused before they are stored.
+func (m *ReviewModel) CountMedia(ctx context.Context, reviewID string) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var count int
+
+	err := m.db.QueryRowContext(ctx, `SELECT count(*) FROM review_media WHERE review_id = $1`, reviewID).Scan(&count)
+	return count, err
+}
+
+// AddMedia attaches uploaded image urls to a review owned by userID, keeping
+// the total under MaxReviewMedia.
+func (m *ReviewModel) AddMedia(ctx context.Context, reviewID, userID string, urls []string) ([]*ReviewMedia, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	media := []*ReviewMedia{}
+
+	err := withTrx(m.db, ctx, func(tx *sql.Tx) error {
+		var existing int
+
+		err := tx.QueryRowContext(ctx, `
+			SELECT (SELECT count(*) FROM review_media WHERE review_id = r.id)
+			FROM reviews r
+			WHERE r.id = $1 AND r.user_id = $2
+			FOR UPDATE`, reviewID, userID).Scan(&existing)
+
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrRecordNotFound
+		case err != nil:
+			return err
+		}
+
+		if existing+len(urls) > MaxReviewMedia {
+			return ErrReviewMediaLimit
+		}
+
+		query := `INSERT INTO review_media(id, review_id, url) VALUES ($1, $2, $3) RETURNING created_at`
+
+		for _, url := range urls {
+			item := &ReviewMedia{
+				ID:       db.GenerateULID(),
+				ReviewID: reviewID,
+				URL:      url,
+			}
+
+			if err := tx.QueryRowContext(ctx, query, item.ID, item.ReviewID, item.URL).Scan(&item.CreatedAt); err != nil {
+				return err
+			}
+
+			media = append(media, item)
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return media, nil
+}
+
+func (m *ReviewModel) RemoveMedia(ctx context.Context, reviewID, mediaID, userID string) error {
+	query := `
+		DELETE FROM review_media rm
+		USING reviews r
+		WHERE rm.id = $1 AND rm.review_id = $2 AND r.id = rm.review_id AND r.user_id = $3
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	result, err := m.db.ExecContext(ctx, query, mediaID, reviewID, userID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// refreshVoteCounts recomputes the denormalized vote counters of a review
+// from review_votes.
+func refreshVoteCounts(ctx context.Context, tx *sql.Tx, reviewID string) (*Review, error) {
+	query := `
+		UPDATE reviews r
+		SET helpful_count = (SELECT count(*) FROM review_votes WHERE review_id = r.id AND helpful),
+			not_helpful_count = (SELECT count(*) FROM review_votes WHERE review_id = r.id AND NOT helpful)
+		WHERE r.id = $1
+		RETURNING ` + reviewColumns
+
+	review := &Review{}
+
+	err := tx.QueryRowContext(ctx, query, reviewID).Scan(review.dest()...)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return review, nil
+}
+
+// Vote records or replaces the user's helpful / not helpful vote on a review.
+func (m *ReviewModel) Vote(ctx context.Context, reviewID, userID string, helpful bool) (*Review, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var review *Review
+
+	err := withTrx(m.db, ctx, func(tx *sql.Tx) error {
+		var authorID string
+
+		err := tx.QueryRowContext(ctx, `SELECT user_id FROM reviews WHERE id = $1 FOR UPDATE`, reviewID).Scan(&authorID)
+
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrRecordNotFound
+		case err != nil:
+			return err
+		case authorID == userID:
+			return ErrOwnReviewVote
+		}
+
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO review_votes(review_id, user_id, helpful) VALUES ($1, $2, $3)
+			ON CONFLICT (review_id, user_id) DO UPDATE SET helpful = EXCLUDED.helpful`,
+			reviewID, userID, helpful)
+
+		if err != nil {
+			return err
+		}
+
+		review, err = refreshVoteCounts(ctx, tx, reviewID)
+		return err
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return review, nil
+}
+
+func (m *ReviewModel) RemoveVote(ctx context.Context, reviewID, userID string) (*Review, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var review *Review
+
+	err := withTrx(m.db, ctx, func(tx *sql.Tx) error {
+		result, err := tx.ExecContext(ctx, `DELETE FROM review_votes WHERE review_id = $1 AND user_id = $2`, reviewID, userID)
+		if err != nil {
+			return err
+		}
+
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+
+		if rowsAffected == 0 {
+			return ErrRecordNotFound
+		}
+
+		review, err = refreshVoteCounts(ctx, tx, reviewID)
+		return err
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return review, nil
+}
@@ -0,0 +1,278 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/devphaseX/buyr-api.git/internal/db"
+	"github.com/lib/pq"
+)
+
+var (
+	ErrDuplicateReviewReport = errors.New("you have already reported this review")
+	ErrOwnReviewReport       = errors.New("you cannot report your own review")
+	ErrNoOpenReviewReports   = errors.New("review has no open reports")
+)
+
+type ReviewStatus string
+
+var (
+	PublishedReviewStatus ReviewStatus = "published"
+	// HeldReviewStatus is set by the content filter; the review is not
+	// listed until a moderator restores it.
+	HeldReviewStatus   ReviewStatus = "held"
+	HiddenReviewStatus ReviewStatus = "hidden"
+)
+
+type ReviewReportReason string
+
+var (
+	SpamReviewReportReason      ReviewReportReason = "spam"
+	OffensiveReviewReportReason ReviewReportReason = "offensive"
+	OffTopicReviewReportReason  ReviewReportReason = "off_topic"
+	FakeReviewReportReason      ReviewReportReason = "fake"
+	OtherReviewReportReason     ReviewReportReason = "other"
+)
+
+type ReviewReportStatus string
+
+var (
+	OpenReviewReportStatus      ReviewReportStatus = "open"
+	ResolvedReviewReportStatus  ReviewReportStatus = "resolved"
+	DismissedReviewReportStatus ReviewReportStatus = "dismissed"
+)
+
+type ReviewModerationAction string
+
+var (
+	HideReviewModerationAction    ReviewModerationAction = "hide"
+	RestoreReviewModerationAction ReviewModerationAction = "restore"
+	DismissReviewModerationAction ReviewModerationAction = "dismiss"
+)
+
+type ReviewReport struct {
+	ID         string             `json:"id"`
+	ReviewID   string             `json:"review_id"`
+	ReporterID string             `json:"reporter_id"`
+	Reason     ReviewReportReason `json:"reason"`
+	Details    string             `json:"details,omitempty"`
+	Status     ReviewReportStatus `json:"status"`
+	CreatedAt  time.Time          `json:"created_at"`
+}
+
+// ReviewModerationItem is a review waiting for a moderator, either because
+// the content filter held it or because users reported it.
+type ReviewModerationItem struct {
+	ReviewID         string       `json:"review_id"`
+	ProductID        string       `json:"product_id"`
+	ProductName      string       `json:"product_name"`
+	UserID           string       `json:"user_id"`
+	Rating           int          `json:"rating"`
+	Comment          string       `json:"comment"`
+	Status           ReviewStatus `json:"status"`
+	ModerationReason *string      `json:"moderation_reason"`
+	OpenReports      int          `json:"open_reports"`
+	ReportReasons    []string     `json:"report_reasons"`
+	LastReportedAt   *time.Time   `json:"last_reported_at"`
+	CreatedAt        time.Time    `json:"created_at"`
+}
+
+type ReviewModeration struct {
+	Review        *Review                `json:"review"`
+	Action        ReviewModerationAction `json:"action"`
+	ReportsClosed int64                  `json:"reports_closed"`
+}
+
+// Report files an abuse report against a published review.
+func (m *ReviewModel) Report(ctx context.Context, report *ReviewReport) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	return withTrx(m.db, ctx, func(tx *sql.Tx) error {
+		var authorID string
+
+		err := tx.QueryRowContext(ctx, `SELECT user_id FROM reviews WHERE id = $1 AND status = $2`,
+			report.ReviewID, PublishedReviewStatus).Scan(&authorID)
+
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrRecordNotFound
+		case err != nil:
+			return err
+		case authorID == report.ReporterID:
+			return ErrOwnReviewReport
+		}
+
+		query := `INSERT INTO review_reports(id, review_id, reporter_id, reason, details, status)
+				  VALUES ($1, $2, $3, $4, $5, $6)
+				  RETURNING created_at`
+
+		report.ID = db.GenerateULID()
+		report.Status = OpenReviewReportStatus
+
+		err = tx.QueryRowContext(ctx, query, report.ID, report.ReviewID, report.ReporterID, report.Reason,
+			sql.NullString{String: report.Details, Valid: report.Details != ""}, report.Status).
+			Scan(&report.CreatedAt)
+
+		if err != nil {
+			var pgErr *pq.Error
+			switch {
+			case errors.As(err, &pgErr) && pgErr.Constraint == "review_reports_review_id_reporter_id_key":
+				return ErrDuplicateReviewReport
+			default:
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+func (m *ReviewModel) GetModerationQueue(ctx context.Context, filter PaginateQueryFilter) ([]*ReviewModerationItem, Metadata, error) {
+	sortColumn := "r." + filter.SortColumn()
+	if filter.SortColumn() == "open_reports" {
+		sortColumn = "rep.open_reports"
+	}
+
+	query := fmt.Sprintf(`
+		SELECT
+			count(r.id) OVER(),
+			r.id, r.product_id, p.name, r.user_id, r.rating, r.comment, r.status, r.moderation_reason,
+			rep.open_reports, COALESCE(rep.reasons, '{}'), rep.last_reported_at, r.created_at
+		FROM reviews r
+		JOIN products p ON p.id = r.product_id
+		CROSS JOIN LATERAL (
+			SELECT count(*) AS open_reports,
+				array_agg(DISTINCT reason) AS reasons,
+				max(created_at) AS last_reported_at
+			FROM review_reports
+			WHERE review_id = r.id AND status = $3
+		) rep
+		WHERE r.status = $4 OR rep.open_reports > 0
+		ORDER BY %s %s, r.id
+		LIMIT $1 OFFSET $2
+	`, sortColumn, filter.SortDirection())
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := m.db.QueryContext(ctx, query, filter.Limit(), filter.Offset(),
+		OpenReviewReportStatus, HeldReviewStatus)
+	if err != nil {
+		return nil, Metadata{}, fmt.Errorf("failed to query review moderation queue: %w", err)
+	}
+	defer rows.Close()
+
+	var (
+		items        = []*ReviewModerationItem{}
+		totalRecords int
+	)
+
+	for rows.Next() {
+		item := &ReviewModerationItem{}
+
+		err := rows.Scan(&totalRecords, &item.ReviewID, &item.ProductID, &item.ProductName, &item.UserID,
+			&item.Rating, &item.Comment, &item.Status, &item.ModerationReason, &item.OpenReports,
+			pq.Array(&item.ReportReasons), &item.LastReportedAt, &item.CreatedAt)
+
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		items = append(items, item)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := calculateMetadata(totalRecords, filter.Page, filter.PageSize)
+
+	return items, metadata, nil
+}
+
+// Moderate applies a moderator decision to a review. Hiding resolves the
+// open reports, restoring publishes the review and dismisses them, and
+// dismissing only closes the reports.
+func (m *ReviewModel) Moderate(ctx context.Context, reviewID, adminID string, action ReviewModerationAction, reason string) (*ReviewModeration, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	moderation := &ReviewModeration{
+		Review: &Review{},
+		Action: action,
+	}
+
+	var (
+		reviewQuery  string
+		reviewArgs   []any
+		reportStatus = DismissedReviewReportStatus
+	)
+
+	switch action {
+	case HideReviewModerationAction:
+		reviewQuery = `
+			UPDATE reviews r
+			SET status = $1, moderation_reason = $2, moderated_by = $3, moderated_at = NOW()
+			WHERE r.id = $4
+			RETURNING ` + reviewColumns
+		reviewArgs = []any{HiddenReviewStatus, sql.NullString{String: reason, Valid: reason != ""}, adminID, reviewID}
+		reportStatus = ResolvedReviewReportStatus
+	case RestoreReviewModerationAction:
+		reviewQuery = `
+			UPDATE reviews r
+			SET status = $1, moderation_reason = NULL, moderated_by = $2, moderated_at = NOW()
+			WHERE r.id = $3
+			RETURNING ` + reviewColumns
+		reviewArgs = []any{PublishedReviewStatus, adminID, reviewID}
+	case DismissReviewModerationAction:
+		reviewQuery = `SELECT ` + reviewColumns + ` FROM reviews r WHERE r.id = $1 FOR UPDATE`
+		reviewArgs = []any{reviewID}
+	default:
+		return nil, fmt.Errorf("unknown review moderation action %q", action)
+	}
+
+	err := withTrx(m.db, ctx, func(tx *sql.Tx) error {
+		err := tx.QueryRowContext(ctx, reviewQuery, reviewArgs...).Scan(moderation.Review.dest()...)
+		if err != nil {
+			switch {
+			case errors.Is(err, sql.ErrNoRows):
+				return ErrRecordNotFound
+			default:
+				return err
+			}
+		}
+
+		result, err := tx.ExecContext(ctx, `
+			UPDATE review_reports
+			SET status = $1, resolved_by = $2, resolved_at = NOW()
+			WHERE review_id = $3 AND status = $4`,
+			reportStatus, adminID, reviewID, OpenReviewReportStatus)
+
+		if err != nil {
+			return err
+		}
+
+		if moderation.ReportsClosed, err = result.RowsAffected(); err != nil {
+			return err
+		}
+
+		if action == DismissReviewModerationAction {
+			if moderation.ReportsClosed == 0 {
+				return ErrNoOpenReviewReports
+			}
+			return nil
+		}
+
+		return refreshProductRating(ctx, tx, moderation.Review.ProductID)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return moderation, nil
+}
@@ -3,25 +3,63 @@ package store
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"time"
 
 	"github.com/devphaseX/buyr-api.git/internal/db"
+	"github.com/devphaseX/buyr-api.git/internal/store/modelfilter"
+	"github.com/lib/pq"
+)
+
+var (
+	ErrReviewRequiresPurchase = errors.New("only customers with a delivered order for this product can review it")
+	ErrDuplicateReview        = errors.New("you have already reviewed this product")
+	ErrReviewAlreadyReplied   = errors.New("this review already has a vendor reply")
 )
 
 type Review struct {
+	ID               string       `json:"id"`
+	UserID           string       `json:"user_id"`
+	ProductID        string       `json:"product_id"`
+	OrderItemID      *string      `json:"-"`
+	Rating           int          `json:"rating"`
+	Comment          string       `json:"comment"`
+	Status           ReviewStatus `json:"status"`
+	ModerationReason *string      `json:"moderation_reason,omitempty"`
+	VerifiedPurchase bool         `json:"verified_purchase"`
+	HelpfulCount     int          `json:"helpful_count"`
+	NotHelpfulCount  int          `json:"not_helpful_count"`
+	EditedAt         *time.Time   `json:"edited_at"`
+	VendorReply      *string      `json:"vendor_reply"`
+	VendorRepliedAt  *time.Time   `json:"vendor_replied_at"`
+	CreatedAt        time.Time    `json:"created_at"`
+	UpdatedAt        time.Time    `json:"updated_at"`
+}
+
+type ReviewMedia struct {
 	ID        string    `json:"id"`
-	UserID    string    `json:"user_id"`
-	ProductID string    `json:"product_id"`
-	Rating    int       `json:"rating"`
-	Comment   string    `json:"comment"`
+	ReviewID  string    `json:"review_id"`
+	URL       string    `json:"url"`
 	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
 }
 
 type ReviewStore interface {
 	Create(ctx context.Context, review *Review) error
+	Update(ctx context.Context, review *Review) error
 	Delete(ctx context.Context, productID, reviewID string) error
+	DeleteByAuthor(ctx context.Context, productID, reviewID, userID string) error
+	Reply(ctx context.Context, productID, reviewID, vendorID, reply string) (*Review, error)
+	GetByID(ctx context.Context, productID, reviewID string) (*Review, error)
+	CountMedia(ctx context.Context, reviewID string) (int, error)
+	AddMedia(ctx context.Context, reviewID, userID string, urls []string) ([]*ReviewMedia, error)
+	RemoveMedia(ctx context.Context, reviewID, mediaID, userID string) error
+	Vote(ctx context.Context, reviewID, userID string, helpful bool) (*Review, error)
+	RemoveVote(ctx context.Context, reviewID, userID string) (*Review, error)
+	Report(ctx context.Context, report *ReviewReport) error
+	GetModerationQueue(ctx context.Context, filter PaginateQueryFilter) ([]*ReviewModerationItem, Metadata, error)
+	Moderate(ctx context.Context, reviewID, adminID string, action ReviewModerationAction, reason string) (*ReviewModeration, error)
 	GetByProductID(ctx context.Context, productID string, filter PaginateQueryFilter) ([]*ReviewWithDetails, Metadata, error)
 	GetReviewRatingAnalytics(ctx context.Context, productID string) (*ReviewRatingAnalytics, error)
 }
@@ -33,50 +71,223 @@ func NewReviewModel(db *sql.DB) ReviewStore {
 	return &ReviewModel{db}
 }
 
+const reviewColumns = `r.id, r.user_id, r.product_id, r.order_item_id, r.rating, r.comment, r.status, r.moderation_reason,
+	r.verified_purchase, r.helpful_count, r.not_helpful_count, r.edited_at, r.vendor_reply, r.vendor_replied_at, r.created_at, r.updated_at`
+
+func (r *Review) dest() []any {
+	return []any{&r.ID, &r.UserID, &r.ProductID, &r.OrderItemID, &r.Rating, &r.Comment, &r.Status, &r.ModerationReason,
+		&r.VerifiedPurchase, &r.HelpfulCount, &r.NotHelpfulCount, &r.EditedAt, &r.VendorReply, &r.VendorRepliedAt, &r.CreatedAt, &r.UpdatedAt}
+}
+
+// Create stores a review against the most recent delivered order item the
+// user has for the product. Users without one get ErrReviewRequiresPurchase.
 func (m *ReviewModel) Create(ctx context.Context, review *Review) error {
-	query := `INSERT INTO reviews(id, user_id, product_id, rating, comment) VALUES($1, $2, $3, $4, $5) RETURNING created_at, updated_at`
+	purchaseQuery := `
+		SELECT oi.id
+		FROM order_items oi
+		JOIN orders o ON o.id = oi.order_id
+		WHERE o.user_id = $1 AND oi.product_id = $2 AND o.status = $3
+		ORDER BY o.created_at DESC
+		LIMIT 1
+	`
+
+	query := `INSERT INTO reviews(id, user_id, product_id, order_item_id, verified_purchase, rating, comment,
+			  status, moderation_reason)
+			  VALUES($1, $2, $3, $4, true, $5, $6, $7, $8)
+			  RETURNING verified_purchase, created_at, updated_at`
 
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 
 	defer cancel()
 
+	var orderItemID string
+
+	err := m.db.QueryRowContext(ctx, purchaseQuery, review.UserID, review.ProductID, DeliveredOrderStatus).Scan(&orderItemID)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrReviewRequiresPurchase
+		default:
+			return err
+		}
+	}
+
 	review.ID = db.GenerateULID()
+	review.OrderItemID = &orderItemID
+
+	if review.Status == "" {
+		review.Status = PublishedReviewStatus
+	}
+
+	args := []any{review.ID, review.UserID, review.ProductID, orderItemID, review.Rating, review.Comment,
+		review.Status, review.ModerationReason}
+
+	return withTrx(m.db, ctx, func(tx *sql.Tx) error {
+		err := tx.QueryRowContext(ctx, query, args...).Scan(&review.VerifiedPurchase, &review.CreatedAt, &review.UpdatedAt)
+
+		if err != nil {
+			var pgErr *pq.Error
+			switch {
+			case errors.As(err, &pgErr) && pgErr.Constraint == "reviews_user_id_product_id_key":
+				return ErrDuplicateReview
+			default:
+				return err
+			}
+		}
+
+		return refreshProductRating(ctx, tx, review.ProductID)
+	})
+}
+
+// Update changes the rating and comment of a review. Only the author can edit
+// their review. A review hidden by a moderator stays hidden; otherwise the
+// status passed in (published or held by the content filter) is applied.
+func (m *ReviewModel) Update(ctx context.Context, review *Review) error {
+	query := `
+		UPDATE reviews r
+		SET rating = $1, comment = $2, edited_at = NOW(),
+			status = CASE WHEN r.status = $6 THEN r.status ELSE $7 END,
+			moderation_reason = CASE WHEN r.status = $6 THEN r.moderation_reason ELSE $8 END
+		WHERE r.id = $3 AND r.product_id = $4 AND r.user_id = $5
+		RETURNING ` + reviewColumns
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	if review.Status == "" {
+		review.Status = PublishedReviewStatus
+	}
 
-	args := []any{review.ID, review.UserID, review.ProductID, review.Rating, review.Comment}
+	return withTrx(m.db, ctx, func(tx *sql.Tx) error {
+		err := tx.QueryRowContext(ctx, query, review.Rating, review.Comment,
+			review.ID, review.ProductID, review.UserID, HiddenReviewStatus,
+			review.Status, review.ModerationReason).Scan(review.dest()...)
+
+		if err != nil {
+			switch {
+			case errors.Is(err, sql.ErrNoRows):
+				return ErrRecordNotFound
+			default:
+				return err
+			}
+		}
+
+		return refreshProductRating(ctx, tx, review.ProductID)
+	})
+}
+
+func (m *ReviewModel) GetByID(ctx context.Context, productID, reviewID string) (*Review, error) {
+	query := `SELECT ` + reviewColumns + ` FROM reviews r WHERE r.id = $1 AND r.product_id = $2`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
 
-	err := m.db.QueryRowContext(ctx, query, args...).Scan(&review.CreatedAt, &review.UpdatedAt)
+	review := &Review{}
 
+	err := m.db.QueryRowContext(ctx, query, reviewID, productID).Scan(review.dest()...)
 	if err != nil {
-		return err
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return review, nil
+}
+
+// Reply attaches the owning vendor's public reply to a review. A review can
+// only be replied to once.
+func (m *ReviewModel) Reply(ctx context.Context, productID, reviewID, vendorID, reply string) (*Review, error) {
+	query := `
+		UPDATE reviews r
+		SET vendor_reply = $1, vendor_replied_at = NOW()
+		FROM products p
+		WHERE r.id = $2 AND r.product_id = $3 AND p.id = r.product_id AND p.vendor_id = $4
+			AND r.vendor_reply IS NULL
+		RETURNING ` + reviewColumns
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	review := &Review{}
+
+	err := m.db.QueryRowContext(ctx, query, reply, reviewID, productID, vendorID).Scan(review.dest()...)
+	if err == nil {
+		return review, nil
+	}
+
+	if !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
 	}
 
-	return nil
+	var replied bool
+	err = m.db.QueryRowContext(ctx, `
+		SELECT r.vendor_reply IS NOT NULL
+		FROM reviews r
+		JOIN products p ON p.id = r.product_id
+		WHERE r.id = $1 AND r.product_id = $2 AND p.vendor_id = $3`,
+		reviewID, productID, vendorID).Scan(&replied)
+
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return nil, ErrRecordNotFound
+	case err != nil:
+		return nil, err
+	case replied:
+		return nil, ErrReviewAlreadyReplied
+	default:
+		return nil, ErrRecordNotFound
+	}
 }
 
 type ReviewWithDetails struct {
 	Review
-	Username  string `json:"username"`
-	AvatarURL string `json:"avatar_url"`
+	Username  string         `json:"username"`
+	AvatarURL string         `json:"avatar_url"`
+	Media     []*ReviewMedia `json:"media"`
 }
 
 func (m *ReviewModel) GetByProductID(ctx context.Context, productID string, filter PaginateQueryFilter) ([]*ReviewWithDetails, Metadata, error) {
 	query := fmt.Sprintf(`
-			  SELECT count(r.id) over(), r.id, r.user_id, r.product_id, r.rating,
-			  r.comment, r.created_at, r.updated_at, nu.first_name || ' ' || nu.last_name,
-			  u.avatar_url
+			  SELECT count(r.id) over(), `+reviewColumns+`,
+			  nu.first_name || ' ' || nu.last_name, u.avatar_url,
+			  COALESCE(
+				(SELECT json_agg(json_build_object(
+					'id', rm.id,
+					'review_id', rm.review_id,
+					'url', rm.url,
+					'created_at', rm.created_at
+				) ORDER BY rm.created_at)
+				FROM review_media rm WHERE rm.review_id = r.id),
+				'[]'
+			  )
 		      FROM reviews r
 			  INNER JOIN users u ON u.id = r.user_id
 			  INNER JOIN normal_users nu on nu.user_id = u.id
-			  WHERE r.product_id = $1
-			  ORDER BY r.%s %s
+			  WHERE r.product_id = $1 AND r.status = $6
+			  AND ($4::int IS NULL OR r.rating = $4)
+			  AND (NOT $5 OR EXISTS (SELECT 1 FROM review_media rm WHERE rm.review_id = r.id))
+			  ORDER BY r.%s %s, r.id DESC
 			  LIMIT $2 OFFSET $3
 					`, filter.SortColumn(), filter.SortDirection())
 
+	var (
+		rating    *int
+		withMedia bool
+	)
+
+	if dataFilter, ok := filter.Filters.(*modelfilter.GetReviewsFilter); ok {
+		rating = dataFilter.Rating
+		withMedia = dataFilter.WithMedia
+	}
+
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 
 	defer cancel()
 
-	rows, err := m.db.QueryContext(ctx, query, productID, filter.Limit(), filter.Offset())
+	rows, err := m.db.QueryContext(ctx, query, productID, filter.Limit(), filter.Offset(), rating, withMedia, PublishedReviewStatus)
 
 	if err != nil {
 		return nil, Metadata{}, fmt.Errorf("failed to query comments: %w", err)
@@ -94,13 +305,13 @@ func (m *ReviewModel) GetByProductID(ctx context.Context, productID string, filt
 		var (
 			review    = &ReviewWithDetails{}
 			avatarURL sql.NullString
+			mediaJSON []byte
 		)
 
-		err := rows.Scan(
-			&totalRecords, &review.ID, &review.UserID, &review.ProductID,
-			&review.Rating, &review.Comment, &review.CreatedAt, &review.UpdatedAt,
-			&review.Username, &avatarURL,
-		)
+		dest := append([]any{&totalRecords}, review.dest()...)
+		dest = append(dest, &review.Username, &avatarURL, &mediaJSON)
+
+		err := rows.Scan(dest...)
 
 		if err != nil {
 			return nil, Metadata{}, err
@@ -110,6 +321,10 @@ func (m *ReviewModel) GetByProductID(ctx context.Context, productID string, filt
 			review.AvatarURL = avatarURL.String
 		}
 
+		if err := json.Unmarshal(mediaJSON, &review.Media); err != nil {
+			return nil, Metadata{}, fmt.Errorf("failed to unmarshal review media: %w", err)
+		}
+
 		reviews = append(reviews, review)
 	}
 
@@ -128,24 +343,40 @@ func (m *ReviewModel) Delete(ctx context.Context, productID, reviewID string) er
 			WHERE id = $1 and product_id = $2
 		`
 
+	return m.delete(ctx, productID, query, reviewID, productID)
+}
+
+// DeleteByAuthor removes a review on behalf of the user who wrote it.
+func (m *ReviewModel) DeleteByAuthor(ctx context.Context, productID, reviewID, userID string) error {
+	query := `DELETE FROM reviews WHERE id = $1 AND product_id = $2 AND user_id = $3`
+
+	return m.delete(ctx, productID, query, reviewID, productID, userID)
+}
+
+// delete runs a review delete query and refreshes the product rating in the
+// same transaction.
+func (m *ReviewModel) delete(ctx context.Context, productID, query string, args ...any) error {
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
-	result, err := m.db.ExecContext(ctx, query, reviewID, productID)
-	if err != nil {
-		return err
-	}
 
-	// Check if the review was actually deleted
-	rowsAffected, err := result.RowsAffected()
-	if err != nil {
-		return err
-	}
+	return withTrx(m.db, ctx, func(tx *sql.Tx) error {
+		result, err := tx.ExecContext(ctx, query, args...)
+		if err != nil {
+			return err
+		}
 
-	if rowsAffected == 0 {
-		return ErrRecordNotFound
-	}
+		// Check if the review was actually deleted
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+
+		if rowsAffected == 0 {
+			return ErrRecordNotFound
+		}
 
-	return nil
+		return refreshProductRating(ctx, tx, productID)
+	})
 }
 
 type ReviewRatingAnalytics struct {
@@ -154,62 +385,36 @@ type ReviewRatingAnalytics struct {
 	TotalReviewsCount int64         `json:"total_reviews_count"`
 }
 
+// GetReviewRatingAnalytics reads the rating aggregates maintained on the
+// product row.
 func (m *ReviewModel) GetReviewRatingAnalytics(ctx context.Context, productID string) (*ReviewRatingAnalytics, error) {
-	query := `
-	select r.rating, count(rs.id)
-		as count  from
-		(
-			select unnest(array[1, 2, 3, 4, 5]) as rating
-		) as r
-		LEFT JOIN reviews rs ON rs.rating = r.rating AND rs.product_id = $1
-		GROUP BY r.rating
-		ORDER BY r.rating DESC;
-
-	`
+	query := `SELECT ` + productRatingColumns + ` FROM products p WHERE p.id = $1`
 
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 
 	defer cancel()
 
-	rows, err := m.db.QueryContext(ctx, query, productID)
+	var rating ProductRating
 
+	err := m.db.QueryRowContext(ctx, query, productID).Scan(rating.dest()...)
 	if err != nil {
-		return nil, err
-	}
-
-	defer rows.Close()
-
-	analytics := &ReviewRatingAnalytics{
-		Ratings: make(map[int]int64),
-	}
-
-	var (
-		totalSum   int64
-		totalCount int64
-	)
-
-	for rows.Next() {
-		var (
-			rating int
-			count  int64
-		)
-
-		if err := rows.Scan(&rating, &count); err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
 			return nil, err
 		}
+	}
 
-		analytics.Ratings[rating] = count
-
-		totalSum += int64(rating) * count
-		totalCount += count
-		analytics.TotalReviewsCount = totalCount
-
+	analytics := &ReviewRatingAnalytics{
+		Ratings:           make(map[int]int64),
+		TotalAverageRate:  rating.Average,
+		TotalReviewsCount: int64(rating.Count),
 	}
 
-	if totalCount > 0 {
-		analytics.TotalAverageRate = float64(totalSum) / float64(totalCount)
+	for i, count := range rating.Stars {
+		analytics.Ratings[i+1] = int64(count)
 	}
 
 	return analytics, nil
-
 }
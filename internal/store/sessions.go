@@ -3,6 +3,7 @@ package store
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"time"
 
@@ -22,12 +23,15 @@ type Session struct {
 	UpdatedAt          time.Time  `json:"updated_at"`
 	RememberMe         bool       `json:"remember_me"`          // Whether the session should be extended
 	MaxRenewalDuration int64      `json:"max_renewal_duration"` // Maximum duration for session renewal (in seconds)
+	Current            bool       `json:"current"`              // Whether the session made the request
 }
 
 type SessionStore interface {
 	Create(ctx context.Context, session *Session) error
 	ValidateSession(ctx context.Context, sessionID string, version int) (*Session, *User, bool, error)
 	InvalidateSession(ctx context.Context, sessionID string) error
+	InvalidateUserSession(ctx context.Context, userID, sessionID string) error
+	InvalidateOtherSessions(ctx context.Context, userID, keepSessionID string) ([]string, error)
 	GetSessionByID(ctx context.Context, sessionID string) (*Session, *User, error)
 	UpdateLastUsed(ctx context.Context, sessionID string, IP string) error
 	GetSessionsByUserID(
@@ -43,6 +47,7 @@ type SessionStore interface {
 		tokenMaker auth.AuthToken,
 		rememberPeriod time.Duration,
 	) (string, error)
+	RotateRefreshToken(ctx context.Context, session *Session, tokenMaker auth.AuthToken) (string, error)
 }
 
 type SessionModel struct {
@@ -88,6 +93,12 @@ func (s *SessionModel) ValidateSession(ctx context.Context, sessionID string, ve
 		return nil, nil, false, nil
 	}
 
+	// Every refresh rotates the token, so an older version can only come
+	// from a token that was already used once: someone else has a copy.
+	if version < session.Version {
+		return session, user, false, ErrRefreshTokenReused
+	}
+
 	if session.Version != version {
 		return nil, nil, false, nil
 	}
@@ -132,6 +143,60 @@ func (s *SessionModel) InvalidateSession(ctx context.Context, sessionID string)
 	return err
 }
 
+// InvalidateUserSession deletes one of a user's sessions. Sessions belonging
+// to someone else are reported as not found.
+func (s *SessionModel) InvalidateUserSession(ctx context.Context, userID, sessionID string) error {
+	query := `DELETE FROM sessions WHERE id = $1 AND user_id = $2`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	result, err := s.db.ExecContext(ctx, query, sessionID, userID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// InvalidateOtherSessions deletes every session of a user except the one
+// given and returns the ids of the sessions removed.
+func (s *SessionModel) InvalidateOtherSessions(ctx context.Context, userID, keepSessionID string) ([]string, error) {
+	query := `DELETE FROM sessions WHERE user_id = $1 AND id <> $2 RETURNING id`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userID, keepSessionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sessionIDs []string
+
+	for rows.Next() {
+		var sessionID string
+
+		if err := rows.Scan(&sessionID); err != nil {
+			return nil, err
+		}
+
+		sessionIDs = append(sessionIDs, sessionID)
+	}
+
+	return sessionIDs, rows.Err()
+}
+
 func (s *SessionModel) GetSessionByID(ctx context.Context, sessionID string) (*Session, *User, error) {
 	var session Session
 	var user User
@@ -296,6 +361,9 @@ func (s *SessionModel) ExtendSessionAndGenerateRefreshToken(
 	updateQuery := `UPDATE sessions SET expires_at = $1, version = version + 1 WHERE id = $2 AND version = $3 RETURNING version`
 	err := s.db.QueryRowContext(ctx, updateQuery, newExpiresAt, session.ID, session.Version).Scan(&version)
 	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", ErrRefreshTokenReused
+		}
 		return "", fmt.Errorf("failed to extend session: %w", err)
 	}
 
@@ -307,11 +375,42 @@ func (s *SessionModel) ExtendSessionAndGenerateRefreshToken(
 
 	// Update the session's ExpiresAt field in memory
 	session.ExpiresAt = newExpiresAt
+	session.Version = version
 
 	// Return the new refresh token (unhashed) to the client
 	return newRefreshToken, nil
 }
 
+// RotateRefreshToken replaces the session's refresh token without extending
+// the session. The version only moves forward from the one the presented token
+// carried, so a concurrent refresh with the same token loses and is reported as
+// reuse.
+func (s *SessionModel) RotateRefreshToken(ctx context.Context, session *Session, tokenMaker auth.AuthToken) (string, error) {
+	query := `UPDATE sessions SET version = version + 1 WHERE id = $1 AND version = $2 RETURNING version`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var version int
+
+	err := s.db.QueryRowContext(ctx, query, session.ID, session.Version).Scan(&version)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", ErrRefreshTokenReused
+		}
+		return "", fmt.Errorf("failed to rotate refresh token: %w", err)
+	}
+
+	refreshToken, err := tokenMaker.GenerateRefreshToken(session.ID, version, time.Until(session.ExpiresAt))
+	if err != nil {
+		return "", fmt.Errorf("failed to generate refresh token: %w", err)
+	}
+
+	session.Version = version
+
+	return refreshToken, nil
+}
+
 func (s *SessionModel) UpdateLastUsed(ctx context.Context, sessionID, IP string) error {
 	query := `UPDATE sessions SET last_used = NOW(), ip = $2 WHERE id = $1`
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
@@ -14,44 +14,66 @@ var (
 	ErrRecordNotFound         = errors.New("record not found")
 	ErrDuplicateEmail         = errors.New("the email address is already in use. Please use a different email.")
 	ErrSessionCannotBeExtends = errors.New("session cannot be extended")
+	ErrRefreshTokenReused     = errors.New("refresh token has already been used")
+	ErrDuplicateAccount       = errors.New("an account from this provider is already linked")
 	ErrUnknownUserRole        = fmt.Errorf("unknown user role")
 )
 
 type Storage struct {
-	Users      UserStorage
-	Sessions   SessionStore
-	Category   CategoryStore
-	Products   ProductStore
-	Reviews    ReviewStore
-	Carts      CartStore
-	CartItems  CartItemStore
-	Wishlists  WhitelistStore
-	AuditLogs  AuditEventStore
-	Orders     OrderStore
-	OrderItems OrderItemStore
-	Payments   PaymentStore
-	Promos     PromoStore
-	Address    AddressStore
-	OptionType OptionTypeStore
+	Users               UserStorage
+	Sessions            SessionStore
+	Category            CategoryStore
+	Products            ProductStore
+	Reviews             ReviewStore
+	Carts               CartStore
+	CartItems           CartItemStore
+	Wishlists           WhitelistStore
+	AuditLogs           AuditEventStore
+	Orders              OrderStore
+	OrderItems          OrderItemStore
+	Payments            PaymentStore
+	Promos              PromoStore
+	Address             AddressStore
+	OptionType          OptionTypeStore
+	ProductImports      ProductImportStore
+	CategoryAttributes  CategoryAttributeStore
+	Notifications       NotificationStore
+	WishlistCollections WishlistCollectionStore
+	Inventory           InventoryStore
+	Warehouses          WarehouseStore
+	WebAuthnCredentials WebAuthnCredentialStore
+	APIKeys             APIKeyStore
+	OAuthClients        OAuthClientStore
+	OAuthGrants         OAuthGrantStore
 }
 
 func NewStorage(db *sql.DB) *Storage {
 	return &Storage{
-		Users:      NewUserModel(db),
-		Sessions:   NewSessionModel(db),
-		Category:   NewCategoryModel(db),
-		Products:   NewProductModel(db),
-		Reviews:    NewReviewModel(db),
-		Carts:      NewCartModel(db),
-		CartItems:  NewCartItemModel(db),
-		Wishlists:  NewWishlistModel(db),
-		AuditLogs:  NewAuditEventModel(db),
-		Orders:     NewOrderModel(db),
-		OrderItems: NewOrderItemModel(db),
-		Payments:   NewPaymentModel(db),
-		Promos:     NewPromoModel(db),
-		Address:    NewAddressModel(db),
-		OptionType: NewOptionTypeModel(db),
+		Users:               NewUserModel(db),
+		Sessions:            NewSessionModel(db),
+		Category:            NewCategoryModel(db),
+		Products:            NewProductModel(db),
+		Reviews:             NewReviewModel(db),
+		Carts:               NewCartModel(db),
+		CartItems:           NewCartItemModel(db),
+		Wishlists:           NewWishlistModel(db),
+		AuditLogs:           NewAuditEventModel(db),
+		Orders:              NewOrderModel(db),
+		OrderItems:          NewOrderItemModel(db),
+		Payments:            NewPaymentModel(db),
+		Promos:              NewPromoModel(db),
+		Address:             NewAddressModel(db),
+		OptionType:          NewOptionTypeModel(db),
+		ProductImports:      NewProductImportModel(db),
+		CategoryAttributes:  NewCategoryAttributeModel(db),
+		Notifications:       NewNotificationModel(db),
+		WishlistCollections: NewWishlistCollectionModel(db),
+		Inventory:           NewInventoryModel(db),
+		Warehouses:          NewWarehouseModel(db),
+		WebAuthnCredentials: NewWebAuthnCredentialModel(db),
+		APIKeys:             NewAPIKeyModel(db),
+		OAuthClients:        NewOAuthClientModel(db),
+		OAuthGrants:         NewOAuthGrantModel(db),
 	}
 }
 
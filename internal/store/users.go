@@ -52,7 +52,9 @@ func (a AdminLevel) GetRank() int {
 type TwoFactorType string
 
 const (
-	TotpFactorType TwoFactorType = "totp"
+	TotpFactorType     TwoFactorType = "totp"
+	EmailFactorType    TwoFactorType = "email"
+	WebAuthnFactorType TwoFactorType = "webauthn"
 )
 
 var AnonymousUser = &User{}
@@ -69,6 +71,7 @@ type User struct {
 	AuthSecret           string     `json:"-"`
 	ForcePasswordChange  bool       `json:"force_password_change"`
 	TwoFactorAuthEnabled bool       `json:"-"`
+	Email2faEnabled      bool       `json:"-"`
 	Version              int        `json:"-"`
 	IsActive             bool       `json:"is_active"`
 	Disabled             bool       `json:"-"`
@@ -299,6 +302,7 @@ type UserStorage interface {
 	UpdatePassword(ctx context.Context, user *User, password string) error
 	EnableTwoFactorAuth(ctx context.Context, userID, authSecret string, recoveryCodes []string) error
 	DisableTwoFactorAuth(ctx context.Context, userID string) error
+	SetEmail2fa(ctx context.Context, userID string, enabled bool) error
 	GetVendorUserByID(ctx context.Context, userID string) (*VendorUser, error)
 	GetAdminUserByID(ctx context.Context, userID string) (*AdminUser, error)
 	GetAdminByID(ctx context.Context, userID string) (*AdminUser, error)
@@ -311,10 +315,14 @@ type UserStorage interface {
 
 	FlattenUser(ctx context.Context, user *User) (*FlattenedUser, error)
 	ResetRecoveryCodes(context.Context, string, []string) error
+	ConsumeRecoveryCode(ctx context.Context, userID, recoveryCode string) error
 	GetNormalUsers(ctx context.Context, filter PaginateQueryFilter) ([]*NormalUser, Metadata, error)
 	GetVendorUsers(ctx context.Context, filter PaginateQueryFilter) ([]*VendorUser, Metadata, error)
 	GetAdminUsers(ctx context.Context, filter PaginateQueryFilter) ([]*AdminUser, Metadata, error)
 	GetUserAccountByUserID(ctx context.Context, userID string) (*Account, error)
+	GetUserAccounts(ctx context.Context, userID string) ([]*Account, error)
+	GetAccountByProvider(ctx context.Context, provider, providerAccountID string) (*Account, error)
+	DeleteAccount(ctx context.Context, userID, provider string) error
 	ChangePassword(ctx context.Context, user *User) error
 	UpdateEmail(ctx context.Context, userID string, newEmail string) error
 }
@@ -385,6 +393,10 @@ func (s *UserModel) UpsertAccount(ctx context.Context, account *Account) error {
 	_, err := s.db.ExecContext(ctx, query, args...)
 
 	if err != nil {
+		var pgErr *pq.Error
+		if errors.As(err, &pgErr) && pgErr.Constraint == "accounts_user_id_provider_key" {
+			return ErrDuplicateAccount
+		}
 		return err
 	}
 	return nil
@@ -502,7 +514,7 @@ func (s *UserModel) CreateAdminUser(ctx context.Context, user *AdminUser) error
 func (s *UserModel) GetByID(ctx context.Context, userID string) (*User, error) {
 	query := `SELECT id, email, password_hash,force_password_change,
 			  avatar_url, role, email_verified_at, version,
-			  is_active, two_factor_auth_enabled, auth_secret,recovery_codes, created_at, updated_at FROM users
+			  is_active, two_factor_auth_enabled, email_2fa_enabled, auth_secret,recovery_codes, created_at, updated_at FROM users
 			  WHERE id = $1
 	`
 
@@ -524,6 +536,7 @@ func (s *UserModel) GetByID(ctx context.Context, userID string) (*User, error) {
 		&user.Version,
 		&isActive,
 		&user.TwoFactorAuthEnabled,
+		&user.Email2faEnabled,
 		&authSecret,
 		pq.Array(&user.RecoveryCodes),
 		&user.CreatedAt,
@@ -561,7 +574,7 @@ func (s *UserModel) GetByID(ctx context.Context, userID string) (*User, error) {
 func (s *UserModel) GetByEmail(ctx context.Context, email string) (*User, error) {
 	query := `SELECT id, email, password_hash,force_password_change,
 			  avatar_url, role, email_verified_at, version,
-			  is_active,  two_factor_auth_enabled, auth_secret, recovery_codes, created_at, updated_at FROM users
+			  is_active,  two_factor_auth_enabled, email_2fa_enabled, auth_secret, recovery_codes, created_at, updated_at FROM users
 			  WHERE email ilike $1
 	`
 
@@ -583,6 +596,7 @@ func (s *UserModel) GetByEmail(ctx context.Context, email string) (*User, error)
 		&user.Version,
 		&isActive,
 		&user.TwoFactorAuthEnabled,
+		&user.Email2faEnabled,
 		&authSecret,
 		pq.Array(&user.RecoveryCodes),
 		&user.CreatedAt,
@@ -654,14 +668,14 @@ func (s *UserModel) UpdatePassword(ctx context.Context, user *User, password str
 func (s *UserModel) EnableTwoFactorAuth(ctx context.Context, userID, authSecret string, recoveryCodes []string) error {
 	query := `
 		UPDATE users
-		SET auth_secret = $1, two_factor_auth_enabled = TRUE
-		WHERE id = $2
+		SET auth_secret = $1, recovery_codes = $2, two_factor_auth_enabled = TRUE
+		WHERE id = $3
 	`
 
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
 
-	_, err := s.db.ExecContext(ctx, query, authSecret, userID)
+	_, err := s.db.ExecContext(ctx, query, authSecret, pq.Array(recoveryCodes), userID)
 	if err != nil {
 		return fmt.Errorf("failed to enable 2FA: %w", err)
 	}
@@ -688,6 +702,21 @@ func (s *UserModel) DisableTwoFactorAuth(ctx context.Context, userID string) err
 	return nil
 }
 
+// SetEmail2fa turns emailed one-time codes on or off as a second factor.
+func (s *UserModel) SetEmail2fa(ctx context.Context, userID string, enabled bool) error {
+	query := `UPDATE users SET email_2fa_enabled = $1 WHERE id = $2`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, enabled, userID)
+	if err != nil {
+		return fmt.Errorf("failed to update email 2FA: %w", err)
+	}
+
+	return nil
+}
+
 func (s *UserModel) GetNormalUserByID(ctx context.Context, userID string) (*NormalUser, error) {
 	query := `
 		SELECT n.id, n.first_name, n.last_name, n.phone_number, n.user_id, n.created_at, n.updated_at,
@@ -1109,6 +1138,33 @@ func (u *UserModel) ResetRecoveryCodes(ctx context.Context, userID string, recov
 	return err
 }
 
+// ConsumeRecoveryCode removes one encrypted recovery code from the user's
+// list. It returns ErrRecordNotFound when the code is no longer there, such
+// as when a concurrent request used it first.
+func (u *UserModel) ConsumeRecoveryCode(ctx context.Context, userID, recoveryCode string) error {
+	query := `UPDATE users SET recovery_codes = array_remove(recovery_codes, $1)
+			  WHERE id = $2 AND $1 = ANY(recovery_codes)`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	result, err := u.db.ExecContext(ctx, query, recoveryCode, userID)
+	if err != nil {
+		return fmt.Errorf("failed to consume recovery code: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
 func (u *UserModel) GetNormalUsers(ctx context.Context, filter PaginateQueryFilter) ([]*NormalUser, Metadata, error) {
 	query := fmt.Sprintf(`
 		SELECT count(n.id) over(),  n.id, n.first_name, n.last_name, n.phone_number, n.user_id, n.created_at, n.updated_at,
@@ -1470,6 +1526,102 @@ func (m *UserModel) GetUserAccountByUserID(ctx context.Context, userID string) (
 	return account, nil
 }
 
+func (m *UserModel) GetUserAccounts(ctx context.Context, userID string) ([]*Account, error) {
+	query := `
+		SELECT user_id, type, provider, provider_account_id,access_token,
+			refresh_token,expires_at,token_type, scope
+		FROM accounts
+		WHERE user_id = $1
+		ORDER BY provider
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := m.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	accounts := []*Account{}
+
+	for rows.Next() {
+		account := &Account{}
+
+		err := rows.Scan(&account.UserID, &account.Type,
+			&account.Provider, &account.ProviderAccountID, &account.AccessToken,
+			&account.RefreshToken, &account.ExpiresAt, &account.TokenType, &account.Scope,
+		)
+
+		if err != nil {
+			return nil, err
+		}
+
+		accounts = append(accounts, account)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return accounts, nil
+}
+
+func (m *UserModel) GetAccountByProvider(ctx context.Context, provider, providerAccountID string) (*Account, error) {
+	query := `
+		SELECT user_id, type, provider, provider_account_id,access_token,
+			refresh_token,expires_at,token_type, scope
+		FROM accounts
+		WHERE provider = $1 AND provider_account_id = $2
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	account := &Account{}
+	err := m.db.QueryRowContext(ctx, query, provider, providerAccountID).Scan(&account.UserID, &account.Type,
+		&account.Provider, &account.ProviderAccountID, &account.AccessToken,
+		&account.RefreshToken, &account.ExpiresAt, &account.TokenType, &account.Scope,
+	)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+
+		default:
+			return nil, err
+		}
+	}
+
+	return account, nil
+}
+
+func (m *UserModel) DeleteAccount(ctx context.Context, userID, provider string) error {
+	query := `DELETE FROM accounts WHERE user_id = $1 AND provider = $2`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	result, err := m.db.ExecContext(ctx, query, userID, provider)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
 func (m *UserModel) ChangePassword(ctx context.Context, user *User) error {
 	query := `UPDATE users SET password_hash = $1 WHERE id = $2`
 
@@ -0,0 +1,223 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/devphaseX/buyr-api.git/internal/db"
+	"github.com/lib/pq"
+)
+
+var (
+	ErrWarehouseNotFound      = errors.New("warehouse not found")
+	ErrDuplicateWarehouseName = errors.New("a warehouse with this name already exists")
+	ErrWarehouseHasStock      = errors.New("warehouse still holds stock")
+)
+
+// Warehouse is a location a vendor ships from.
+type Warehouse struct {
+	ID            string    `json:"id"`
+	VendorID      string    `json:"vendor_id"`
+	Name          string    `json:"name"`
+	StreetAddress string    `json:"street_address"`
+	City          string    `json:"city"`
+	State         string    `json:"state"`
+	PostalCode    string    `json:"postal_code"`
+	Country       string    `json:"country"`
+	IsActive      bool      `json:"is_active"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+type WarehouseStock struct {
+	ProductID   string    `json:"product_id"`
+	ProductName string    `json:"product_name"`
+	Quantity    int       `json:"quantity"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+type WarehouseStore interface {
+	Create(ctx context.Context, warehouse *Warehouse) error
+	Update(ctx context.Context, warehouse *Warehouse) error
+	Delete(ctx context.Context, warehouseID, vendorID string) error
+	GetByID(ctx context.Context, warehouseID, vendorID string) (*Warehouse, error)
+	GetByVendorID(ctx context.Context, vendorID string) ([]*Warehouse, error)
+	GetStock(ctx context.Context, warehouseID, vendorID string) ([]*WarehouseStock, error)
+}
+
+type WarehouseModel struct {
+	db *sql.DB
+}
+
+func NewWarehouseModel(db *sql.DB) WarehouseStore {
+	return &WarehouseModel{db}
+}
+
+const warehouseColumns = `id, vendor_id, name, street_address, city, state, postal_code, country,
+	is_active, created_at, updated_at`
+
+func (w *Warehouse) dest() []any {
+	return []any{&w.ID, &w.VendorID, &w.Name, &w.StreetAddress, &w.City, &w.State, &w.PostalCode,
+		&w.Country, &w.IsActive, &w.CreatedAt, &w.UpdatedAt}
+}
+
+func mapWarehouseError(err error) error {
+	var pgErr *pq.Error
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return ErrWarehouseNotFound
+	case errors.As(err, &pgErr) && pgErr.Constraint == "warehouses_vendor_id_name_key":
+		return ErrDuplicateWarehouseName
+	default:
+		return err
+	}
+}
+
+func (m *WarehouseModel) Create(ctx context.Context, warehouse *Warehouse) error {
+	query := `INSERT INTO warehouses(id, vendor_id, name, street_address, city, state, postal_code, country, is_active)
+			  VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+			  RETURNING created_at, updated_at`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	warehouse.ID = db.GenerateULID()
+
+	args := []any{warehouse.ID, warehouse.VendorID, warehouse.Name, warehouse.StreetAddress, warehouse.City,
+		warehouse.State, warehouse.PostalCode, warehouse.Country, warehouse.IsActive}
+
+	err := m.db.QueryRowContext(ctx, query, args...).Scan(&warehouse.CreatedAt, &warehouse.UpdatedAt)
+	if err != nil {
+		return mapWarehouseError(err)
+	}
+
+	return nil
+}
+
+func (m *WarehouseModel) Update(ctx context.Context, warehouse *Warehouse) error {
+	query := `UPDATE warehouses
+			  SET name = $1, street_address = $2, city = $3, state = $4, postal_code = $5, country = $6, is_active = $7
+			  WHERE id = $8 AND vendor_id = $9
+			  RETURNING updated_at`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	args := []any{warehouse.Name, warehouse.StreetAddress, warehouse.City, warehouse.State,
+		warehouse.PostalCode, warehouse.Country, warehouse.IsActive, warehouse.ID, warehouse.VendorID}
+
+	err := m.db.QueryRowContext(ctx, query, args...).Scan(&warehouse.UpdatedAt)
+	if err != nil {
+		return mapWarehouseError(err)
+	}
+
+	return nil
+}
+
+// Delete removes an empty warehouse. Stock must be moved out first so the
+// product totals stay in line with the ledger.
+func (m *WarehouseModel) Delete(ctx context.Context, warehouseID, vendorID string) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	return withTrx(m.db, ctx, func(tx *sql.Tx) error {
+		var held int
+
+		err := tx.QueryRowContext(ctx, `
+			SELECT COALESCE(SUM(ws.quantity), 0)
+			FROM warehouses w
+			LEFT JOIN warehouse_stock ws ON ws.warehouse_id = w.id
+			WHERE w.id = $1 AND w.vendor_id = $2
+			GROUP BY w.id`, warehouseID, vendorID).Scan(&held)
+
+		if err != nil {
+			return mapWarehouseError(err)
+		}
+
+		if held > 0 {
+			return ErrWarehouseHasStock
+		}
+
+		_, err = tx.ExecContext(ctx, `DELETE FROM warehouses WHERE id = $1 AND vendor_id = $2`, warehouseID, vendorID)
+
+		return err
+	})
+}
+
+func (m *WarehouseModel) GetByID(ctx context.Context, warehouseID, vendorID string) (*Warehouse, error) {
+	query := `SELECT ` + warehouseColumns + ` FROM warehouses WHERE id = $1 AND vendor_id = $2`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	warehouse := &Warehouse{}
+
+	if err := m.db.QueryRowContext(ctx, query, warehouseID, vendorID).Scan(warehouse.dest()...); err != nil {
+		return nil, mapWarehouseError(err)
+	}
+
+	return warehouse, nil
+}
+
+func (m *WarehouseModel) GetByVendorID(ctx context.Context, vendorID string) ([]*Warehouse, error) {
+	query := `SELECT ` + warehouseColumns + ` FROM warehouses WHERE vendor_id = $1 ORDER BY created_at`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := m.db.QueryContext(ctx, query, vendorID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	warehouses := []*Warehouse{}
+
+	for rows.Next() {
+		warehouse := &Warehouse{}
+
+		if err := rows.Scan(warehouse.dest()...); err != nil {
+			return nil, err
+		}
+
+		warehouses = append(warehouses, warehouse)
+	}
+
+	return warehouses, rows.Err()
+}
+
+func (m *WarehouseModel) GetStock(ctx context.Context, warehouseID, vendorID string) ([]*WarehouseStock, error) {
+	query := `
+		SELECT ws.product_id, p.name, ws.quantity, ws.updated_at
+		FROM warehouse_stock ws
+		JOIN warehouses w ON w.id = ws.warehouse_id
+		JOIN products p ON p.id = ws.product_id
+		WHERE ws.warehouse_id = $1 AND w.vendor_id = $2
+		ORDER BY p.name
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := m.db.QueryContext(ctx, query, warehouseID, vendorID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	stock := []*WarehouseStock{}
+
+	for rows.Next() {
+		item := &WarehouseStock{}
+
+		if err := rows.Scan(&item.ProductID, &item.ProductName, &item.Quantity, &item.UpdatedAt); err != nil {
+			return nil, err
+		}
+
+		stock = append(stock, item)
+	}
+
+	return stock, rows.Err()
+}
@@ -0,0 +1,176 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/devphaseX/buyr-api.git/internal/db"
+	"github.com/lib/pq"
+)
+
+var (
+	ErrDuplicateWebAuthnCredential = errors.New("this passkey is already registered")
+)
+
+// WebAuthnCredential is a passkey or security key registered to a user. The
+// key material is only needed to verify assertions and is never returned.
+type WebAuthnCredential struct {
+	ID              string     `json:"id"`
+	UserID          string     `json:"user_id"`
+	CredentialID    []byte     `json:"-"`
+	PublicKey       []byte     `json:"-"`
+	AttestationType string     `json:"-"`
+	Transports      []string   `json:"transports"`
+	AAGUID          []byte     `json:"-"`
+	SignCount       int64      `json:"-"`
+	BackupEligible  bool       `json:"backup_eligible"`
+	BackupState     bool       `json:"backup_state"`
+	Name            string     `json:"name"`
+	LastUsedAt      *time.Time `json:"last_used_at"`
+	CreatedAt       time.Time  `json:"created_at"`
+}
+
+type WebAuthnCredentialStore interface {
+	Create(ctx context.Context, credential *WebAuthnCredential) error
+	GetByUserID(ctx context.Context, userID string) ([]*WebAuthnCredential, error)
+	RecordUse(ctx context.Context, credential *WebAuthnCredential) error
+	Delete(ctx context.Context, id, userID string) error
+	DeleteAllForUser(ctx context.Context, userID string) error
+}
+
+type WebAuthnCredentialModel struct {
+	db *sql.DB
+}
+
+func NewWebAuthnCredentialModel(db *sql.DB) WebAuthnCredentialStore {
+	return &WebAuthnCredentialModel{db}
+}
+
+func (m *WebAuthnCredentialModel) Create(ctx context.Context, credential *WebAuthnCredential) error {
+	query := `INSERT INTO webauthn_credentials(id, user_id, credential_id, public_key, attestation_type, transports,
+			  aaguid, sign_count, backup_eligible, backup_state, name)
+			  VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+			  RETURNING created_at`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	credential.ID = db.GenerateULID()
+
+	args := []any{credential.ID, credential.UserID, credential.CredentialID, credential.PublicKey,
+		credential.AttestationType, pq.Array(credential.Transports), credential.AAGUID, credential.SignCount,
+		credential.BackupEligible, credential.BackupState, credential.Name}
+
+	err := m.db.QueryRowContext(ctx, query, args...).Scan(&credential.CreatedAt)
+	if err != nil {
+		var pgErr *pq.Error
+		if errors.As(err, &pgErr) && pgErr.Constraint == "webauthn_credentials_credential_id_key" {
+			return ErrDuplicateWebAuthnCredential
+		}
+		return err
+	}
+
+	return nil
+}
+
+func (m *WebAuthnCredentialModel) GetByUserID(ctx context.Context, userID string) ([]*WebAuthnCredential, error) {
+	query := `SELECT id, user_id, credential_id, public_key, attestation_type, transports, aaguid, sign_count,
+			  backup_eligible, backup_state, name, last_used_at, created_at
+			  FROM webauthn_credentials
+			  WHERE user_id = $1
+			  ORDER BY created_at`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := m.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	credentials := []*WebAuthnCredential{}
+
+	for rows.Next() {
+		var credential WebAuthnCredential
+
+		err := rows.Scan(&credential.ID, &credential.UserID, &credential.CredentialID, &credential.PublicKey,
+			&credential.AttestationType, pq.Array(&credential.Transports), &credential.AAGUID, &credential.SignCount,
+			&credential.BackupEligible, &credential.BackupState, &credential.Name, &credential.LastUsedAt,
+			&credential.CreatedAt)
+
+		if err != nil {
+			return nil, err
+		}
+
+		credentials = append(credentials, &credential)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return credentials, nil
+}
+
+// RecordUse stores the signature counter and backup state reported by the
+// authenticator on a successful assertion.
+func (m *WebAuthnCredentialModel) RecordUse(ctx context.Context, credential *WebAuthnCredential) error {
+	query := `UPDATE webauthn_credentials
+			  SET sign_count = $1, backup_state = $2, last_used_at = NOW()
+			  WHERE id = $3
+			  RETURNING last_used_at`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	err := m.db.QueryRowContext(ctx, query, credential.SignCount, credential.BackupState, credential.ID).
+		Scan(&credential.LastUsedAt)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (m *WebAuthnCredentialModel) Delete(ctx context.Context, id, userID string) error {
+	query := `DELETE FROM webauthn_credentials WHERE id = $1 AND user_id = $2`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	result, err := m.db.ExecContext(ctx, query, id, userID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+func (m *WebAuthnCredentialModel) DeleteAllForUser(ctx context.Context, userID string) error {
+	query := `DELETE FROM webauthn_credentials WHERE user_id = $1`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := m.db.ExecContext(ctx, query, userID)
+	return err
+}
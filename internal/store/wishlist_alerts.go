@@ -0,0 +1,106 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/devphaseX/buyr-api.git/internal/db"
+)
+
+type WishlistAlertKind string
+
+var (
+	PriceDropWishlistAlertKind   WishlistAlertKind = "price_drop"
+	BackInStockWishlistAlertKind WishlistAlertKind = "back_in_stock"
+)
+
+// WishlistAlertCandidate is a wishlist entry whose owner should hear about a
+// change to the product.
+type WishlistAlertCandidate struct {
+	WishlistID   string
+	UserID       string
+	Email        string
+	Username     string
+	ProductID    string
+	ProductName  string
+	PriceAtAdd   float64
+	CurrentPrice float64
+}
+
+// GetAlertCandidates returns one wishlist entry per user for a product that
+// qualifies for an alert of the given kind, leaving out users who already
+// received dailyLimit alerts in the last 24 hours. A price drop is measured against
+// the last price the user was alerted at, or the price when they wishlisted.
+func (m *WishlistModel) GetAlertCandidates(ctx context.Context, productID string, kind WishlistAlertKind, dailyLimit int) ([]*WishlistAlertCandidate, error) {
+	condition := `p.stock_quantity > 0`
+	if kind == PriceDropWishlistAlertKind {
+		condition = effectivePriceSQL + ` < COALESCE(w.alerted_price, w.price_at_add)`
+	}
+
+	query := fmt.Sprintf(`
+		SELECT DISTINCT ON (w.user_id) w.id, w.user_id, u.email, COALESCE(nu.first_name, ''), p.id, p.name,
+			COALESCE(w.price_at_add, 0), %s
+		FROM wishlists w
+		JOIN products p ON p.id = w.product_id
+		JOIN users u ON u.id = w.user_id
+		LEFT JOIN normal_users nu ON nu.user_id = u.id
+		WHERE w.product_id = $1
+			AND p.published = true AND p.status = $2
+			AND %s
+			AND (
+				SELECT count(*) FROM wishlist_alerts wa
+				WHERE wa.user_id = w.user_id AND wa.created_at > NOW() - INTERVAL '1 day'
+			) < $3
+		ORDER BY w.user_id, w.created_at
+	`, effectivePriceSQL, condition)
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := m.db.QueryContext(ctx, query, productID, ApprovedProductStatus, dailyLimit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query wishlist alert candidates: %w", err)
+	}
+	defer rows.Close()
+
+	candidates := []*WishlistAlertCandidate{}
+
+	for rows.Next() {
+		candidate := &WishlistAlertCandidate{}
+
+		if err := rows.Scan(&candidate.WishlistID, &candidate.UserID, &candidate.Email, &candidate.Username,
+			&candidate.ProductID, &candidate.ProductName, &candidate.PriceAtAdd, &candidate.CurrentPrice); err != nil {
+			return nil, err
+		}
+
+		candidates = append(candidates, candidate)
+	}
+
+	return candidates, rows.Err()
+}
+
+// RecordAlert logs a sent alert towards the user's daily limit. Price-drop
+// alerts also move the wishlist baseline so the same price is not reported
+// twice.
+func (m *WishlistModel) RecordAlert(ctx context.Context, candidate *WishlistAlertCandidate, kind WishlistAlertKind) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	return withTrx(m.db, ctx, func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, `INSERT INTO wishlist_alerts(id, user_id, product_id, kind, price) VALUES ($1, $2, $3, $4, $5)`,
+			db.GenerateULID(), candidate.UserID, candidate.ProductID, kind, candidate.CurrentPrice)
+		if err != nil {
+			return err
+		}
+
+		if kind != PriceDropWishlistAlertKind {
+			return nil
+		}
+
+		_, err = tx.ExecContext(ctx, `UPDATE wishlists SET alerted_price = $1 WHERE user_id = $2 AND product_id = $3`,
+			candidate.CurrentPrice, candidate.UserID, candidate.ProductID)
+
+		return err
+	})
+}
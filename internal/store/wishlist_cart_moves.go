@@ -0,0 +1,198 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/devphaseX/buyr-api.git/internal/db"
+)
+
+type ItemMoveStatus string
+
+var (
+	MovedItemMoveStatus             ItemMoveStatus = "moved"
+	NotFoundItemMoveStatus          ItemMoveStatus = "not_found"
+	OutOfStockItemMoveStatus        ItemMoveStatus = "out_of_stock"
+	AlreadyCartedItemMoveStatus     ItemMoveStatus = "already_carted"
+	AlreadyWishlistedItemMoveStatus ItemMoveStatus = "already_wishlisted"
+)
+
+// ItemMoveResult reports what happened to one item of a bulk move between
+// the wishlist and the cart. Items that could not be moved stay where they
+// were.
+type ItemMoveResult struct {
+	ItemID    string         `json:"item_id"`
+	ProductID string         `json:"product_id,omitempty"`
+	Status    ItemMoveStatus `json:"status"`
+	NewItemID string         `json:"new_item_id,omitempty"`
+	Message   string         `json:"message,omitempty"`
+}
+
+func (r *ItemMoveResult) Moved() bool {
+	return r.Status == MovedItemMoveStatus
+}
+
+// MoveFromWishlist moves wishlist items of userID into the cart in a single
+// transaction. Each moved item is carted with a quantity of one; items whose
+// product is out of stock or already in the cart are left on the wishlist
+// and reported.
+func (m *CartItemModel) MoveFromWishlist(ctx context.Context, cartID, userID string, wishlistItemIDs []string) ([]*ItemMoveResult, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	results := make([]*ItemMoveResult, 0, len(wishlistItemIDs))
+
+	err := withTrx(m.DB, ctx, func(tx *sql.Tx) error {
+		for _, itemID := range wishlistItemIDs {
+			result := &ItemMoveResult{ItemID: itemID}
+			results = append(results, result)
+
+			var orderable bool
+
+			err := tx.QueryRowContext(ctx, `
+				SELECT w.product_id, p.stock_quantity > 0 OR p.allow_backorder OR COALESCE(p.preorder_release_at > NOW(), false)
+				FROM wishlists w
+				JOIN products p ON p.id = w.product_id
+				WHERE w.id = $1 AND w.user_id = $2
+				FOR UPDATE OF w`, itemID, userID).Scan(&result.ProductID, &orderable)
+
+			if err != nil {
+				if errors.Is(err, sql.ErrNoRows) {
+					result.Status = NotFoundItemMoveStatus
+					result.Message = "item not found"
+					continue
+				}
+				return err
+			}
+
+			if !orderable {
+				result.Status = OutOfStockItemMoveStatus
+				result.Message = "product not in stock"
+				continue
+			}
+
+			newItemID := db.GenerateULID()
+
+			insert, err := tx.ExecContext(ctx, `
+				INSERT INTO cart_items (id, cart_id, product_id, added_at, quantity)
+				VALUES ($1, $2, $3, NOW(), 1)
+				ON CONFLICT ON CONSTRAINT cart_items_cart_product_unique DO NOTHING`,
+				newItemID, cartID, result.ProductID)
+
+			if err != nil {
+				return err
+			}
+
+			if inserted, err := insert.RowsAffected(); err != nil {
+				return err
+			} else if inserted == 0 {
+				result.Status = AlreadyCartedItemMoveStatus
+				result.Message = ErrProductAlreadyCarted.Error()
+				continue
+			}
+
+			if _, err := tx.ExecContext(ctx, `DELETE FROM wishlists WHERE id = $1`, itemID); err != nil {
+				return err
+			}
+
+			result.Status = MovedItemMoveStatus
+			result.NewItemID = newItemID
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+// MoveToWishlist moves cart items into one of userID's wishlist collections
+// in a single transaction, falling back to the default collection when
+// collectionID is empty. Products already on that collection stay in the
+// cart and are reported.
+func (m *CartItemModel) MoveToWishlist(ctx context.Context, cartID, userID, collectionID string, cartItemIDs []string) ([]*ItemMoveResult, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	results := make([]*ItemMoveResult, 0, len(cartItemIDs))
+
+	err := withTrx(m.DB, ctx, func(tx *sql.Tx) error {
+		if collectionID == "" {
+			defaultID, err := ensureDefaultWishlistCollection(ctx, tx, userID)
+			if err != nil {
+				return err
+			}
+			collectionID = defaultID
+		} else {
+			var exists bool
+			err := tx.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM wishlist_collections WHERE id = $1 AND user_id = $2)`,
+				collectionID, userID).Scan(&exists)
+
+			if err != nil {
+				return err
+			}
+
+			if !exists {
+				return ErrWishlistCollectionNotFound
+			}
+		}
+
+		for _, itemID := range cartItemIDs {
+			result := &ItemMoveResult{ItemID: itemID}
+			results = append(results, result)
+
+			err := tx.QueryRowContext(ctx, `
+				SELECT product_id FROM cart_items
+				WHERE id = $1 AND cart_id = $2
+				FOR UPDATE`, itemID, cartID).Scan(&result.ProductID)
+
+			if err != nil {
+				if errors.Is(err, sql.ErrNoRows) {
+					result.Status = NotFoundItemMoveStatus
+					result.Message = "item not found"
+					continue
+				}
+				return err
+			}
+
+			newItemID := db.GenerateULID()
+
+			insert, err := tx.ExecContext(ctx, `
+				INSERT INTO wishlists (id, user_id, product_id, collection_id, price_at_add)
+				VALUES ($1, $2, $3, $4, (SELECT `+effectivePriceSQL+` FROM products p WHERE p.id = $3))
+				ON CONFLICT ON CONSTRAINT wishlists_collection_id_product_id_uniq DO NOTHING`,
+				newItemID, userID, result.ProductID, collectionID)
+
+			if err != nil {
+				return err
+			}
+
+			if inserted, err := insert.RowsAffected(); err != nil {
+				return err
+			} else if inserted == 0 {
+				result.Status = AlreadyWishlistedItemMoveStatus
+				result.Message = ErrProductWishlistedAlready.Error()
+				continue
+			}
+
+			if _, err := tx.ExecContext(ctx, `DELETE FROM cart_items WHERE id = $1`, itemID); err != nil {
+				return err
+			}
+
+			result.Status = MovedItemMoveStatus
+			result.NewItemID = newItemID
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
@@ -0,0 +1,480 @@
+package store
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base32"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/devphaseX/buyr-api.git/internal/db"
+	"github.com/lib/pq"
+)
+
+var (
+	ErrWishlistCollectionNotFound = errors.New("wishlist not found")
+	ErrDefaultWishlistCollection  = errors.New("the default wishlist cannot be deleted")
+	ErrWishlistNotRegistry        = errors.New("items can only be claimed on a gift registry")
+	ErrWishlistItemClaimed        = errors.New("this item has already been claimed")
+	ErrOwnWishlistClaim           = errors.New("you cannot claim items on your own wishlist")
+)
+
+type WishlistVisibility string
+
+var (
+	PrivateWishlistVisibility WishlistVisibility = "private"
+	// UnlistedWishlistVisibility is reachable by anyone with the share token
+	// but is not listed publicly.
+	UnlistedWishlistVisibility WishlistVisibility = "unlisted"
+	PublicWishlistVisibility   WishlistVisibility = "public"
+)
+
+const defaultWishlistCollectionName = "My Wishlist"
+
+type WishlistCollection struct {
+	ID         string             `json:"id"`
+	UserID     string             `json:"user_id"`
+	Name       string             `json:"name"`
+	Visibility WishlistVisibility `json:"visibility"`
+	IsDefault  bool               `json:"is_default"`
+	// Registry lets visitors claim items so they are not bought twice.
+	Registry   bool      `json:"registry"`
+	ShareToken string    `json:"share_token,omitempty"`
+	ItemsCount int       `json:"items_count"`
+	OwnerName  string    `json:"owner_name,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// SharedWishlistItem is a wishlist item as seen through a collection. Claim
+// details are only filled in for visitors of a registry, never the owner.
+type SharedWishlistItem struct {
+	ID          string    `json:"id"`
+	ProductID   string    `json:"product_id"`
+	PriceAtAdd  *float64  `json:"price_at_add,omitempty"`
+	Claimed     *bool     `json:"claimed,omitempty"`
+	ClaimedByMe *bool     `json:"claimed_by_me,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+	Product     struct {
+		ID             string  `json:"id"`
+		Name           string  `json:"name"`
+		AvatarURL      string  `json:"avatar_url"`
+		StockQuantity  int     `json:"stock_quantity"`
+		Price          float64 `json:"price"`
+		EffectivePrice float64 `json:"effective_price"`
+		Published      bool    `json:"published"`
+	} `json:"product"`
+}
+
+type WishlistClaim struct {
+	ID         string    `json:"id"`
+	WishlistID string    `json:"wishlist_id"`
+	ClaimedBy  string    `json:"claimed_by"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+type WishlistCollectionStore interface {
+	Create(ctx context.Context, collection *WishlistCollection) error
+	Update(ctx context.Context, collection *WishlistCollection) error
+	Delete(ctx context.Context, collectionID, userID string) error
+	GetByID(ctx context.Context, collectionID, userID string) (*WishlistCollection, error)
+	GetByUserID(ctx context.Context, userID string) ([]*WishlistCollection, error)
+	GetByShareToken(ctx context.Context, token string) (*WishlistCollection, error)
+	GetPublic(ctx context.Context, filter PaginateQueryFilter) ([]*WishlistCollection, Metadata, error)
+	RotateShareToken(ctx context.Context, collectionID, userID string) (*WishlistCollection, error)
+	GetItems(ctx context.Context, collection *WishlistCollection, viewerID string, filter PaginateQueryFilter) ([]*SharedWishlistItem, Metadata, error)
+	Claim(ctx context.Context, collection *WishlistCollection, itemID, userID string) (*WishlistClaim, error)
+	Unclaim(ctx context.Context, collection *WishlistCollection, itemID, userID string) error
+}
+
+type WishlistCollectionModel struct {
+	db *sql.DB
+}
+
+func NewWishlistCollectionModel(db *sql.DB) WishlistCollectionStore {
+	return &WishlistCollectionModel{db}
+}
+
+func newShareToken() (string, error) {
+	randomBytes := make([]byte, 20)
+
+	if _, err := rand.Read(randomBytes); err != nil {
+		return "", err
+	}
+
+	return base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(randomBytes), nil
+}
+
+// ensureDefaultWishlistCollection returns the id of the user's default
+// collection, creating it if needed.
+func ensureDefaultWishlistCollection(ctx context.Context, tx *sql.Tx, userID string) (string, error) {
+	shareToken, err := newShareToken()
+	if err != nil {
+		return "", err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO wishlist_collections(id, user_id, name, visibility, is_default, share_token)
+		VALUES ($1, $2, $3, $4, true, $5)
+		ON CONFLICT (user_id) WHERE is_default DO NOTHING`,
+		db.GenerateULID(), userID, defaultWishlistCollectionName, PrivateWishlistVisibility, shareToken)
+
+	if err != nil {
+		return "", err
+	}
+
+	var id string
+	err = tx.QueryRowContext(ctx, `SELECT id FROM wishlist_collections WHERE user_id = $1 AND is_default`, userID).Scan(&id)
+
+	return id, err
+}
+
+const wishlistCollectionColumns = `c.id, c.user_id, c.name, c.visibility, c.is_default, c.registry, c.share_token,
+	(SELECT count(*) FROM wishlists w WHERE w.collection_id = c.id), c.created_at, c.updated_at`
+
+func (c *WishlistCollection) dest() []any {
+	return []any{&c.ID, &c.UserID, &c.Name, &c.Visibility, &c.IsDefault, &c.Registry, &c.ShareToken,
+		&c.ItemsCount, &c.CreatedAt, &c.UpdatedAt}
+}
+
+func (m *WishlistCollectionModel) getOne(ctx context.Context, query string, args ...any) (*WishlistCollection, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	collection := &WishlistCollection{}
+
+	err := m.db.QueryRowContext(ctx, query, args...).Scan(collection.dest()...)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrWishlistCollectionNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return collection, nil
+}
+
+func (m *WishlistCollectionModel) Create(ctx context.Context, collection *WishlistCollection) error {
+	query := `INSERT INTO wishlist_collections(id, user_id, name, visibility, registry, share_token)
+			  VALUES ($1, $2, $3, $4, $5, $6)
+			  RETURNING is_default, created_at, updated_at`
+
+	shareToken, err := newShareToken()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	collection.ID = db.GenerateULID()
+	collection.ShareToken = shareToken
+
+	return m.db.QueryRowContext(ctx, query, collection.ID, collection.UserID, collection.Name,
+		collection.Visibility, collection.Registry, collection.ShareToken).
+		Scan(&collection.IsDefault, &collection.CreatedAt, &collection.UpdatedAt)
+}
+
+func (m *WishlistCollectionModel) Update(ctx context.Context, collection *WishlistCollection) error {
+	query := `
+		UPDATE wishlist_collections c
+		SET name = $1, visibility = $2, registry = $3
+		WHERE c.id = $4 AND c.user_id = $5
+		RETURNING ` + wishlistCollectionColumns
+
+	updated, err := m.getOne(ctx, query, collection.Name, collection.Visibility, collection.Registry,
+		collection.ID, collection.UserID)
+	if err != nil {
+		return err
+	}
+
+	*collection = *updated
+
+	return nil
+}
+
+func (m *WishlistCollectionModel) Delete(ctx context.Context, collectionID, userID string) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var isDefault bool
+
+	err := m.db.QueryRowContext(ctx, `
+		DELETE FROM wishlist_collections
+		WHERE id = $1 AND user_id = $2 AND NOT is_default
+		RETURNING is_default`, collectionID, userID).Scan(&isDefault)
+
+	if !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+
+	err = m.db.QueryRowContext(ctx, `SELECT is_default FROM wishlist_collections WHERE id = $1 AND user_id = $2`,
+		collectionID, userID).Scan(&isDefault)
+
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return ErrWishlistCollectionNotFound
+	case err != nil:
+		return err
+	default:
+		return ErrDefaultWishlistCollection
+	}
+}
+
+func (m *WishlistCollectionModel) GetByID(ctx context.Context, collectionID, userID string) (*WishlistCollection, error) {
+	query := `SELECT ` + wishlistCollectionColumns + ` FROM wishlist_collections c WHERE c.id = $1 AND c.user_id = $2`
+
+	return m.getOne(ctx, query, collectionID, userID)
+}
+
+// GetByShareToken resolves a shared link. Private collections are never
+// returned, even with a valid token.
+func (m *WishlistCollectionModel) GetByShareToken(ctx context.Context, token string) (*WishlistCollection, error) {
+	query := `SELECT ` + wishlistCollectionColumns + `
+			  FROM wishlist_collections c
+			  WHERE c.share_token = $1 AND c.visibility <> $2`
+
+	return m.getOne(ctx, query, token, PrivateWishlistVisibility)
+}
+
+func (m *WishlistCollectionModel) GetByUserID(ctx context.Context, userID string) ([]*WishlistCollection, error) {
+	query := `SELECT ` + wishlistCollectionColumns + `
+			  FROM wishlist_collections c
+			  WHERE c.user_id = $1
+			  ORDER BY c.is_default DESC, c.created_at`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := m.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	collections := []*WishlistCollection{}
+
+	for rows.Next() {
+		collection := &WishlistCollection{}
+
+		if err := rows.Scan(collection.dest()...); err != nil {
+			return nil, err
+		}
+
+		collections = append(collections, collection)
+	}
+
+	return collections, rows.Err()
+}
+
+func (m *WishlistCollectionModel) GetPublic(ctx context.Context, filter PaginateQueryFilter) ([]*WishlistCollection, Metadata, error) {
+	query := fmt.Sprintf(`
+		SELECT count(c.id) OVER(), `+wishlistCollectionColumns+`,
+			TRIM(COALESCE(nu.first_name, '') || ' ' || COALESCE(nu.last_name, ''))
+		FROM wishlist_collections c
+		LEFT JOIN normal_users nu ON nu.user_id = c.user_id
+		WHERE c.visibility = $3
+		ORDER BY c.%s %s
+		LIMIT $1 OFFSET $2
+	`, filter.SortColumn(), filter.SortDirection())
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := m.db.QueryContext(ctx, query, filter.Limit(), filter.Offset(), PublicWishlistVisibility)
+	if err != nil {
+		return nil, Metadata{}, fmt.Errorf("failed to query public wishlists: %w", err)
+	}
+	defer rows.Close()
+
+	var (
+		collections  = []*WishlistCollection{}
+		totalRecords int
+	)
+
+	for rows.Next() {
+		collection := &WishlistCollection{}
+
+		dest := append([]any{&totalRecords}, collection.dest()...)
+		dest = append(dest, &collection.OwnerName)
+
+		if err := rows.Scan(dest...); err != nil {
+			return nil, Metadata{}, err
+		}
+
+		collections = append(collections, collection)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := calculateMetadata(totalRecords, filter.Page, filter.PageSize)
+
+	return collections, metadata, nil
+}
+
+// RotateShareToken invalidates the current share link of a collection.
+func (m *WishlistCollectionModel) RotateShareToken(ctx context.Context, collectionID, userID string) (*WishlistCollection, error) {
+	shareToken, err := newShareToken()
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		UPDATE wishlist_collections c
+		SET share_token = $1
+		WHERE c.id = $2 AND c.user_id = $3
+		RETURNING ` + wishlistCollectionColumns
+
+	return m.getOne(ctx, query, shareToken, collectionID, userID)
+}
+
+// GetItems lists the items of a collection. For registries viewed by anyone
+// other than the owner each item carries whether it has been claimed; the
+// owner never sees claims so the surprise is kept.
+func (m *WishlistCollectionModel) GetItems(ctx context.Context, collection *WishlistCollection, viewerID string, filter PaginateQueryFilter) ([]*SharedWishlistItem, Metadata, error) {
+	query := fmt.Sprintf(`
+		SELECT count(w.id) OVER(), w.id, w.product_id, w.price_at_add, w.created_at,
+			json_build_object(
+				'id', p.id,
+				'name', p.name,
+				'avatar_url', pi.url,
+				'stock_quantity', p.stock_quantity,
+				'price', p.price,
+				'effective_price', `+effectivePriceSQL+`,
+				'published', p.published
+			),
+			wc.id IS NOT NULL, COALESCE(wc.claimed_by = $4, false)
+		FROM wishlists w
+		JOIN products p ON p.id = w.product_id
+		LEFT JOIN product_images pi ON pi.product_id = p.id AND pi.is_primary = true
+		LEFT JOIN wishlist_claims wc ON wc.wishlist_id = w.id
+		WHERE w.collection_id = $3
+		ORDER BY w.%s %s
+		LIMIT $1 OFFSET $2
+	`, filter.SortColumn(), filter.SortDirection())
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := m.db.QueryContext(ctx, query, filter.Limit(), filter.Offset(), collection.ID, viewerID)
+	if err != nil {
+		return nil, Metadata{}, fmt.Errorf("failed to query wishlist items: %w", err)
+	}
+	defer rows.Close()
+
+	var (
+		items        = []*SharedWishlistItem{}
+		totalRecords int
+		isOwner      = viewerID == collection.UserID
+	)
+
+	for rows.Next() {
+		var (
+			item        = &SharedWishlistItem{}
+			productJSON []byte
+			claimed     bool
+			claimedByMe bool
+		)
+
+		err := rows.Scan(&totalRecords, &item.ID, &item.ProductID, &item.PriceAtAdd, &item.CreatedAt,
+			&productJSON, &claimed, &claimedByMe)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		if err := json.Unmarshal(productJSON, &item.Product); err != nil {
+			return nil, Metadata{}, fmt.Errorf("failed to unmarshal product JSON: %w", err)
+		}
+
+		if !isOwner {
+			item.PriceAtAdd = nil
+
+			if collection.Registry {
+				item.Claimed = &claimed
+				item.ClaimedByMe = &claimedByMe
+			}
+		}
+
+		items = append(items, item)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := calculateMetadata(totalRecords, filter.Page, filter.PageSize)
+
+	return items, metadata, nil
+}
+
+func (m *WishlistCollectionModel) Claim(ctx context.Context, collection *WishlistCollection, itemID, userID string) (*WishlistClaim, error) {
+	switch {
+	case !collection.Registry:
+		return nil, ErrWishlistNotRegistry
+	case collection.UserID == userID:
+		return nil, ErrOwnWishlistClaim
+	}
+
+	query := `
+		INSERT INTO wishlist_claims(id, wishlist_id, claimed_by)
+		SELECT $1, w.id, $2 FROM wishlists w WHERE w.id = $3 AND w.collection_id = $4
+		RETURNING id, wishlist_id, claimed_by, created_at
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	claim := &WishlistClaim{}
+
+	err := m.db.QueryRowContext(ctx, query, db.GenerateULID(), userID, itemID, collection.ID).
+		Scan(&claim.ID, &claim.WishlistID, &claim.ClaimedBy, &claim.CreatedAt)
+
+	if err != nil {
+		var pgErr *pq.Error
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		case errors.As(err, &pgErr) && pgErr.Constraint == "wishlist_claims_wishlist_id_key":
+			return nil, ErrWishlistItemClaimed
+		default:
+			return nil, err
+		}
+	}
+
+	return claim, nil
+}
+
+// Unclaim releases a claim. Only the visitor who made it can release it.
+func (m *WishlistCollectionModel) Unclaim(ctx context.Context, collection *WishlistCollection, itemID, userID string) error {
+	query := `
+		DELETE FROM wishlist_claims wc
+		USING wishlists w
+		WHERE wc.wishlist_id = w.id AND w.id = $1 AND w.collection_id = $2 AND wc.claimed_by = $3
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	result, err := m.db.ExecContext(ctx, query, itemID, collection.ID, userID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
@@ -18,11 +18,16 @@ var (
 )
 
 type Wishlist struct {
-	ID        string    `json:"id"`
-	UserID    string    `json:"user_id"`
-	ProductID string    `json:"product_id"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID        string `json:"id"`
+	UserID    string `json:"user_id"`
+	ProductID string `json:"product_id"`
+	// CollectionID is the named wishlist the item belongs to.
+	CollectionID string `json:"collection_id"`
+	// PriceAtAdd is the effective price when the product was wishlisted,
+	// used as the baseline for price-drop alerts.
+	PriceAtAdd *float64  `json:"price_at_add"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
 }
 
 type WhitelistStore interface {
@@ -30,6 +35,8 @@ type WhitelistStore interface {
 	RemoveItem(ctx context.Context, itemID, userID string) error
 	GetWishlistItems(ctx context.Context, userID, vendorID string, filter PaginateQueryFilter) ([]*VendorGroupWishlistItem, Metadata, error)
 	GetGroupWishlistItems(ctx context.Context, userID string, itemLimit int, filter PaginateQueryFilter) ([]*VendorWithWishlistItems, Metadata, error)
+	GetAlertCandidates(ctx context.Context, productID string, kind WishlistAlertKind, dailyLimit int) ([]*WishlistAlertCandidate, error)
+	RecordAlert(ctx context.Context, candidate *WishlistAlertCandidate, kind WishlistAlertKind) error
 }
 
 type WishlistModel struct {
@@ -40,37 +47,63 @@ func NewWishlistModel(db *sql.DB) WhitelistStore {
 	return &WishlistModel{db}
 }
 
+// AddItem puts a product on one of the user's wishlist collections. Without a
+// CollectionID the item goes to the user's default collection, which is
+// created on first use.
 func (m *WishlistModel) AddItem(ctx context.Context, whitelist *Wishlist) error {
 	whitelist.ID = db.GenerateULID()
 
 	query := `
-			INSERT INTO wishlists (id, user_id, product_id) VALUES ($1, $2, $3)
-			RETURNING created_at, updated_at
+			INSERT INTO wishlists (id, user_id, product_id, collection_id, price_at_add)
+			VALUES ($1, $2, $3, $4, (SELECT ` + effectivePriceSQL + ` FROM products p WHERE p.id = $3))
+			RETURNING price_at_add, created_at, updated_at
 	`
 
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 
 	defer cancel()
 
-	args := []any{whitelist.ID, whitelist.UserID, whitelist.ProductID}
-
-	err := m.db.QueryRowContext(ctx, query, args...).Scan(&whitelist.CreatedAt, &whitelist.UpdatedAt)
+	return withTrx(m.db, ctx, func(tx *sql.Tx) error {
+		if whitelist.CollectionID == "" {
+			collectionID, err := ensureDefaultWishlistCollection(ctx, tx, whitelist.UserID)
+			if err != nil {
+				return err
+			}
+			whitelist.CollectionID = collectionID
+		} else {
+			var exists bool
+			err := tx.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM wishlist_collections WHERE id = $1 AND user_id = $2)`,
+				whitelist.CollectionID, whitelist.UserID).Scan(&exists)
+
+			if err != nil {
+				return err
+			}
 
-	if err != nil {
-		var pgErr *pq.Error
-		switch {
-		case errors.As(err, &pgErr):
-			if pgErr.Constraint == "wishlists_user_id_product_id_uniq" {
-				return ErrProductWishlistedAlready
+			if !exists {
+				return ErrWishlistCollectionNotFound
 			}
+		}
+
+		args := []any{whitelist.ID, whitelist.UserID, whitelist.ProductID, whitelist.CollectionID}
 
-			fallthrough
-		default:
-			return err
+		err := tx.QueryRowContext(ctx, query, args...).Scan(&whitelist.PriceAtAdd, &whitelist.CreatedAt, &whitelist.UpdatedAt)
+
+		if err != nil {
+			var pgErr *pq.Error
+			switch {
+			case errors.As(err, &pgErr):
+				if pgErr.Constraint == "wishlists_collection_id_product_id_uniq" {
+					return ErrProductWishlistedAlready
+				}
+
+				fallthrough
+			default:
+				return err
+			}
 		}
-	}
 
-	return nil
+		return nil
+	})
 }
 
 func (m *WishlistModel) RemoveItem(ctx context.Context, itemID, userID string) error {
@@ -116,6 +149,7 @@ type VendorGroupWishlistItem struct {
 		VendorID            string        `json:"vendor_id"`
 		Discount            float64       `json:"discount"`
 		Price               float64       `json:"price"`
+		EffectivePrice      float64       `json:"effective_price"`
 		CategoryID          string        `json:"category_id"`
 		CreatedAt           time.Time     `json:"created_at"`
 		UpdatedAt           time.Time     `json:"updated_at"`
@@ -147,6 +181,8 @@ func (m *WishlistModel) GetGroupWishlistItems(ctx context.Context, userID string
                 w.id AS wishlist_id,
                 w.user_id,
                 w.product_id,
+                w.collection_id,
+                w.price_at_add,
                 w.created_at AS wishlist_created_at,
                 w.updated_at AS wishlist_updated_at,
                 p.name AS product_name,
@@ -158,6 +194,7 @@ func (m *WishlistModel) GetGroupWishlistItems(ctx context.Context, userID string
                 p.total_items_sold_count,
                 p.discount,
                 p.price,
+                ` + effectivePriceSQL + ` AS effective_price,
                 p.category_id,
                 p.created_at AS product_created_at,
                 p.updated_at AS product_updated_at,
@@ -192,6 +229,8 @@ func (m *WishlistModel) GetGroupWishlistItems(ctx context.Context, userID string
                         'id', vi.wishlist_id,
                         'user_id', vi.user_id,
                         'product_id', vi.product_id,
+                        'collection_id', vi.collection_id,
+                        'price_at_add', vi.price_at_add,
                         'created_at', vi.wishlist_created_at,
                         'updated_at', vi.wishlist_updated_at,
                         'product', json_build_object(
@@ -206,6 +245,7 @@ func (m *WishlistModel) GetGroupWishlistItems(ctx context.Context, userID string
                             'total_items_sold_count', vi.total_items_sold_count,
                             'discount', vi.discount,
                             'price', vi.price,
+                            'effective_price', vi.effective_price,
                             'category_id', vi.category_id,
                             'created_at', vi.product_created_at,
                             'updated_at', vi.product_updated_at,
@@ -298,6 +338,8 @@ func (m *WishlistModel) GetWishlistItems(ctx context.Context, userID, vendorID s
                 w.id AS wishlist_id,
                 w.user_id,
                 w.product_id,
+                w.collection_id,
+                w.price_at_add,
                 w.created_at AS wishlist_created_at,
                 w.updated_at AS wishlist_updated_at,
                 p.name AS product_name,
@@ -309,6 +351,7 @@ func (m *WishlistModel) GetWishlistItems(ctx context.Context, userID, vendorID s
                 p.total_items_sold_count,
                 p.discount,
                 p.price,
+                ` + effectivePriceSQL + ` AS effective_price,
                 p.category_id,
                 p.created_at AS product_created_at,
                 p.updated_at AS product_updated_at,
@@ -325,6 +368,8 @@ func (m *WishlistModel) GetWishlistItems(ctx context.Context, userID, vendorID s
             vi.wishlist_id,
             vi.user_id,
             vi.product_id,
+            vi.collection_id,
+            vi.price_at_add,
             vi.wishlist_created_at,
             vi.wishlist_updated_at,
             json_build_object(
@@ -339,6 +384,7 @@ func (m *WishlistModel) GetWishlistItems(ctx context.Context, userID, vendorID s
                 'total_items_sold_count', vi.total_items_sold_count,
                 'discount', vi.discount,
                 'price', vi.price,
+                'effective_price', vi.effective_price,
                 'category_id', vi.category_id,
                 'created_at', vi.product_created_at,
                 'updated_at', vi.product_updated_at,
@@ -375,7 +421,7 @@ func (m *WishlistModel) GetWishlistItems(ctx context.Context, userID, vendorID s
 		)
 
 		err := rows.Scan(
-			&totalRecords, &item.ID, &item.UserID, &item.ProductID,
+			&totalRecords, &item.ID, &item.UserID, &item.ProductID, &item.CollectionID, &item.PriceAtAdd,
 			&item.CreatedAt, &item.UpdatedAt, &itemsJSON,
 		)
 		if err != nil {
@@ -0,0 +1,27 @@
+CREATE TABLE IF NOT EXISTS product_imports (
+    id VARCHAR(50) PRIMARY KEY,
+    vendor_id VARCHAR(50) NOT NULL,
+    format VARCHAR(20) NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    dry_run BOOLEAN NOT NULL DEFAULT FALSE,
+    payload BYTEA NOT NULL,
+    total_rows INT NOT NULL DEFAULT 0,
+    succeeded_rows INT NOT NULL DEFAULT 0,
+    failed_rows INT NOT NULL DEFAULT 0,
+    report JSONB NOT NULL DEFAULT '[]',
+    completed_at TIMESTAMP
+    WITH
+        TIME ZONE,
+        created_at TIMESTAMP
+    WITH
+        TIME ZONE DEFAULT NOW (),
+        updated_at TIMESTAMP
+    WITH
+        TIME ZONE DEFAULT NOW (),
+        CONSTRAINT product_imports_vendor_id_fk FOREIGN KEY (vendor_id) REFERENCES vendor_users (id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS product_imports_vendor_id_idx ON product_imports (vendor_id);
+
+CREATE TRIGGER update_product_imports_updated_at BEFORE
+UPDATE ON product_imports FOR EACH ROW EXECUTE FUNCTION update_updated_at_column ();
@@ -0,0 +1,14 @@
+DROP INDEX IF EXISTS category_path_idx;
+
+DROP INDEX IF EXISTS category_parent_id_idx;
+
+ALTER TABLE category
+DROP CONSTRAINT IF EXISTS category_parent_id_not_self,
+DROP CONSTRAINT IF EXISTS category_parent_id_fk,
+DROP CONSTRAINT IF EXISTS category_slug_key;
+
+ALTER TABLE category
+DROP COLUMN IF EXISTS depth,
+DROP COLUMN IF EXISTS path,
+DROP COLUMN IF EXISTS slug,
+DROP COLUMN IF EXISTS parent_id;
@@ -0,0 +1,31 @@
+ALTER TABLE category
+ADD COLUMN IF NOT EXISTS parent_id VARCHAR(50),
+ADD COLUMN IF NOT EXISTS slug VARCHAR(255),
+ADD COLUMN IF NOT EXISTS path TEXT,
+ADD COLUMN IF NOT EXISTS depth INT NOT NULL DEFAULT 0;
+
+-- existing categories become roots; the id suffix keeps backfilled slugs unique.
+UPDATE category
+SET
+    slug = trim(
+        both '-'
+        FROM
+            regexp_replace(lower(coalesce(name, '')), '[^a-z0-9]+', '-', 'g')
+    ) || '-' || lower(right(id, 6)),
+    path = '/' || id || '/'
+WHERE
+    slug IS NULL;
+
+ALTER TABLE category
+ALTER COLUMN slug SET NOT NULL,
+ALTER COLUMN path SET NOT NULL;
+
+ALTER TABLE category ADD CONSTRAINT category_slug_key UNIQUE (slug);
+
+ALTER TABLE category ADD CONSTRAINT category_parent_id_fk FOREIGN KEY (parent_id) REFERENCES category (id) ON DELETE RESTRICT;
+
+ALTER TABLE category ADD CONSTRAINT category_parent_id_not_self CHECK (parent_id IS NULL OR parent_id <> id);
+
+CREATE INDEX IF NOT EXISTS category_parent_id_idx ON category (parent_id);
+
+CREATE INDEX IF NOT EXISTS category_path_idx ON category (path text_pattern_ops);
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS product_attribute_values;
+
+DROP TABLE IF EXISTS category_attributes;
@@ -0,0 +1,40 @@
+CREATE TABLE IF NOT EXISTS category_attributes (
+    id VARCHAR(50) PRIMARY KEY,
+    category_id VARCHAR(50) NOT NULL,
+    name VARCHAR(100) NOT NULL,
+    type VARCHAR(20) NOT NULL,
+    unit VARCHAR(50),
+    allowed_values TEXT[] NOT NULL DEFAULT '{}',
+    required BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMP
+    WITH
+        TIME ZONE DEFAULT NOW (),
+        updated_at TIMESTAMP
+    WITH
+        TIME ZONE DEFAULT NOW (),
+        CONSTRAINT category_attributes_category_id_fk FOREIGN KEY (category_id) REFERENCES category (id) ON DELETE CASCADE,
+        CONSTRAINT category_attributes_type_check CHECK (type IN ('text', 'number', 'boolean', 'enum'))
+);
+
+-- names are matched case-insensitively, so "Color" and "color" are the same
+CREATE UNIQUE INDEX IF NOT EXISTS category_attributes_category_id_name_key ON category_attributes (category_id, lower(name));
+
+CREATE TABLE IF NOT EXISTS product_attribute_values (
+    product_id VARCHAR(50) NOT NULL,
+    attribute_id VARCHAR(50) NOT NULL,
+    value_text TEXT NOT NULL,
+    value_number NUMERIC,
+    created_at TIMESTAMP
+    WITH
+        TIME ZONE DEFAULT NOW (),
+        PRIMARY KEY (product_id, attribute_id),
+        CONSTRAINT product_attribute_values_product_id_fk FOREIGN KEY (product_id) REFERENCES products (id) ON DELETE CASCADE,
+        CONSTRAINT product_attribute_values_attribute_id_fk FOREIGN KEY (attribute_id) REFERENCES category_attributes (id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS product_attribute_values_attribute_text_idx ON product_attribute_values (attribute_id, value_text);
+
+CREATE INDEX IF NOT EXISTS product_attribute_values_attribute_number_idx ON product_attribute_values (attribute_id, value_number);
+
+CREATE TRIGGER update_category_attributes_updated_at BEFORE
+UPDATE ON category_attributes FOR EACH ROW EXECUTE FUNCTION update_updated_at_column ();
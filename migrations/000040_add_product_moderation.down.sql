@@ -0,0 +1,15 @@
+DROP TABLE IF EXISTS product_moderation_events;
+
+DROP INDEX IF EXISTS products_status_submitted_at_idx;
+
+ALTER TABLE products
+DROP CONSTRAINT IF EXISTS products_reviewed_by_fk;
+
+ALTER TABLE products
+DROP COLUMN IF EXISTS submitted_at,
+DROP COLUMN IF EXISTS reviewed_at,
+DROP COLUMN IF EXISTS reviewed_by,
+DROP COLUMN IF EXISTS rejection_reason;
+
+ALTER TABLE notifications
+ALTER COLUMN is_read DROP DEFAULT;
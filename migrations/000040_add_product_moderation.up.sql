@@ -0,0 +1,35 @@
+ALTER TABLE products
+ADD COLUMN IF NOT EXISTS rejection_reason TEXT,
+ADD COLUMN IF NOT EXISTS reviewed_by VARCHAR(50),
+ADD COLUMN IF NOT EXISTS reviewed_at TIMESTAMP
+WITH
+    TIME ZONE,
+ADD COLUMN IF NOT EXISTS submitted_at TIMESTAMP
+WITH
+    TIME ZONE NOT NULL DEFAULT NOW ();
+
+UPDATE products SET submitted_at = created_at;
+
+ALTER TABLE products ADD CONSTRAINT products_reviewed_by_fk FOREIGN KEY (reviewed_by) REFERENCES admin_users (id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS products_status_submitted_at_idx ON products (status, submitted_at);
+
+CREATE TABLE IF NOT EXISTS product_moderation_events (
+    id VARCHAR(50) PRIMARY KEY,
+    product_id VARCHAR(50) NOT NULL,
+    decision VARCHAR(20) NOT NULL,
+    reason TEXT,
+    reviewer_id VARCHAR(50),
+    created_at TIMESTAMP
+    WITH
+        TIME ZONE DEFAULT NOW (),
+        CONSTRAINT product_moderation_events_product_id_fk FOREIGN KEY (product_id) REFERENCES products (id) ON DELETE CASCADE,
+        CONSTRAINT product_moderation_events_reviewer_id_fk FOREIGN KEY (reviewer_id) REFERENCES admin_users (id) ON DELETE SET NULL
+);
+
+CREATE INDEX IF NOT EXISTS product_moderation_events_product_id_idx ON product_moderation_events (product_id, created_at);
+
+ALTER TABLE notifications
+ALTER COLUMN is_read SET DEFAULT false;
+
+UPDATE notifications SET is_read = false WHERE is_read IS NULL;
@@ -0,0 +1,17 @@
+DROP INDEX IF EXISTS products_sale_ends_at_idx;
+
+DROP INDEX IF EXISTS products_unpublish_at_idx;
+
+DROP INDEX IF EXISTS products_publish_at_idx;
+
+ALTER TABLE products
+DROP CONSTRAINT IF EXISTS products_sale_price_check,
+DROP CONSTRAINT IF EXISTS products_sale_window_check,
+DROP CONSTRAINT IF EXISTS products_publish_window_check;
+
+ALTER TABLE products
+DROP COLUMN IF EXISTS sale_ends_at,
+DROP COLUMN IF EXISTS sale_starts_at,
+DROP COLUMN IF EXISTS sale_price,
+DROP COLUMN IF EXISTS unpublish_at,
+DROP COLUMN IF EXISTS publish_at;
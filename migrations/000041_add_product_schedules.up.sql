@@ -0,0 +1,46 @@
+ALTER TABLE products
+ADD COLUMN IF NOT EXISTS publish_at TIMESTAMP
+WITH
+    TIME ZONE,
+ADD COLUMN IF NOT EXISTS unpublish_at TIMESTAMP
+WITH
+    TIME ZONE,
+ADD COLUMN IF NOT EXISTS sale_price DECIMAL(10, 2),
+ADD COLUMN IF NOT EXISTS sale_starts_at TIMESTAMP
+WITH
+    TIME ZONE,
+ADD COLUMN IF NOT EXISTS sale_ends_at TIMESTAMP
+WITH
+    TIME ZONE;
+
+ALTER TABLE products ADD CONSTRAINT products_publish_window_check CHECK (
+    publish_at IS NULL
+    OR unpublish_at IS NULL
+    OR unpublish_at > publish_at
+);
+
+ALTER TABLE products ADD CONSTRAINT products_sale_window_check CHECK (
+    sale_starts_at IS NULL
+    OR sale_ends_at IS NULL
+    OR sale_ends_at > sale_starts_at
+);
+
+ALTER TABLE products ADD CONSTRAINT products_sale_price_check CHECK (
+    sale_price IS NULL
+    OR (
+        sale_price >= 0
+        AND sale_price < price
+    )
+);
+
+CREATE INDEX IF NOT EXISTS products_publish_at_idx ON products (publish_at)
+WHERE
+    publish_at IS NOT NULL;
+
+CREATE INDEX IF NOT EXISTS products_unpublish_at_idx ON products (unpublish_at)
+WHERE
+    unpublish_at IS NOT NULL;
+
+CREATE INDEX IF NOT EXISTS products_sale_ends_at_idx ON products (sale_ends_at)
+WHERE
+    sale_ends_at IS NOT NULL;
@@ -0,0 +1,16 @@
+ALTER TABLE reviews
+DROP CONSTRAINT IF EXISTS reviews_user_id_product_id_key,
+DROP CONSTRAINT IF EXISTS reviews_order_item_id_fk;
+
+INSERT INTO reviews
+SELECT *
+FROM reviews_duplicates_archive ON CONFLICT (id) DO NOTHING;
+
+DROP TABLE IF EXISTS reviews_duplicates_archive;
+
+ALTER TABLE reviews
+DROP COLUMN IF EXISTS vendor_replied_at,
+DROP COLUMN IF EXISTS vendor_reply,
+DROP COLUMN IF EXISTS edited_at,
+DROP COLUMN IF EXISTS verified_purchase,
+DROP COLUMN IF EXISTS order_item_id;
@@ -0,0 +1,63 @@
+ALTER TABLE reviews
+ADD COLUMN IF NOT EXISTS order_item_id varchar(50),
+ADD COLUMN IF NOT EXISTS verified_purchase boolean NOT NULL DEFAULT false,
+ADD COLUMN IF NOT EXISTS edited_at timestamp
+WITH
+    time zone,
+ADD COLUMN IF NOT EXISTS vendor_reply text,
+ADD COLUMN IF NOT EXISTS vendor_replied_at timestamp
+WITH
+    time zone;
+
+ALTER TABLE reviews ADD CONSTRAINT reviews_order_item_id_fk FOREIGN KEY (order_item_id) REFERENCES order_items (id) ON DELETE SET NULL;
+
+-- keep only the newest review per user and product before enforcing
+-- uniqueness; the older ones are moved to an archive the down migration
+-- restores them from
+CREATE TABLE IF NOT EXISTS reviews_duplicates_archive (LIKE reviews INCLUDING DEFAULTS);
+
+WITH
+    ranked AS (
+        SELECT id, row_number() OVER (
+                PARTITION BY user_id, product_id
+                ORDER BY created_at DESC, id DESC
+            ) AS position
+        FROM reviews
+    ),
+    archived AS (
+        DELETE FROM reviews r USING ranked
+        WHERE
+            ranked.id = r.id
+            AND ranked.position > 1
+        RETURNING r.*
+    )
+INSERT INTO reviews_duplicates_archive
+SELECT *
+FROM archived;
+
+ALTER TABLE reviews ADD CONSTRAINT reviews_user_id_product_id_key UNIQUE (user_id, product_id);
+
+UPDATE reviews r
+SET
+    verified_purchase = true,
+    order_item_id = (
+        SELECT oi.id
+        FROM order_items oi
+        JOIN orders o ON o.id = oi.order_id
+        WHERE
+            o.user_id = r.user_id
+            AND oi.product_id = r.product_id
+            AND o.status = 'delivered'
+        ORDER BY o.created_at DESC
+        LIMIT 1
+    )
+WHERE
+    EXISTS (
+        SELECT 1
+        FROM order_items oi
+        JOIN orders o ON o.id = oi.order_id
+        WHERE
+            o.user_id = r.user_id
+            AND oi.product_id = r.product_id
+            AND o.status = 'delivered'
+    );
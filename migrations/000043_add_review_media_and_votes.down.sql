@@ -0,0 +1,11 @@
+DROP INDEX IF EXISTS reviews_product_id_helpful_count_idx;
+
+DROP TRIGGER IF EXISTS update_review_votes_updated_at ON review_votes;
+
+DROP TABLE IF EXISTS review_votes;
+
+DROP TABLE IF EXISTS review_media;
+
+ALTER TABLE reviews
+DROP COLUMN IF EXISTS not_helpful_count,
+DROP COLUMN IF EXISTS helpful_count;
@@ -0,0 +1,39 @@
+ALTER TABLE reviews
+ADD COLUMN IF NOT EXISTS helpful_count integer NOT NULL DEFAULT 0,
+ADD COLUMN IF NOT EXISTS not_helpful_count integer NOT NULL DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS review_media (
+    id varchar(50) NOT NULL PRIMARY KEY,
+    review_id varchar(50) NOT NULL,
+    url text NOT NULL,
+    created_at timestamp
+    with
+        time zone default now ()
+);
+
+ALTER TABLE review_media ADD CONSTRAINT review_media_review_id_fk FOREIGN KEY (review_id) REFERENCES reviews (id) ON DELETE CASCADE;
+
+CREATE INDEX IF NOT EXISTS review_media_review_id_idx ON review_media (review_id);
+
+CREATE TABLE IF NOT EXISTS review_votes (
+    review_id varchar(50) NOT NULL,
+    user_id varchar(50) NOT NULL,
+    helpful boolean NOT NULL,
+    created_at timestamp
+    with
+        time zone default now (),
+        updated_at timestamp
+    with
+        time zone default now (),
+        PRIMARY KEY (review_id, user_id)
+);
+
+ALTER TABLE review_votes ADD CONSTRAINT review_votes_review_id_fk FOREIGN KEY (review_id) REFERENCES reviews (id) ON DELETE CASCADE,
+ADD CONSTRAINT review_votes_user_id_fk FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE;
+
+CREATE TRIGGER update_review_votes_updated_at
+BEFORE UPDATE ON review_votes
+FOR EACH ROW
+EXECUTE FUNCTION update_updated_at_column();
+
+CREATE INDEX IF NOT EXISTS reviews_product_id_helpful_count_idx ON reviews (product_id, helpful_count DESC);
@@ -0,0 +1,13 @@
+DROP TABLE IF EXISTS review_reports;
+
+DROP INDEX IF EXISTS reviews_status_idx;
+
+ALTER TABLE reviews
+DROP CONSTRAINT IF EXISTS reviews_moderated_by_fk,
+DROP CONSTRAINT IF EXISTS reviews_status_check;
+
+ALTER TABLE reviews
+DROP COLUMN IF EXISTS moderated_at,
+DROP COLUMN IF EXISTS moderated_by,
+DROP COLUMN IF EXISTS moderation_reason,
+DROP COLUMN IF EXISTS status;
@@ -0,0 +1,41 @@
+ALTER TABLE reviews
+ADD COLUMN IF NOT EXISTS status varchar(20) NOT NULL DEFAULT 'published',
+ADD COLUMN IF NOT EXISTS moderation_reason text,
+ADD COLUMN IF NOT EXISTS moderated_by varchar(50),
+ADD COLUMN IF NOT EXISTS moderated_at timestamp
+WITH
+    time zone;
+
+ALTER TABLE reviews ADD CONSTRAINT reviews_status_check CHECK (status IN ('published', 'held', 'hidden'));
+
+ALTER TABLE reviews ADD CONSTRAINT reviews_moderated_by_fk FOREIGN KEY (moderated_by) REFERENCES admin_users (id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS reviews_status_idx ON reviews (status)
+WHERE
+    status <> 'published';
+
+CREATE TABLE IF NOT EXISTS review_reports (
+    id varchar(50) NOT NULL PRIMARY KEY,
+    review_id varchar(50) NOT NULL,
+    reporter_id varchar(50) NOT NULL,
+    reason varchar(30) NOT NULL,
+    details text,
+    status varchar(20) NOT NULL DEFAULT 'open',
+    resolved_by varchar(50),
+    resolved_at timestamp
+    with
+        time zone,
+        created_at timestamp
+    with
+        time zone default now ()
+);
+
+ALTER TABLE review_reports ADD CONSTRAINT review_reports_review_id_fk FOREIGN KEY (review_id) REFERENCES reviews (id) ON DELETE CASCADE,
+ADD CONSTRAINT review_reports_reporter_id_fk FOREIGN KEY (reporter_id) REFERENCES users (id) ON DELETE CASCADE,
+ADD CONSTRAINT review_reports_resolved_by_fk FOREIGN KEY (resolved_by) REFERENCES admin_users (id) ON DELETE SET NULL,
+ADD CONSTRAINT review_reports_review_id_reporter_id_key UNIQUE (review_id, reporter_id),
+ADD CONSTRAINT review_reports_status_check CHECK (status IN ('open', 'resolved', 'dismissed'));
+
+CREATE INDEX IF NOT EXISTS review_reports_open_idx ON review_reports (review_id)
+WHERE
+    status = 'open';
@@ -0,0 +1,48 @@
+ALTER TABLE products
+ADD COLUMN IF NOT EXISTS rating_avg decimal(3, 2) NOT NULL DEFAULT 0,
+ADD COLUMN IF NOT EXISTS rating_count integer NOT NULL DEFAULT 0,
+ADD COLUMN IF NOT EXISTS rating_1_count integer NOT NULL DEFAULT 0,
+ADD COLUMN IF NOT EXISTS rating_2_count integer NOT NULL DEFAULT 0,
+ADD COLUMN IF NOT EXISTS rating_3_count integer NOT NULL DEFAULT 0,
+ADD COLUMN IF NOT EXISTS rating_4_count integer NOT NULL DEFAULT 0,
+ADD COLUMN IF NOT EXISTS rating_5_count integer NOT NULL DEFAULT 0;
+
+UPDATE products p
+SET
+    rating_count = s.total,
+    rating_avg = s.average,
+    rating_1_count = s.one,
+    rating_2_count = s.two,
+    rating_3_count = s.three,
+    rating_4_count = s.four,
+    rating_5_count = s.five
+FROM (
+        SELECT
+            product_id, count(*) AS total, COALESCE(avg(rating), 0) AS average, count(*) FILTER (
+                WHERE
+                    rating = 1
+            ) AS one, count(*) FILTER (
+                WHERE
+                    rating = 2
+            ) AS two, count(*) FILTER (
+                WHERE
+                    rating = 3
+            ) AS three, count(*) FILTER (
+                WHERE
+                    rating = 4
+            ) AS four, count(*) FILTER (
+                WHERE
+                    rating = 5
+            ) AS five
+        FROM reviews
+        WHERE
+            status = 'published'
+        GROUP BY
+            product_id
+    ) s
+WHERE
+    p.id = s.product_id;
+
+CREATE INDEX IF NOT EXISTS products_rating_avg_idx ON products (rating_avg);
+
+CREATE INDEX IF NOT EXISTS products_rating_count_idx ON products (rating_count);
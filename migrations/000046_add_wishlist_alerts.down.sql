@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS wishlist_alerts;
+
+ALTER TABLE wishlists
+DROP COLUMN IF EXISTS alerted_price,
+DROP COLUMN IF EXISTS price_at_add;
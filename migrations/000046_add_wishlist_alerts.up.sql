@@ -0,0 +1,28 @@
+ALTER TABLE wishlists
+ADD COLUMN IF NOT EXISTS price_at_add decimal(10, 2),
+ADD COLUMN IF NOT EXISTS alerted_price decimal(10, 2);
+
+UPDATE wishlists w
+SET
+    price_at_add = p.price - p.discount
+FROM products p
+WHERE
+    p.id = w.product_id
+    AND w.price_at_add IS NULL;
+
+CREATE TABLE IF NOT EXISTS wishlist_alerts (
+    id varchar(50) NOT NULL PRIMARY KEY,
+    user_id varchar(50) NOT NULL,
+    product_id varchar(50) NOT NULL,
+    kind varchar(20) NOT NULL,
+    price decimal(10, 2) NOT NULL,
+    created_at timestamp
+    with
+        time zone default now ()
+);
+
+ALTER TABLE wishlist_alerts ADD CONSTRAINT wishlist_alerts_user_id_fk FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE,
+ADD CONSTRAINT wishlist_alerts_product_id_fk FOREIGN KEY (product_id) REFERENCES products (id) ON DELETE CASCADE,
+ADD CONSTRAINT wishlist_alerts_kind_check CHECK (kind IN ('price_drop', 'back_in_stock'));
+
+CREATE INDEX IF NOT EXISTS wishlist_alerts_user_id_created_at_idx ON wishlist_alerts (user_id, created_at);
@@ -0,0 +1,20 @@
+DROP TABLE IF EXISTS wishlist_claims;
+
+ALTER TABLE wishlists DROP CONSTRAINT IF EXISTS wishlists_collection_id_product_id_uniq;
+
+-- collapse collections back into a single list per user
+DELETE FROM wishlists w USING wishlists older
+WHERE
+    w.user_id = older.user_id
+    AND w.product_id = older.product_id
+    AND w.id > older.id;
+
+ALTER TABLE wishlists ADD CONSTRAINT wishlists_user_id_product_id_uniq UNIQUE (user_id, product_id);
+
+ALTER TABLE wishlists DROP CONSTRAINT IF EXISTS wishlists_collection_id_fk;
+
+ALTER TABLE wishlists DROP COLUMN IF EXISTS collection_id;
+
+DROP TRIGGER IF EXISTS update_wishlist_collections_updated_at ON wishlist_collections;
+
+DROP TABLE IF EXISTS wishlist_collections;
@@ -0,0 +1,75 @@
+CREATE TABLE IF NOT EXISTS wishlist_collections (
+    id varchar(50) NOT NULL PRIMARY KEY,
+    user_id varchar(50) NOT NULL,
+    name varchar(100) NOT NULL,
+    visibility varchar(20) NOT NULL DEFAULT 'private',
+    is_default boolean NOT NULL DEFAULT false,
+    registry boolean NOT NULL DEFAULT false,
+    share_token varchar(64) NOT NULL,
+    created_at timestamp
+    with
+        time zone default now (),
+        updated_at timestamp
+    with
+        time zone default now ()
+);
+
+ALTER TABLE wishlist_collections ADD CONSTRAINT wishlist_collections_user_id_fk FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE,
+ADD CONSTRAINT wishlist_collections_share_token_key UNIQUE (share_token),
+ADD CONSTRAINT wishlist_collections_visibility_check CHECK (visibility IN ('private', 'unlisted', 'public'));
+
+CREATE UNIQUE INDEX IF NOT EXISTS wishlist_collections_user_id_default_idx ON wishlist_collections (user_id)
+WHERE
+    is_default;
+
+CREATE INDEX IF NOT EXISTS wishlist_collections_public_idx ON wishlist_collections (created_at)
+WHERE
+    visibility = 'public';
+
+CREATE TRIGGER update_wishlist_collections_updated_at
+BEFORE UPDATE ON wishlist_collections
+FOR EACH ROW
+EXECUTE FUNCTION update_updated_at_column();
+
+-- every user with wishlist items gets a default collection holding them
+INSERT INTO
+    wishlist_collections (id, user_id, name, is_default, share_token)
+SELECT gen_random_uuid ()::text, user_id, 'My Wishlist', true, replace(gen_random_uuid ()::text, '-', '')
+FROM wishlists
+WHERE
+    user_id IS NOT NULL
+GROUP BY
+    user_id;
+
+ALTER TABLE wishlists ADD COLUMN IF NOT EXISTS collection_id varchar(50);
+
+UPDATE wishlists w
+SET
+    collection_id = c.id
+FROM wishlist_collections c
+WHERE
+    c.user_id = w.user_id
+    AND c.is_default;
+
+DELETE FROM wishlists WHERE collection_id IS NULL;
+
+ALTER TABLE wishlists ALTER COLUMN collection_id SET NOT NULL;
+
+ALTER TABLE wishlists ADD CONSTRAINT wishlists_collection_id_fk FOREIGN KEY (collection_id) REFERENCES wishlist_collections (id) ON DELETE CASCADE;
+
+ALTER TABLE wishlists DROP CONSTRAINT IF EXISTS wishlists_user_id_product_id_uniq;
+
+ALTER TABLE wishlists ADD CONSTRAINT wishlists_collection_id_product_id_uniq UNIQUE (collection_id, product_id);
+
+CREATE TABLE IF NOT EXISTS wishlist_claims (
+    id varchar(50) NOT NULL PRIMARY KEY,
+    wishlist_id varchar(50) NOT NULL,
+    claimed_by varchar(50) NOT NULL,
+    created_at timestamp
+    with
+        time zone default now ()
+);
+
+ALTER TABLE wishlist_claims ADD CONSTRAINT wishlist_claims_wishlist_id_fk FOREIGN KEY (wishlist_id) REFERENCES wishlists (id) ON DELETE CASCADE,
+ADD CONSTRAINT wishlist_claims_claimed_by_fk FOREIGN KEY (claimed_by) REFERENCES users (id) ON DELETE CASCADE,
+ADD CONSTRAINT wishlist_claims_wishlist_id_key UNIQUE (wishlist_id);
@@ -0,0 +1,7 @@
+DROP TABLE IF EXISTS inventory_movements;
+
+ALTER TABLE products DROP CONSTRAINT IF EXISTS products_low_stock_threshold_check;
+
+ALTER TABLE products
+DROP COLUMN IF EXISTS low_stock_alerted_at,
+DROP COLUMN IF EXISTS low_stock_threshold;
@@ -0,0 +1,49 @@
+ALTER TABLE products
+ADD COLUMN IF NOT EXISTS low_stock_threshold integer,
+ADD COLUMN IF NOT EXISTS low_stock_alerted_at timestamp
+with
+    time zone;
+
+ALTER TABLE products ADD CONSTRAINT products_low_stock_threshold_check CHECK (low_stock_threshold >= 0);
+
+CREATE TABLE IF NOT EXISTS inventory_movements (
+    id varchar(50) NOT NULL PRIMARY KEY,
+    product_id varchar(50) NOT NULL,
+    kind varchar(20) NOT NULL,
+    quantity integer NOT NULL,
+    stock_after integer NOT NULL,
+    actor_id varchar(50),
+    order_id varchar(50),
+    reason text,
+    created_at timestamp
+    with
+        time zone default now ()
+);
+
+ALTER TABLE inventory_movements ADD CONSTRAINT inventory_movements_product_id_fk FOREIGN KEY (product_id) REFERENCES products (id) ON DELETE CASCADE,
+ADD CONSTRAINT inventory_movements_actor_id_fk FOREIGN KEY (actor_id) REFERENCES users (id) ON DELETE SET NULL,
+ADD CONSTRAINT inventory_movements_order_id_fk FOREIGN KEY (order_id) REFERENCES orders (id) ON DELETE SET NULL,
+ADD CONSTRAINT inventory_movements_kind_check CHECK (
+    kind IN (
+        'restock',
+        'sale',
+        'return',
+        'adjustment',
+        'reservation'
+    )
+),
+ADD CONSTRAINT inventory_movements_quantity_check CHECK (quantity <> 0);
+
+CREATE INDEX IF NOT EXISTS inventory_movements_product_id_created_at_idx ON inventory_movements (product_id, created_at);
+
+CREATE INDEX IF NOT EXISTS inventory_movements_order_id_idx ON inventory_movements (order_id)
+WHERE
+    order_id IS NOT NULL;
+
+-- open the ledger with the stock each product already holds
+INSERT INTO
+    inventory_movements (id, product_id, kind, quantity, stock_after, reason)
+SELECT gen_random_uuid ()::text, id, 'adjustment', stock_quantity, stock_quantity, 'opening balance'
+FROM products
+WHERE
+    stock_quantity <> 0;
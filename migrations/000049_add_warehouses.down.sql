@@ -0,0 +1,13 @@
+ALTER TABLE order_items DROP CONSTRAINT IF EXISTS order_items_warehouse_id_fk;
+
+ALTER TABLE order_items DROP COLUMN IF EXISTS warehouse_id;
+
+ALTER TABLE inventory_movements DROP CONSTRAINT IF EXISTS inventory_movements_warehouse_id_fk;
+
+ALTER TABLE inventory_movements DROP COLUMN IF EXISTS warehouse_id;
+
+DROP TABLE IF EXISTS warehouse_stock;
+
+DROP TRIGGER IF EXISTS update_warehouses_updated_at ON warehouses;
+
+DROP TABLE IF EXISTS warehouses;
@@ -0,0 +1,51 @@
+CREATE TABLE IF NOT EXISTS warehouses (
+    id varchar(50) NOT NULL PRIMARY KEY,
+    vendor_id varchar(50) NOT NULL,
+    name varchar(100) NOT NULL,
+    street_address varchar(255) NOT NULL,
+    city varchar(100) NOT NULL,
+    state varchar(100) NOT NULL,
+    postal_code varchar(20) NOT NULL,
+    country varchar(100) NOT NULL,
+    is_active boolean NOT NULL DEFAULT true,
+    created_at timestamp
+    with
+        time zone default now (),
+        updated_at timestamp
+    with
+        time zone default now ()
+);
+
+ALTER TABLE warehouses ADD CONSTRAINT warehouses_vendor_id_fk FOREIGN KEY (vendor_id) REFERENCES vendor_users (id) ON DELETE CASCADE,
+ADD CONSTRAINT warehouses_vendor_id_name_key UNIQUE (vendor_id, name);
+
+CREATE TRIGGER update_warehouses_updated_at
+BEFORE UPDATE ON warehouses
+FOR EACH ROW
+EXECUTE FUNCTION update_updated_at_column();
+
+-- stock held at each location; products.stock_quantity stays the total, and
+-- whatever is not held by a location is the vendor's unassigned stock
+CREATE TABLE IF NOT EXISTS warehouse_stock (
+    warehouse_id varchar(50) NOT NULL,
+    product_id varchar(50) NOT NULL,
+    quantity integer NOT NULL DEFAULT 0,
+    updated_at timestamp
+    with
+        time zone default now (),
+        PRIMARY KEY (warehouse_id, product_id)
+);
+
+ALTER TABLE warehouse_stock ADD CONSTRAINT warehouse_stock_warehouse_id_fk FOREIGN KEY (warehouse_id) REFERENCES warehouses (id) ON DELETE CASCADE,
+ADD CONSTRAINT warehouse_stock_product_id_fk FOREIGN KEY (product_id) REFERENCES products (id) ON DELETE CASCADE,
+ADD CONSTRAINT warehouse_stock_quantity_check CHECK (quantity >= 0);
+
+CREATE INDEX IF NOT EXISTS warehouse_stock_product_id_idx ON warehouse_stock (product_id);
+
+ALTER TABLE inventory_movements ADD COLUMN IF NOT EXISTS warehouse_id varchar(50);
+
+ALTER TABLE inventory_movements ADD CONSTRAINT inventory_movements_warehouse_id_fk FOREIGN KEY (warehouse_id) REFERENCES warehouses (id) ON DELETE SET NULL;
+
+ALTER TABLE order_items ADD COLUMN IF NOT EXISTS warehouse_id varchar(50);
+
+ALTER TABLE order_items ADD CONSTRAINT order_items_warehouse_id_fk FOREIGN KEY (warehouse_id) REFERENCES warehouses (id) ON DELETE SET NULL;
@@ -0,0 +1,14 @@
+DROP INDEX IF EXISTS order_items_outstanding_backorders_idx;
+
+ALTER TABLE order_items
+DROP COLUMN IF EXISTS backorder_filled_at,
+DROP COLUMN IF EXISTS expected_ship_at,
+DROP COLUMN IF EXISTS preorder,
+DROP COLUMN IF EXISTS backordered;
+
+ALTER TABLE orders DROP COLUMN IF EXISTS backordered;
+
+ALTER TABLE products
+DROP COLUMN IF EXISTS preorder_release_at,
+DROP COLUMN IF EXISTS backorder_ships_at,
+DROP COLUMN IF EXISTS allow_backorder;
@@ -0,0 +1,27 @@
+ALTER TABLE products
+ADD COLUMN IF NOT EXISTS allow_backorder boolean NOT NULL DEFAULT false,
+ADD COLUMN IF NOT EXISTS backorder_ships_at timestamp
+with
+    time zone,
+ADD COLUMN IF NOT EXISTS preorder_release_at timestamp
+with
+    time zone;
+
+ALTER TABLE orders
+ADD COLUMN IF NOT EXISTS backordered boolean NOT NULL DEFAULT false;
+
+ALTER TABLE order_items
+ADD COLUMN IF NOT EXISTS backordered boolean NOT NULL DEFAULT false,
+ADD COLUMN IF NOT EXISTS preorder boolean NOT NULL DEFAULT false,
+ADD COLUMN IF NOT EXISTS expected_ship_at timestamp
+with
+    time zone,
+ADD COLUMN IF NOT EXISTS backorder_filled_at timestamp
+with
+    time zone;
+
+-- outstanding backorders are filled oldest first as stock comes in
+CREATE INDEX IF NOT EXISTS order_items_outstanding_backorders_idx ON order_items (product_id)
+WHERE
+    backordered
+    AND backorder_filled_at IS NULL;
@@ -0,0 +1,24 @@
+CREATE TABLE IF NOT EXISTS webauthn_credentials (
+    id varchar(50) NOT NULL PRIMARY KEY,
+    user_id varchar(50) NOT NULL,
+    credential_id bytea NOT NULL,
+    public_key bytea NOT NULL,
+    attestation_type varchar(50) NOT NULL DEFAULT '',
+    transports text[] NOT NULL DEFAULT '{}',
+    aaguid bytea,
+    sign_count bigint NOT NULL DEFAULT 0,
+    backup_eligible boolean NOT NULL DEFAULT false,
+    backup_state boolean NOT NULL DEFAULT false,
+    name varchar(100) NOT NULL,
+    last_used_at timestamp
+    with
+        time zone,
+        created_at timestamp
+    with
+        time zone default now ()
+);
+
+ALTER TABLE webauthn_credentials ADD CONSTRAINT webauthn_credentials_user_id_fk FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE,
+ADD CONSTRAINT webauthn_credentials_credential_id_key UNIQUE (credential_id);
+
+CREATE INDEX IF NOT EXISTS webauthn_credentials_user_id_idx ON webauthn_credentials (user_id);
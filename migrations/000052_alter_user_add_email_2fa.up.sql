@@ -0,0 +1 @@
+ALTER TABLE users ADD COLUMN IF NOT EXISTS email_2fa_enabled boolean NOT NULL DEFAULT false;
@@ -0,0 +1 @@
+ALTER TABLE accounts ADD CONSTRAINT accounts_user_id_provider_key UNIQUE (user_id, provider);
@@ -0,0 +1,23 @@
+CREATE TABLE IF NOT EXISTS api_keys (
+    id varchar(50) NOT NULL PRIMARY KEY,
+    user_id varchar(50) NOT NULL,
+    name varchar(100) NOT NULL,
+    prefix varchar(20) NOT NULL,
+    key_hash bytea NOT NULL,
+    scopes text[] NOT NULL DEFAULT '{}',
+    last_used_at timestamp
+    with
+        time zone,
+        last_used_ip varchar(100),
+        expires_at timestamp
+    with
+        time zone,
+        created_at timestamp
+    with
+        time zone default now ()
+);
+
+ALTER TABLE api_keys ADD CONSTRAINT api_keys_user_id_fk FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE,
+ADD CONSTRAINT api_keys_key_hash_key UNIQUE (key_hash);
+
+CREATE INDEX IF NOT EXISTS api_keys_user_id_idx ON api_keys (user_id);
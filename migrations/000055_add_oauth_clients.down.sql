@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS oauth_grants;
+
+DROP TABLE IF EXISTS oauth_clients;
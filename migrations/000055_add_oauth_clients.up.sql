@@ -0,0 +1,37 @@
+CREATE TABLE IF NOT EXISTS oauth_clients (
+    id varchar(50) NOT NULL PRIMARY KEY,
+    user_id varchar(50) NOT NULL,
+    name varchar(100) NOT NULL,
+    website_url text,
+    secret_hash bytea,
+    redirect_uris text[] NOT NULL DEFAULT '{}',
+    scopes text[] NOT NULL DEFAULT '{}',
+    created_at timestamp
+    with
+        time zone default now ()
+);
+
+ALTER TABLE oauth_clients ADD CONSTRAINT oauth_clients_user_id_fk FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE;
+
+CREATE INDEX IF NOT EXISTS oauth_clients_user_id_idx ON oauth_clients (user_id);
+
+CREATE TABLE IF NOT EXISTS oauth_grants (
+    id varchar(50) NOT NULL PRIMARY KEY,
+    client_id varchar(50) NOT NULL,
+    user_id varchar(50) NOT NULL,
+    scopes text[] NOT NULL DEFAULT '{}',
+    version int NOT NULL DEFAULT 1,
+    expires_at timestamp
+    with
+        time zone NOT NULL,
+        created_at timestamp
+    with
+        time zone default now ()
+);
+
+ALTER TABLE oauth_grants ADD CONSTRAINT oauth_grants_client_id_fk FOREIGN KEY (client_id) REFERENCES oauth_clients (id) ON DELETE CASCADE,
+ADD CONSTRAINT oauth_grants_user_id_fk FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE;
+
+CREATE INDEX IF NOT EXISTS oauth_grants_user_id_idx ON oauth_grants (user_id);
+
+CREATE INDEX IF NOT EXISTS oauth_grants_client_id_idx ON oauth_grants (client_id);
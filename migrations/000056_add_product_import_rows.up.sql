@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS product_import_rows (
+    import_id VARCHAR(50) NOT NULL,
+    line INT NOT NULL,
+    product_id VARCHAR(50) NOT NULL,
+    status VARCHAR(20) NOT NULL,
+    created_at TIMESTAMP
+    WITH
+        TIME ZONE DEFAULT NOW (),
+        PRIMARY KEY (import_id, line),
+        CONSTRAINT product_import_rows_import_id_fk FOREIGN KEY (import_id) REFERENCES product_imports (id) ON DELETE CASCADE,
+        CONSTRAINT product_import_rows_product_id_fk FOREIGN KEY (product_id) REFERENCES products (id) ON DELETE CASCADE
+);
@@ -0,0 +1,5 @@
+ALTER TABLE products
+ADD COLUMN IF NOT EXISTS sale_started BOOLEAN NOT NULL DEFAULT FALSE;
+
+UPDATE products SET sale_started = TRUE
+WHERE sale_price IS NOT NULL AND (sale_starts_at IS NULL OR sale_starts_at <= NOW());
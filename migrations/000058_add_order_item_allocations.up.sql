@@ -0,0 +1,16 @@
+-- the locations an order item ships from when its quantity is split across
+-- warehouses; a row without a warehouse is taken from unassigned stock
+CREATE TABLE IF NOT EXISTS order_item_allocations (
+    order_item_id varchar(50) NOT NULL,
+    warehouse_id varchar(50),
+    quantity integer NOT NULL,
+    created_at timestamp
+    with
+        time zone default now ()
+);
+
+ALTER TABLE order_item_allocations ADD CONSTRAINT order_item_allocations_order_item_id_fk FOREIGN KEY (order_item_id) REFERENCES order_items (id) ON DELETE CASCADE,
+ADD CONSTRAINT order_item_allocations_warehouse_id_fk FOREIGN KEY (warehouse_id) REFERENCES warehouses (id) ON DELETE SET NULL,
+ADD CONSTRAINT order_item_allocations_quantity_check CHECK (quantity > 0);
+
+CREATE INDEX IF NOT EXISTS order_item_allocations_order_item_id_idx ON order_item_allocations (order_item_id);
@@ -0,0 +1,2 @@
+ALTER TABLE audit_events
+ADD COLUMN performed_by_type TEXT NOT NULL DEFAULT 'admin';
@@ -0,0 +1,323 @@
+// mock_oidc runs a minimal OpenID Connect provider for exercising the generic
+// OIDC sign-in locally. Every authorization request is approved at once for
+// the user given on the command line. Point the API at it with:
+//
+//	OIDC_PROVIDERS=mock
+//	OIDC_MOCK_ISSUER=http://localhost:9999
+//	OIDC_MOCK_CLIENT_ID=buyr
+//	OIDC_MOCK_CLIENT_SECRET=secret
+package main
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"math/big"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+type config struct {
+	addr          string
+	issuer        string
+	clientID      string
+	clientSecret  string
+	subject       string
+	name          string
+	email         string
+	emailVerified bool
+	rotateEvery   time.Duration
+}
+
+// authorization is what a code was issued for
+type authorization struct {
+	redirectURI   string
+	nonce         string
+	codeChallenge string
+	expiresAt     time.Time
+}
+
+type signingKey struct {
+	id  string
+	key *rsa.PrivateKey
+}
+
+type server struct {
+	cfg config
+
+	mu            sync.Mutex
+	keys          []signingKey // newest first, the previous key stays published after a rotation
+	codes         map[string]authorization
+	accessTokens  map[string]time.Time
+	keysRotatedAt time.Time
+	keyCount      int
+}
+
+func main() {
+	var cfg config
+
+	flag.StringVar(&cfg.addr, "addr", ":9999", "address to listen on")
+	flag.StringVar(&cfg.issuer, "issuer", "http://localhost:9999", "issuer identifier, must match the address clients use")
+	flag.StringVar(&cfg.clientID, "client-id", "buyr", "client id the API is registered with")
+	flag.StringVar(&cfg.clientSecret, "client-secret", "secret", "client secret the API is registered with")
+	flag.StringVar(&cfg.subject, "sub", "mock-user-1", "subject of the signed in user")
+	flag.StringVar(&cfg.name, "name", "Mock User", "name of the signed in user")
+	flag.StringVar(&cfg.email, "email", "mock.user@example.com", "email of the signed in user")
+	flag.BoolVar(&cfg.emailVerified, "email-verified", true, "whether the email is reported as verified")
+	flag.DurationVar(&cfg.rotateEvery, "rotate-every", 0, "rotate the signing key at this interval, 0 disables rotation")
+	flag.Parse()
+
+	s := &server{
+		cfg:          cfg,
+		codes:        make(map[string]authorization),
+		accessTokens: make(map[string]time.Time),
+	}
+
+	if err := s.rotateKey(); err != nil {
+		log.Fatal(err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", s.discovery)
+	mux.HandleFunc("GET /jwks", s.jwks)
+	mux.HandleFunc("GET /authorize", s.authorize)
+	mux.HandleFunc("POST /token", s.token)
+	mux.HandleFunc("GET /userinfo", s.userinfo)
+
+	log.Printf("mock OIDC provider for %s listening on %s", cfg.issuer, cfg.addr)
+	log.Fatal(http.ListenAndServe(cfg.addr, mux))
+}
+
+func (s *server) rotateKey() error {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return err
+	}
+
+	s.keyCount++
+	s.keys = append([]signingKey{{id: fmt.Sprintf("mock-%d", s.keyCount), key: key}}, s.keys...)
+	if len(s.keys) > 2 {
+		s.keys = s.keys[:2]
+	}
+
+	s.keysRotatedAt = time.Now()
+	return nil
+}
+
+// currentKey returns the key new tokens are signed with, rotating it first
+// when due
+func (s *server) currentKey() (signingKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.cfg.rotateEvery > 0 && time.Since(s.keysRotatedAt) > s.cfg.rotateEvery {
+		if err := s.rotateKey(); err != nil {
+			return signingKey{}, err
+		}
+		log.Printf("rotated signing key to %s", s.keys[0].id)
+	}
+
+	return s.keys[0], nil
+}
+
+func (s *server) discovery(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]any{
+		"issuer":                                s.cfg.issuer,
+		"authorization_endpoint":                s.cfg.issuer + "/authorize",
+		"token_endpoint":                        s.cfg.issuer + "/token",
+		"userinfo_endpoint":                     s.cfg.issuer + "/userinfo",
+		"jwks_uri":                              s.cfg.issuer + "/jwks",
+		"response_types_supported":              []string{"code"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+		"code_challenge_methods_supported":      []string{"S256"},
+		"scopes_supported":                      []string{"openid", "email", "profile"},
+	})
+}
+
+func (s *server) jwks(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	keys := make([]map[string]string, 0, len(s.keys))
+	for _, k := range s.keys {
+		keys = append(keys, map[string]string{
+			"kty": "RSA",
+			"kid": k.id,
+			"use": "sig",
+			"alg": "RS256",
+			"n":   base64.RawURLEncoding.EncodeToString(k.key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(k.key.E)).Bytes()),
+		})
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{"keys": keys})
+}
+
+func (s *server) authorize(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	if query.Get("client_id") != s.cfg.clientID {
+		http.Error(w, "unknown client_id", http.StatusBadRequest)
+		return
+	}
+
+	if query.Get("response_type") != "code" || query.Get("code_challenge_method") != "S256" {
+		http.Error(w, "only the code flow with S256 PKCE is supported", http.StatusBadRequest)
+		return
+	}
+
+	redirectURI, err := url.Parse(query.Get("redirect_uri"))
+	if err != nil || redirectURI.Scheme == "" {
+		http.Error(w, "invalid redirect_uri", http.StatusBadRequest)
+		return
+	}
+
+	code := randomString()
+
+	s.mu.Lock()
+	s.codes[code] = authorization{
+		redirectURI:   redirectURI.String(),
+		nonce:         query.Get("nonce"),
+		codeChallenge: query.Get("code_challenge"),
+		expiresAt:     time.Now().Add(time.Minute),
+	}
+	s.mu.Unlock()
+
+	params := redirectURI.Query()
+	params.Set("code", code)
+	params.Set("state", query.Get("state"))
+	redirectURI.RawQuery = params.Encode()
+
+	http.Redirect(w, r, redirectURI.String(), http.StatusFound)
+}
+
+func (s *server) token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		tokenError(w, "invalid_request")
+		return
+	}
+
+	clientID, clientSecret, ok := r.BasicAuth()
+	if !ok {
+		clientID, clientSecret = r.PostForm.Get("client_id"), r.PostForm.Get("client_secret")
+	}
+
+	if clientID != s.cfg.clientID || clientSecret != s.cfg.clientSecret {
+		tokenError(w, "invalid_client")
+		return
+	}
+
+	if r.PostForm.Get("grant_type") != "authorization_code" {
+		tokenError(w, "unsupported_grant_type")
+		return
+	}
+
+	s.mu.Lock()
+	code, ok := s.codes[r.PostForm.Get("code")]
+	delete(s.codes, r.PostForm.Get("code"))
+	s.mu.Unlock()
+
+	if !ok || time.Now().After(code.expiresAt) || code.redirectURI != r.PostForm.Get("redirect_uri") {
+		tokenError(w, "invalid_grant")
+		return
+	}
+
+	challenge := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	if base64.RawURLEncoding.EncodeToString(challenge[:]) != code.codeChallenge {
+		tokenError(w, "invalid_grant")
+		return
+	}
+
+	key, err := s.currentKey()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	now := time.Now()
+	idToken := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"iss":            s.cfg.issuer,
+		"sub":            s.cfg.subject,
+		"aud":            s.cfg.clientID,
+		"iat":            now.Unix(),
+		"exp":            now.Add(time.Hour).Unix(),
+		"nonce":          code.nonce,
+		"name":           s.cfg.name,
+		"email":          s.cfg.email,
+		"email_verified": s.cfg.emailVerified,
+	})
+	idToken.Header["kid"] = key.id
+
+	signed, err := idToken.SignedString(key.key)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	accessToken := randomString()
+
+	s.mu.Lock()
+	s.accessTokens[accessToken] = now.Add(time.Hour)
+	s.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"access_token": accessToken,
+		"token_type":   "Bearer",
+		"expires_in":   3600,
+		"scope":        r.PostForm.Get("scope"),
+		"id_token":     signed,
+	})
+}
+
+func (s *server) userinfo(w http.ResponseWriter, r *http.Request) {
+	var accessToken string
+	if _, err := fmt.Sscanf(r.Header.Get("Authorization"), "Bearer %s", &accessToken); err != nil {
+		http.Error(w, "missing bearer token", http.StatusUnauthorized)
+		return
+	}
+
+	s.mu.Lock()
+	expiresAt, ok := s.accessTokens[accessToken]
+	s.mu.Unlock()
+
+	if !ok || time.Now().After(expiresAt) {
+		http.Error(w, "invalid bearer token", http.StatusUnauthorized)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"sub":            s.cfg.subject,
+		"name":           s.cfg.name,
+		"email":          s.cfg.email,
+		"email_verified": s.cfg.emailVerified,
+	})
+}
+
+func tokenError(w http.ResponseWriter, code string) {
+	writeJSON(w, http.StatusBadRequest, map[string]string{"error": code})
+}
+
+func writeJSON(w http.ResponseWriter, status int, data any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(data)
+}
+
+func randomString() string {
+	data := make([]byte, 24)
+	if _, err := rand.Read(data); err != nil {
+		panic(err)
+	}
+
+	return base64.RawURLEncoding.EncodeToString(data)
+}
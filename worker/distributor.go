@@ -14,6 +14,13 @@ type TaskDistributor interface {
 	DistributeTaskProcessOrderPayment(ctx context.Context, payload *ProcessPaymentPayload, opts ...asynq.Option) error
 	DistributeTaskOrderConfirmationEmail(ctx context.Context, payload *SendOrderConfirmationEmailPayload, opts ...asynq.Option) error
 	DistributeTaskSendVerifyEmail(ctx context.Context, payload *PayloadSendVerifyEmail, opts ...asynq.Option) error
+	DistributeTaskProcessProductImport(ctx context.Context, payload *PayloadProcessProductImport, opts ...asynq.Option) error
+	DistributeTaskSendWishlistAlerts(ctx context.Context, payload *PayloadSendWishlistAlerts, opts ...asynq.Option) error
+	DistributeTaskSendLowStockAlert(ctx context.Context, payload *PayloadSendLowStockAlert, opts ...asynq.Option) error
+	DistributeTaskSendSuspiciousActivityEmail(ctx context.Context, payload *PayloadSendSuspiciousActivityEmail, opts ...asynq.Option) error
+	DistributeTaskSendAccountLockedEmail(ctx context.Context, payload *PayloadSendAccountLockedEmail, opts ...asynq.Option) error
+	DistributeTaskSendTwoFactorCodeEmail(ctx context.Context, payload *PayloadSendTwoFactorCodeEmail, opts ...asynq.Option) error
+	DistributeTaskSendTwoFactorResetEmail(ctx context.Context, payload *PayloadSendTwoFactorResetEmail, opts ...asynq.Option) error
 }
 
 type RedisTaskDistributor struct {
@@ -28,6 +28,13 @@ type TaskProcessor interface {
 	ProcessTaskSendVendorActivationEmail(ctx context.Context, task *asynq.Task) error
 	ProcessTaskSendAdminOnboardEmail(ctx context.Context, task *asynq.Task) error
 	ProcessTaskSendVerifyEmail(ctx context.Context, task *asynq.Task) error
+	ProcessTaskProcessProductImport(ctx context.Context, task *asynq.Task) error
+	ProcessTaskSendWishlistAlerts(ctx context.Context, task *asynq.Task) error
+	ProcessTaskSendLowStockAlert(ctx context.Context, task *asynq.Task) error
+	ProcessTaskSendSuspiciousActivityEmail(ctx context.Context, task *asynq.Task) error
+	ProcessTaskSendAccountLockedEmail(ctx context.Context, task *asynq.Task) error
+	ProcessTaskSendTwoFactorCodeEmail(ctx context.Context, task *asynq.Task) error
+	ProcessTaskSendTwoFactorResetEmail(ctx context.Context, task *asynq.Task) error
 }
 
 type RedisTaskProcessor struct {
@@ -81,6 +88,13 @@ func (processor *RedisTaskProcessor) Start() error {
 	mux.HandleFunc(TaskProcessOrderPayment, processor.ProcessTaskConfirmOrderPayment)
 	mux.HandleFunc(TaskSendOrderConfirmationEmail, processor.ProcessSendOrderConfirmationEmailTask)
 	mux.HandleFunc(TaskSendVerifyEmail, processor.ProcessTaskSendVerifyEmail)
+	mux.HandleFunc(TaskProcessProductImport, processor.ProcessTaskProcessProductImport)
+	mux.HandleFunc(TaskSendWishlistAlerts, processor.ProcessTaskSendWishlistAlerts)
+	mux.HandleFunc(TaskSendLowStockAlert, processor.ProcessTaskSendLowStockAlert)
+	mux.HandleFunc(TaskSendSuspiciousActivityEmail, processor.ProcessTaskSendSuspiciousActivityEmail)
+	mux.HandleFunc(TaskSendAccountLockedEmail, processor.ProcessTaskSendAccountLockedEmail)
+	mux.HandleFunc(TaskSendTwoFactorCodeEmail, processor.ProcessTaskSendTwoFactorCodeEmail)
+	mux.HandleFunc(TaskSendTwoFactorResetEmail, processor.ProcessTaskSendTwoFactorResetEmail)
 
 	if processor.cronTaskRunner != nil {
 		processor.cronTaskRunner.MountTasks(mux)
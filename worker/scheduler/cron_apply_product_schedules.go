@@ -0,0 +1,39 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/devphaseX/buyr-api.git/internal/store"
+	"github.com/hibiken/asynq"
+)
+
+var (
+	CronApplyProductSchedules = "apply_product_schedules"
+)
+
+func (c *AsyncTaskScheduler) applyProductSchedules() {
+	_, err := c.scheduler.Register("@every 1m", asynq.NewTask(CronApplyProductSchedules, nil))
+	if err != nil {
+		log.Fatalf("failed to schedule ApplyProductSchedules task: %v", err)
+	}
+}
+
+func (p *AsyncTaskProcessor) HandleApplyProductSchedules(ctx context.Context, t *asynq.Task) error {
+	p.logger.Info("running apply product schedules")
+
+	result, err := p.store.Products.ApplySchedules(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to apply product schedules: %w", err)
+	}
+
+	if result.Published+result.Unpublished+result.SalesStarted+result.SalesEnded > 0 {
+		p.logger.Info(fmt.Sprintf("product schedules applied: published=%d unpublished=%d sales_started=%d sales_ended=%d",
+			result.Published, result.Unpublished, result.SalesStarted, result.SalesEnded))
+	}
+
+	p.queueWishlistAlerts(ctx, store.PriceDropWishlistAlertKind, result.StartedSaleProductIDs)
+
+	return nil
+}
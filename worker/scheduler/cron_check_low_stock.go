@@ -0,0 +1,44 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/devphaseX/buyr-api.git/worker"
+	"github.com/hibiken/asynq"
+)
+
+var (
+	CronCheckLowStock = "check_low_stock"
+)
+
+func (c *AsyncTaskScheduler) checkLowStock() {
+	_, err := c.scheduler.Register("@every 15m", asynq.NewTask(CronCheckLowStock, nil))
+	if err != nil {
+		log.Fatalf("failed to schedule CheckLowStock task: %v", err)
+	}
+}
+
+func (p *AsyncTaskProcessor) HandleCheckLowStock(ctx context.Context, t *asynq.Task) error {
+	p.logger.Info("running check low stock")
+
+	vendorIDs, err := p.store.Inventory.GetLowStockVendorIDs(ctx, p.lowStockThreshold)
+	if err != nil {
+		return fmt.Errorf("failed to fetch low stock vendors: %w", err)
+	}
+
+	for _, vendorID := range vendorIDs {
+		err := p.taskDistributor.DistributeTaskSendLowStockAlert(ctx, &worker.PayloadSendLowStockAlert{
+			VendorID:         vendorID,
+			DefaultThreshold: p.lowStockThreshold,
+			ClientURL:        p.clientURL,
+		}, asynq.MaxRetry(3))
+
+		if err != nil {
+			log.Printf("failed to enqueue low stock alert for vendor %s: %v", vendorID, err)
+		}
+	}
+
+	return nil
+}
@@ -2,10 +2,12 @@ package scheduler
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"time"
 
+	"github.com/devphaseX/buyr-api.git/internal/store"
 	"github.com/hibiken/asynq"
 )
 
@@ -35,22 +37,18 @@ func (p *AsyncTaskProcessor) HandleReclaimAbandonedPromos(ctx context.Context, t
 		return fmt.Errorf("failed to fetch abandoned orders: %w", err)
 	}
 
-	// Release promo codes for abandoned orders
+	// Expire abandoned orders, giving back their promo code use and the
+	// stock reserved at checkout
 	for _, order := range abandonedOrders {
-		if order.PromoCode != "" {
-			err := p.store.Promos.ReleaseUsage(ctx, order.PromoCode)
-			if err != nil {
-				// Log the error and continue processing other orders
-				log.Printf("failed to release promo code for order %s: %v", order.ID, err)
-				continue
+		restocked, err := p.store.Orders.Expire(ctx, order.ID)
+		if err != nil {
+			if !errors.Is(err, store.ErrRecordNotFound) {
+				log.Printf("failed to expire order %s: %v", order.ID, err)
 			}
+			continue
 		}
 
-		// Mark the order as expired
-		err := p.store.Orders.UpdateStatus(ctx, order.ID, "expired")
-		if err != nil {
-			log.Printf("failed to update status for order %s: %v", order.ID, err)
-		}
+		p.queueWishlistAlerts(ctx, store.BackInStockWishlistAlertKind, restocked)
 	}
 
 	return nil
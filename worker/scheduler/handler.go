@@ -1,29 +1,59 @@
 package scheduler
 
 import (
+	"context"
+	"log"
+
 	"github.com/devphaseX/buyr-api.git/internal/store"
 	"github.com/devphaseX/buyr-api.git/worker"
 	"github.com/hibiken/asynq"
 )
 
 type AsyncTaskProcessor struct {
-	store  *store.Storage
-	logger asynq.Logger
+	store             *store.Storage
+	taskDistributor   worker.TaskDistributor
+	clientURL         string
+	lowStockThreshold int
+	logger            asynq.Logger
 }
 
 func NewAsyncTaskProcessor(
 	redisOpt asynq.RedisClientOpt,
 	store *store.Storage,
+	taskDistributor worker.TaskDistributor,
+	clientURL string,
+	lowStockThreshold int,
 ) *AsyncTaskProcessor {
 
 	logger := worker.NewLogger()
 
 	return &AsyncTaskProcessor{
-		store:  store,
-		logger: logger,
+		store:             store,
+		taskDistributor:   taskDistributor,
+		clientURL:         clientURL,
+		lowStockThreshold: lowStockThreshold,
+		logger:            logger,
 	}
 }
 
 func (p *AsyncTaskProcessor) MountTasks(mux *asynq.ServeMux) {
 	mux.HandleFunc(CronReclaimAbandonedPromos, p.HandleReclaimAbandonedPromos)
+	mux.HandleFunc(CronApplyProductSchedules, p.HandleApplyProductSchedules)
+	mux.HandleFunc(CronCheckLowStock, p.HandleCheckLowStock)
+}
+
+// queueWishlistAlerts enqueues an alert of the given kind for each product.
+// Whether a user is actually alerted is decided when the task runs.
+func (p *AsyncTaskProcessor) queueWishlistAlerts(ctx context.Context, kind store.WishlistAlertKind, productIDs []string) {
+	for _, productID := range productIDs {
+		err := p.taskDistributor.DistributeTaskSendWishlistAlerts(ctx, &worker.PayloadSendWishlistAlerts{
+			ProductID: productID,
+			Kind:      kind,
+			ClientURL: p.clientURL,
+		})
+
+		if err != nil {
+			log.Printf("failed to enqueue %s wishlist alerts for product %s: %v", kind, productID, err)
+		}
+	}
 }
@@ -38,6 +38,8 @@ func (c *AsyncTaskScheduler) Run() {
 
 func (s *AsyncTaskScheduler) RegisterTasks() {
 	s.reclaimAbandonedPromos()
+	s.applyProductSchedules()
+	s.checkLowStock()
 }
 
 func (c *AsyncTaskScheduler) Close() {
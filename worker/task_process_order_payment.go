@@ -17,6 +17,7 @@ type ProcessPaymentPayload struct {
 	Amount        float64             `json:"amount"`
 	TransactionID string              `json:"transaction_id"`
 	Status        store.PaymentStatus `json:"status"`
+	ClientURL     string              `json:"client_url"`
 }
 
 func (rt *RedisTaskDistributor) DistributeTaskProcessOrderPayment(ctx context.Context, payload *ProcessPaymentPayload, opts ...asynq.Option) error {
@@ -73,6 +74,18 @@ func (rt *RedisTaskProcessor) ProcessTaskConfirmOrderPayment(ctx context.Context
 	rt.logger.Info("payment processed", "successfully", "order_id", payload.OrderID)
 
 	if payment.Status == store.FailedPaymentStatus {
+		for _, productID := range payment.RestockedProductIDs {
+			err := rt.taskDistributor.DistributeTaskSendWishlistAlerts(ctx, &PayloadSendWishlistAlerts{
+				ProductID: productID,
+				Kind:      store.BackInStockWishlistAlertKind,
+				ClientURL: payload.ClientURL,
+			})
+
+			if err != nil {
+				rt.logger.Error("failed to enqueue wishlist alerts", "product_id", productID, "error", err)
+			}
+		}
+
 		order, err := rt.store.Orders.GetOrderByID(ctx, payload.OrderID)
 
 		if err != nil && order.PromoCode != "" {
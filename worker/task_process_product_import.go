@@ -0,0 +1,252 @@
+package worker
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/devphaseX/buyr-api.git/internal/productio"
+	"github.com/devphaseX/buyr-api.git/internal/store"
+	"github.com/devphaseX/buyr-api.git/internal/validator"
+	"github.com/hibiken/asynq"
+)
+
+const TaskProcessProductImport = "task:process_product_import"
+
+type PayloadProcessProductImport struct {
+	ImportID string `json:"import_id"`
+}
+
+var importValidator = validator.New()
+
+func (rt *RedisTaskDistributor) DistributeTaskProcessProductImport(ctx context.Context, payload *PayloadProcessProductImport, opts ...asynq.Option) error {
+	jsonPayload, err := json.Marshal(payload)
+
+	if err != nil {
+		return fmt.Errorf("failed to marshal task payload: %w", err)
+	}
+
+	productImportTask := asynq.NewTask(TaskProcessProductImport, jsonPayload, opts...)
+
+	taskInfo, err := rt.client.EnqueueContext(ctx,
+		productImportTask,
+		asynq.Unique(time.Minute*10),
+		asynq.TaskID(payload.ImportID),
+	)
+
+	if err != nil {
+		return err
+	}
+
+	rt.logger.Info(
+		"message", "enqueued task",
+		"type", taskInfo.Type,
+		"queue", taskInfo.Queue,
+		"max_retry", taskInfo.MaxRetry,
+	)
+
+	return nil
+}
+
+func (processor *RedisTaskProcessor) ProcessTaskProcessProductImport(ctx context.Context, task *asynq.Task) error {
+	var payload PayloadProcessProductImport
+
+	if err := json.Unmarshal(task.Payload(), &payload); err != nil {
+		return fmt.Errorf("failed to unmarshal payload: %w", asynq.SkipRetry)
+	}
+
+	productImport, err := processor.store.ProductImports.GetByID(ctx, payload.ImportID)
+	if err != nil {
+		if errors.Is(err, store.ErrRecordNotFound) {
+			return fmt.Errorf("product import not found: %w", asynq.SkipRetry)
+		}
+		return fmt.Errorf("failed to fetch product import: %w", err)
+	}
+
+	if productImport.Status == store.CompletedProductImportStatus ||
+		productImport.Status == store.FailedProductImportStatus {
+		return nil
+	}
+
+	if err := processor.store.ProductImports.SetStatus(ctx, productImport.ID, store.ProcessingProductImportStatus); err != nil {
+		return fmt.Errorf("failed to update product import status: %w", err)
+	}
+
+	format, err := productio.ParseFormat(productImport.Format)
+	if err != nil {
+		return processor.failProductImport(ctx, productImport, err)
+	}
+
+	records, err := productio.Decode(format, bytes.NewReader(productImport.Payload))
+	if err != nil {
+		return processor.failProductImport(ctx, productImport, err)
+	}
+
+	// lines applied by an earlier attempt of this task are skipped, so a retry
+	// after a crash or timeout never creates the same product twice.
+	applied, err := processor.store.ProductImports.GetAppliedRows(ctx, productImport.ID)
+	if err != nil {
+		return fmt.Errorf("failed to fetch applied import rows: %w", err)
+	}
+
+	// category lookups are cached since catalogs usually share a handful of categories.
+	var (
+		categories = map[string]bool{}
+		schemas    = map[string]store.CategoryAttributes{}
+	)
+
+	productImport.Report = make([]*store.ProductImportRowReport, 0, len(records))
+	productImport.TotalRows = len(records)
+
+	for _, record := range records {
+		report := &store.ProductImportRowReport{Line: record.Line}
+		productImport.Report = append(productImport.Report, report)
+
+		if previous, ok := applied[record.Line]; ok {
+			report.Status = previous.Status
+			report.ProductID = previous.ProductID
+			if record.Row != nil {
+				report.Name = record.Row.Name
+			}
+			productImport.SucceededRows++
+			continue
+		}
+
+		if record.Err != nil {
+			report.Status = store.InvalidProductImportRowStatus
+			report.Errors = map[string]string{"row": record.Err.Error()}
+			productImport.FailedRows++
+			continue
+		}
+
+		report.Name = record.Row.Name
+
+		if verr := importValidator.Struct(record.Row); verr != nil {
+			report.Status = store.InvalidProductImportRowStatus
+			report.Errors = verr.FieldErrors()
+			productImport.FailedRows++
+			continue
+		}
+
+		visible, ok := categories[record.Row.CategoryID]
+		if !ok {
+			category, err := processor.store.Category.GetByID(ctx, record.Row.CategoryID)
+			if err != nil && !errors.Is(err, store.ErrRecordNotFound) {
+				return fmt.Errorf("failed to fetch category: %w", err)
+			}
+
+			visible = category != nil && category.Visible
+			categories[record.Row.CategoryID] = visible
+
+			if visible {
+				schema, err := processor.store.CategoryAttributes.GetEffectiveSchema(ctx, category.ID)
+				if err != nil {
+					return fmt.Errorf("failed to fetch category attributes: %w", err)
+				}
+				schemas[category.ID] = schema
+			}
+		}
+
+		if !visible {
+			report.Status = store.InvalidProductImportRowStatus
+			report.Errors = map[string]string{"category_id": "category not found"}
+			productImport.FailedRows++
+			continue
+		}
+
+		if record.Row.ID != "" {
+			existing, err := processor.store.Products.GetProductByID(ctx, record.Row.ID)
+			if err != nil && !errors.Is(err, store.ErrRecordNotFound) {
+				return fmt.Errorf("failed to fetch product: %w", err)
+			}
+
+			if existing == nil || existing.VendorID != productImport.VendorID {
+				report.Status = store.InvalidProductImportRowStatus
+				report.Errors = map[string]string{"id": "product not found"}
+				productImport.FailedRows++
+				continue
+			}
+		}
+
+		product := record.Row.Product(productImport.VendorID)
+
+		// an update replaces the product's features, so an empty list clears them
+		if product.ID != "" && product.Features == nil {
+			product.Features = []*store.ProductFeature{}
+		}
+
+		attributeErrors, attributes := schemas[record.Row.CategoryID].Validate(product.Features)
+		if len(attributeErrors) != 0 {
+			report.Status = store.InvalidProductImportRowStatus
+			report.Errors = map[string]string{}
+			for name, message := range attributeErrors {
+				report.Errors["features."+name] = message
+			}
+			productImport.FailedRows++
+			continue
+		}
+
+		product.Attributes = attributes
+
+		if productImport.DryRun {
+			report.Status = store.ValidProductImportRowStatus
+			productImport.SucceededRows++
+			continue
+		}
+
+		status, err := processor.store.Products.Import(ctx, productImport.ID, record.Line, product)
+		if err != nil {
+			report.Status = store.FailedProductImportRowStatus
+			report.Errors = map[string]string{"row": "failed to import product"}
+
+			switch {
+			case errors.Is(err, store.ErrProductCategoryNotFound):
+				report.Errors = map[string]string{"category_id": "category not found"}
+			case errors.Is(err, store.ErrRecordNotFound):
+				report.Errors = map[string]string{"id": "product not found"}
+			case errors.Is(err, store.ErrInsufficientStock):
+				report.Errors = map[string]string{"stock_quantity": "stock quantity cannot be lower than the stock held in warehouses"}
+			case errors.Is(err, store.ErrInvalidSalePrice):
+				report.Errors = map[string]string{"price": "price must be higher than the product's sale price"}
+			default:
+				processor.logger.Error("failed to import product", "import_id", productImport.ID,
+					"line", record.Line, "err", err)
+			}
+
+			productImport.FailedRows++
+			continue
+		}
+
+		report.Status = status
+		report.ProductID = product.ID
+		productImport.SucceededRows++
+	}
+
+	productImport.Status = store.CompletedProductImportStatus
+
+	if err := processor.store.ProductImports.Complete(ctx, productImport); err != nil {
+		return fmt.Errorf("failed to complete product import: %w", err)
+	}
+
+	processor.logger.Info("product import processed", "import_id", productImport.ID,
+		"total", productImport.TotalRows, "succeeded", productImport.SucceededRows,
+		"failed", productImport.FailedRows, "dry_run", productImport.DryRun)
+
+	return nil
+}
+
+func (processor *RedisTaskProcessor) failProductImport(ctx context.Context, productImport *store.ProductImport, cause error) error {
+	productImport.Status = store.FailedProductImportStatus
+	productImport.Report = []*store.ProductImportRowReport{
+		{Status: store.InvalidProductImportRowStatus, Errors: map[string]string{"file": cause.Error()}},
+	}
+
+	if err := processor.store.ProductImports.Complete(ctx, productImport); err != nil {
+		return fmt.Errorf("failed to complete product import: %w", err)
+	}
+
+	return nil
+}
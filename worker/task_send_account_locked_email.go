@@ -0,0 +1,71 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/devphaseX/buyr-api.git/internal/mailer"
+	"github.com/hibiken/asynq"
+)
+
+const TaskSendAccountLockedEmail = "task:send_account_locked_email"
+
+type PayloadSendAccountLockedEmail struct {
+	Username    string    `json:"username"`
+	Email       string    `json:"email"`
+	Token       string    `json:"token"`
+	LockedUntil time.Time `json:"locked_until"`
+	ClientURL   string    `json:"client_url"`
+}
+
+func (rt *RedisTaskDistributor) DistributeTaskSendAccountLockedEmail(ctx context.Context, payload *PayloadSendAccountLockedEmail, opts ...asynq.Option) error {
+	jsonPayload, err := json.Marshal(payload)
+
+	if err != nil {
+		return fmt.Errorf("failed to marshal task payload: %w", err)
+	}
+
+	accountLockedEmailTask := asynq.NewTask(TaskSendAccountLockedEmail, jsonPayload, opts...)
+
+	taskInfo, err := rt.client.EnqueueContext(ctx,
+		accountLockedEmailTask,
+		asynq.Unique(time.Second*5),
+		asynq.TaskID(payload.Token),
+	)
+
+	if err != nil {
+		return err
+	}
+
+	rt.logger.Info(
+		"message", "enqueued task",
+		"type", taskInfo.Type,
+		"queue", taskInfo.Queue,
+		"max_retry", taskInfo.MaxRetry,
+	)
+
+	return nil
+}
+
+func (processor *RedisTaskProcessor) ProcessTaskSendAccountLockedEmail(ctx context.Context, task *asynq.Task) error {
+	var payload PayloadSendAccountLockedEmail
+
+	if err := json.Unmarshal(task.Payload(), &payload); err != nil {
+		return fmt.Errorf("failed to unmarshal payload: %w", asynq.SkipRetry)
+	}
+
+	return processor.mailClient.Send(&mailer.MailOption{
+		To:           []string{payload.Email},
+		TemplateFile: mailer.AccountLockedTemplate,
+	}, struct {
+		Username    string
+		LockedUntil time.Time
+		UnlockURL   string
+	}{
+		Username:    payload.Username,
+		LockedUntil: payload.LockedUntil,
+		UnlockURL:   fmt.Sprintf("%s/unlock-account/%s", payload.ClientURL, payload.Token),
+	})
+}
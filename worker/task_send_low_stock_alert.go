@@ -0,0 +1,98 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/devphaseX/buyr-api.git/internal/mailer"
+	"github.com/devphaseX/buyr-api.git/internal/store"
+	"github.com/hibiken/asynq"
+)
+
+const TaskSendLowStockAlert = "task:send_low_stock_alert"
+
+type PayloadSendLowStockAlert struct {
+	VendorID         string `json:"vendor_id"`
+	DefaultThreshold int    `json:"default_threshold"`
+	ClientURL        string `json:"client_url"`
+}
+
+func (rt *RedisTaskDistributor) DistributeTaskSendLowStockAlert(ctx context.Context, payload *PayloadSendLowStockAlert, opts ...asynq.Option) error {
+	jsonPayload, err := json.Marshal(payload)
+
+	if err != nil {
+		return fmt.Errorf("failed to marshal task payload: %w", err)
+	}
+
+	lowStockAlertTask := asynq.NewTask(TaskSendLowStockAlert, jsonPayload, opts...)
+
+	taskInfo, err := rt.client.EnqueueContext(ctx,
+		lowStockAlertTask,
+		asynq.Unique(time.Minute*10),
+		asynq.TaskID(fmt.Sprintf("low_stock:%s", payload.VendorID)),
+	)
+
+	if err != nil {
+		return err
+	}
+
+	rt.logger.Info(
+		"message", "enqueued task",
+		"type", taskInfo.Type,
+		"queue", taskInfo.Queue,
+		"max_retry", taskInfo.MaxRetry,
+	)
+
+	return nil
+}
+
+// ProcessTaskSendLowStockAlert emails a vendor every product that crossed its
+// low-stock threshold since the last alert, then marks them as alerted.
+func (processor *RedisTaskProcessor) ProcessTaskSendLowStockAlert(ctx context.Context, task *asynq.Task) error {
+	var payload PayloadSendLowStockAlert
+
+	if err := json.Unmarshal(task.Payload(), &payload); err != nil {
+		return fmt.Errorf("failed to unmarshal payload: %w", asynq.SkipRetry)
+	}
+
+	alert, err := processor.store.Inventory.GetLowStockAlert(ctx, payload.VendorID, payload.DefaultThreshold)
+	if err != nil {
+		if errors.Is(err, store.ErrRecordNotFound) {
+			return fmt.Errorf("vendor not found: %w", asynq.SkipRetry)
+		}
+		return err
+	}
+
+	if len(alert.Products) == 0 {
+		return nil
+	}
+
+	err = processor.mailClient.Send(&mailer.MailOption{
+		To:           []string{alert.Email},
+		TemplateFile: mailer.LowStockAlertTemplate,
+	}, struct {
+		BusinessName string
+		Products     []*store.LowStockProduct
+		InventoryURL string
+		CurrentYear  int
+	}{
+		BusinessName: alert.BusinessName,
+		Products:     alert.Products,
+		InventoryURL: fmt.Sprintf("%s/vendor/products", payload.ClientURL),
+		CurrentYear:  time.Now().Year(),
+	})
+
+	if err != nil {
+		return fmt.Errorf("failed to send low stock alert: %w", err)
+	}
+
+	productIDs := make([]string, len(alert.Products))
+	for i, product := range alert.Products {
+		productIDs[i] = product.ID
+	}
+
+	return processor.store.Inventory.MarkLowStockAlerted(ctx, productIDs)
+}
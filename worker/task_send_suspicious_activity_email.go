@@ -0,0 +1,77 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/devphaseX/buyr-api.git/internal/mailer"
+	"github.com/hibiken/asynq"
+)
+
+const TaskSendSuspiciousActivityEmail = "task:send_suspicious_activity_email"
+
+type PayloadSendSuspiciousActivityEmail struct {
+	Username   string    `json:"username"`
+	Email      string    `json:"email"`
+	SessionID  string    `json:"session_id"`
+	IP         string    `json:"ip"`
+	UserAgent  string    `json:"user_agent"`
+	DetectedAt time.Time `json:"detected_at"`
+	ClientURL  string    `json:"client_url"`
+}
+
+func (rt *RedisTaskDistributor) DistributeTaskSendSuspiciousActivityEmail(ctx context.Context, payload *PayloadSendSuspiciousActivityEmail, opts ...asynq.Option) error {
+	jsonPayload, err := json.Marshal(payload)
+
+	if err != nil {
+		return fmt.Errorf("failed to marshal task payload: %w", err)
+	}
+
+	suspiciousActivityEmailTask := asynq.NewTask(TaskSendSuspiciousActivityEmail, jsonPayload, opts...)
+
+	taskInfo, err := rt.client.EnqueueContext(ctx,
+		suspiciousActivityEmailTask,
+		asynq.Unique(time.Minute),
+		asynq.TaskID(fmt.Sprintf("suspicious-activity-%s", payload.SessionID)),
+	)
+
+	if err != nil {
+		return err
+	}
+
+	rt.logger.Info(
+		"message", "enqueued task",
+		"type", taskInfo.Type,
+		"queue", taskInfo.Queue,
+		"max_retry", taskInfo.MaxRetry,
+	)
+
+	return nil
+}
+
+func (processor *RedisTaskProcessor) ProcessTaskSendSuspiciousActivityEmail(ctx context.Context, task *asynq.Task) error {
+	var payload PayloadSendSuspiciousActivityEmail
+
+	if err := json.Unmarshal(task.Payload(), &payload); err != nil {
+		return fmt.Errorf("failed to unmarshal payload: %w", asynq.SkipRetry)
+	}
+
+	return processor.mailClient.Send(&mailer.MailOption{
+		To:           []string{payload.Email},
+		TemplateFile: mailer.SuspiciousActivityTemplate,
+	}, struct {
+		Username    string
+		IP          string
+		UserAgent   string
+		DetectedAt  time.Time
+		SecurityURL string
+	}{
+		Username:    payload.Username,
+		IP:          payload.IP,
+		UserAgent:   payload.UserAgent,
+		DetectedAt:  payload.DetectedAt,
+		SecurityURL: fmt.Sprintf("%s/account/security", payload.ClientURL),
+	})
+}
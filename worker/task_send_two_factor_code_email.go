@@ -0,0 +1,71 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/devphaseX/buyr-api.git/internal/mailer"
+	"github.com/hibiken/asynq"
+)
+
+const TaskSendTwoFactorCodeEmail = "task:send_two_factor_code_email"
+
+type PayloadSendTwoFactorCodeEmail struct {
+	Username  string        `json:"username"`
+	Email     string        `json:"email"`
+	CodeID    string        `json:"code_id"`
+	Code      string        `json:"code"`
+	ExpiresIn time.Duration `json:"expires_in"`
+}
+
+func (rt *RedisTaskDistributor) DistributeTaskSendTwoFactorCodeEmail(ctx context.Context, payload *PayloadSendTwoFactorCodeEmail, opts ...asynq.Option) error {
+	jsonPayload, err := json.Marshal(payload)
+
+	if err != nil {
+		return fmt.Errorf("failed to marshal task payload: %w", err)
+	}
+
+	twoFactorCodeEmailTask := asynq.NewTask(TaskSendTwoFactorCodeEmail, jsonPayload, opts...)
+
+	taskInfo, err := rt.client.EnqueueContext(ctx,
+		twoFactorCodeEmailTask,
+		asynq.Unique(time.Second*5),
+		asynq.TaskID(payload.CodeID),
+	)
+
+	if err != nil {
+		return err
+	}
+
+	rt.logger.Info(
+		"message", "enqueued task",
+		"type", taskInfo.Type,
+		"queue", taskInfo.Queue,
+		"max_retry", taskInfo.MaxRetry,
+	)
+
+	return nil
+}
+
+func (processor *RedisTaskProcessor) ProcessTaskSendTwoFactorCodeEmail(ctx context.Context, task *asynq.Task) error {
+	var payload PayloadSendTwoFactorCodeEmail
+
+	if err := json.Unmarshal(task.Payload(), &payload); err != nil {
+		return fmt.Errorf("failed to unmarshal payload: %w", asynq.SkipRetry)
+	}
+
+	return processor.mailClient.Send(&mailer.MailOption{
+		To:           []string{payload.Email},
+		TemplateFile: mailer.TwoFactorCodeTemplate,
+	}, struct {
+		Username         string
+		Code             string
+		ExpiresInMinutes int
+	}{
+		Username:         payload.Username,
+		Code:             payload.Code,
+		ExpiresInMinutes: int(payload.ExpiresIn.Minutes()),
+	})
+}
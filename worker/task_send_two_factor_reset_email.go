@@ -0,0 +1,71 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/devphaseX/buyr-api.git/internal/mailer"
+	"github.com/hibiken/asynq"
+)
+
+const TaskSendTwoFactorResetEmail = "task:send_two_factor_reset_email"
+
+type PayloadSendTwoFactorResetEmail struct {
+	Username  string    `json:"username"`
+	Email     string    `json:"email"`
+	UserID    string    `json:"user_id"`
+	ResetAt   time.Time `json:"reset_at"`
+	ClientURL string    `json:"client_url"`
+}
+
+func (rt *RedisTaskDistributor) DistributeTaskSendTwoFactorResetEmail(ctx context.Context, payload *PayloadSendTwoFactorResetEmail, opts ...asynq.Option) error {
+	jsonPayload, err := json.Marshal(payload)
+
+	if err != nil {
+		return fmt.Errorf("failed to marshal task payload: %w", err)
+	}
+
+	twoFactorResetEmailTask := asynq.NewTask(TaskSendTwoFactorResetEmail, jsonPayload, opts...)
+
+	taskInfo, err := rt.client.EnqueueContext(ctx,
+		twoFactorResetEmailTask,
+		asynq.Unique(time.Second*5),
+		asynq.TaskID(fmt.Sprintf("two-factor-reset-%s-%d", payload.UserID, payload.ResetAt.Unix())),
+	)
+
+	if err != nil {
+		return err
+	}
+
+	rt.logger.Info(
+		"message", "enqueued task",
+		"type", taskInfo.Type,
+		"queue", taskInfo.Queue,
+		"max_retry", taskInfo.MaxRetry,
+	)
+
+	return nil
+}
+
+func (processor *RedisTaskProcessor) ProcessTaskSendTwoFactorResetEmail(ctx context.Context, task *asynq.Task) error {
+	var payload PayloadSendTwoFactorResetEmail
+
+	if err := json.Unmarshal(task.Payload(), &payload); err != nil {
+		return fmt.Errorf("failed to unmarshal payload: %w", asynq.SkipRetry)
+	}
+
+	return processor.mailClient.Send(&mailer.MailOption{
+		To:           []string{payload.Email},
+		TemplateFile: mailer.TwoFactorResetTemplate,
+	}, struct {
+		Username    string
+		ResetAt     time.Time
+		SecurityURL string
+	}{
+		Username:    payload.Username,
+		ResetAt:     payload.ResetAt,
+		SecurityURL: fmt.Sprintf("%s/account/security", payload.ClientURL),
+	})
+}
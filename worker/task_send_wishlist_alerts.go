@@ -0,0 +1,98 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/devphaseX/buyr-api.git/internal/mailer"
+	"github.com/devphaseX/buyr-api.git/internal/store"
+	"github.com/hibiken/asynq"
+)
+
+const TaskSendWishlistAlerts = "task:send_wishlist_alerts"
+
+// wishlistAlertDailyLimit caps how many wishlist alert emails a single user
+// receives per day across all products.
+const wishlistAlertDailyLimit = 3
+
+type PayloadSendWishlistAlerts struct {
+	ProductID string                  `json:"product_id"`
+	Kind      store.WishlistAlertKind `json:"kind"`
+	ClientURL string                  `json:"client_url"`
+}
+
+func (rt *RedisTaskDistributor) DistributeTaskSendWishlistAlerts(ctx context.Context, payload *PayloadSendWishlistAlerts, opts ...asynq.Option) error {
+	jsonPayload, err := json.Marshal(payload)
+
+	if err != nil {
+		return fmt.Errorf("failed to marshal task payload: %w", err)
+	}
+
+	wishlistAlertsTask := asynq.NewTask(TaskSendWishlistAlerts, jsonPayload, opts...)
+
+	// every change gets its own task; repeats are filtered per user when the
+	// task runs, by the price baseline and the daily limit
+	taskInfo, err := rt.client.EnqueueContext(ctx, wishlistAlertsTask)
+
+	if err != nil {
+		return err
+	}
+
+	rt.logger.Info(
+		"message", "enqueued task",
+		"type", taskInfo.Type,
+		"queue", taskInfo.Queue,
+		"max_retry", taskInfo.MaxRetry,
+	)
+
+	return nil
+}
+
+func (processor *RedisTaskProcessor) ProcessTaskSendWishlistAlerts(ctx context.Context, task *asynq.Task) error {
+	var payload PayloadSendWishlistAlerts
+
+	if err := json.Unmarshal(task.Payload(), &payload); err != nil {
+		return fmt.Errorf("failed to unmarshal payload: %w", asynq.SkipRetry)
+	}
+
+	candidates, err := processor.store.Wishlists.GetAlertCandidates(ctx, payload.ProductID, payload.Kind, wishlistAlertDailyLimit)
+	if err != nil {
+		return err
+	}
+
+	for _, candidate := range candidates {
+		err := processor.mailClient.Send(&mailer.MailOption{
+			To:           []string{candidate.Email},
+			TemplateFile: mailer.WishlistAlertTemplate,
+		}, struct {
+			Username     string
+			ProductName  string
+			BackInStock  bool
+			PriceAtAdd   string
+			CurrentPrice string
+			ProductURL   string
+			CurrentYear  int
+		}{
+			Username:     candidate.Username,
+			ProductName:  candidate.ProductName,
+			BackInStock:  payload.Kind == store.BackInStockWishlistAlertKind,
+			PriceAtAdd:   fmt.Sprintf("%.2f", candidate.PriceAtAdd),
+			CurrentPrice: fmt.Sprintf("%.2f", candidate.CurrentPrice),
+			ProductURL:   fmt.Sprintf("%s/products/%s", payload.ClientURL, candidate.ProductID),
+			CurrentYear:  time.Now().Year(),
+		})
+
+		if err != nil {
+			processor.logger.Error("failed to send wishlist alert", "user_id", candidate.UserID, "error", err)
+			continue
+		}
+
+		if err := processor.store.Wishlists.RecordAlert(ctx, candidate, payload.Kind); err != nil {
+			processor.logger.Error("failed to record wishlist alert", "user_id", candidate.UserID, "error", err)
+		}
+	}
+
+	return nil
+}